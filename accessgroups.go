@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// AccessGroup группа доступа PERCo: набор дверей, которые может открыть карта
+type AccessGroup struct {
+	ID    int64   `json:"id"`
+	Name  string  `json:"name"`
+	Doors []int64 `json:"doors,omitempty"`
+}
+
+// AccessData группы доступа с дверями и назначения групп сотрудникам
+type AccessData struct {
+	Groups      []AccessGroup
+	StaffGroups map[int64][]int64
+}
+
+// AccessGroupSource источник, который умеет отдавать группы доступа
+type AccessGroupSource interface {
+	FetchAccessGroups(ctx context.Context) (*AccessData, error)
+}
+
+// initAccessGroupTables создает таблицы групп доступа, их дверей и назначений
+func initAccessGroupTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS access_groups (
+			id BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS access_group_doors (
+			group_id BIGINT NOT NULL,
+			door_id BIGINT NOT NULL,
+			PRIMARY KEY (group_id, door_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS staff_access_groups (
+			id_staff BIGINT NOT NULL,
+			group_id BIGINT NOT NULL,
+			PRIMARY KEY (id_staff, group_id)
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("error creating access group tables: %v", err)
+		}
+	}
+	return nil
+}
+
+// fetchAccessGroups получает группы доступа, если источник их поддерживает
+func fetchAccessGroups(ctx context.Context) (*AccessData, error) {
+	source, err := newSourceConnector()
+	if err != nil {
+		return nil, err
+	}
+	as, ok := source.(AccessGroupSource)
+	if !ok {
+		return nil, nil
+	}
+	return as.FetchAccessGroups(ctx)
+}
+
+// replaceAccessGroups перезаписывает группы доступа в рамках транзакции синхронизации
+func replaceAccessGroups(tx *sql.Tx, data *AccessData) error {
+	for _, table := range []string{"staff_access_groups", "access_group_doors", "access_groups"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("Error clearing %s: %v", table, err)
+		}
+	}
+
+	doors := 0
+	for _, g := range data.Groups {
+		if _, err := tx.Exec("INSERT INTO access_groups (id, name) VALUES ($1, $2)", g.ID, g.Name); err != nil {
+			return fmt.Errorf("Error inserting access group %d: %v", g.ID, err)
+		}
+		for _, door := range g.Doors {
+			_, err := tx.Exec("INSERT INTO access_group_doors (group_id, door_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", g.ID, door)
+			if err != nil {
+				return fmt.Errorf("Error inserting door %d of access group %d: %v", door, g.ID, err)
+			}
+			doors++
+		}
+	}
+
+	assignments := 0
+	for idStaff, groups := range data.StaffGroups {
+		for _, groupID := range groups {
+			_, err := tx.Exec("INSERT INTO staff_access_groups (id_staff, group_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", idStaff, groupID)
+			if err != nil {
+				return fmt.Errorf("Error assigning access group %d to %d: %v", groupID, idStaff, err)
+			}
+			assignments++
+		}
+	}
+
+	log.Printf("🔑 Synced %d access groups, %d doors, %d assignments", len(data.Groups), doors, assignments)
+	return nil
+}
+
+// staffHasDoorAccess проверяет, входит ли дверь в группы доступа сотрудника
+func staffHasDoorAccess(db *sql.DB, idStaff, doorID int64) (bool, error) {
+	var ok bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM staff_access_groups sag
+			JOIN access_group_doors d ON d.group_id = sag.group_id
+			WHERE sag.id_staff = $1 AND d.door_id = $2
+		)
+	`, idStaff, doorID).Scan(&ok)
+	return ok, err
+}
+
+// accessGroupsHandler возвращает группы доступа с их дверями
+func accessGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := pgDB.Query(`
+		SELECT g.id, g.name, d.door_id
+		FROM access_groups g
+		LEFT JOIN access_group_doors d ON d.group_id = g.id
+		ORDER BY g.name, g.id, d.door_id
+	`)
+	if err != nil {
+		returnJSONError(w, fmt.Sprintf("Access groups query error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	groups := []AccessGroup{}
+	for rows.Next() {
+		var g AccessGroup
+		var door sql.NullInt64
+		if err := rows.Scan(&g.ID, &g.Name, &door); err != nil {
+			returnJSONError(w, fmt.Sprintf("Error scanning access group: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if len(groups) == 0 || groups[len(groups)-1].ID != g.ID {
+			groups = append(groups, g)
+		}
+		if door.Valid {
+			last := &groups[len(groups)-1]
+			last.Doors = append(last.Doors, door.Int64)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		returnJSONError(w, fmt.Sprintf("Error iterating access groups: %v", err), http.StatusInternalServerError)
+		return
+	}
+	returnJSONSuccess(w, groups, fmt.Sprintf("%d access groups", len(groups)))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// adAccount структура для атрибутов учетной записи Active Directory
+type adAccount struct {
+	Email   string
+	Phone   string
+	Account string
+}
+
+// adTabKey ключ индекса учетных записей по табельному номеру
+func adTabKey(tab string) string {
+	return "tab:" + strings.TrimSpace(tab)
+}
+
+// normalizeFullName приводит ФИО к виду, пригодному для сопоставления
+func normalizeFullName(parts ...string) string {
+	var words []string
+	for _, p := range parts {
+		words = append(words, strings.Fields(strings.ToLower(p))...)
+	}
+	return strings.ReplaceAll(strings.Join(words, " "), "ё", "е")
+}
+
+// loadADAccounts загружает учетные записи из AD и индексирует их по ФИО
+func loadADAccounts() (map[string]*adAccount, error) {
+	conn, err := ldap.DialURL(config.ADURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to AD: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.Bind(config.ADBindDN, config.ADBindPassword); err != nil {
+		return nil, fmt.Errorf("failed to bind to AD: %v", err)
+	}
+
+	request := ldap.NewSearchRequest(
+		config.ADBaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		config.ADFilter,
+		[]string{"displayName", "sn", "givenName", "mail", "telephoneNumber", "mobile", "sAMAccountName", config.ADTabAttribute},
+		nil,
+	)
+	result, err := conn.SearchWithPaging(request, 500)
+	if err != nil {
+		return nil, fmt.Errorf("AD search failed: %v", err)
+	}
+
+	accounts := make(map[string]*adAccount)
+	ambiguous := make(map[string]bool)
+	for _, entry := range result.Entries {
+		acc := &adAccount{
+			Email:   entry.GetAttributeValue("mail"),
+			Phone:   entry.GetAttributeValue("telephoneNumber"),
+			Account: entry.GetAttributeValue("sAMAccountName"),
+		}
+		if acc.Phone == "" {
+			acc.Phone = entry.GetAttributeValue("mobile")
+		}
+
+		// Индексируем по табельному номеру, displayName и по связке фамилия + имя
+		keys := []string{normalizeFullName(entry.GetAttributeValue("displayName"))}
+		if tab := entry.GetAttributeValue(config.ADTabAttribute); tab != "" {
+			keys = append(keys, adTabKey(tab))
+		}
+		if sn := entry.GetAttributeValue("sn"); sn != "" {
+			keys = append(keys, normalizeFullName(sn, entry.GetAttributeValue("givenName")))
+		}
+		for _, key := range keys {
+			if key == "" || ambiguous[key] {
+				continue
+			}
+			if existing, ok := accounts[key]; ok && existing != acc {
+				// Однофамильцы с одинаковым ФИО не сопоставляются
+				delete(accounts, key)
+				ambiguous[key] = true
+				continue
+			}
+			accounts[key] = acc
+		}
+	}
+
+	log.Printf("📇 Loaded %d accounts from AD (%d ambiguous names skipped)", len(result.Entries), len(ambiguous))
+	return accounts, nil
+}
+
+// enrichFromAD дополняет записи сотрудников данными из Active Directory и возвращает число совпадений
+func enrichFromAD(staffCards []StaffCard, accounts map[string]*adAccount) int {
+	matched := 0
+	for i := range staffCards {
+		sc := &staffCards[i]
+		var lastName, firstName, middleName string
+		if sc.LastName != nil {
+			lastName = *sc.LastName
+		}
+		if sc.FirstName != nil {
+			firstName = *sc.FirstName
+		}
+		if sc.MiddleName != nil {
+			middleName = *sc.MiddleName
+		}
+
+		// Табельный номер надежнее ФИО, поэтому проверяется первым
+		var acc *adAccount
+		ok := false
+		if sc.TabNumber != nil {
+			acc, ok = accounts[adTabKey(*sc.TabNumber)]
+		}
+		if !ok {
+			acc, ok = accounts[normalizeFullName(lastName, firstName, middleName)]
+		}
+		if !ok {
+			acc, ok = accounts[normalizeFullName(lastName, firstName)]
+		}
+		if !ok {
+			continue
+		}
+
+		if acc.Email != "" {
+			sc.Email = &acc.Email
+		}
+		if acc.Phone != "" {
+			sc.Phone = &acc.Phone
+		}
+		if acc.Account != "" {
+			sc.ADAccount = &acc.Account
+		}
+		matched++
+	}
+	return matched
+}
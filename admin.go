@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// publicMux маршруты основного порта, adminMux - служебного порта ADMIN_ADDR.
+// http.DefaultServeMux не используется: net/http/pprof регистрирует в нем профилировщик при импорте.
+var (
+	publicMux = http.NewServeMux()
+	adminMux  = http.NewServeMux()
+)
+
+// handleAdmin регистрирует служебный маршрут: на порту ADMIN_ADDR, если он задан, иначе на основном
+func handleAdmin(pattern string, handler http.HandlerFunc) {
+	if config.AdminAddr != "" {
+		adminMux.HandleFunc(pattern, handler)
+		return
+	}
+	publicMux.HandleFunc(pattern, handler)
+}
+
+// registerPprof подключает профилировщик; он доступен только на служебном порту
+func registerPprof() {
+	adminMux.HandleFunc("/debug/pprof/", pprof.Index)
+	adminMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	adminMux.HandleFunc("/debug/pprof/profile", withoutWriteTimeout(pprof.Profile))
+	adminMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	adminMux.HandleFunc("/debug/pprof/trace", withoutWriteTimeout(pprof.Trace))
+}
+
+// startAdminServer запускает служебный порт в фоне
+func startAdminServer() {
+	registerPprof()
+	log.Printf("🔧 Admin endpoints listening on %s", config.AdminAddr)
+	go func() {
+		log.Fatalf("❌ Admin server error: %v", newHTTPServer(config.AdminAddr, compressHandler(adminMux)).ListenAndServe())
+	}()
+}
+
+// healthzHandler отвечает 200, пока процесс жив; состояние базы проверяет /readyz
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// metricsHandler отдает счетчики сервиса в текстовом формате Prometheus.
+// Метрики берутся из памяти процесса, запросов к базе не выполняется.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	metric("percoweb_build_info", "gauge", "Service version")
+	fmt.Fprintf(w, "percoweb_build_info{version=%q} 1\n", version)
+
+	// Последняя синхронизация
+	lastAttempt, lastSuccess, lastError := lastSync.snapshot()
+	metric("percoweb_last_sync_attempt_timestamp_seconds", "gauge", "Time of the last sync attempt; 0 - no attempts since start")
+	fmt.Fprintf(w, "percoweb_last_sync_attempt_timestamp_seconds %d\n", unixOrZero(lastAttempt))
+	metric("percoweb_last_sync_success_timestamp_seconds", "gauge", "Time of the last successful sync; 0 - no successful sync since start")
+	fmt.Fprintf(w, "percoweb_last_sync_success_timestamp_seconds %d\n", unixOrZero(lastSuccess))
+	failed := 0
+	if lastError != "" {
+		failed = 1
+	}
+	metric("percoweb_last_sync_failed", "gauge", "1 if the last sync attempt failed")
+	fmt.Fprintf(w, "percoweb_last_sync_failed %d\n", failed)
+	metric("percoweb_sync_consecutive_failures", "gauge", "Failed sync attempts in a row since the last successful one")
+	fmt.Fprintf(w, "percoweb_sync_consecutive_failures %d\n", lastSync.consecutiveFailures())
+	metric("percoweb_last_sync_peak_memory_bytes", "gauge", "Peak heap size during the last successful sync")
+	fmt.Fprintf(w, "percoweb_last_sync_peak_memory_bytes %d\n", lastSync.peakMemoryBytes())
+
+	// Подготовленные запросы поиска и проверки доступа
+	st := preparedStatements.snapshot()
+	metric("percoweb_prepared_statements", "gauge", "Prepared statements in the cache")
+	fmt.Fprintf(w, "percoweb_prepared_statements %d\n", st.Prepared)
+	metric("percoweb_statement_executions_total", "counter", "Search and verify query executions")
+	fmt.Fprintf(w, "percoweb_statement_executions_total{prepared=\"true\"} %d\n", st.Executions)
+	fmt.Fprintf(w, "percoweb_statement_executions_total{prepared=\"false\"} %d\n", st.Unprepared)
+	metric("percoweb_statement_prepare_errors_total", "counter", "Failed statement preparations")
+	fmt.Fprintf(w, "percoweb_statement_prepare_errors_total %d\n", st.PrepareErrors)
+
+	// Запросы проверки допуска
+	verifyTotal, verifyErrors := verifyRequestStats()
+	metric("percoweb_verify_requests_total", "counter", "Access check requests")
+	fmt.Fprintf(w, "percoweb_verify_requests_total %d\n", verifyTotal)
+	metric("percoweb_verify_errors_total", "counter", "Access check requests that failed with an error")
+	fmt.Fprintf(w, "percoweb_verify_errors_total %d\n", verifyErrors)
+
+	// Номера карт, отклоненные проверкой ввода
+	rejected := rejectedCardInputStats()
+	metric("percoweb_rejected_card_inputs_total", "counter", "Card numbers rejected by input validation")
+	for _, code := range cardInputErrorCodes {
+		fmt.Fprintf(w, "percoweb_rejected_card_inputs_total{code=%q} %d\n", code, rejected[code])
+	}
+
+	// Состояние пулов соединений; пул, который еще не создавался, не выводится
+	pools := []struct {
+		name string
+		pool *dbPool
+	}{{"postgres", &pgPool}, {"postgres_replica", &pgReadPool}, {"firebird", &fbPool}}
+	stats := make(map[string]*PoolStats)
+	for _, p := range pools {
+		if s := p.pool.stats(); s != nil {
+			stats[p.name] = s
+		}
+	}
+	poolMetric := func(name, kind, help string, value func(*PoolStats) string) {
+		metric(name, kind, help)
+		for _, p := range pools {
+			if s := stats[p.name]; s != nil {
+				fmt.Fprintf(w, "%s{pool=%q} %s\n", name, p.name, value(s))
+			}
+		}
+	}
+	poolMetric("percoweb_db_pool_max_open", "gauge", "Maximum open connections; 0 - unlimited",
+		func(s *PoolStats) string { return fmt.Sprint(s.MaxOpen) })
+	poolMetric("percoweb_db_pool_open", "gauge", "Open connections",
+		func(s *PoolStats) string { return fmt.Sprint(s.Open) })
+	poolMetric("percoweb_db_pool_in_use", "gauge", "Connections in use",
+		func(s *PoolStats) string { return fmt.Sprint(s.InUse) })
+	poolMetric("percoweb_db_pool_idle", "gauge", "Idle connections",
+		func(s *PoolStats) string { return fmt.Sprint(s.Idle) })
+	poolMetric("percoweb_db_pool_wait_count_total", "counter", "Connections waited for",
+		func(s *PoolStats) string { return fmt.Sprint(s.WaitCount) })
+	poolMetric("percoweb_db_pool_wait_seconds_total", "counter", "Total time waited for connections",
+		func(s *PoolStats) string { return fmt.Sprintf("%.3f", s.WaitSeconds) })
+}
+
+// unixOrZero возвращает время в секундах Unix; нулевое время - 0
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// errAlertNoData данных для оценки правила недостаточно; состояние правила не меняется
+var errAlertNoData = errors.New("not enough data")
+
+// alertRule правило оповещения: check возвращает описание проблемы или пустую строку, если все в порядке
+type alertRule struct {
+	name  string
+	check func() (string, error)
+}
+
+// alertState состояние правила между проверками
+type alertState struct {
+	firing   bool
+	lastSent time.Time
+}
+
+// alertsEnabled сообщает, включено ли хотя бы одно правило оповещения
+func alertsEnabled() bool {
+	return config.AlertSyncFailures > 0 || config.AlertDataMaxAge > 0 || config.AlertVerifyErrorRate > 0
+}
+
+// syncFailuresRule срабатывает после ALERT_SYNC_FAILURES неудачных синхронизаций подряд
+func syncFailuresRule() alertRule {
+	return alertRule{name: "sync_failures", check: func() (string, error) {
+		failures := lastSync.consecutiveFailures()
+		if failures < config.AlertSyncFailures {
+			return "", nil
+		}
+		_, _, lastError := lastSync.snapshot()
+		return fmt.Sprintf("Синхронизация карт не удалась %d раз подряд: %s", failures, lastError), nil
+	}}
+}
+
+// dataAgeRule срабатывает, когда данные старше ALERT_DATA_MAX_AGE. Если синхронизаций не было вовсе,
+// правило ждет ALERT_DATA_MAX_AGE с запуска, чтобы не оповещать о пустой базе сразу после установки.
+func dataAgeRule(startedAt time.Time) alertRule {
+	return alertRule{name: "data_age", check: func() (string, error) {
+		pgDB, err := connectPostgres()
+		if err != nil {
+			return "", fmt.Errorf("PostgreSQL connection error: %v", err)
+		}
+		syncTime, err := lastSyncTime(pgDB)
+		if err != nil {
+			return "", err
+		}
+		if syncTime.IsZero() {
+			if time.Since(startedAt) < config.AlertDataMaxAge {
+				return "", nil
+			}
+			return "Данные ни разу не синхронизировались", nil
+		}
+		if age := time.Since(syncTime); age > config.AlertDataMaxAge {
+			return fmt.Sprintf("Данные устарели: последняя синхронизация %s (%s назад)",
+				displayTime(syncTime).Format("02.01.2006 15:04"), formatDuration(age.Truncate(time.Minute))), nil
+		}
+		return "", nil
+	}}
+}
+
+// verifyErrorRateRule срабатывает, когда доля ошибок /api/verify с прошлой проверки выше
+// ALERT_VERIFY_ERROR_RATE процентов; при малом числе запросов состояние правила не меняется
+func verifyErrorRateRule() alertRule {
+	prevTotal, prevErrors := verifyRequestStats()
+	return alertRule{name: "verify_error_rate", check: func() (string, error) {
+		total, errors := verifyRequestStats()
+		requests, failed := total-prevTotal, errors-prevErrors
+		prevTotal, prevErrors = total, errors
+		if requests < int64(config.AlertVerifyMinRequests) {
+			return "", errAlertNoData
+		}
+		if rate := failed * 100 / requests; rate > int64(config.AlertVerifyErrorRate) {
+			return fmt.Sprintf("Ошибки проверки допуска: %d из %d запросов (%d%%) за %s",
+				failed, requests, rate, formatDuration(config.AlertCheckInterval)), nil
+		}
+		return "", nil
+	}}
+}
+
+// evaluateAlerts проверяет правила и рассылает оповещения: о новой проблеме сразу, о продолжающейся -
+// не чаще ALERT_COOLDOWN, а когда проблема ушла - одно сообщение о восстановлении
+func evaluateAlerts(rules []alertRule, states map[string]*alertState) {
+	for _, rule := range rules {
+		problem, err := rule.check()
+		if err == errAlertNoData {
+			continue
+		}
+		if err != nil {
+			log.Printf("⚠️ Alert rule %s check failed: %v", rule.name, err)
+			continue
+		}
+		state := states[rule.name]
+		if problem == "" {
+			if state.firing {
+				state.firing = false
+				log.Printf("✅ Alert %s resolved", rule.name)
+				notify(Notification{Kind: eventAlertResolved, Message: alertResolvedMessage(rule.name)})
+			}
+			continue
+		}
+		if state.firing && time.Since(state.lastSent) < config.AlertCooldown {
+			continue
+		}
+		state.firing = true
+		state.lastSent = time.Now()
+		log.Printf("🚨 Alert %s: %s", rule.name, problem)
+		notify(Notification{Kind: eventAlert, Message: problem})
+	}
+}
+
+// alertResolvedMessage текст сообщения о восстановлении по имени правила
+func alertResolvedMessage(name string) string {
+	switch name {
+	case "sync_failures":
+		return "Синхронизация карт снова проходит успешно"
+	case "data_age":
+		return "Данные снова актуальны"
+	case "verify_error_rate":
+		return "Ошибки проверки допуска прекратились"
+	}
+	return "Проблема устранена: " + name
+}
+
+// startAlerting запускает периодическую проверку включенных правил оповещения
+func startAlerting() {
+	var rules []alertRule
+	if config.AlertSyncFailures > 0 {
+		rules = append(rules, syncFailuresRule())
+	}
+	if config.AlertDataMaxAge > 0 {
+		rules = append(rules, dataAgeRule(time.Now()))
+	}
+	if config.AlertVerifyErrorRate > 0 {
+		rules = append(rules, verifyErrorRateRule())
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	states := make(map[string]*alertState, len(rules))
+	for _, rule := range rules {
+		states[rule.name] = &alertState{}
+	}
+	startPeriodicJob("Alert check", config.AlertCheckInterval, func() error {
+		evaluateAlerts(rules, states)
+		return nil
+	})
+	log.Printf("🚨 Alerting enabled: %d rules, checked every %s", len(rules), formatDuration(config.AlertCheckInterval))
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// CardAnnotation локальные пометки карты (заметка, теги, ответственный), которые сохраняются между синхронизациями
+type CardAnnotation struct {
+	Identifier string    `json:"identifier"`
+	Notes      string    `json:"notes"`
+	Tags       []string  `json:"tags"`
+	Custodian  string    `json:"custodian"`
+	UpdatedBy  string    `json:"updated_by"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// initCardAnnotationsTable создает таблицу локальных пометок карт
+func initCardAnnotationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS card_annotations (
+			identifier TEXT PRIMARY KEY,
+			notes TEXT NOT NULL DEFAULT '',
+			tags TEXT[] NOT NULL DEFAULT '{}',
+			custodian VARCHAR(255) NOT NULL DEFAULT '',
+			updated_by VARCHAR(255) NOT NULL DEFAULT '',
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating card_annotations table: %v", err)
+	}
+	return nil
+}
+
+// applyCardAnnotations переносит локальные пометки в только что загруженные карты
+func applyCardAnnotations(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		UPDATE cards c SET notes = NULLIF(a.notes, ''), tags = a.tags, custodian = NULLIF(a.custodian, '')
+		FROM card_annotations a
+		WHERE a.identifier = c.identifier
+	`)
+	if err != nil {
+		return fmt.Errorf("Error applying card annotations: %v", err)
+	}
+	return nil
+}
+
+// normalizeTags убирает пробелы по краям, пустые и повторные теги, сохраняя порядок
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	result := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// loadCardAnnotations возвращает пометки всех карт
+func loadCardAnnotations(db *sql.DB) ([]CardAnnotation, error) {
+	rows, err := db.Query(`
+		SELECT identifier, notes, tags, custodian, updated_by, updated_at
+		FROM card_annotations
+		ORDER BY identifier
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("Card annotations query error: %v", err)
+	}
+	defer rows.Close()
+
+	annotations := []CardAnnotation{}
+	for rows.Next() {
+		var a CardAnnotation
+		err := rows.Scan(&a.Identifier, &a.Notes, pq.Array(&a.Tags), &a.Custodian, &a.UpdatedBy, &a.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("Error scanning card annotation: %v", err)
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations, rows.Err()
+}
+
+// saveCardAnnotation сохраняет пометки и сразу переносит их в cards
+func saveCardAnnotation(db *sql.DB, a *CardAnnotation) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO card_annotations (identifier, notes, tags, custodian, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		ON CONFLICT (identifier) DO UPDATE SET notes = EXCLUDED.notes, tags = EXCLUDED.tags,
+			custodian = EXCLUDED.custodian, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`, a.Identifier, a.Notes, pq.Array(a.Tags), a.Custodian, a.UpdatedBy).Scan(&a.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving card annotation: %v", err)
+	}
+	_, err = tx.Exec("UPDATE cards SET notes = NULLIF($2, ''), tags = $3, custodian = NULLIF($4, '') WHERE identifier = $1",
+		a.Identifier, a.Notes, pq.Array(a.Tags), a.Custodian)
+	if err != nil {
+		return fmt.Errorf("error updating card: %v", err)
+	}
+	return tx.Commit()
+}
+
+// deleteCardAnnotation удаляет пометки карты из card_annotations и cards
+func deleteCardAnnotation(db *sql.DB, identifier string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM card_annotations WHERE identifier = $1", identifier)
+	if err != nil {
+		return false, fmt.Errorf("error removing card annotation: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec("UPDATE cards SET notes = NULL, tags = '{}', custodian = NULL WHERE identifier = $1", identifier); err != nil {
+		return false, fmt.Errorf("error updating card: %v", err)
+	}
+	return true, tx.Commit()
+}
+
+// cardAnnotationsHandler перечисляет (GET), сохраняет (POST) и снимает (DELETE ?identifier=) пометки карт
+func cardAnnotationsHandler(w http.ResponseWriter, r *http.Request) {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		annotations, err := loadCardAnnotations(pgDB)
+		if err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		returnJSONSuccess(w, annotations, fmt.Sprintf("%d annotated cards", len(annotations)))
+
+	case http.MethodPost:
+		var a CardAnnotation
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			returnJSONError(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		a.Identifier = strings.TrimSpace(a.Identifier)
+		if a.Identifier == "" {
+			returnJSONError(w, "Missing 'identifier'", http.StatusBadRequest)
+			return
+		}
+		a.Notes = strings.TrimSpace(a.Notes)
+		a.Custodian = strings.TrimSpace(a.Custodian)
+		a.Tags = normalizeTags(a.Tags)
+		a.UpdatedBy = requestActor(r)
+
+		if err := saveCardAnnotation(pgDB, &a); err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("🏷️ Card %s annotated by %s", a.Identifier, a.UpdatedBy)
+		returnJSONSuccess(w, a, "Card annotation saved")
+
+	case http.MethodDelete:
+		identifier := r.URL.Query().Get("identifier")
+		if identifier == "" {
+			returnJSONError(w, "Missing 'identifier'", http.StatusBadRequest)
+			return
+		}
+		found, err := deleteCardAnnotation(pgDB, identifier)
+		if err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			returnJSONError(w, "Card annotation not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("🏷️ Card %s annotation removed by %s", identifier, requestActor(r))
+		returnJSONSuccess(w, map[string]string{"identifier": identifier}, "Card annotation removed")
+
+	default:
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
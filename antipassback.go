@@ -0,0 +1,75 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// initPassageStateTable создает таблицу последнего направления прохода сотрудников
+func initPassageStateTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS passage_state (
+			id_staff BIGINT PRIMARY KEY,
+			direction SMALLINT NOT NULL,
+			passed_at TIMESTAMPTZ NOT NULL,
+			source VARCHAR(10) NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating passage_state table: %v", err)
+	}
+	// Зона последнего прохода для /api/occupancy; у проходов через /api/verify зона неизвестна
+	if _, err := db.Exec("ALTER TABLE passage_state ADD COLUMN IF NOT EXISTS area_id BIGINT"); err != nil {
+		return fmt.Errorf("error adding passage_state.area_id: %v", err)
+	}
+	// Состояние прежних версий почти целиком перенесено из событий и записано по времени PERCo
+	return convertToTimestamptz(db, "passage_state", config.SourceTimezone, "passed_at")
+}
+
+// updatePassageStateFromEvents переносит в passage_state последнее событие каждого сотрудника,
+// если оно новее уже известного состояния
+func updatePassageStateFromEvents(db *sql.DB, since time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO passage_state (id_staff, direction, passed_at, source, area_id)
+		SELECT DISTINCT ON (id_staff) id_staff, direction, event_time, 'event', area_id
+		FROM events
+		WHERE event_time >= $1
+		ORDER BY id_staff, event_time DESC
+		ON CONFLICT (id_staff) DO UPDATE
+			SET direction = EXCLUDED.direction, passed_at = EXCLUDED.passed_at, source = EXCLUDED.source,
+				area_id = EXCLUDED.area_id
+			WHERE passage_state.passed_at < EXCLUDED.passed_at
+	`, since)
+	if err != nil {
+		return fmt.Errorf("error updating passage state: %v", err)
+	}
+	return nil
+}
+
+// recordVerifiedPassage запоминает направление разрешенного прохода из запроса verify
+func recordVerifiedPassage(db *sql.DB, idStaff int64, direction int) error {
+	_, err := db.Exec(`
+		INSERT INTO passage_state (id_staff, direction, passed_at, source)
+		VALUES ($1, $2, NOW(), 'verify')
+		ON CONFLICT (id_staff) DO UPDATE
+			SET direction = EXCLUDED.direction, passed_at = EXCLUDED.passed_at, source = EXCLUDED.source,
+				area_id = NULL
+	`, idStaff, direction)
+	if err != nil {
+		return fmt.Errorf("error recording passage: %v", err)
+	}
+	return nil
+}
+
+// antipassbackViolation сообщает, был ли у сотрудника вход без выхода в пределах ANTIPASSBACK_WINDOW
+func antipassbackViolation(db *sql.DB, idStaff int64) (bool, error) {
+	var violation bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM passage_state
+			WHERE id_staff = $1 AND direction = $2 AND passed_at > NOW() - make_interval(secs => $3)
+		)
+	`, idStaff, directionIn, config.AntipassbackWindow.Seconds()).Scan(&violation)
+	return violation, err
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// CardAssignment период, в течение которого идентификатор был выдан сотруднику
+type CardAssignment struct {
+	Identifier     string     `json:"identifier"`
+	IdentifierType string     `json:"identifier_type"`
+	AssignedFrom   time.Time  `json:"assigned_from"`
+	AssignedTo     *time.Time `json:"assigned_to"`
+}
+
+// initCardAssignmentsTable создает таблицу истории выдачи идентификаторов
+func initCardAssignmentsTable(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS card_assignments (
+			id BIGSERIAL PRIMARY KEY,
+			id_staff BIGINT NOT NULL,
+			identifier TEXT NOT NULL,
+			identifier_type VARCHAR(20) NOT NULL,
+			assigned_from TIMESTAMP NOT NULL,
+			assigned_to TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS card_assignments_staff_idx ON card_assignments (id_staff, assigned_from)`,
+		`CREATE INDEX IF NOT EXISTS card_assignments_identifier_idx ON card_assignments (identifier, assigned_from)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("error creating card_assignments table: %v", err)
+		}
+	}
+	return nil
+}
+
+// updateCardAssignments закрывает выдачи, пропавшие из staff_cards, и открывает новые.
+// Для карт, которые были до появления истории, началом выдачи считается первая синхронизация.
+func updateCardAssignments(tx *sql.Tx, at string) error {
+	closed, err := tx.Exec(`
+		UPDATE card_assignments a SET assigned_to = $1
+		WHERE a.assigned_to IS NULL AND NOT EXISTS (
+			SELECT 1 FROM staff_cards sc WHERE sc.id_staff = a.id_staff AND sc.identifier = a.identifier
+		)
+	`, at)
+	if err != nil {
+		return fmt.Errorf("Error closing card assignments: %v", err)
+	}
+	opened, err := tx.Exec(`
+		INSERT INTO card_assignments (id_staff, identifier, identifier_type, assigned_from)
+		SELECT DISTINCT ON (sc.id_staff, sc.identifier) sc.id_staff, sc.identifier, sc.identifier_type, $1::timestamp
+		FROM staff_cards sc
+		WHERE NOT EXISTS (
+			SELECT 1 FROM card_assignments a
+			WHERE a.assigned_to IS NULL AND a.id_staff = sc.id_staff AND a.identifier = sc.identifier
+		)
+	`, at)
+	if err != nil {
+		return fmt.Errorf("Error opening card assignments: %v", err)
+	}
+
+	nClosed, _ := closed.RowsAffected()
+	nOpened, _ := opened.RowsAffected()
+	if nClosed > 0 || nOpened > 0 {
+		log.Printf("🪪 Card assignments: %d opened, %d closed", nOpened, nClosed)
+	}
+	return nil
+}
+
+// loadCardAssignments возвращает историю выдачи идентификаторов сотруднику, начиная с последних
+func loadCardAssignments(db *sql.DB, idStaff int64) ([]CardAssignment, error) {
+	rows, err := db.Query(`
+		SELECT identifier, identifier_type, assigned_from, assigned_to
+		FROM card_assignments
+		WHERE id_staff = $1
+		ORDER BY assigned_from DESC, identifier
+	`, idStaff)
+	if err != nil {
+		return nil, fmt.Errorf("Card history query error: %v", err)
+	}
+	defer rows.Close()
+
+	history := []CardAssignment{}
+	for rows.Next() {
+		var a CardAssignment
+		if err := rows.Scan(&a.Identifier, &a.IdentifierType, &a.AssignedFrom, &a.AssignedTo); err != nil {
+			return nil, fmt.Errorf("Error scanning card assignment: %v", err)
+		}
+		history = append(history, a)
+	}
+	return history, rows.Err()
+}
+
+// cardHistoryHandler отдает историю выдачи идентификаторов сотруднику
+func cardHistoryHandler(w http.ResponseWriter, r *http.Request, idStaff int64) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	history, err := loadCardAssignments(pgDB, idStaff)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	returnJSONSuccess(w, history, fmt.Sprintf("%d card assignments", len(history)))
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PresenceInterval отрезок времени между входом и выходом
+type PresenceInterval struct {
+	In    time.Time `json:"in"`
+	Out   time.Time `json:"out"`
+	Hours float64   `json:"hours"`
+}
+
+// AttendanceReportRow итог по сотруднику за период отчета
+type AttendanceReportRow struct {
+	IDStaff     int64              `json:"id_staff"`
+	FullName    string             `json:"full_name"`
+	Department  string             `json:"department"`
+	Shift       string             `json:"shift,omitempty"`
+	Intervals   []PresenceInterval `json:"intervals"`
+	Days        []WorkedDay        `json:"days"`
+	AbsentDays  []string           `json:"absent_days"`
+	TotalHours  float64            `json:"total_hours"`
+	WorkedHours float64            `json:"worked_hours"`
+	NightHours  float64            `json:"night_hours"`
+}
+
+// buildAttendanceReport выбирает события за период, собирает интервалы присутствия и применяет правила.
+// department, если не nil, ограничивает отчет подразделением и вложенными в него, site - объектом сотрудника.
+// Опоздания и ранние уходы считаются от графика сотрудника, если он назначен, а неявки
+// и работа в выходные определяются по производственному календарю.
+func buildAttendanceReport(db *sql.DB, from, to time.Time, department *int64, site string, rules *WorkRules) ([]AttendanceReportRow, error) {
+	query := `
+		SELECT e.id_staff,
+			COALESCE(concat_ws(' ', sc.last_name, sc.first_name, sc.middle_name), ''),
+			COALESCE(dp.path, ''),
+			e.event_time, e.direction
+		FROM events e
+		LEFT JOIN LATERAL (
+			SELECT last_name, first_name, middle_name, department_id, site
+			FROM staff_cards WHERE id_staff = e.id_staff LIMIT 1
+		) sc ON true
+		LEFT JOIN department_paths dp ON dp.id = sc.department_id
+		WHERE e.event_time >= $1 AND e.event_time < $2`
+	args := []interface{}{from, to}
+	if department != nil {
+		args = append(args, *department)
+		query += fmt.Sprintf(" AND sc.department_id IN (SELECT id FROM department_paths WHERE $%d = ANY(ancestors))", len(args))
+	}
+	if site != "" {
+		args = append(args, site)
+		query += fmt.Sprintf(" AND sc.site = $%d", len(args))
+	}
+	query += " ORDER BY 2, e.id_staff, e.event_time"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("attendance report query error: %v", err)
+	}
+	defer rows.Close()
+
+	report := []AttendanceReportRow{}
+	var current *AttendanceReportRow
+	var openIn *time.Time
+	for rows.Next() {
+		var idStaff int64
+		var fullName, dept string
+		var eventTime time.Time
+		var direction int
+		if err := rows.Scan(&idStaff, &fullName, &dept, &eventTime, &direction); err != nil {
+			return nil, fmt.Errorf("error scanning attendance event: %v", err)
+		}
+		// Интервалы делятся по дням в поясе отображения
+		eventTime = displayTime(eventTime)
+
+		if current == nil || current.IDStaff != idStaff {
+			report = append(report, AttendanceReportRow{IDStaff: idStaff, FullName: fullName, Department: dept})
+			current = &report[len(report)-1]
+			openIn = nil
+		}
+
+		// Интервал открывается первым входом и закрывается ближайшим выходом;
+		// повторные входы и выходы без входа не меняют результат
+		switch direction {
+		case directionIn:
+			if openIn == nil {
+				t := eventTime
+				openIn = &t
+			}
+		case directionOut:
+			if openIn != nil {
+				hours := eventTime.Sub(*openIn).Hours()
+				current.Intervals = append(current.Intervals, PresenceInterval{In: *openIn, Out: eventTime, Hours: hours})
+				current.TotalHours += hours
+				openIn = nil
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attendance events: %v", err)
+	}
+
+	staffShifts, err := loadStaffShifts(db)
+	if err != nil {
+		return nil, err
+	}
+	calendar, err := loadCalendar(db, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range report {
+		row := &report[i]
+		rowRules := rules
+		if shift, ok := staffShifts[row.IDStaff]; ok {
+			if rowRules, err = rules.forShift(shift); err != nil {
+				return nil, err
+			}
+			row.Shift = shift.Name
+		}
+		row.Days = rowRules.applyWorkRules(row.Intervals)
+
+		present := make(map[string]bool)
+		for i := range row.Days {
+			d := &row.Days[i]
+			day, _ := time.ParseInLocation("2006-01-02", d.Date, displayLocation())
+			d.DayOff = !isWorkday(calendar, day)
+			present[d.Date] = true
+			row.WorkedHours += d.WorkedHours
+			row.NightHours += d.NightHours
+		}
+
+		// Неявкой считается рабочий день без присутствия; будущие дни периода не учитываются
+		row.AbsentDays = []string{}
+		for day := from; day.Before(to) && day.Before(time.Now()); day = day.AddDate(0, 0, 1) {
+			date := day.Format("2006-01-02")
+			if isWorkday(calendar, day) && !present[date] {
+				row.AbsentDays = append(row.AbsentDays, date)
+			}
+		}
+	}
+	return report, nil
+}
+
+// attendanceReportRows формирует строки таблицы отчета: рабочие сутки и итог по каждому сотруднику
+func attendanceReportRows(report []AttendanceReportRow) [][]string {
+	rows := [][]string{{"ID сотрудника", "ФИО", "Подразделение", "Дата", "Первый вход", "Последний выход",
+		"Присутствие, ч", "Отработано, ч", "Ночные, ч", "Опоздание", "Ранний уход", "Выходной день", "Неявки"}}
+	yesNo := func(b bool) string {
+		if b {
+			return "да"
+		}
+		return ""
+	}
+	for _, r := range report {
+		id := strconv.FormatInt(r.IDStaff, 10)
+		for _, d := range r.Days {
+			rows = append(rows, []string{
+				id, r.FullName, r.Department,
+				d.FirstIn.Format("02.01.2006"), d.FirstIn.Format("15:04"), d.LastOut.Format("15:04"),
+				fmt.Sprintf("%.2f", d.RawHours), fmt.Sprintf("%.2f", d.WorkedHours), fmt.Sprintf("%.2f", d.NightHours),
+				yesNo(d.Late), yesNo(d.LeftEarly), yesNo(d.DayOff), "",
+			})
+		}
+		rows = append(rows, []string{id, r.FullName, r.Department, "Итого", "", "",
+			fmt.Sprintf("%.2f", r.TotalHours), fmt.Sprintf("%.2f", r.WorkedHours), fmt.Sprintf("%.2f", r.NightHours), "", "", "",
+			strconv.Itoa(len(r.AbsentDays))})
+	}
+	return rows
+}
+
+// parseReportPeriod читает from и to (YYYY-MM-DD, to включительно); по умолчанию текущий месяц
+func parseReportPeriod(r *http.Request) (time.Time, time.Time, error) {
+	now := displayTime(time.Now())
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var err error
+	if s := r.URL.Query().Get("from"); s != "" {
+		if from, err = time.ParseInLocation("2006-01-02", s, displayLocation()); err != nil {
+			return from, to, fmt.Errorf("invalid 'from' date, use YYYY-MM-DD")
+		}
+	}
+	if s := r.URL.Query().Get("to"); s != "" {
+		if to, err = time.ParseInLocation("2006-01-02", s, displayLocation()); err != nil {
+			return from, to, fmt.Errorf("invalid 'to' date, use YYYY-MM-DD")
+		}
+	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("'to' must not be earlier than 'from'")
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+// attendanceReportHandler отдает отчет о присутствии сотрудников в JSON или XLSX
+func attendanceReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	from, to, err := parseReportPeriod(r)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var department *int64
+	if s := r.URL.Query().Get("department"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			returnJSONError(w, "Invalid 'department' parameter", http.StatusBadRequest)
+			return
+		}
+		department = &id
+	}
+
+	rules, err := loadWorkRules()
+	if err != nil {
+		returnJSONError(w, fmt.Sprintf("Invalid work rules configuration: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	pgDB, err := connectPostgresRead()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	report, err := buildAttendanceReport(pgDB, from, to, department, r.URL.Query().Get("site"), rules)
+	if err != nil {
+		log.Printf("❌ Attendance report failed: %v", err)
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "xlsx" {
+		w.Header().Set("Content-Type", exportContentTypes["xlsx"])
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="attendance_%s_%s.xlsx"`,
+			from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102")))
+		if err := writeRowsXLSX(w, "Присутствие", attendanceReportRows(report)); err != nil {
+			log.Printf("❌ Attendance export failed: %v", err)
+		}
+		return
+	}
+
+	returnJSONSuccess(w, report, fmt.Sprintf("Attendance for %d employees", len(report)))
+}
@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Snapshot структура для снимка данных сервиса
+type Snapshot struct {
+	CreatedAt   time.Time          `json:"created_at"`
+	SyncedAt    *time.Time         `json:"synced_at"`
+	StaffCards  []StaffCard        `json:"staff_cards"`
+	Departments []Department       `json:"departments,omitempty"`
+	Blocklist   []BlocklistEntry   `json:"blocklist,omitempty"`
+	SyncHistory []SyncHistoryEntry `json:"sync_history"`
+
+	// Локальные данные, которых нет в источнике; в снимках старых версий отсутствуют
+	ExpiryOverrides []ExpiryOverride      `json:"expiry_overrides,omitempty"`
+	BlocklistAudit  []BlocklistAuditEntry `json:"blocklist_audit,omitempty"`
+	CardAnnotations []CardAnnotation      `json:"card_annotations,omitempty"`
+	StaffTags       []StaffTag            `json:"staff_tags,omitempty"`
+	StaffNotes      []StaffNote           `json:"staff_notes,omitempty"`
+	TemporaryCards  []TemporaryCard       `json:"temporary_cards,omitempty"`
+}
+
+// newS3Client создает клиент S3-совместимого хранилища
+func newS3Client() (*minio.Client, error) {
+	if config.S3Endpoint == "" || config.S3Bucket == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT and S3_BUCKET must be set")
+	}
+	return minio.New(config.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.S3AccessKey, config.S3SecretKey, ""),
+		Secure: config.S3UseSSL,
+		Region: config.S3Region,
+	})
+}
+
+// errBackupDialect снимок включает таблицы, которые есть только в схеме PostgreSQL
+var errBackupDialect = errors.New("backup and restore require TARGET_DB=postgres")
+
+// loadSnapshot читает карты, стоп-лист, локальные сроки действия и журналы
+func loadSnapshot(db *sql.DB) (*Snapshot, error) {
+	if !targetDialect().extended {
+		return nil, errBackupDialect
+	}
+	snap := &Snapshot{CreatedAt: time.Now()}
+
+	var syncedAt sql.NullTime
+	if err := db.QueryRow("SELECT MAX(updated_at) FROM staff_cards").Scan(&syncedAt); err != nil {
+		return nil, fmt.Errorf("error reading last update time: %v", err)
+	}
+	if syncedAt.Valid {
+		snap.SyncedAt = &syncedAt.Time
+	}
+
+	staffCards, err := queryStaffCards(db, "")
+	if err != nil {
+		return nil, fmt.Errorf("error reading staff_cards: %v", err)
+	}
+	snap.StaffCards = staffCards
+
+	departments, err := loadDepartments(db)
+	if err != nil {
+		return nil, fmt.Errorf("error reading departments: %v", err)
+	}
+	snap.Departments = departments
+
+	blocklist, err := loadBlocklist(db)
+	if err != nil {
+		return nil, fmt.Errorf("error reading blocklist: %v", err)
+	}
+	snap.Blocklist = blocklist
+
+	historyRows, err := db.Query(`
+		SELECT id, started_at, finished_at, success, records, COALESCE(error, '')
+		FROM sync_history
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sync_history: %v", err)
+	}
+	defer historyRows.Close()
+
+	for historyRows.Next() {
+		var h SyncHistoryEntry
+		if err := historyRows.Scan(&h.ID, &h.StartedAt, &h.FinishedAt, &h.Success, &h.Records, &h.Error); err != nil {
+			return nil, fmt.Errorf("error scanning sync_history row: %v", err)
+		}
+		snap.SyncHistory = append(snap.SyncHistory, h)
+	}
+	if err := historyRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sync_history: %v", err)
+	}
+
+	overrideRows, err := db.Query("SELECT identifier, valid_until FROM card_expiry_overrides ORDER BY identifier")
+	if err != nil {
+		return nil, fmt.Errorf("error reading card_expiry_overrides: %v", err)
+	}
+	defer overrideRows.Close()
+	for overrideRows.Next() {
+		var o ExpiryOverride
+		if err := overrideRows.Scan(&o.Identifier, &o.ValidUntil); err != nil {
+			return nil, fmt.Errorf("error scanning card_expiry_overrides row: %v", err)
+		}
+		snap.ExpiryOverrides = append(snap.ExpiryOverrides, o)
+	}
+	if err := overrideRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating card_expiry_overrides: %v", err)
+	}
+
+	auditRows, err := db.Query("SELECT id, action, identifier, reason, actor, created_at FROM blocklist_audit ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error reading blocklist_audit: %v", err)
+	}
+	defer auditRows.Close()
+	for auditRows.Next() {
+		var e BlocklistAuditEntry
+		if err := auditRows.Scan(&e.ID, &e.Action, &e.Identifier, &e.Reason, &e.Actor, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning blocklist_audit row: %v", err)
+		}
+		snap.BlocklistAudit = append(snap.BlocklistAudit, e)
+	}
+	if err := auditRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating blocklist_audit: %v", err)
+	}
+
+	if snap.CardAnnotations, err = loadCardAnnotations(db); err != nil {
+		return nil, err
+	}
+	if snap.StaffTags, err = loadStaffTags(db, 0); err != nil {
+		return nil, err
+	}
+	if snap.StaffNotes, err = loadStaffNotes(db, 0); err != nil {
+		return nil, err
+	}
+	if snap.TemporaryCards, err = loadTemporaryCards(db, true); err != nil {
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+// restoreSnapshot заменяет содержимое таблиц данными из снимка
+func restoreSnapshot(db *sql.DB, snap *Snapshot) error {
+	if !targetDialect().extended {
+		return errBackupDialect
+	}
+	if err := initPostgresTable(db); err != nil {
+		return err
+	}
+	if err := initDepartmentsTable(db); err != nil {
+		return err
+	}
+	if err := initBlocklistTables(db); err != nil {
+		return err
+	}
+	if err := initAccessGroupTables(db); err != nil {
+		return err
+	}
+	if err := initSyncHistoryTable(db); err != nil {
+		return err
+	}
+	if err := initExpiryOverridesTable(db); err != nil {
+		return err
+	}
+	if err := initCardAnnotationsTable(db); err != nil {
+		return err
+	}
+	if err := initStaffTagTables(db); err != nil {
+		return err
+	}
+	if err := initTemporaryCardsTable(db); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("transaction error: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := clearStaffCards(tx); err != nil {
+		return err
+	}
+	if snap.Departments != nil {
+		if err := replaceDepartments(tx, snap.Departments); err != nil {
+			return err
+		}
+	}
+	if snap.Blocklist != nil {
+		if err := replaceBlocklist(tx, snap.Blocklist); err != nil {
+			return err
+		}
+	}
+	if snap.ExpiryOverrides != nil {
+		if _, err := tx.Exec("DELETE FROM card_expiry_overrides"); err != nil {
+			return fmt.Errorf("error clearing card_expiry_overrides: %v", err)
+		}
+		for _, o := range snap.ExpiryOverrides {
+			if _, err := tx.Exec("INSERT INTO card_expiry_overrides (identifier, valid_until) VALUES ($1, $2)",
+				o.Identifier, o.ValidUntil); err != nil {
+				return fmt.Errorf("error restoring expiry override %s: %v", o.Identifier, err)
+			}
+		}
+	}
+
+	updatedAt := snap.CreatedAt
+	if snap.SyncedAt != nil {
+		updatedAt = *snap.SyncedAt
+	}
+
+	// Временные пропуска восстанавливаются в temporary_cards, а их строки в cards пересоздаются
+	// ниже как локальные: копии из staff_cards снимка записались бы как карты из источника
+	if snap.TemporaryCards != nil {
+		if err := restoreTemporaryCards(tx, snap.TemporaryCards); err != nil {
+			return err
+		}
+	}
+
+	// Локальные карты не удаляются при очистке, их копии из снимка пропускаются
+	local, err := loadLocalIdentifiers(tx)
+	if err != nil {
+		return err
+	}
+	for _, c := range snap.TemporaryCards {
+		local[c.Identifier] = true
+	}
+	var cards []StaffCard
+	for _, sc := range snap.StaffCards {
+		if !local[sc.Identifier] {
+			cards = append(cards, sc)
+		}
+	}
+	if uniqueIndex, err := hasUniqueIdentifierIndex(tx); err != nil {
+		return fmt.Errorf("index check error: %v", err)
+	} else if uniqueIndex {
+		cards, _ = splitDuplicateCards(cards)
+	}
+
+	writer := newCardWriter(tx, updatedAt)
+	for _, sc := range cards {
+		if err := writer.write(sc); err != nil {
+			return fmt.Errorf("error restoring cards: %v", err)
+		}
+	}
+	if err := writer.flush(); err != nil {
+		return fmt.Errorf("error restoring cards: %v", err)
+	}
+	if err := insertTemporaryCards(tx, displayTime(updatedAt).Format("2006-01-02 15:04:05")); err != nil {
+		return err
+	}
+	if snap.CardAnnotations != nil {
+		if _, err := tx.Exec("DELETE FROM card_annotations"); err != nil {
+			return fmt.Errorf("error clearing card_annotations: %v", err)
+		}
+		for _, a := range snap.CardAnnotations {
+			_, err := tx.Exec(`
+				INSERT INTO card_annotations (identifier, notes, tags, custodian, updated_by, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`, a.Identifier, a.Notes, pq.Array(normalizeTags(a.Tags)), a.Custodian, a.UpdatedBy, a.UpdatedAt)
+			if err != nil {
+				return fmt.Errorf("error restoring card annotation %s: %v", a.Identifier, err)
+			}
+		}
+	}
+	if err := applyCardAnnotations(tx); err != nil {
+		return err
+	}
+	if snap.StaffTags != nil {
+		if _, err := tx.Exec("DELETE FROM staff_tags"); err != nil {
+			return fmt.Errorf("error clearing staff_tags: %v", err)
+		}
+		for _, t := range snap.StaffTags {
+			_, err := tx.Exec("INSERT INTO staff_tags (id_staff, tag, added_by, added_at) VALUES ($1, $2, $3, $4)",
+				t.IDStaff, t.Tag, t.AddedBy, t.AddedAt)
+			if err != nil {
+				return fmt.Errorf("error restoring staff tag %d/%s: %v", t.IDStaff, t.Tag, err)
+			}
+		}
+	}
+	if snap.StaffNotes != nil {
+		if _, err := tx.Exec("DELETE FROM staff_notes"); err != nil {
+			return fmt.Errorf("error clearing staff_notes: %v", err)
+		}
+		for _, n := range snap.StaffNotes {
+			_, err := tx.Exec("INSERT INTO staff_notes (id, id_staff, text, author, created_at) VALUES ($1, $2, $3, $4, $5)",
+				n.ID, n.IDStaff, n.Text, n.Author, n.CreatedAt)
+			if err != nil {
+				return fmt.Errorf("error restoring staff note %d: %v", n.ID, err)
+			}
+		}
+		if _, err := tx.Exec("SELECT setval('staff_notes_id_seq', GREATEST((SELECT MAX(id) FROM staff_notes), 1))"); err != nil {
+			return fmt.Errorf("error updating staff_notes sequence: %v", err)
+		}
+	}
+
+	// Журнал дополняется: уже существующие записи не перезаписываются
+	for _, h := range snap.SyncHistory {
+		_, err := tx.Exec(`
+			INSERT INTO sync_history (id, started_at, finished_at, success, records, error)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+			ON CONFLICT (id) DO NOTHING
+		`, h.ID, h.StartedAt, h.FinishedAt, h.Success, h.Records, h.Error)
+		if err != nil {
+			return fmt.Errorf("error restoring sync history %d: %v", h.ID, err)
+		}
+	}
+	if _, err := tx.Exec("SELECT setval('sync_history_id_seq', GREATEST((SELECT MAX(id) FROM sync_history), 1))"); err != nil {
+		return fmt.Errorf("error updating sync_history sequence: %v", err)
+	}
+	for _, e := range snap.BlocklistAudit {
+		_, err := tx.Exec(`
+			INSERT INTO blocklist_audit (id, action, identifier, reason, actor, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (id) DO NOTHING
+		`, e.ID, e.Action, e.Identifier, e.Reason, e.Actor, e.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("error restoring blocklist audit %d: %v", e.ID, err)
+		}
+	}
+	if _, err := tx.Exec("SELECT setval('blocklist_audit_id_seq', GREATEST((SELECT MAX(id) FROM blocklist_audit), 1))"); err != nil {
+		return fmt.Errorf("error updating blocklist_audit sequence: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing restore: %v", err)
+	}
+
+	log.Printf("✅ Restored %d cards and %d sync history entries from snapshot of %s",
+		len(snap.StaffCards), len(snap.SyncHistory), snap.CreatedAt.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+// restoreTemporaryCards заменяет временные пропуска пропусками из снимка вместе с их локальными строками
+// в staff и cards; строки пересоздает insertTemporaryCards после записи карт
+func restoreTemporaryCards(tx *sql.Tx, cards []TemporaryCard) error {
+	for _, stmt := range []string{
+		"DELETE FROM cards WHERE source = '" + recordSourceLocal + "'",
+		"DELETE FROM staff WHERE source = '" + recordSourceLocal + "'",
+		"DELETE FROM temporary_cards",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("error clearing temporary cards: %v", err)
+		}
+	}
+	for _, c := range cards {
+		_, err := tx.Exec(`
+			INSERT INTO temporary_cards (id, identifier, identifier_type, last_name, first_name, middle_name, company, site,
+				valid_from, valid_to, created_by, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		`, c.ID, c.Identifier, c.IdentifierType, c.LastName, c.FirstName, c.MiddleName, c.Company, c.Site,
+			c.ValidFrom, c.ValidTo, c.CreatedBy, c.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("error restoring temporary card %s: %v", c.Identifier, err)
+		}
+	}
+	if _, err := tx.Exec("SELECT setval('temporary_cards_id_seq', GREATEST((SELECT MAX(id) FROM temporary_cards), 1))"); err != nil {
+		return fmt.Errorf("error updating temporary_cards sequence: %v", err)
+	}
+	return nil
+}
+
+// encodeSnapshot сериализует снимок в сжатый JSON
+func encodeSnapshot(snap *Snapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(snap); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeSnapshot читает снимок из сжатого JSON
+func decodeSnapshot(r io.Reader) (*Snapshot, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot archive: %v", err)
+	}
+	defer gz.Close()
+
+	var snap Snapshot
+	if err := json.NewDecoder(gz).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("invalid snapshot content: %v", err)
+	}
+	return &snap, nil
+}
+
+// listS3Snapshots возвращает ключи снимков в бакете, от старых к новым
+func listS3Snapshots(ctx context.Context, client *minio.Client) ([]string, error) {
+	var keys []string
+	for obj := range client.ListObjects(ctx, config.S3Bucket, minio.ListObjectsOptions{
+		Prefix:    config.S3Prefix,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("error listing snapshots: %v", obj.Err)
+		}
+		if strings.HasSuffix(obj.Key, ".json.gz") {
+			keys = append(keys, obj.Key)
+		}
+	}
+	// Имена содержат метку времени, поэтому сортировка по ключу хронологическая
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// createS3Snapshot выгружает снимок в хранилище и удаляет устаревшие копии
+func createS3Snapshot() (string, error) {
+	client, err := newS3Client()
+	if err != nil {
+		return "", err
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return "", fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+
+	snap, err := loadSnapshot(pgDB)
+	if err != nil {
+		return "", err
+	}
+	data, err := encodeSnapshot(snap)
+	if err != nil {
+		return "", fmt.Errorf("error encoding snapshot: %v", err)
+	}
+
+	ctx := context.Background()
+	key := fmt.Sprintf("%ssnapshot_%s.json.gz", config.S3Prefix, snap.CreatedAt.Format("20060102_150405"))
+	_, err = client.PutObject(ctx, config.S3Bucket, key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/gzip"})
+	if err != nil {
+		return "", fmt.Errorf("error uploading snapshot: %v", err)
+	}
+	log.Printf("💾 Snapshot uploaded to s3://%s/%s (%d cards, %d bytes)", config.S3Bucket, key, len(snap.StaffCards), len(data))
+
+	if config.BackupRetention > 0 {
+		keys, err := listS3Snapshots(ctx, client)
+		if err != nil {
+			return key, err
+		}
+		for len(keys) > config.BackupRetention {
+			if err := client.RemoveObject(ctx, config.S3Bucket, keys[0], minio.RemoveObjectOptions{}); err != nil {
+				return key, fmt.Errorf("error removing old snapshot %s: %v", keys[0], err)
+			}
+			log.Printf("🗑️ Old snapshot removed: %s", keys[0])
+			keys = keys[1:]
+		}
+	}
+
+	return key, nil
+}
+
+// restoreS3Snapshot восстанавливает данные из снимка; пустой ключ означает последний снимок
+func restoreS3Snapshot(key string) error {
+	client, err := newS3Client()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if key == "" || key == "latest" {
+		keys, err := listS3Snapshots(ctx, client)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("no snapshots found in s3://%s/%s", config.S3Bucket, config.S3Prefix)
+		}
+		key = keys[len(keys)-1]
+	}
+
+	obj, err := client.GetObject(ctx, config.S3Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("error downloading snapshot %s: %v", key, err)
+	}
+	defer obj.Close()
+
+	snap, err := decodeSnapshot(obj)
+	if err != nil {
+		return err
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+
+	log.Printf("♻️ Restoring snapshot s3://%s/%s", config.S3Bucket, key)
+	return restoreSnapshot(pgDB, snap)
+}
+
+// writeSnapshotFile сохраняет снимок в локальный файл; "-" - стандартный вывод
+func writeSnapshotFile(path string) (*Snapshot, error) {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return nil, fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+	snap, err := loadSnapshot(pgDB)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodeSnapshot(snap)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding snapshot: %v", err)
+	}
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return snap, err
+	}
+	// Файл пишется под временным именем, чтобы прерванная выгрузка не оставила обрезанную копию
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return nil, fmt.Errorf("error writing backup: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("error writing backup: %v", err)
+	}
+	return snap, nil
+}
+
+// restoreSnapshotFile восстанавливает данные из локального файла; "-" - стандартный ввод
+func restoreSnapshotFile(path string) error {
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("error opening backup: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	snap, err := decodeSnapshot(in)
+	if err != nil {
+		return err
+	}
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+
+	log.Printf("♻️ Restoring backup %s", path)
+	return restoreSnapshot(pgDB, snap)
+}
+
+// startBackupScheduler периодически выгружает снимки в S3
+func startBackupScheduler() {
+	log.Printf("💾 S3 backups enabled: every %s to s3://%s/%s, keeping %d",
+		config.BackupInterval, config.S3Bucket, config.S3Prefix, config.BackupRetention)
+
+	startPeriodicJob("S3 backup", config.BackupInterval, func() error {
+		_, err := createS3Snapshot()
+		return err
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// normalizeBasePath приводит BASE_PATH к виду /perco: с ведущим слешем и без завершающего; пусто или "/" - корень
+func normalizeBasePath(path string) string {
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// withBasePath публикует маршруты под BASE_PATH: префикс снимается до маршрутизации,
+// запрос к самому префиксу перенаправляется на него же со слешем, остальные пути - 404
+func withBasePath(next http.Handler) http.Handler {
+	base := config.BasePath
+	if base == "" {
+		return next
+	}
+
+	strip := http.StripPrefix(base, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == base:
+			target := base + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, base+"/"):
+			strip.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// BlocklistEntry идентификатор в стоп-листе; стоп-лист имеет приоритет над статусом из PERCo
+type BlocklistEntry struct {
+	Identifier string    `json:"identifier"`
+	Reason     string    `json:"reason"`
+	AddedBy    string    `json:"added_by"`
+	AddedAt    time.Time `json:"added_at"`
+}
+
+// BlocklistAuditEntry запись журнала изменений стоп-листа
+type BlocklistAuditEntry struct {
+	ID         int64     `json:"id"`
+	Action     string    `json:"action"`
+	Identifier string    `json:"identifier"`
+	Reason     string    `json:"reason"`
+	Actor      string    `json:"actor"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// notBlocklistedCondition условие для карт, отсутствующих в стоп-листе
+const notBlocklistedCondition = `identifier NOT IN (SELECT identifier FROM blocklist)`
+
+// initBlocklistTables создает стоп-лист и его журнал; синхронизация эти таблицы не изменяет
+func initBlocklistTables(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS blocklist (
+			identifier TEXT PRIMARY KEY,
+			reason TEXT NOT NULL DEFAULT '',
+			added_by VARCHAR(255) NOT NULL DEFAULT '',
+			added_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating blocklist table: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS blocklist_audit (
+			id BIGSERIAL PRIMARY KEY,
+			action VARCHAR(10) NOT NULL,
+			identifier TEXT NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			actor VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating blocklist_audit table: %v", err)
+	}
+	return nil
+}
+
+// requestActor определяет, кто выполняет изменение: пользователь от прокси или адрес клиента
+func requestActor(r *http.Request) string {
+	if user := r.Header.Get("X-Remote-User"); user != "" {
+		return user
+	}
+	return r.RemoteAddr
+}
+
+// loadBlocklist читает все записи стоп-листа
+func loadBlocklist(db *sql.DB) ([]BlocklistEntry, error) {
+	rows, err := db.Query("SELECT identifier, reason, added_by, added_at FROM blocklist ORDER BY added_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("Blocklist query error: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []BlocklistEntry{}
+	for rows.Next() {
+		var e BlocklistEntry
+		if err := rows.Scan(&e.Identifier, &e.Reason, &e.AddedBy, &e.AddedAt); err != nil {
+			return nil, fmt.Errorf("Error scanning blocklist entry: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// replaceBlocklist перезаписывает стоп-лист при восстановлении из снимка
+func replaceBlocklist(tx *sql.Tx, entries []BlocklistEntry) error {
+	if _, err := tx.Exec("DELETE FROM blocklist"); err != nil {
+		return fmt.Errorf("error clearing blocklist: %v", err)
+	}
+	for _, e := range entries {
+		_, err := tx.Exec("INSERT INTO blocklist (identifier, reason, added_by, added_at) VALUES ($1, $2, $3, $4)",
+			e.Identifier, e.Reason, e.AddedBy, e.AddedAt)
+		if err != nil {
+			return fmt.Errorf("error restoring blocklist entry %s: %v", e.Identifier, err)
+		}
+	}
+	return nil
+}
+
+// changeBlocklist добавляет или удаляет идентификатор и пишет запись в журнал в одной транзакции
+func changeBlocklist(db *sql.DB, action string, e BlocklistEntry) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("transaction error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var result sql.Result
+	if action == "add" {
+		result, err = tx.Exec(`
+			INSERT INTO blocklist (identifier, reason, added_by) VALUES ($1, $2, $3)
+			ON CONFLICT (identifier) DO UPDATE SET reason = EXCLUDED.reason
+		`, e.Identifier, e.Reason, e.AddedBy)
+	} else {
+		result, err = tx.Exec("DELETE FROM blocklist WHERE identifier = $1", e.Identifier)
+	}
+	if err != nil {
+		return false, fmt.Errorf("blocklist %s error: %v", action, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec("INSERT INTO blocklist_audit (action, identifier, reason, actor) VALUES ($1, $2, $3, $4)",
+		action, e.Identifier, e.Reason, e.AddedBy)
+	if err != nil {
+		return false, fmt.Errorf("blocklist audit error: %v", err)
+	}
+	return true, tx.Commit()
+}
+
+// blocklistHandler показывает (GET), пополняет (POST) и сокращает (DELETE) стоп-лист
+func blocklistHandler(w http.ResponseWriter, r *http.Request) {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		entries, err := loadBlocklist(pgDB)
+		if err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		returnJSONSuccess(w, entries, fmt.Sprintf("%d blocked identifiers", len(entries)))
+
+	case http.MethodPost:
+		var e BlocklistEntry
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			returnJSONError(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if e.Identifier == "" {
+			returnJSONError(w, "Missing 'identifier'", http.StatusBadRequest)
+			return
+		}
+		e.AddedBy = requestActor(r)
+		if _, err := changeBlocklist(pgDB, "add", e); err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("⛔ %s added to blocklist by %s: %s", e.Identifier, e.AddedBy, e.Reason)
+		returnJSONSuccess(w, e, "Identifier blocked")
+
+	case http.MethodDelete:
+		e := BlocklistEntry{Identifier: r.URL.Query().Get("identifier"), AddedBy: requestActor(r)}
+		if e.Identifier == "" {
+			returnJSONError(w, "Missing 'identifier' parameter", http.StatusBadRequest)
+			return
+		}
+		removed, err := changeBlocklist(pgDB, "remove", e)
+		if err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !removed {
+			returnJSONError(w, "Identifier is not in the blocklist", http.StatusNotFound)
+			return
+		}
+		log.Printf("⛔ %s removed from blocklist by %s", e.Identifier, e.AddedBy)
+		returnJSONSuccess(w, e, "Identifier unblocked")
+
+	default:
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// blocklistAuditHandler возвращает последние изменения стоп-листа
+func blocklistAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 1000 {
+			returnJSONError(w, "'limit' must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	query := "SELECT id, action, identifier, reason, actor, created_at FROM blocklist_audit"
+	args := []interface{}{limit}
+	if id := r.URL.Query().Get("identifier"); id != "" {
+		query += " WHERE identifier = $2"
+		args = append(args, id)
+	}
+	rows, err := pgDB.Query(query+" ORDER BY id DESC LIMIT $1", args...)
+	if err != nil {
+		returnJSONError(w, fmt.Sprintf("Blocklist audit query error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entries := []BlocklistAuditEntry{}
+	for rows.Next() {
+		var e BlocklistAuditEntry
+		if err := rows.Scan(&e.ID, &e.Action, &e.Identifier, &e.Reason, &e.Actor, &e.CreatedAt); err != nil {
+			returnJSONError(w, fmt.Sprintf("Error scanning audit entry: %v", err), http.StatusInternalServerError)
+			return
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		returnJSONError(w, fmt.Sprintf("Error iterating audit entries: %v", err), http.StatusInternalServerError)
+		return
+	}
+	returnJSONSuccess(w, entries, fmt.Sprintf("%d audit entries", len(entries)))
+}
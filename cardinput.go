@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// maxCardInputLength самый длинный номер карты, который принимают API поиска и проверки
+const maxCardInputLength = 32
+
+// Коды ошибок для некорректного номера карты; возвращаются в поле code ответа 400
+const (
+	cardInputEmpty        = "card_empty"
+	cardInputTooLong      = "card_too_long"
+	cardInputControlChars = "card_control_chars"
+	cardInputBadFormat    = "card_invalid_format"
+)
+
+// cardInputErrorCodes все коды ошибок в порядке вывода в /status
+var cardInputErrorCodes = []string{cardInputEmpty, cardInputTooLong, cardInputControlChars, cardInputBadFormat}
+
+var (
+	decimalCardPattern = regexp.MustCompile(`^\d+$`)
+	hexCardPattern     = regexp.MustCompile(`^(0[xX])?[0-9A-Fa-f]+$`)
+)
+
+// rejectedCardInputs число отклоненных номеров карт по кодам ошибок для /api/stats и /status
+var rejectedCardInputs = struct {
+	sync.Mutex
+	byCode map[string]int64
+}{byCode: make(map[string]int64)}
+
+// cardInputError номер карты не прошел проверку
+type cardInputError struct {
+	Code    string
+	Message string
+}
+
+func (e *cardInputError) Error() string {
+	return e.Message
+}
+
+// validateCardInput проверяет номер карты из запроса: длину, символы и форму (десятичный номер,
+// hex или пара Wiegand). Номер в hex с префиксом 0x переводится в десятичный, как его хранит PERCo.
+func validateCardInput(value string) (string, error) {
+	value = strings.Trim(value, " ")
+	switch {
+	case value == "":
+		return "", rejectCardInput(cardInputEmpty, "Card number is empty")
+	case strings.IndexFunc(value, unicode.IsControl) >= 0:
+		return "", rejectCardInput(cardInputControlChars, "Card number contains control characters")
+	case len(value) > maxCardInputLength:
+		return "", rejectCardInput(cardInputTooLong, fmt.Sprintf("Card number is longer than %d characters", maxCardInputLength))
+	case decimalCardPattern.MatchString(value), wiegandPattern.MatchString(value):
+		return value, nil
+	case hexCardPattern.MatchString(value):
+		if digits, ok := strings.CutPrefix(strings.ToLower(value), "0x"); ok {
+			n, _ := new(big.Int).SetString(digits, 16)
+			return n.String(), nil
+		}
+		return value, nil
+	default:
+		return "", rejectCardInput(cardInputBadFormat, "Card number must be decimal, hex or facility,number")
+	}
+}
+
+// rejectCardInput учитывает отклоненный номер в счетчиках и возвращает ошибку проверки
+func rejectCardInput(code, message string) error {
+	rejectedCardInputs.Lock()
+	rejectedCardInputs.byCode[code]++
+	rejectedCardInputs.Unlock()
+	return &cardInputError{code, message}
+}
+
+// rejectedCardInputStats возвращает копию счетчиков отклоненных номеров
+func rejectedCardInputStats() map[string]int64 {
+	rejectedCardInputs.Lock()
+	defer rejectedCardInputs.Unlock()
+	stats := make(map[string]int64, len(rejectedCardInputs.byCode))
+	for code, n := range rejectedCardInputs.byCode {
+		stats[code] = n
+	}
+	return stats
+}
+
+// returnCardInputError отвечает 400 с кодом ошибки проверки номера карты
+func returnCardInputError(w http.ResponseWriter, err error) {
+	code := cardInputBadFormat
+	if e, ok := err.(*cardInputError); ok {
+		code = e.Code
+	}
+	returnJSONErrorCode(w, code, err.Error(), http.StatusBadRequest)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// cardListCursor позиция в выдаче /api/cards: последняя отданная пара id_staff и идентификатор
+type cardListCursor struct {
+	IDStaff    int64
+	Identifier string
+}
+
+// encode возвращает непрозрачный для клиента курсор
+func (c cardListCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.IDStaff, 10) + ":" + c.Identifier))
+}
+
+// parseCardListCursor разбирает курсор, выданный в next_cursor
+func parseCardListCursor(s string) (*cardListCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	idPart, identifier, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &cardListCursor{id, identifier}, nil
+}
+
+// cardListHandler отдает карты постранично по ключу (id_staff, identifier) после курсора.
+// В отличие от OFFSET глубокие страницы не замедляются, а синхронизация между запросами
+// не сдвигает выдачу: перезагрузка сохраняет id_staff, и клиент продолжает с той же позиции.
+func cardListHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter, err := cardFilterFromQuery(q)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s := q.Get("cursor"); s != "" {
+		cursor, err := parseCardListCursor(s)
+		if err != nil {
+			returnJSONError(w, "Invalid 'cursor'", http.StatusBadRequest)
+			return
+		}
+		filter.args = append(filter.args, cursor.IDStaff, cursor.Identifier)
+		filter.conditions = append(filter.conditions,
+			fmt.Sprintf("(id_staff, identifier) > ($%d, $%d)", len(filter.args)-1, len(filter.args)))
+	}
+
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 1000 {
+			returnJSONError(w, "'limit' must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	pgDB, err := connectPostgresRead()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := searchContext(r)
+	defer cancel()
+
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	query := "SELECT " + targetDialect().staffCardColumns() + " FROM staff_cards"
+	if where := filter.where(); where != "" {
+		query += " WHERE " + where
+	}
+	query += fmt.Sprintf(" ORDER BY id_staff, identifier LIMIT %d", limit+1)
+	rows, err := pgDB.QueryContext(ctx, query, filter.args...)
+	if err != nil {
+		log.Printf("❌ Card list query failed: %v", err)
+		returnSearchError(w, ctx, fmt.Errorf("Card list query error: %v", err))
+		return
+	}
+
+	cards := []StaffCard{}
+	hasMore := false
+	err = scanStaffCardRows(rows, func(sc StaffCard) error {
+		if len(cards) == limit {
+			hasMore = true
+			return nil
+		}
+		cards = append(cards, sc)
+		return nil
+	})
+	if err != nil {
+		returnSearchError(w, ctx, err)
+		return
+	}
+
+	nextCursor := ""
+	if len(cards) > 0 {
+		last := cards[len(cards)-1]
+		nextCursor = cardListCursor{last.IDStaff, last.Identifier}.encode()
+	}
+	returnJSONSuccess(w, map[string]interface{}{
+		"cards":       cards,
+		"next_cursor": nextCursor,
+		"has_more":    hasMore,
+	}, fmt.Sprintf("%d cards", len(cards)))
+}
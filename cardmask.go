@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Поиск по маске номера карты: охранник часто видит на стертой карте только часть цифр.
+// * - любое число цифр, ? - одна цифра.
+const (
+	minCardMaskDigits = 3  // меньше известных цифр совпадет со слишком большой частью карт
+	maxCardMaskCards  = 50 // больше совпадений API не отдает, маску нужно уточнить
+)
+
+var cardMaskPattern = regexp.MustCompile(`^[0-9*?]+$`)
+
+// isCardMask сообщает, что номер карты из запроса содержит подстановочные символы
+func isCardMask(value string) bool {
+	return strings.ContainsAny(value, "*?")
+}
+
+// validateCardMask проверяет маску номера карты: только цифры, * и ?, не меньше minCardMaskDigits цифр
+func validateCardMask(mask string) (string, error) {
+	mask = strings.Trim(mask, " ")
+	switch {
+	case len(mask) > maxCardInputLength:
+		return "", rejectCardInput(cardInputTooLong, fmt.Sprintf("Card mask is longer than %d characters", maxCardInputLength))
+	case !cardMaskPattern.MatchString(mask):
+		return "", rejectCardInput(cardInputBadFormat, "Card mask may contain only digits, * (any digits) and ? (one digit)")
+	case strings.Count(mask, "*")+strings.Count(mask, "?") > len(mask)-minCardMaskDigits:
+		return "", rejectCardInput(cardInputBadFormat, fmt.Sprintf("Card mask must contain at least %d digits", minCardMaskDigits))
+	}
+	return mask, nil
+}
+
+// cardMaskCondition строит условие LIKE по проверенной маске номера карты
+func cardMaskCondition(mask string) (string, []interface{}) {
+	pattern := strings.NewReplacer("*", "%", "?", "_").Replace(mask)
+	return "identifier LIKE $1", []interface{}{pattern}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateCardMask(t *testing.T) {
+	tests := []struct {
+		mask string
+		want string
+		code string
+	}{
+		{"123*89", "123*89", ""},
+		{"*4567", "*4567", ""},
+		{" 12?4 ", "12?4", ""},
+		{"1*2*3", "1*2*3", ""},
+		{"*45", "", cardInputBadFormat},
+		{"**1", "", cardInputBadFormat},
+		{"???", "", cardInputBadFormat},
+		{"12a*", "", cardInputBadFormat},
+		{"12%4*", "", cardInputBadFormat},
+		{"12_4*", "", cardInputBadFormat},
+		{"*123456789012345678901234567890123", "", cardInputTooLong},
+	}
+	for _, tt := range tests {
+		got, err := validateCardMask(tt.mask)
+		if tt.code == "" {
+			if err != nil || got != tt.want {
+				t.Errorf("validateCardMask(%q) = %q, %v; want %q", tt.mask, got, err, tt.want)
+			}
+			continue
+		}
+		e, ok := err.(*cardInputError)
+		if !ok || e.Code != tt.code {
+			t.Errorf("validateCardMask(%q) error = %v; want code %s", tt.mask, err, tt.code)
+		}
+	}
+}
+
+func TestCardMaskCondition(t *testing.T) {
+	tests := []struct {
+		mask    string
+		pattern string
+	}{
+		{"123*89", "123%89"},
+		{"*4567", "%4567"},
+		{"12?4", "12_4"},
+		{"1?3*", "1_3%"},
+	}
+	for _, tt := range tests {
+		where, args := cardMaskCondition(tt.mask)
+		if where != "identifier LIKE $1" {
+			t.Errorf("cardMaskCondition(%q) where = %q", tt.mask, where)
+		}
+		if !reflect.DeepEqual(args, []interface{}{tt.pattern}) {
+			t.Errorf("cardMaskCondition(%q) args = %v; want [%s]", tt.mask, args, tt.pattern)
+		}
+	}
+}
+
+func TestIsCardMask(t *testing.T) {
+	for value, want := range map[string]bool{"123*": true, "12?4": true, "1234": false, "12,345": false} {
+		if got := isCardMask(value); got != want {
+			t.Errorf("isCardMask(%q) = %v; want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CardChange запись журнала изменений карт
+type CardChange struct {
+	ID         string          `json:"id"`
+	ChangedAt  time.Time       `json:"changed_at"`
+	ChangeType string          `json:"change_type"`
+	Identifier string          `json:"identifier"`
+	IDStaff    int64           `json:"id_staff"`
+	Old        json.RawMessage `json:"old"`
+	New        json.RawMessage `json:"new"`
+}
+
+// initCardChangesTable создает журнал изменений карт
+func initCardChangesTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS card_changes (
+			seq BIGSERIAL PRIMARY KEY,
+			changed_at TIMESTAMP NOT NULL,
+			change_type VARCHAR(10) NOT NULL,
+			identifier TEXT NOT NULL,
+			id_staff BIGINT,
+			old_data JSONB,
+			new_data JSONB
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating card_changes table: %v", err)
+	}
+	return nil
+}
+
+// snapshotCardsBeforeSync сохраняет копию таблицы до перезагрузки во временную таблицу транзакции
+func snapshotCardsBeforeSync(tx *sql.Tx) error {
+	_, err := tx.Exec("CREATE TEMP TABLE staff_cards_before ON COMMIT DROP AS SELECT * FROM staff_cards")
+	return err
+}
+
+// cardChangeIgnored поля staff_cards, которые не входят в журнал изменений: служебные и локальные пометки,
+// не приходящие из источника
+const cardChangeIgnored = `ARRAY['updated_at', 'name_key', 'notes', 'tags', 'custodian']`
+
+// recordCardChanges сравнивает карты до и после перезагрузки и пишет разницу в журнал
+func recordCardChanges(tx *sql.Tx, changedAt string) (int64, error) {
+	result, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO card_changes (changed_at, change_type, identifier, id_staff, old_data, new_data)
+		SELECT $1,
+			CASE WHEN o.identifier IS NULL THEN 'added'
+				WHEN n.identifier IS NULL THEN 'removed'
+				ELSE 'updated' END,
+			COALESCE(n.identifier, o.identifier),
+			COALESCE(n.id_staff, o.id_staff),
+			CASE WHEN o.identifier IS NULL THEN NULL ELSE to_jsonb(o) - %[1]s END,
+			CASE WHEN n.identifier IS NULL THEN NULL ELSE to_jsonb(n) - %[1]s END
+		FROM (SELECT DISTINCT ON (identifier) * FROM staff_cards_before ORDER BY identifier, id_staff) o
+		FULL JOIN (SELECT DISTINCT ON (identifier) * FROM staff_cards ORDER BY identifier, id_staff) n
+			ON o.identifier = n.identifier
+		WHERE o.identifier IS NULL OR n.identifier IS NULL
+			OR (to_jsonb(o) - %[1]s) IS DISTINCT FROM (to_jsonb(n) - %[1]s)
+		ORDER BY 3
+	`, cardChangeIgnored), changedAt)
+	if err != nil {
+		return 0, fmt.Errorf("error recording card changes: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// changesHandler отдает изменения карт постранично после курсора since
+func changesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var since int64
+	if cursor := r.URL.Query().Get("since"); cursor != "" {
+		var err error
+		since, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || since < 0 {
+			returnJSONError(w, "Invalid 'since' cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 1000 {
+			returnJSONError(w, "'limit' must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	rows, err := pgDB.Query(`
+		SELECT seq, changed_at, change_type, identifier, COALESCE(id_staff, 0),
+			COALESCE(old_data, 'null'::jsonb), COALESCE(new_data, 'null'::jsonb)
+		FROM card_changes
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2
+	`, since, limit+1)
+	if err != nil {
+		returnJSONError(w, fmt.Sprintf("Changes query error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	changes := []CardChange{}
+	nextCursor := since
+	hasMore := false
+	for rows.Next() {
+		if len(changes) == limit {
+			hasMore = true
+			break
+		}
+
+		var c CardChange
+		var seq int64
+		var oldData, newData []byte
+		if err := rows.Scan(&seq, &c.ChangedAt, &c.ChangeType, &c.Identifier, &c.IDStaff, &oldData, &newData); err != nil {
+			returnJSONError(w, fmt.Sprintf("Error scanning row: %v", err), http.StatusInternalServerError)
+			return
+		}
+		c.ID = strconv.FormatInt(seq, 10)
+		c.Old = oldData
+		c.New = newData
+		changes = append(changes, c)
+		nextCursor = seq
+	}
+	if err := rows.Err(); err != nil {
+		returnJSONError(w, fmt.Sprintf("Error iterating rows: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	returnJSONSuccess(w, map[string]interface{}{
+		"changes":     changes,
+		"next_cursor": strconv.FormatInt(nextCursor, 10),
+		"has_more":    hasMore,
+	}, fmt.Sprintf("%d changes", len(changes)))
+}
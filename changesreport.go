@@ -0,0 +1,281 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Разделы ежедневного отчета об изменениях карт
+const (
+	dailyChangeAdded     = "added"
+	dailyChangeRemoved   = "removed"
+	dailyChangeBlocked   = "blocked"
+	dailyChangeUnblocked = "unblocked"
+	dailyChangeRenamed   = "renamed"
+	dailyChangeUpdated   = "updated"
+)
+
+// dailyChangeSections порядок разделов в отчете и их заголовки для XLSX
+var dailyChangeSections = []struct {
+	Key   string
+	Title string
+}{
+	{dailyChangeAdded, "Добавлена"},
+	{dailyChangeRemoved, "Удалена"},
+	{dailyChangeBlocked, "Заблокирована"},
+	{dailyChangeUnblocked, "Разблокирована"},
+	{dailyChangeRenamed, "Изменено ФИО"},
+	{dailyChangeUpdated, "Другие изменения"},
+}
+
+// DailyChange одно изменение за день. Для blocked и unblocked Old/New - статус или причина стоп-листа,
+// для renamed - ФИО, для updated - список изменившихся полей в Fields.
+type DailyChange struct {
+	ChangedAt  time.Time `json:"changed_at"`
+	Identifier string    `json:"identifier"`
+	IDStaff    int64     `json:"id_staff"`
+	Name       string    `json:"name"`
+	Source     string    `json:"source"` // sync - синхронизация с PERCo, blocklist - локальный стоп-лист
+	Old        string    `json:"old,omitempty"`
+	New        string    `json:"new,omitempty"`
+	Fields     []string  `json:"fields,omitempty"`
+}
+
+// DailyChangesReport сводка изменений карт за день для утреннего обзора
+type DailyChangesReport struct {
+	Date    string                   `json:"date"`
+	Summary map[string]int           `json:"summary"`
+	Changes map[string][]DailyChange `json:"changes"`
+}
+
+// cardSnapshotName ФИО из снимка строки staff_cards в журнале изменений
+func cardSnapshotName(row map[string]interface{}) string {
+	part := func(key string) string {
+		s, _ := row[key].(string)
+		return s
+	}
+	return normalizeName(part("last_name") + " " + part("first_name") + " " + part("middle_name"))
+}
+
+// cardSnapshotStatus статус карты из снимка; nil в JSON дает пустую строку
+func cardSnapshotStatus(row map[string]interface{}) string {
+	s, _ := row["status"].(string)
+	return s
+}
+
+// classifyCardChange раскладывает запись card_changes по разделам отчета; одно изменение
+// может попасть в несколько разделов, например блокировка с одновременной сменой ФИО
+func classifyCardChange(report *DailyChangesReport, base DailyChange, changeType string, oldRow, newRow map[string]interface{}) {
+	add := func(section string, c DailyChange) {
+		report.Changes[section] = append(report.Changes[section], c)
+	}
+	switch changeType {
+	case "added":
+		base.Name = cardSnapshotName(newRow)
+		add(dailyChangeAdded, base)
+		return
+	case "removed":
+		base.Name = cardSnapshotName(oldRow)
+		add(dailyChangeRemoved, base)
+		return
+	}
+
+	base.Name = cardSnapshotName(newRow)
+	var other []string
+	for key, newValue := range newRow {
+		oldJSON, _ := json.Marshal(oldRow[key])
+		newJSON, _ := json.Marshal(newValue)
+		if string(oldJSON) == string(newJSON) {
+			continue
+		}
+		switch key {
+		case "status", "last_name", "first_name", "middle_name":
+		default:
+			other = append(other, key)
+		}
+	}
+
+	oldStatus, newStatus := cardSnapshotStatus(oldRow), cardSnapshotStatus(newRow)
+	wasAllowed := oldStatus != "blocked" && oldStatus != "dismissed"
+	isAllowed := newStatus != "blocked" && newStatus != "dismissed"
+	switch {
+	case wasAllowed && !isAllowed:
+		c := base
+		c.Old, c.New = oldStatus, newStatus
+		add(dailyChangeBlocked, c)
+	case !wasAllowed && isAllowed:
+		c := base
+		c.Old, c.New = oldStatus, newStatus
+		add(dailyChangeUnblocked, c)
+	case oldStatus != newStatus:
+		other = append(other, "status")
+	}
+
+	if oldName := cardSnapshotName(oldRow); oldName != base.Name {
+		c := base
+		c.Old, c.New = oldName, base.Name
+		add(dailyChangeRenamed, c)
+	}
+	if len(other) > 0 {
+		sort.Strings(other)
+		c := base
+		c.Fields = other
+		add(dailyChangeUpdated, c)
+	}
+}
+
+// buildDailyChangesReport собирает изменения за сутки [day, day+1) из card_changes и blocklist_audit
+func buildDailyChangesReport(db *sql.DB, day time.Time) (*DailyChangesReport, error) {
+	report := &DailyChangesReport{
+		Date:    day.Format("2006-01-02"),
+		Summary: make(map[string]int),
+		Changes: make(map[string][]DailyChange),
+	}
+	from, to := day.Format("2006-01-02"), day.AddDate(0, 0, 1).Format("2006-01-02")
+
+	rows, err := db.Query(`
+		SELECT changed_at, change_type, identifier, COALESCE(id_staff, 0),
+			COALESCE(old_data, '{}'::jsonb), COALESCE(new_data, '{}'::jsonb)
+		FROM card_changes
+		WHERE changed_at >= $1 AND changed_at < $2
+		ORDER BY seq
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("Changes query error: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var base DailyChange
+		var changeType string
+		var oldData, newData []byte
+		if err := rows.Scan(&base.ChangedAt, &changeType, &base.Identifier, &base.IDStaff, &oldData, &newData); err != nil {
+			return nil, fmt.Errorf("Error scanning change: %v", err)
+		}
+		var oldRow, newRow map[string]interface{}
+		if err := json.Unmarshal(oldData, &oldRow); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(newData, &newRow); err != nil {
+			return nil, err
+		}
+		base.Source = "sync"
+		classifyCardChange(report, base, changeType, oldRow, newRow)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Локальный стоп-лист меняется вне синхронизации, но для охраны это такая же блокировка
+	auditRows, err := db.Query(`
+		SELECT a.created_at, a.action, a.identifier, a.reason, a.actor,
+			COALESCE(MIN(c.id_staff), 0), COALESCE(MIN(concat_ws(' ', c.last_name, c.first_name, c.middle_name)), '')
+		FROM blocklist_audit a
+		LEFT JOIN staff_cards c ON c.identifier = a.identifier
+		WHERE a.created_at >= $1 AND a.created_at < $2
+		GROUP BY a.id, a.created_at, a.action, a.identifier, a.reason, a.actor
+		ORDER BY a.id
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("Blocklist audit query error: %v", err)
+	}
+	defer auditRows.Close()
+	for auditRows.Next() {
+		c := DailyChange{Source: "blocklist"}
+		var action, reason, actor string
+		if err := auditRows.Scan(&c.ChangedAt, &action, &c.Identifier, &reason, &actor, &c.IDStaff, &c.Name); err != nil {
+			return nil, fmt.Errorf("Error scanning blocklist audit: %v", err)
+		}
+		c.Name = normalizeName(c.Name)
+		section := dailyChangeBlocked
+		if action == "remove" {
+			section = dailyChangeUnblocked
+		}
+		c.New = strings.TrimSpace(reason + " (" + actor + ")")
+		report.Changes[section] = append(report.Changes[section], c)
+	}
+	if err := auditRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, s := range dailyChangeSections {
+		changes := report.Changes[s.Key]
+		if changes == nil {
+			changes = []DailyChange{}
+		}
+		sort.SliceStable(changes, func(i, j int) bool { return changes[i].ChangedAt.Before(changes[j].ChangedAt) })
+		report.Changes[s.Key] = changes
+		report.Summary[s.Key] = len(changes)
+	}
+	return report, nil
+}
+
+// dailyChangesRows формирует строки таблицы отчета по разделам
+func dailyChangesRows(report *DailyChangesReport) [][]string {
+	rows := [][]string{{"Изменение", "Время", "Идентификатор", "ID сотрудника", "ФИО", "Источник", "Было", "Стало"}}
+	for _, s := range dailyChangeSections {
+		for _, c := range report.Changes[s.Key] {
+			newValue := c.New
+			if len(c.Fields) > 0 {
+				newValue = strings.Join(c.Fields, ", ")
+			}
+			rows = append(rows, []string{s.Title, c.ChangedAt.Format("15:04"), c.Identifier,
+				strconv.FormatInt(c.IDStaff, 10), c.Name, c.Source, c.Old, newValue})
+		}
+	}
+	return rows
+}
+
+// dailyChangesReportHandler сводка изменений карт за день date (YYYY-MM-DD, по умолчанию вчера):
+// добавленные, удаленные, заблокированные, разблокированные, с новым ФИО и прочие; format=xlsx - книга Excel
+func dailyChangesReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := displayTime(time.Now())
+	day := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, now.Location())
+	if s := r.URL.Query().Get("date"); s != "" {
+		var err error
+		if day, err = time.ParseInLocation("2006-01-02", s, displayLocation()); err != nil {
+			returnJSONError(w, "Invalid 'date', use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	pgDB, err := connectPostgresRead()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	report, err := buildDailyChangesReport(pgDB, day)
+	if err != nil {
+		log.Printf("❌ Daily changes report failed: %v", err)
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "xlsx" {
+		w.Header().Set("Content-Type", exportContentTypes["xlsx"])
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="changes_%s.xlsx"`, day.Format("20060102")))
+		if err := writeRowsXLSX(w, "Изменения", dailyChangesRows(report)); err != nil {
+			log.Printf("❌ Daily changes export failed: %v", err)
+		}
+		return
+	}
+
+	total := 0
+	for _, n := range report.Summary {
+		total += n
+	}
+	returnJSONSuccess(w, report, fmt.Sprintf("%d changes on %s", total, report.Date))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// firebirdCharsets кодировки соединения Firebird, с которыми работает сервис
+var firebirdCharsets = map[string]bool{"UTF8": true, "WIN1251": true, "NONE": true}
+
+// validateFirebirdCharset проверяет FIREBIRD_charset и возвращает имя в верхнем регистре
+func validateFirebirdCharset(charset string) (string, error) {
+	name := strings.ToUpper(strings.TrimSpace(charset))
+	if !firebirdCharsets[name] {
+		return "", fmt.Errorf("unsupported FIREBIRD_charset %q, use UTF8, WIN1251 or NONE", charset)
+	}
+	return name, nil
+}
+
+// detectFirebirdCharset читает кодировку базы по умолчанию и предупреждает о вероятных искажениях
+func detectFirebirdCharset(ctx context.Context, db fbQuerier) string {
+	var dbCharset sql.NullString
+	err := db.QueryRowContext(ctx, "SELECT TRIM(RDB$CHARACTER_SET_NAME) FROM RDB$DATABASE").Scan(&dbCharset)
+	if err != nil {
+		log.Printf("⚠️ Cannot detect Firebird database charset: %v", err)
+		return ""
+	}
+	name := strings.ToUpper(dbCharset.String)
+	if name == "" {
+		name = "NONE"
+	}
+	log.Printf("📥 Firebird database charset: %s (connection charset: %s, transcoding: %s)",
+		name, config.FirebirdCharset, config.FirebirdTranscode)
+	if name == "NONE" && config.FirebirdTranscode == "off" {
+		log.Printf("⚠️ Database charset is NONE: names in WIN1251 will arrive garbled, set FIREBIRD_TRANSCODE=auto")
+	}
+	return name
+}
+
+// textDecoder переводит строки из Firebird в UTF-8 согласно FIREBIRD_TRANSCODE:
+// auto - строки с некорректным UTF-8 считаются WIN1251, win1251 - все строки в WIN1251, off - без изменений
+type textDecoder struct {
+	mode  string
+	bad   []string // поля текущей записи с байтами, которые не удалось декодировать
+	rows  int      // записи с недекодируемыми байтами за все время чтения
+	fixed int      // строки, перекодированные из WIN1251
+}
+
+// newTextDecoder создает декодер для режима из конфигурации
+func newTextDecoder() (*textDecoder, error) {
+	switch config.FirebirdTranscode {
+	case "auto", "win1251", "off":
+		return &textDecoder{mode: config.FirebirdTranscode}, nil
+	default:
+		return nil, fmt.Errorf("unsupported FIREBIRD_TRANSCODE %q, use auto, win1251 or off", config.FirebirdTranscode)
+	}
+}
+
+// decode возвращает строку в UTF-8 и запоминает поле, если в нем остались недекодируемые байты
+func (d *textDecoder) decode(field, s string) string {
+	if d.mode == "off" || s == "" || (d.mode == "auto" && utf8.ValidString(s)) {
+		return s
+	}
+	// Неопределенный в WIN1251 байт 0x98 декодируется в U+FFFD
+	out, err := charmap.Windows1251.NewDecoder().String(s)
+	if err != nil || strings.ContainsRune(out, utf8.RuneError) {
+		d.bad = append(d.bad, field)
+	}
+	if err != nil {
+		return strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+	d.fixed++
+	return out
+}
+
+// decodePtr декодирует необязательное значение на месте
+func (d *textDecoder) decodePtr(field string, s *string) {
+	if s != nil {
+		*s = d.decode(field, *s)
+	}
+}
+
+// decodeStaffCard декодирует текстовые поля записи и сообщает о недекодируемых байтах в лог
+func (d *textDecoder) decodeStaffCard(sc *StaffCard) {
+	d.bad = d.bad[:0]
+	d.decodePtr("LAST_NAME", sc.LastName)
+	d.decodePtr("FIRST_NAME", sc.FirstName)
+	d.decodePtr("MIDDLE_NAME", sc.MiddleName)
+	d.decodePtr("POSITION", sc.Position)
+	d.decodePtr("STATUS", sc.Status)
+	d.decodePtr("TABEL_ID", sc.TabNumber)
+	d.decodePtr("SITE", sc.Site)
+	for key, value := range sc.ExtraFields {
+		sc.ExtraFields[key] = d.decode("extra."+key, value)
+	}
+	if len(d.bad) > 0 {
+		d.rows++
+		log.Printf("⚠️ ID_STAFF %d: undecodable bytes in %s", sc.IDStaff, strings.Join(d.bad, ", "))
+	}
+}
+
+// report выводит итог перекодирования за чтение
+func (d *textDecoder) report() {
+	if d.fixed > 0 {
+		log.Printf("🔤 Transcoded %d values from WIN1251", d.fixed)
+	}
+	if d.rows > 0 {
+		log.Printf("⚠️ %d records contain undecodable bytes, check FIREBIRD_charset", d.rows)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// firebirdRequiredColumns таблицы и колонки базы PERCo, которые читает синхронизация
+var firebirdRequiredColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"STAFF", []string{"ID_STAFF", "LAST_NAME", "FIRST_NAME", "MIDDLE_NAME", "TABEL_ID"}},
+	{"STAFF_CARDS", []string{"STAFF_ID", "IDENTIFIER"}},
+	{"STAFF_REF", []string{"STAFF_ID", "SUBDIV_ID", "APPOINT_ID"}},
+	{"APPOINT_REF", []string{"ID_REF", "DISPLAY_NAME"}},
+	{"SUBDIV_REF", []string{"ID_REF", "DISPLAY_NAME", "ID_PARENT"}},
+}
+
+// checkReport собирает результаты проверок check и печатает их по мере выполнения
+type checkReport struct {
+	failed int
+}
+
+// result печатает результат одной проверки
+func (c *checkReport) result(name string, err error) bool {
+	if err != nil {
+		c.failed++
+		fmt.Printf("❌ %s: %v\n", name, err)
+		return false
+	}
+	fmt.Printf("✅ %s\n", name)
+	return true
+}
+
+// runCheckCommand проверяет готовность к развертыванию: конфигурацию, подключения к базам,
+// структуру базы PERCo и права в PostgreSQL. Возвращает ошибку, если хотя бы одна проверка не прошла.
+func runCheckCommand(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: check")
+	}
+	report := &checkReport{}
+
+	report.result("Configuration", validateConfig())
+
+	// Источник данных
+	switch config.SourceType {
+	case "firebird":
+		checkFirebird(report)
+	case "percoweb":
+		_, err := percoWebToken(context.Background())
+		report.result("PERCo-Web API authentication", err)
+	case "csv":
+		f, err := os.Open(config.CSVSourceFile)
+		if err == nil {
+			f.Close()
+		}
+		report.result("CSV source file "+config.CSVSourceFile, err)
+	}
+	if config.EventsEnabled && config.SourceType != "firebird" {
+		checkFirebird(report)
+	}
+
+	checkPostgres(report)
+
+	if report.failed > 0 {
+		return fmt.Errorf("%d checks failed", report.failed)
+	}
+	fmt.Println("✅ All checks passed")
+	return nil
+}
+
+// checkFirebird проверяет подключение, таблицы и колонки PERCo и разбор настроенных запросов
+func checkFirebird(report *checkReport) {
+	fbDB, err := connectFirebird()
+	if !report.result("Firebird connection", err) {
+		return
+	}
+
+	for _, t := range firebirdRequiredColumns {
+		report.result("Firebird table "+t.table, firebirdMissingColumns(fbDB, t.table, t.columns))
+	}
+
+	// Запросы из конфигурации только подготавливаются: так сервер проверяет синтаксис и имена, не выполняя их
+	queries := []struct {
+		key, query string
+	}{
+		{"VEHICLES_QUERY", config.VehiclesQuery},
+		{"ACCESS_GROUPS_QUERY", config.AccessGroupsQuery},
+		{"ACCESS_GROUP_DOORS_QUERY", config.AccessGroupDoorsQuery},
+		{"STAFF_ACCESS_GROUPS_QUERY", config.StaffAccessGroupsQuery},
+		{"READERS_QUERY", config.ReadersQuery},
+		{"PHOTOS_QUERY", config.PhotosQuery},
+		{"SHIFTS_QUERY", config.ShiftsQuery},
+		{"STAFF_SHIFTS_QUERY", config.StaffShiftsQuery},
+	}
+	if config.EventsEnabled {
+		queries = append(queries, struct{ key, query string }{"EVENTS_QUERY", config.EventsQuery})
+	}
+	for _, q := range queries {
+		if strings.TrimSpace(q.query) == "" {
+			continue
+		}
+		stmt, err := fbDB.Prepare(q.query)
+		if err == nil {
+			stmt.Close()
+		}
+		report.result("Firebird "+q.key, err)
+	}
+}
+
+// firebirdMissingColumns возвращает ошибку, если таблицы нет или в ней не хватает колонок
+func firebirdMissingColumns(fbDB *sql.DB, table string, columns []string) error {
+	rows, err := fbDB.Query("SELECT TRIM(RDB$FIELD_NAME) FROM RDB$RELATION_FIELDS WHERE RDB$RELATION_NAME = ?", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	present := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		present[strings.TrimSpace(name)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(present) == 0 {
+		return fmt.Errorf("table not found")
+	}
+
+	var missing []string
+	for _, c := range columns {
+		if !present[c] {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing columns %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkPostgres проверяет подключение, версию схемы и права, нужные миграциям и синхронизации
+func checkPostgres(report *checkReport) {
+	pgDB, err := connectPostgres()
+	if !report.result("PostgreSQL connection", err) {
+		return
+	}
+
+	// Права проверяются по каталогу PostgreSQL; для MySQL их покажет первая миграция
+	if targetDialect().extended {
+		var canCreate bool
+		err = pgDB.QueryRow("SELECT has_schema_privilege(current_schema(), 'CREATE')").Scan(&canCreate)
+		if err == nil && !canCreate {
+			err = fmt.Errorf("user %s cannot create tables in the current schema", config.PostgresUser)
+		}
+		report.result("PostgreSQL CREATE privilege", err)
+
+		// Таблицы, созданные другим пользователем, должны быть доступны на чтение и запись
+		rows, err := pgDB.Query(`
+			SELECT tablename FROM pg_tables
+			WHERE schemaname = current_schema()
+				AND NOT has_table_privilege(quote_ident(tablename), 'SELECT, INSERT, UPDATE, DELETE')
+			ORDER BY tablename
+		`)
+		if err == nil {
+			var denied []string
+			for rows.Next() {
+				var table string
+				if err = rows.Scan(&table); err != nil {
+					break
+				}
+				denied = append(denied, table)
+			}
+			if err == nil {
+				err = rows.Err()
+			}
+			rows.Close()
+			if err == nil && len(denied) > 0 {
+				err = fmt.Errorf("no read/write access to %s", strings.Join(denied, ", "))
+			}
+		}
+		report.result("PostgreSQL table privileges", err)
+	}
+
+	version, dirty, err := currentSchemaVersion(pgDB)
+	if err == nil {
+		var latest uint
+		latest, err = latestSchemaVersion()
+		switch {
+		case err != nil:
+		case dirty:
+			err = fmt.Errorf("schema version %d is dirty, run 'migrate force %d' after fixing it", version, version)
+		case version > latest:
+			err = fmt.Errorf("schema version %d is newer than this build (%d)", version, latest)
+		case version < latest:
+			fmt.Printf("⚠️ Schema version %d, migrations up to %d will be applied on start\n", version, latest)
+		}
+	}
+	report.result("PostgreSQL schema version", err)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// runCommand выполняет подкоманду командной строки вместо запуска веб-сервера
+func runCommand(args []string) error {
+	switch args[0] {
+	case "snapshot":
+		return runSnapshotCommand(args[1:])
+	case "migrate":
+		return runMigrateCommand(args[1:])
+	case "loadtest":
+		return runLoadtestCommand(args[1:])
+	case "config":
+		return runConfigCommand(args[1:])
+	case "check":
+		return runCheckCommand(args[1:])
+	case "backup":
+		return runBackupCommand(args[1:])
+	case "restore":
+		return runRestoreCommand(args[1:])
+	case "import-csv":
+		return runImportCSVCommand(args[1:])
+	case "service":
+		return runServiceCommand(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// runSnapshotCommand управляет снимками в S3: создание, список, восстановление
+func runSnapshotCommand(args []string) error {
+	if len(args) == 0 {
+		key, err := createS3Snapshot()
+		if err != nil {
+			return err
+		}
+		log.Printf("✅ Snapshot created: %s", key)
+		return nil
+	}
+
+	switch args[0] {
+	case "list":
+		client, err := newS3Client()
+		if err != nil {
+			return err
+		}
+		keys, err := listS3Snapshots(context.Background(), client)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			fmt.Println(key)
+		}
+		return nil
+	case "restore":
+		key := ""
+		if len(args) > 1 {
+			key = args[1]
+		}
+		return restoreS3Snapshot(key)
+	default:
+		return fmt.Errorf("usage: snapshot [list | restore [key|latest]]")
+	}
+}
+
+// runBackupCommand сохраняет данные сервиса в локальный файл перед рискованными изменениями
+func runBackupCommand(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := fs.String("out", "perco_web_backup_"+time.Now().Format("20060102_150405")+".json.gz", "backup file, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	snap, err := writeSnapshotFile(*out)
+	if err != nil {
+		return err
+	}
+	log.Printf("💾 Backup written to %s (%d cards, %d blocklist entries, %d expiry overrides)",
+		*out, len(snap.StaffCards), len(snap.Blocklist), len(snap.ExpiryOverrides))
+	return nil
+}
+
+// runRestoreCommand заменяет данные сервиса содержимым файла, созданного командой backup
+func runRestoreCommand(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	in := fs.String("in", "", "backup file, - for stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" && fs.NArg() == 1 {
+		*in = fs.Arg(0)
+	}
+	if *in == "" {
+		return fmt.Errorf("usage: restore [--in] <file.json.gz>")
+	}
+	return restoreSnapshotFile(*in)
+}
+
+// runImportCSVCommand загружает временные пропуска из CSV файла, как POST /api/import/csv
+func runImportCSVCommand(args []string) error {
+	fs := flag.NewFlagSet("import-csv", flag.ContinueOnError)
+	mapping := fs.String("map", "", "column mapping field=column, comma separated")
+	delimiter := fs.String("delimiter", config.CSVDelimiter, "CSV delimiter")
+	dryRun := fs.Bool("dry-run", false, "only validate the file and show what would be imported")
+	skipInvalid := fs.Bool("skip-invalid", false, "import valid rows even if some rows are invalid")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: import-csv [--dry-run] [--skip-invalid] [--map field=column,...] <file.csv>")
+	}
+
+	opts := CSVImportOptions{Delimiter: *delimiter, DryRun: *dryRun, SkipInvalid: *skipInvalid, CreatedBy: "import-csv"}
+	var err error
+	if opts.Mapping, err = parseCSVMapping(*mapping); err != nil {
+		return err
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return err
+	}
+	result, err := importTemporaryCardsCSV(pgDB, f, opts)
+	if result != nil {
+		for _, e := range result.Errors {
+			fmt.Printf("line %d: %s\n", e.Line, e.Error)
+		}
+		fmt.Printf("rows=%d valid=%d new=%d updated=%d invalid=%d dry_run=%t\n",
+			result.Rows, result.Valid, result.Created, result.Updated, len(result.Errors), result.DryRun)
+	}
+	return err
+}
+
+// runMigrateCommand применяет миграции схемы, показывает текущую версию или снимает флаг dirty
+func runMigrateCommand(args []string) error {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || args[0] == "up" {
+		// Справочник типов идентификаторов нужен миграциям до создания cards
+		if err := initIdentifierTypesTable(pgDB); err != nil {
+			return err
+		}
+		return runMigrations(pgDB)
+	}
+
+	switch args[0] {
+	case "version":
+		version, dirty, err := currentSchemaVersion(pgDB)
+		if err != nil {
+			return err
+		}
+		latest, err := latestSchemaVersion()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version=%d latest=%d dirty=%t\n", version, latest, dirty)
+		return nil
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: migrate force <version>")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		m, err := newMigrator(pgDB)
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		if err := m.Force(version); err != nil {
+			return fmt.Errorf("error forcing schema version: %v", err)
+		}
+		log.Printf("📁 Schema version forced to %d", version)
+		return nil
+	default:
+		return fmt.Errorf("usage: migrate [up | version | force <version>]")
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clickHouseTablePattern имя таблицы ClickHouse, допускается с базой: db.table
+var clickHouseTablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+var clickHouseClient = &http.Client{Timeout: 60 * time.Second}
+
+// clickHouseEvent строка таблицы событий ClickHouse в формате JSONEachRow
+type clickHouseEvent struct {
+	SourceID  int64  `json:"source_id"`
+	IDStaff   int64  `json:"id_staff"`
+	EventTime string `json:"event_time"`
+	Direction int    `json:"direction"`
+	AreaID    *int64 `json:"area_id"`
+	Site      string `json:"site"`
+}
+
+// clickHouseQuery выполняет запрос через HTTP-интерфейс ClickHouse; body - данные для INSERT или nil
+func clickHouseQuery(query string, body []byte) ([]byte, error) {
+	u, err := url.Parse(config.ClickHouseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CLICKHOUSE_URL: %v", err)
+	}
+	q := u.Query()
+	q.Set("query", query)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if config.ClickHouseUser != "" {
+		req.Header.Set("X-ClickHouse-User", config.ClickHouseUser)
+		req.Header.Set("X-ClickHouse-Key", config.ClickHousePassword)
+	}
+
+	resp, err := clickHouseClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ClickHouse request error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ClickHouse response error: %v", err)
+	}
+	if resp.StatusCode >= 300 {
+		if len(data) > 512 {
+			data = data[:512]
+		}
+		return nil, fmt.Errorf("ClickHouse returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// initClickHouseTable создает таблицу событий. ReplacingMergeTree схлопывает строки, отправленные повторно
+// после сбоя, а помесячные секции совпадают с секциями events в PostgreSQL.
+func initClickHouseTable() error {
+	_, err := clickHouseQuery(`
+		CREATE TABLE IF NOT EXISTS `+config.ClickHouseTable+` (
+			source_id Int64,
+			id_staff Int64,
+			event_time DateTime,
+			direction Int8,
+			area_id Nullable(Int64),
+			site LowCardinality(String)
+		) ENGINE = ReplacingMergeTree
+		PARTITION BY toYYYYMM(event_time)
+		ORDER BY (event_time, source_id)
+	`, nil)
+	if err != nil {
+		return fmt.Errorf("error creating ClickHouse table %s: %v", config.ClickHouseTable, err)
+	}
+	return nil
+}
+
+// clickHouseWatermark возвращает наибольший source_id, уже переданный в ClickHouse; 0 - таблица пуста
+func clickHouseWatermark() (int64, error) {
+	data, err := clickHouseQuery("SELECT max(source_id) FROM "+config.ClickHouseTable+" FORMAT TabSeparated", nil)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected ClickHouse watermark %q: %v", data, err)
+	}
+	return id, nil
+}
+
+// pushEventsToClickHouse догружает в ClickHouse события PostgreSQL после последнего переданного source_id.
+// Отметка хранится в самом ClickHouse, поэтому после недоступности приемника пропущенное отправляется целиком.
+func pushEventsToClickHouse() error {
+	pgDB, err := connectPostgresRead()
+	if err != nil {
+		return fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+	lastID, err := clickHouseWatermark()
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for {
+		rows, err := pgDB.Query(`
+			SELECT source_id, id_staff, event_time, direction, area_id, COALESCE(site, '')
+			FROM events
+			WHERE source_id > $1
+			ORDER BY source_id
+			LIMIT $2
+		`, lastID, config.ClickHouseBatchSize)
+		if err != nil {
+			return fmt.Errorf("events query error: %v", err)
+		}
+
+		var body bytes.Buffer
+		enc := json.NewEncoder(&body)
+		count := 0
+		for rows.Next() {
+			var ev PassEvent
+			var site string
+			if err := rows.Scan(&ev.SourceID, &ev.IDStaff, &ev.EventTime, &ev.Direction, &ev.AreaID, &site); err != nil {
+				rows.Close()
+				return fmt.Errorf("error scanning event: %v", err)
+			}
+			if err := enc.Encode(clickHouseEvent{
+				SourceID:  ev.SourceID,
+				IDStaff:   ev.IDStaff,
+				EventTime: displayTime(ev.EventTime).Format("2006-01-02 15:04:05"),
+				Direction: ev.Direction,
+				AreaID:    ev.AreaID,
+				Site:      site,
+			}); err != nil {
+				rows.Close()
+				return err
+			}
+			lastID = ev.SourceID
+			count++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating events: %v", err)
+		}
+		if count == 0 {
+			break
+		}
+
+		if _, err := clickHouseQuery("INSERT INTO "+config.ClickHouseTable+" FORMAT JSONEachRow", body.Bytes()); err != nil {
+			return err
+		}
+		total += count
+		if count < config.ClickHouseBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Printf("📈 Sent %d passage events to ClickHouse", total)
+	}
+	return nil
+}
+
+// startClickHouseSink создает таблицу в ClickHouse и запускает периодическую передачу событий
+func startClickHouseSink() error {
+	if err := initClickHouseTable(); err != nil {
+		return err
+	}
+	log.Printf("📈 ClickHouse sink enabled: every %s to %s", config.ClickHouseInterval, config.ClickHouseTable)
+	startPeriodicJob("ClickHouse sink", config.ClickHouseInterval, pushEventsToClickHouse)
+	return nil
+}
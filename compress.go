@@ -0,0 +1,168 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressibleTypes типы ответов, которые имеет смысл сжимать; XLSX и фото уже сжаты
+var compressibleTypes = []string{"application/json", "application/x-ndjson", "text/html", "text/csv", "text/plain", "application/xml"}
+
+// compressEncoder общий интерфейс gzip и zstd для потокового сжатия
+type compressEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var (
+	gzipEncoders = sync.Pool{New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}}
+	zstdEncoders = sync.Pool{New: func() interface{} {
+		// Один поток на ответ: сжатие параллельно запросам, а не внутри одного
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return enc
+	}}
+)
+
+// acceptsEncoding проверяет, что клиент принимает кодировку (q > 0) в Accept-Encoding
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			v, err := strconv.ParseFloat(q, 64)
+			return err == nil && v > 0
+		}
+		return true
+	}
+	return false
+}
+
+// chooseEncoding выбирает сжатие по HTTP_COMPRESSION и Accept-Encoding; пустая строка - без сжатия
+func chooseEncoding(r *http.Request) string {
+	accept := r.Header.Get("Accept-Encoding")
+	if accept == "" || r.Method == http.MethodHead {
+		return ""
+	}
+	enabled := strings.Split(config.HTTPCompression, ",")
+	for _, encoding := range []string{"zstd", "gzip"} {
+		for _, e := range enabled {
+			if strings.TrimSpace(e) == encoding && acceptsEncoding(accept, encoding) {
+				return encoding
+			}
+		}
+	}
+	return ""
+}
+
+// compressResponseWriter сжимает тело ответа, если тип содержимого из compressibleTypes.
+// Решение принимается при отправке заголовков, поэтому потоковые ответы сжимаются по мере записи.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	encoder     compressEncoder
+	wroteHeader bool
+}
+
+// WriteHeader включает сжатие для подходящих ответов и отправляет заголовки
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	h := cw.Header()
+	h.Add("Vary", "Accept-Encoding")
+	if status != http.StatusNoContent && status != http.StatusNotModified && h.Get("Content-Encoding") == "" &&
+		isCompressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if cw.encoding == "zstd" {
+			cw.encoder = zstdEncoders.Get().(compressEncoder)
+		} else {
+			cw.encoder = gzipEncoders.Get().(compressEncoder)
+		}
+		cw.encoder.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+// Write пишет тело через кодировщик, если сжатие включено
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush отправляет клиенту уже сжатые данные, чтобы потоковые выгрузки не копились в буфере
+func (cw *compressResponseWriter) Flush() {
+	if cw.encoder != nil {
+		cw.encoder.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap открывает исходный ResponseWriter для http.ResponseController
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close завершает сжатый поток и возвращает кодировщик в пул
+func (cw *compressResponseWriter) close() {
+	if cw.encoder == nil {
+		return
+	}
+	cw.encoder.Close()
+	cw.encoder.Reset(nil)
+	if cw.encoding == "zstd" {
+		zstdEncoders.Put(cw.encoder)
+	} else {
+		gzipEncoders.Put(cw.encoder)
+	}
+	cw.encoder = nil
+}
+
+// isCompressible проверяет тип содержимого по списку compressibleTypes
+func isCompressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	for _, t := range compressibleTypes {
+		if strings.EqualFold(mediaType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressHandler сжимает ответы gzip или zstd согласно Accept-Encoding клиента
+func compressHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := chooseEncoding(r)
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
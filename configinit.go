@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// configSetting переменная окружения, прочитанная при загрузке конфигурации, и ее значение по умолчанию
+type configSetting struct {
+	Key     string
+	Default string
+}
+
+// configSettings все переменные в порядке чтения в loadConfig; заполняется getEnv*
+var (
+	configSettings []configSetting
+	configSeen     = make(map[string]bool)
+)
+
+// recordSetting запоминает переменную для шаблона конфигурации
+func recordSetting(key, defaultValue string) {
+	if !configSeen[key] {
+		configSeen[key] = true
+		configSettings = append(configSettings, configSetting{key, defaultValue})
+	}
+}
+
+// formatDuration выводит длительность без нулевых хвостов: 24h вместо 24h0m0s
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if d == 0 {
+		return s
+	}
+	s = strings.TrimSuffix(s, "0s")
+	return strings.TrimSuffix(s, "0m")
+}
+
+// configSections разделы шаблона конфигурации: заголовок и описания переменных.
+// Переменная, которой здесь нет, все равно попадает в шаблон в разделе "Прочие настройки".
+var configSections = []struct {
+	title    string
+	settings [][2]string
+}{
+	{"Веб-сервер и журнал", [][2]string{
+		{"PORT", "HTTP-порт сервиса"},
+		{"LOG_LEVEL", "Уровень журнала: info, warn или error"},
+		{"ADMIN_ADDR", "Служебный адрес для /metrics, /debug/pprof и административных API, например 127.0.0.1:9090; пусто - все на PORT"},
+		{"BASE_PATH", "Префикс публикации за обратным прокси, например /perco; прокси передает путь без изменений"},
+		{"TRUSTED_PROXIES", "Адреса и подсети обратных прокси через запятую, например 10.0.0.5,172.16.0.0/12; от них берется X-Forwarded-For"},
+		{"TENANTS_FILE", "JSON-файл организаций (name, config, env, api_keys); задан - сервис работает шлюзом и запускает процесс на каждую"},
+		{"TENANTS_BASE_PORT", "Первый локальный порт процессов организаций; следующие получают порты по порядку"},
+		{"TENANT_NAME", "Имя организации; задается шлюзом процессу организации, вручную не указывается"},
+	}},
+	{"Подключение к базе PERCo (Firebird)", [][2]string{
+		{"FIREBIRD_USER", "Пользователь Firebird"},
+		{"FIREBIRD_PASSWORD", "Пароль Firebird"},
+		{"FIREBIRD_HOST", "Хост сервера Firebird"},
+		{"FIREBIRD_PORT", "Порт сервера Firebird"},
+		{"FIREBIRD_DB", "Путь к базе PERCo на сервере Firebird, например C:/PERCo/SCD17K.FDB"},
+		{"FIREBIRD_charset", "Кодировка соединения: UTF8, WIN1251 или NONE (имя переменной в нижнем регистре исторически)"},
+		{"FIREBIRD_TRANSCODE", "Перекодирование строк в UTF-8: auto, win1251 или off"},
+		{"SOURCE_TIMEZONE", "Часовой пояс сервера PERCo, в котором записано время в Firebird, например Asia/Yekaterinburg; пусто - пояс сервиса"},
+		{"DISPLAY_TIMEZONE", "Часовой пояс времени в API, интерфейсе и отчетах, например Europe/Moscow; задается и сессиям PostgreSQL. Пусто - пояс сервиса"},
+		{"FIREBIRD_MAX_OPEN_CONNS", "Максимум открытых соединений с Firebird; 0 - без ограничения"},
+		{"FIREBIRD_MAX_IDLE_CONNS", "Максимум простаивающих соединений с Firebird"},
+		{"FIREBIRD_CONN_MAX_LIFETIME", "Время жизни соединения с Firebird"},
+	}},
+	{"Источник данных", [][2]string{
+		{"DEMO_MODE", "true - сгенерировать демо-сотрудников, карты и проходы без сервера PERCo (SOURCE_TYPE=demo)"},
+		{"DEMO_STAFF_COUNT", "Число демо-сотрудников"},
+		{"SOURCE_TYPE", "Источник сотрудников и карт: firebird, percoweb, csv или demo"},
+		{"PERCOWEB_URL", "Адрес PERCo-Web"},
+		{"PERCOWEB_LOGIN", "Логин PERCo-Web"},
+		{"PERCOWEB_PASSWORD", "Пароль PERCo-Web"},
+		{"PERCOWEB_TOKEN", "Готовый токен PERCo-Web вместо логина и пароля"},
+		{"PERCOWEB_PAGE_SIZE", "Размер страницы при чтении PERCo-Web"},
+		{"CSV_SOURCE_FILE", "Файл источника csv"},
+		{"CSV_DELIMITER", "Разделитель полей файла источника csv"},
+		{"VEHICLES_QUERY", "Запрос Firebird номеров автомобилей: (id_staff, номер)"},
+		{"CARD_VALID_UNTIL_COLUMN", "Колонка STAFF_CARDS со сроком действия карты; пусто - сроки задаются только локально"},
+		{"STATUS_COLUMN", "Выражение Firebird с кодом статуса карты"},
+		{"STATUS_MAPPING", "Соответствие кодов статуса меткам active/blocked/dismissed, например 0=active;1=blocked"},
+		{"EXTRA_FIELDS", "Дополнительные поля карты: \"ключ=выражение Firebird;...\""},
+		{"DISMISSAL_DATE_FIELD", "Ключ EXTRA_FIELDS с датой увольнения для /api/cards/dismissed/block"},
+		{"DISMISSAL_AUTO_BLOCK", "Вносить в стоп-лист карты уволенных до сегодняшнего дня после каждой синхронизации"},
+		{"BIRTH_DATE_FIELD", "Ключ EXTRA_FIELDS с датой рождения для отчета /api/reports/duplicates"},
+		{"SITE", "Объект (здание) по умолчанию"},
+		{"SITE_COLUMN", "Выражение Firebird с объектом, если в одной базе несколько объектов"},
+		{"ACCESS_GROUPS_QUERY", "Запрос групп доступа: (id, name)"},
+		{"ACCESS_GROUP_DOORS_QUERY", "Запрос дверей групп доступа: (group_id, door_id)"},
+		{"STAFF_ACCESS_GROUPS_QUERY", "Запрос групп доступа сотрудников: (id_staff, group_id)"},
+		{"READERS_QUERY", "Запрос считывателей: (id, name, address, door_id, door_name, controller)"},
+		{"PHOTOS_QUERY", "Запрос фотографий: (id_staff, photo)"},
+		{"SHIFTS_QUERY", "Запрос рабочих графиков: (id, name, start, end)"},
+		{"STAFF_SHIFTS_QUERY", "Запрос назначений графиков: (id_staff, shift_id)"},
+	}},
+	{"PostgreSQL", [][2]string{
+		{"TARGET_DB", "Целевая база: postgres или mysql; mysql - только сотрудники, карты, поиск и проверка доступа"},
+		{"MYSQL_DSN", "DSN MySQL/MariaDB при TARGET_DB=mysql, например user:pass@tcp(db:3306)/cards_service"},
+		{"POSTGRES_HOST", "Хост PostgreSQL"},
+		{"POSTGRES_PORT", "Порт PostgreSQL"},
+		{"POSTGRES_USER", "Пользователь PostgreSQL"},
+		{"POSTGRES_PASSWORD", "Пароль PostgreSQL"},
+		{"POSTGRES_DB", "База данных сервиса"},
+		{"POSTGRES_SSLMODE", "Режим SSL: disable, require, verify-ca или verify-full"},
+		{"POSTGRES_SCHEMA", "Схема для таблиц сервиса; создается при запуске, если ее нет"},
+		{"TABLE_PREFIX", "Префикс имен таблиц, представлений и индексов, например perco_"},
+		{"POSTGRES_READ_DSN", "DSN реплики только для чтения для поиска, статистики и отчетов (при TARGET_DB=mysql - DSN MySQL)"},
+		{"POSTGRES_MAX_OPEN_CONNS", "Максимум открытых соединений с PostgreSQL; 0 - без ограничения"},
+		{"POSTGRES_MAX_IDLE_CONNS", "Максимум простаивающих соединений с PostgreSQL"},
+		{"POSTGRES_CONN_MAX_LIFETIME", "Время жизни соединения с PostgreSQL"},
+	}},
+	{"Синхронизация", [][2]string{
+		{"INSERT_BATCH_SIZE", "Записей в одном INSERT"},
+		{"SYNC_WORKERS", "Пакетов синхронизации в памяти одновременно"},
+		{"DUPLICATE_POLICY", "Повторные идентификаторы: keep-first, keep-latest-by-staff-id или reject-and-report"},
+		{"SYNC_ANALYZE", "ANALYZE таблиц после синхронизации"},
+		{"SYNC_VACUUM", "VACUUM (ANALYZE) вместо ANALYZE после синхронизации"},
+		{"SYNC_INTERVAL", "Период автоматической синхронизации; 0 - только вручную"},
+		{"SYNC_OFF_HOURS_INTERVAL", "Период синхронизации вне рабочих часов и в выходные; 0 - как SYNC_INTERVAL"},
+		{"SYNC_WORK_HOURS", "Рабочие часы для SYNC_OFF_HOURS_INTERVAL, например 08:00-20:00"},
+		{"SYNC_WORK_DAYS", "Рабочие дни, например mon-fri или mon,wed,fri"},
+		{"SYNC_BLACKOUT", "Окна без синхронизации по расписанию через запятую, например 07:30-09:00,17:00-18:00"},
+		{"SYNC_TRIGGER_SECRET", "Секрет HMAC для запуска синхронизации через /api/sync/trigger"},
+		{"SYNC_PRE_HOOK", "Команда или http(s)-URL перед синхронизацией; ошибка отменяет синхронизацию. Данные в PERCO_SYNC_* или в JSON"},
+		{"SYNC_POST_HOOK", "Команда или http(s)-URL после каждой синхронизации с PERCO_SYNC_STATUS, _RECORDS, _RUN_ID"},
+		{"SYNC_HOOK_TIMEOUT", "Ограничение времени хука синхронизации"},
+		{"SYNC_TIMEOUT", "Ограничение времени синхронизации; 0 - без ограничения"},
+	}},
+	{"Поиск и API", [][2]string{
+		{"SEARCH_TIMEOUT", "Ограничение времени интерактивного поиска; 0 - без ограничения"},
+		{"SEARCH_TRGM_INDEXES", "Trigram-индексы для поиска (нужно расширение pg_trgm)"},
+		{"HTTP_COMPRESSION", "Сжатие ответов: gzip, zstd или оба через запятую; пусто - без сжатия"},
+		{"HTTP_READ_HEADER_TIMEOUT", "Время на получение заголовков запроса; 0 - без ограничения"},
+		{"HTTP_READ_TIMEOUT", "Время на получение всего запроса; 0 - без ограничения"},
+		{"HTTP_WRITE_TIMEOUT", "Время на отправку ответа, кроме синхронизации, выгрузок и отчетов; 0 - без ограничения"},
+		{"HTTP_IDLE_TIMEOUT", "Время простоя keep-alive соединения; 0 - как HTTP_READ_TIMEOUT"},
+		{"HTTP_MAX_HEADER_BYTES", "Максимальный размер заголовков запроса в байтах"},
+		{"HTTP_MAX_BODY_BYTES", "Максимальный размер тела запроса в байтах; 0 - без ограничения"},
+		{"STATS_CACHE_TTL", "Время жизни кэша счетчиков /api/stats; 0 - без кэша"},
+		{"DATA_STALE_AFTER", "Возраст данных, после которого ответы помечаются stale; 0 - как STATUS_MAX_SYNC_AGE"},
+		{"ANTIPASSBACK_WINDOW", "Запрет повторного входа без выхода в течение окна; 0 - отключен"},
+		{"VERIFY_FULL_CACHE_TTL", "Время кэширования ответа /api/verify/full терминалом; 0 - без кэша"},
+		{"OFFLINE_LIST_SECRET", "Ключ HMAC подписи офлайн-списка /api/offline-list; пусто - список отключен"},
+		{"OFFLINE_LIST_MAX_AGE", "Срок, после которого контроллер не должен пускать по скачанному офлайн-списку"},
+		{"OFFLINE_LIST_REFRESH", "Период пересборки офлайн-списка между синхронизациями"},
+	}},
+	{"Active Directory", [][2]string{
+		{"AD_ENABLED", "Дополнять записи атрибутами из Active Directory"},
+		{"AD_URL", "Адрес сервера LDAP"},
+		{"AD_BIND_DN", "Учетная запись для подключения"},
+		{"AD_BIND_PASSWORD", "Пароль учетной записи"},
+		{"AD_BASE_DN", "База поиска пользователей"},
+		{"AD_TAB_ATTRIBUTE", "Атрибут с табельным номером"},
+		{"AD_FILTER", "Фильтр пользователей LDAP"},
+	}},
+	{"Мониторинг", [][2]string{
+		{"STATUS_MAX_SYNC_AGE", "Возраст последней синхронизации, после которого /status сообщает о проблеме"},
+	}},
+	{"Резервное копирование в S3", [][2]string{
+		{"BACKUP_ENABLED", "Периодические снимки в S3"},
+		{"BACKUP_INTERVAL", "Период снимков"},
+		{"BACKUP_RETENTION", "Сколько снимков хранить"},
+		{"S3_ENDPOINT", "Адрес S3-совместимого хранилища"},
+		{"S3_ACCESS_KEY", "Ключ доступа S3"},
+		{"S3_SECRET_KEY", "Секретный ключ S3"},
+		{"S3_BUCKET", "Бакет для снимков"},
+		{"S3_PREFIX", "Префикс ключей снимков"},
+		{"S3_REGION", "Регион S3"},
+		{"S3_USE_SSL", "Подключаться к S3 по HTTPS"},
+	}},
+	{"Выгрузка в Google Sheets", [][2]string{
+		{"SHEETS_ENABLED", "Периодическая выгрузка в Google Sheets"},
+		{"SHEETS_CREDENTIALS_FILE", "Файл ключа сервисного аккаунта"},
+		{"SHEETS_SPREADSHEET_ID", "Идентификатор таблицы"},
+		{"SHEETS_RANGE", "Лист или диапазон для выгрузки"},
+		{"SHEETS_FILTER", "Строка поиска для отбора выгружаемых записей; пусто - все"},
+		{"SHEETS_INTERVAL", "Период выгрузки"},
+	}},
+	{"Интеграции", [][2]string{
+		{"CONNECTORS_FILE", "JSON-файл внешних контроллеров для рассылки списка карт"},
+		{"MQTT_ENABLED", "Публикация состояния в MQTT для Home Assistant"},
+		{"MQTT_BROKER", "Адрес брокера MQTT"},
+		{"MQTT_USERNAME", "Пользователь MQTT"},
+		{"MQTT_PASSWORD", "Пароль MQTT"},
+		{"MQTT_NODE_ID", "Идентификатор узла в топиках"},
+		{"MQTT_TOPIC_PREFIX", "Префикс топиков состояния"},
+		{"MQTT_DISCOVERY_PREFIX", "Префикс MQTT discovery Home Assistant"},
+		{"MQTT_INTERVAL", "Период публикации"},
+		{"WEBHOOK_URL", "Входящий webhook Slack/Mattermost для уведомлений"},
+		{"WEBHOOK_USERNAME", "Имя отправителя уведомлений"},
+		{"WEBHOOK_CHANNEL", "Канал уведомлений"},
+		{"WEBHOOK_TEMPLATES_FILE", "Файл шаблонов уведомлений"},
+		{"NOTIFY_ON_SUCCESS", "Уведомлять и об успешных синхронизациях"},
+		{"TELEGRAM_BOT_TOKEN", "Токен бота Telegram для уведомлений; пусто - не используется"},
+		{"TELEGRAM_CHAT_ID", "Чат или канал Telegram для уведомлений"},
+		{"SMTP_ADDR", "SMTP-сервер для уведомлений по почте, host:port; пусто - не используется"},
+		{"SMTP_USERNAME", "Пользователь SMTP; пусто - без авторизации"},
+		{"SMTP_PASSWORD", "Пароль SMTP"},
+		{"SMTP_FROM", "Адрес отправителя уведомлений"},
+		{"SMTP_TO", "Получатели уведомлений через запятую"},
+		{"ALERT_SYNC_FAILURES", "Оповещать после стольких неудачных синхронизаций подряд; 0 - нет"},
+		{"ALERT_DATA_MAX_AGE", "Оповещать, если данные старше, например 6h; 0 - нет"},
+		{"ALERT_VERIFY_ERROR_RATE", "Оповещать, если ошибок /api/verify за интервал проверки больше стольких процентов; 0 - нет"},
+		{"ALERT_VERIFY_MIN_REQUESTS", "Меньше запросов /api/verify за интервал - процент ошибок не оценивается"},
+		{"ALERT_CHECK_INTERVAL", "Период проверки правил оповещений"},
+		{"ALERT_COOLDOWN", "Не повторять то же оповещение чаще"},
+		{"TIMETRACKING_URL", "Адрес системы учета рабочего времени"},
+		{"TIMETRACKING_TOKEN", "Токен системы учета рабочего времени"},
+		{"TIMETRACKING_INTERVAL", "Период отправки"},
+		{"TIMETRACKING_DAYS", "За сколько дней отправлять данные"},
+		{"TIMETRACKING_RETRIES", "Повторов при ошибке отправки"},
+	}},
+	{"Выгрузка файлов", [][2]string{
+		{"EXPORT_CSV_DELIMITER", "Разделитель полей CSV-выгрузки"},
+		{"DELIVERY_URL", "Адрес доставки выгрузки: sftp://..., ftp://..., сетевая шара \\\\server\\share\\dir или smb://server/share/dir (Windows), путь к каталогу; пусто - без доставки"},
+		{"DELIVERY_FORMAT", "Формат доставляемой выгрузки"},
+		{"DELIVERY_TIME", "Время ежедневной доставки"},
+		{"DELIVERY_NAME_PATTERN", "Шаблон имени файла"},
+		{"DELIVERY_SSH_KEY_FILE", "Закрытый ключ SFTP"},
+		{"DELIVERY_SSH_HOST_KEY", "Ключ сервера SFTP для проверки, обязателен для sftp://"},
+		{"PHONEBOOK_FILE", "Файл телефонного справочника; пусто - не создается"},
+		{"PHONEBOOK_FORMAT", "Формат справочника: csv или ldif"},
+		{"PHONEBOOK_INTERVAL", "Период обновления справочника"},
+		{"PHONEBOOK_LDIF_BASE", "Базовый DN записей LDIF"},
+	}},
+	{"События проходов", [][2]string{
+		{"EVENTS_ENABLED", "Загрузка событий проходов из Firebird"},
+		{"EVENTS_INTERVAL", "Период загрузки событий"},
+		{"EVENTS_QUERY", "Запрос событий: (id, id_staff, время, направление, зона) после id ?"},
+		{"EVENTS_RETENTION_MONTHS", "Сколько месяцев событий держать в секциях events; 0 - все"},
+		{"OCCUPANCY_MAX_AGE", "Через сколько вход без выхода перестает учитываться в /api/occupancy; 0 - никогда"},
+		{"ZONE_NAMES", "Названия зон PERCo для /api/occupancy: \"1=Главный корпус;2=Склад\""},
+		{"EVENTS_DEDUP_WINDOW", "Окно, в котором повторы события (сотрудник, направление, зона) не загружаются, например 10s; 0 - все"},
+		{"CLICKHOUSE_URL", "HTTP-интерфейс ClickHouse для копии событий, например http://clickhouse:8123/?database=perco; пусто - не используется"},
+		{"CLICKHOUSE_USER", "Пользователь ClickHouse"},
+		{"CLICKHOUSE_PASSWORD", "Пароль ClickHouse"},
+		{"CLICKHOUSE_TABLE", "Таблица событий в ClickHouse; создается при запуске"},
+		{"CLICKHOUSE_INTERVAL", "Период передачи событий в ClickHouse"},
+		{"CLICKHOUSE_BATCH_SIZE", "Событий в одном INSERT в ClickHouse"},
+	}},
+	{"Учет рабочего времени", [][2]string{
+		{"WORK_DAY_START", "Начало рабочего дня"},
+		{"WORK_DAY_END", "Конец рабочего дня"},
+		{"WORK_GRACE", "Допустимое опоздание"},
+		{"WORK_LUNCH_DURATION", "Длительность обеда, вычитаемая из отработанного времени"},
+		{"WORK_LUNCH_AFTER", "Обед вычитается, если отработано больше"},
+		{"WORK_DAY_BOUNDARY", "Граница суток для ночных смен"},
+		{"WORK_NIGHT_START", "Начало ночного времени"},
+		{"WORK_NIGHT_END", "Конец ночного времени"},
+		{"HOLIDAYS_URL", "Производственный календарь в формате xmlcalendar.ru, {year} заменяется на год"},
+	}},
+}
+
+// writeConfigTemplate пишет закомментированный шаблон .env со всеми переменными и значениями по умолчанию
+func writeConfigTemplate(w io.Writer) error {
+	defaults := make(map[string]string, len(configSettings))
+	for _, s := range configSettings {
+		defaults[s.Key] = s.Default
+	}
+	// Многострочные значения по умолчанию (запросы) записываются одной строкой
+	value := func(key string) string {
+		v := strings.Join(strings.Fields(defaults[key]), " ")
+		if strings.ContainsAny(v, " #\"'") {
+			return `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+		}
+		return v
+	}
+
+	fmt.Fprintf(w, "# Конфигурация perco_web %s\n", version)
+	fmt.Fprintf(w, "# Раскомментируйте и измените нужные строки; остальные берут значение по умолчанию.\n")
+	written := make(map[string]bool)
+	for _, section := range configSections {
+		fmt.Fprintf(w, "\n# === %s ===\n", section.title)
+		for _, s := range section.settings {
+			fmt.Fprintf(w, "\n# %s\n#%s=%s\n", s[1], s[0], value(s[0]))
+			written[s[0]] = true
+		}
+	}
+	var rest []string
+	for _, s := range configSettings {
+		if !written[s.Key] {
+			rest = append(rest, s.Key)
+		}
+	}
+	if len(rest) > 0 {
+		fmt.Fprintf(w, "\n# === Прочие настройки ===\n")
+		for _, key := range rest {
+			fmt.Fprintf(w, "\n#%s=%s\n", key, value(key))
+		}
+	}
+	return nil
+}
+
+// runConfigCommand выполняет подкоманды config
+func runConfigCommand(args []string) error {
+	if len(args) == 0 || args[0] != "init" {
+		return fmt.Errorf("usage: config init [file]")
+	}
+	if len(args) < 2 || args[1] == "-" {
+		return writeConfigTemplate(os.Stdout)
+	}
+	// Существующую конфигурацию не перезаписываем
+	f, err := os.OpenFile(args[1], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot create %s: %v", args[1], err)
+	}
+	if err := writeConfigTemplate(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✅ Configuration template written to %s\n", args[1])
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// CardConflict идентификатор, который в источнике выдан нескольким записям
+type CardConflict struct {
+	Identifier string    `json:"identifier"`
+	IDStaff    []int64   `json:"id_staff"`
+	Records    int       `json:"records"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// ConflictsReport дубликаты идентификаторов и состояние уникального индекса
+type ConflictsReport struct {
+	UniqueIndex bool           `json:"unique_index"`
+	Conflicts   []CardConflict `json:"conflicts"`
+}
+
+// initCardIndexes создает индексы поиска и пытается создать уникальный индекс по identifier.
+// Дубликаты не мешают запуску: они попадают в /api/conflicts, а индекс создается после их устранения.
+func initCardIndexes(db *sql.DB) error {
+	statements := []string{
+		`CREATE INDEX IF NOT EXISTS staff_name_idx ON staff (last_name, first_name)`,
+		`CREATE INDEX IF NOT EXISTS cards_updated_at_idx ON cards (updated_at)`,
+		`CREATE TABLE IF NOT EXISTS card_conflicts (
+			identifier TEXT NOT NULL,
+			id_staff BIGINT NOT NULL,
+			detected_at TIMESTAMP NOT NULL
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("error creating card indexes: %v", err)
+		}
+	}
+
+	_, err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS cards_identifier_unique_idx ON cards (identifier)")
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		log.Printf("⚠️ Duplicate identifiers prevent unique index on cards, see /api/conflicts")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error creating unique identifier index: %v", err)
+	}
+	return nil
+}
+
+// rowQuerier общий интерфейс *sql.DB и *sql.Tx для запросов одной строки
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// hasUniqueIdentifierIndex проверяет, создан ли уникальный индекс по identifier
+func hasUniqueIdentifierIndex(q rowQuerier) (bool, error) {
+	return targetDialect().indexExists(q, "cards_identifier_unique_idx")
+}
+
+// splitDuplicateCards отделяет повторные записи с уже встречавшимся идентификатором
+func splitDuplicateCards(cards []StaffCard) (unique, duplicates []StaffCard) {
+	seen := make(map[string]bool, len(cards))
+	for _, sc := range cards {
+		if seen[sc.Identifier] {
+			duplicates = append(duplicates, sc)
+			continue
+		}
+		seen[sc.Identifier] = true
+		unique = append(unique, sc)
+	}
+	return unique, duplicates
+}
+
+// Политики DUPLICATE_POLICY для повторных идентификаторов в источнике
+const (
+	duplicateKeepFirst  = "keep-first"              // остается первая прочитанная запись
+	duplicateKeepLatest = "keep-latest-by-staff-id" // остается запись с наибольшим id_staff
+	duplicateReject     = "reject-and-report"       // не загружается ни одна запись с идентификатором
+)
+
+// SkippedCard запись источника, не загруженная из-за повторного идентификатора
+type SkippedCard struct {
+	Identifier string `json:"identifier"`
+	IDStaff    int64  `json:"id_staff"`
+	Reason     string `json:"reason"`
+}
+
+// validateDuplicatePolicy проверяет значение DUPLICATE_POLICY
+func validateDuplicatePolicy(policy string) error {
+	switch policy {
+	case duplicateKeepFirst, duplicateKeepLatest, duplicateReject:
+		return nil
+	default:
+		return fmt.Errorf("unsupported DUPLICATE_POLICY %q, use %s, %s or %s",
+			policy, duplicateKeepFirst, duplicateKeepLatest, duplicateReject)
+	}
+}
+
+// conflictTracker находит повторные идентификаторы по мере чтения источника и сохраняет
+// все записи с ними для /api/conflicts; в памяти хранятся только идентификаторы, а не карты
+type conflictTracker struct {
+	tx         *sql.Tx
+	detectedAt string
+	first      map[string]int64 // id_staff первой записи с идентификатором
+	reported   map[string]bool  // первая запись уже сохранена как конфликтная
+	conflicts  int
+}
+
+// newConflictTracker очищает конфликты прошлой синхронизации
+func newConflictTracker(tx *sql.Tx, detectedAt string) (*conflictTracker, error) {
+	if _, err := tx.Exec("DELETE FROM card_conflicts"); err != nil {
+		return nil, fmt.Errorf("Error clearing card conflicts: %v", err)
+	}
+	return &conflictTracker{
+		tx:         tx,
+		detectedAt: detectedAt,
+		first:      make(map[string]int64),
+		reported:   make(map[string]bool),
+	}, nil
+}
+
+// check запоминает запись и возвращает true и id_staff первой записи, если идентификатор уже встречался
+func (t *conflictTracker) check(sc StaffCard) (bool, int64, error) {
+	firstStaff, seen := t.first[sc.Identifier]
+	if !seen {
+		t.first[sc.Identifier] = sc.IDStaff
+		return false, 0, nil
+	}
+	if !t.reported[sc.Identifier] {
+		if err := t.record(sc.Identifier, firstStaff); err != nil {
+			return true, firstStaff, err
+		}
+		t.reported[sc.Identifier] = true
+	}
+	return true, firstStaff, t.record(sc.Identifier, sc.IDStaff)
+}
+
+// record сохраняет одну запись с повторяющимся идентификатором
+func (t *conflictTracker) record(identifier string, idStaff int64) error {
+	_, err := t.tx.Exec("INSERT INTO card_conflicts (identifier, id_staff, detected_at) VALUES ($1, $2, $3)",
+		identifier, idStaff, t.detectedAt)
+	if err != nil {
+		return fmt.Errorf("Error recording card conflict %s: %v", identifier, err)
+	}
+	t.conflicts++
+	return nil
+}
+
+// loadConflicts возвращает дубликаты, найденные при последней синхронизации
+func loadConflicts(db *sql.DB) ([]CardConflict, error) {
+	rows, err := db.Query(`
+		SELECT identifier, array_agg(DISTINCT id_staff ORDER BY id_staff), COUNT(*), MAX(detected_at)
+		FROM card_conflicts
+		GROUP BY identifier
+		ORDER BY identifier
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("Conflicts query error: %v", err)
+	}
+	defer rows.Close()
+
+	conflicts := []CardConflict{}
+	for rows.Next() {
+		var c CardConflict
+		if err := rows.Scan(&c.Identifier, pq.Array(&c.IDStaff), &c.Records, &c.DetectedAt); err != nil {
+			return nil, fmt.Errorf("Error scanning conflict: %v", err)
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, rows.Err()
+}
+
+// conflictsHandler отдает идентификаторы, выданные в источнике нескольким записям
+func conflictsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var report ConflictsReport
+	if report.UniqueIndex, err = hasUniqueIdentifierIndex(pgDB); err != nil {
+		returnJSONError(w, fmt.Sprintf("Index check error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if report.Conflicts, err = loadConflicts(pgDB); err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	returnJSONSuccess(w, report, fmt.Sprintf("%d duplicate identifiers", len(report.Conflicts)))
+}
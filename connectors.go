@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Connector структура для описания внешнего контроллера, получающего список карт
+type Connector struct {
+	Name         string            `json:"name"`
+	URL          string            `json:"url"`
+	Method       string            `json:"method"`
+	AuthType     string            `json:"auth_type"`
+	Token        string            `json:"token"`
+	Username     string            `json:"username"`
+	Password     string            `json:"password"`
+	Headers      map[string]string `json:"headers"`
+	BatchSize    int               `json:"batch_size"`
+	BodyTemplate string            `json:"body_template"`
+
+	urlTmpl  *template.Template
+	bodyTmpl *template.Template
+}
+
+// ConnectorBatch данные одной порции, доступные в шаблонах URL и тела запроса
+type ConnectorBatch struct {
+	Connector string      `json:"connector"`
+	Batch     int         `json:"batch"`
+	Batches   int         `json:"batches"`
+	Total     int         `json:"total"`
+	Cards     []StaffCard `json:"cards"`
+}
+
+var connectors []*Connector
+
+var connectorClient = &http.Client{Timeout: 30 * time.Second}
+
+// loadConnectors читает описание контроллеров из JSON файла
+func loadConnectors(path string) ([]*Connector, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read connectors file: %v", err)
+	}
+
+	var list []*Connector
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid connectors file: %v", err)
+	}
+
+	funcs := template.FuncMap{"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	}}
+	for _, c := range list {
+		if c.URL == "" {
+			return nil, fmt.Errorf("connector %q has no url", c.Name)
+		}
+		if c.Method == "" {
+			c.Method = http.MethodPost
+		}
+		if c.BatchSize <= 0 {
+			c.BatchSize = 500
+		}
+		if c.urlTmpl, err = template.New(c.Name + " url").Parse(c.URL); err != nil {
+			return nil, fmt.Errorf("connector %q: invalid url template: %v", c.Name, err)
+		}
+		if c.BodyTemplate != "" {
+			if c.bodyTmpl, err = template.New(c.Name + " body").Funcs(funcs).Parse(c.BodyTemplate); err != nil {
+				return nil, fmt.Errorf("connector %q: invalid body template: %v", c.Name, err)
+			}
+		}
+	}
+	return list, nil
+}
+
+// push отправляет список карт на контроллер порциями по BatchSize
+func (c *Connector) push(cards []StaffCard) error {
+	batches := (len(cards) + c.BatchSize - 1) / c.BatchSize
+	for i := 0; i < batches; i++ {
+		end := (i + 1) * c.BatchSize
+		if end > len(cards) {
+			end = len(cards)
+		}
+		batch := ConnectorBatch{
+			Connector: c.Name,
+			Batch:     i + 1,
+			Batches:   batches,
+			Total:     len(cards),
+			Cards:     cards[i*c.BatchSize : end],
+		}
+		if err := c.send(batch); err != nil {
+			return fmt.Errorf("batch %d/%d: %v", batch.Batch, batches, err)
+		}
+	}
+	return nil
+}
+
+// send выполняет HTTP запрос для одной порции карт
+func (c *Connector) send(batch ConnectorBatch) error {
+	var target strings.Builder
+	if err := c.urlTmpl.Execute(&target, batch); err != nil {
+		return fmt.Errorf("url template error: %v", err)
+	}
+
+	var body bytes.Buffer
+	if c.bodyTmpl != nil {
+		if err := c.bodyTmpl.Execute(&body, batch); err != nil {
+			return fmt.Errorf("body template error: %v", err)
+		}
+	} else if err := json.NewEncoder(&body).Encode(batch); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(c.Method, target.String(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	switch c.AuthType {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case "basic":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := connectorClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("controller returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// pushToConnectors рассылает список разрешенных карт всем контроллерам
+func pushToConnectors(result *SyncResult) {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ Connectors: PostgreSQL connection error: %v", err)
+		return
+	}
+
+	cards, err := queryStaffCards(pgDB, allowedCardCondition)
+	if err != nil {
+		log.Printf("❌ Connectors: %v", err)
+		return
+	}
+
+	for _, c := range connectors {
+		if err := c.push(cards); err != nil {
+			log.Printf("❌ Connector %s push failed: %v", c.Name, err)
+			continue
+		}
+		log.Printf("📡 Connector %s: pushed %d cards", c.Name, len(cards))
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// csvImportFields поля временного пропуска, которые можно загрузить из CSV
+var csvImportFields = []string{"identifier", "identifier_type", "last_name", "first_name", "middle_name",
+	"company", "site", "valid_from", "valid_to"}
+
+// csvImportPreviewSize сколько загруженных записей возвращается в ответе
+const csvImportPreviewSize = 20
+
+// csvDateLayouts форматы дат в файлах подрядчиков
+var csvDateLayouts = []string{"2006-01-02 15:04", "2006-01-02", "02.01.2006 15:04", "02.01.2006", time.RFC3339}
+
+// CSVImportOptions параметры загрузки: соответствие колонок и режим проверки
+type CSVImportOptions struct {
+	Mapping     map[string]string // поле пропуска -> заголовок колонки файла
+	Delimiter   string
+	DryRun      bool
+	SkipInvalid bool
+	CreatedBy   string
+}
+
+// CSVImportError ошибка в строке файла
+type CSVImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// CSVImportResult итог загрузки или предварительной проверки
+type CSVImportResult struct {
+	DryRun   bool             `json:"dry_run"`
+	Rows     int              `json:"rows"`
+	Valid    int              `json:"valid"`
+	Created  int              `json:"created"`
+	Updated  int              `json:"updated"`
+	Errors   []CSVImportError `json:"errors"`
+	Preview  []TemporaryCard  `json:"preview"`
+	Imported bool             `json:"imported"`
+}
+
+// errCSVImportInvalid в файле есть ошибочные строки, а пропуск ошибок не разрешен
+var errCSVImportInvalid = errors.New("CSV file has invalid rows, fix them or import with skip_invalid")
+
+// parseCSVMapping разбирает соответствие колонок "identifier=Номер пропуска,last_name=Фамилия"
+func parseCSVMapping(s string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !ok || strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("invalid column mapping %q, use field=column", pair)
+		}
+		known := false
+		for _, f := range csvImportFields {
+			known = known || f == field
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown field %q in column mapping, use one of %s", field, strings.Join(csvImportFields, ", "))
+		}
+		mapping[field] = strings.TrimSpace(column)
+	}
+	return mapping, nil
+}
+
+// parseCSVDate разбирает дату в одном из форматов csvDateLayouts в локальном времени
+func parseCSVDate(s string) (time.Time, error) {
+	for _, layout := range csvDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD or DD.MM.YYYY with optional HH:MM", s)
+}
+
+// readCSVImport разбирает и проверяет файл и возвращает записи с номерами их строк.
+// Строки с ошибками попадают в result.Errors.
+func readCSVImport(in io.Reader, opts CSVImportOptions, result *CSVImportResult) ([]TemporaryCard, []int, error) {
+	reader := csv.NewReader(in)
+	if opts.Delimiter != "" {
+		reader.Comma = []rune(opts.Delimiter)[0]
+	}
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("CSV header error: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	// Поле без явного соответствия ищется в колонке с тем же именем
+	index := make(map[string]int)
+	for _, field := range csvImportFields {
+		column := field
+		if c, ok := opts.Mapping[field]; ok {
+			column = strings.ToLower(c)
+			if _, found := columns[column]; !found {
+				return nil, nil, fmt.Errorf("column %q mapped to %s is not in the file", c, field)
+			}
+		}
+		if i, ok := columns[column]; ok {
+			index[field] = i
+		}
+	}
+	for _, required := range []string{"identifier", "valid_to"} {
+		if _, ok := index[required]; !ok {
+			return nil, nil, fmt.Errorf("CSV file has no column for %s", required)
+		}
+	}
+
+	var cards []TemporaryCard
+	var lines []int
+	seen := make(map[string]int)
+	now := time.Now()
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("CSV line %d: %v", line, err)
+		}
+		field := func(name string) string {
+			i, ok := index[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		result.Rows++
+		fail := func(format string, args ...interface{}) {
+			result.Errors = append(result.Errors, CSVImportError{Line: line, Error: fmt.Sprintf(format, args...)})
+		}
+
+		c := TemporaryCard{
+			Identifier: field("identifier"),
+			LastName:   field("last_name"),
+			FirstName:  field("first_name"),
+			MiddleName: field("middle_name"),
+			Company:    field("company"),
+			Site:       field("site"),
+			CreatedBy:  opts.CreatedBy,
+		}
+		if c.Identifier == "" {
+			fail("empty identifier")
+			continue
+		}
+		if c.IdentifierType, err = normalizeIdentifierType(field("identifier_type")); err != nil {
+			fail("%v", err)
+			continue
+		}
+		switch c.IdentifierType {
+		case identifierPlate:
+			c.Identifier = normalizePlate(c.Identifier)
+		case identifierCard:
+			if c.Identifier, err = validateCardInput(c.Identifier); err != nil {
+				fail("%v", err)
+				continue
+			}
+		}
+		if c.Site == "" {
+			c.Site = config.Site
+		}
+
+		c.ValidFrom = now
+		if s := field("valid_from"); s != "" {
+			if c.ValidFrom, err = parseCSVDate(s); err != nil {
+				fail("valid_from: %v", err)
+				continue
+			}
+		}
+		s := field("valid_to")
+		if s == "" {
+			fail("empty valid_to")
+			continue
+		}
+		if c.ValidTo, err = parseCSVDate(s); err != nil {
+			fail("valid_to: %v", err)
+			continue
+		}
+		// Дата без времени означает пропуск до конца этого дня
+		if len(s) == len("2006-01-02") {
+			c.ValidTo = c.ValidTo.AddDate(0, 0, 1)
+		}
+		if !c.ValidTo.After(c.ValidFrom) {
+			fail("valid_to must be later than valid_from")
+			continue
+		}
+		if first, ok := seen[c.Identifier]; ok {
+			fail("identifier %s is already on line %d", c.Identifier, first)
+			continue
+		}
+		seen[c.Identifier] = line
+		cards = append(cards, c)
+		lines = append(lines, line)
+	}
+	return cards, lines, nil
+}
+
+// importTemporaryCardsCSV загружает список подрядчиков как временные пропуска.
+// Пропуск с тем же идентификатором обновляется, карта из PERCo с этим идентификатором - ошибка строки.
+// В режиме DryRun ничего не записывается; при ошибочных строках без SkipInvalid файл не загружается целиком.
+func importTemporaryCardsCSV(db *sql.DB, in io.Reader, opts CSVImportOptions) (*CSVImportResult, error) {
+	result := &CSVImportResult{DryRun: opts.DryRun, Errors: []CSVImportError{}, Preview: []TemporaryCard{}}
+	cards, lines, err := readCSVImport(in, opts, result)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("transaction error: %v", err)
+	}
+	defer tx.Rollback()
+
+	var ids []int64
+	for i, c := range cards {
+		// Идентификатор, занятый сотрудником из источника, временным пропуском не перекрывается
+		var taken bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM cards WHERE identifier = $1 AND id_staff > 0)", c.Identifier).Scan(&taken)
+		if err != nil {
+			return nil, fmt.Errorf("identifier check error: %v", err)
+		}
+		if taken {
+			result.Errors = append(result.Errors, CSVImportError{Line: lines[i],
+				Error: fmt.Sprintf("identifier %s belongs to an employee", c.Identifier)})
+			continue
+		}
+
+		var id int64
+		var created bool
+		err = tx.QueryRow(`
+			INSERT INTO temporary_cards (identifier, identifier_type, last_name, first_name, middle_name, company, site,
+				valid_from, valid_to, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (identifier) DO UPDATE SET identifier_type = EXCLUDED.identifier_type,
+				last_name = EXCLUDED.last_name, first_name = EXCLUDED.first_name, middle_name = EXCLUDED.middle_name,
+				company = EXCLUDED.company, site = EXCLUDED.site, valid_from = EXCLUDED.valid_from,
+				valid_to = EXCLUDED.valid_to
+			RETURNING id, xmax = 0
+		`, c.Identifier, c.IdentifierType, c.LastName, c.FirstName, c.MiddleName, c.Company, c.Site,
+			c.ValidFrom, c.ValidTo, c.CreatedBy).Scan(&id, &created)
+		if err != nil {
+			return nil, fmt.Errorf("error saving temporary card %s: %v", c.Identifier, err)
+		}
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+		c.ID = id
+		ids = append(ids, id)
+		if len(result.Preview) < csvImportPreviewSize {
+			result.Preview = append(result.Preview, c)
+		}
+	}
+	result.Valid = len(ids)
+
+	if opts.DryRun {
+		return result, nil
+	}
+	if len(result.Errors) > 0 && !opts.SkipInvalid {
+		return result, errCSVImportInvalid
+	}
+
+	// Строки измененных пропусков в staff_cards пересоздаются, чтобы сразу отразить новые данные
+	for _, table := range []string{"cards", "staff"} {
+		for _, id := range ids {
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE id_staff = $1", -id); err != nil {
+				return nil, fmt.Errorf("error refreshing temporary cards: %v", err)
+			}
+		}
+	}
+	if _, err := tx.Exec(temporaryCardsInsert, time.Now().Format("2006-01-02 15:04:05"), nil); err != nil {
+		return nil, fmt.Errorf("error adding temporary cards: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing import: %v", err)
+	}
+	result.Imported = true
+	log.Printf("🎫 Imported %d temporary cards from CSV (%d new, %d updated, %d rows skipped)",
+		result.Valid, result.Created, result.Updated, len(result.Errors))
+	return result, nil
+}
+
+// csvImportOptionsFromQuery читает параметры загрузки из строки запроса
+func csvImportOptionsFromQuery(q url.Values) (CSVImportOptions, error) {
+	opts := CSVImportOptions{
+		Delimiter:   q.Get("delimiter"),
+		DryRun:      q.Get("dry_run") == "true",
+		SkipInvalid: q.Get("skip_invalid") == "true",
+	}
+	if opts.Delimiter == "" {
+		opts.Delimiter = config.CSVDelimiter
+	}
+	var err error
+	opts.Mapping, err = parseCSVMapping(q.Get("map"))
+	return opts, err
+}
+
+// csvImportHandler загружает временные пропуска из CSV в теле запроса.
+// ?dry_run=true только проверяет файл и показывает, что будет загружено.
+func csvImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	opts, err := csvImportOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.CreatedBy = requestActor(r)
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	result, err := importTemporaryCardsCSV(pgDB, r.Body, opts)
+	if errors.Is(err, errCSVImportInvalid) {
+		// Ошибки по строкам нужны в ответе, чтобы исправить файл
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(APIResponse{Success: false, Error: err.Error(), Data: result})
+		return
+	}
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	message := fmt.Sprintf("Imported %d of %d rows", result.Valid, result.Rows)
+	if opts.DryRun {
+		message = fmt.Sprintf("Dry run: %d of %d rows can be imported", result.Valid, result.Rows)
+	}
+	returnJSONSuccess(w, result, message)
+}
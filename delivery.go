@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// deliveryFileName формирует имя файла по шаблону DELIVERY_NAME_PATTERN
+func deliveryFileName(now time.Time) (string, error) {
+	t, err := template.New("name").Parse(config.DeliveryNamePattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid DELIVERY_NAME_PATTERN: %v", err)
+	}
+
+	var name strings.Builder
+	err = t.Execute(&name, map[string]string{
+		"Date":     now.Format("20060102"),
+		"Time":     now.Format("150405"),
+		"Ext":      config.DeliveryFormat,
+		"Database": config.PostgresDB,
+	})
+	return name.String(), err
+}
+
+// deliveryHostKey разбирает DELIVERY_SSH_HOST_KEY; без ключа SFTP не подключается,
+// чтобы выгрузку с персональными данными нельзя было перехватить подменой сервера
+func deliveryHostKey() (ssh.PublicKey, error) {
+	if config.DeliverySSHHostKey == "" {
+		return nil, fmt.Errorf("DELIVERY_SSH_HOST_KEY is required for sftp:// delivery, e.g. a key line from ssh-keyscan <host> without the host name")
+	}
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(config.DeliverySSHHostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DELIVERY_SSH_HOST_KEY: %v", err)
+	}
+	return hostKey, nil
+}
+
+// uploadSFTP загружает файл на SFTP сервер
+func uploadSFTP(target *url.URL, name string, data []byte) error {
+	sshConfig := &ssh.ClientConfig{
+		User:    target.User.Username(),
+		Timeout: 30 * time.Second,
+	}
+	if password, ok := target.User.Password(); ok {
+		sshConfig.Auth = append(sshConfig.Auth, ssh.Password(password))
+	}
+	if config.DeliverySSHKeyFile != "" {
+		key, err := os.ReadFile(config.DeliverySSHKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read SSH key: %v", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return fmt.Errorf("invalid SSH key: %v", err)
+		}
+		sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeys(signer))
+	}
+	hostKey, err := deliveryHostKey()
+	if err != nil {
+		return err
+	}
+	sshConfig.HostKeyCallback = ssh.FixedHostKey(hostKey)
+
+	host := target.Host
+	if target.Port() == "" {
+		host = net.JoinHostPort(target.Hostname(), "22")
+	}
+	conn, err := ssh.Dial("tcp", host, sshConfig)
+	if err != nil {
+		return fmt.Errorf("SSH connection error: %v", err)
+	}
+	defer conn.Close()
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		return fmt.Errorf("SFTP session error: %v", err)
+	}
+	defer client.Close()
+
+	// Пишем во временный файл и переименовываем, чтобы потребитель не прочитал его наполовину
+	remote := path.Join(target.Path, name)
+	tmp := remote + ".part"
+	f, err := client.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("SFTP create error: %v", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("SFTP write error: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("SFTP close error: %v", err)
+	}
+	client.Remove(remote)
+	if err := client.Rename(tmp, remote); err != nil {
+		return fmt.Errorf("SFTP rename error: %v", err)
+	}
+	return nil
+}
+
+// uploadFTP загружает файл на FTP сервер
+func uploadFTP(target *url.URL, name string, data []byte) error {
+	host := target.Host
+	if target.Port() == "" {
+		host = net.JoinHostPort(target.Hostname(), "21")
+	}
+	conn, err := ftp.Dial(host, ftp.DialWithTimeout(30*time.Second))
+	if err != nil {
+		return fmt.Errorf("FTP connection error: %v", err)
+	}
+	defer conn.Quit()
+
+	password, _ := target.User.Password()
+	if err := conn.Login(target.User.Username(), password); err != nil {
+		return fmt.Errorf("FTP login error: %v", err)
+	}
+
+	remote := path.Join(target.Path, name)
+	tmp := remote + ".part"
+	if err := conn.Stor(tmp, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("FTP upload error: %v", err)
+	}
+	conn.Delete(remote)
+	if err := conn.Rename(tmp, remote); err != nil {
+		return fmt.Errorf("FTP rename error: %v", err)
+	}
+	return nil
+}
+
+// deliveryShareDir каталог для записи выгрузки напрямую: UNC-путь \\server\share\dir, smb://server/share/dir
+// (в Windows превращается в UNC-путь, доступ к шаре - правами учетной записи службы), file:///path или
+// абсолютный путь, например смонтированная через CIFS шара в Linux. Для SFTP/FTP возвращает пустую строку.
+func deliveryShareDir(raw string) (string, error) {
+	if strings.HasPrefix(raw, `\\`) && runtime.GOOS != "windows" {
+		return "", fmt.Errorf("DELIVERY_URL: UNC paths are only supported on Windows; mount the share (mount -t cifs) and set DELIVERY_URL to the mount path")
+	}
+	if strings.HasPrefix(raw, `\\`) || filepath.IsAbs(raw) {
+		return raw, nil
+	}
+	target, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid DELIVERY_URL: %v", err)
+	}
+	switch target.Scheme {
+	case "file":
+		if target.Host != "" && runtime.GOOS == "windows" {
+			return `\\` + target.Host + filepath.FromSlash(target.Path), nil
+		}
+		return filepath.FromSlash(target.Path), nil
+	case "smb":
+		if target.User != nil {
+			return "", fmt.Errorf("DELIVERY_URL: smb credentials are not supported, grant the service account write access to the share")
+		}
+		if runtime.GOOS != "windows" {
+			return "", fmt.Errorf("DELIVERY_URL: smb:// is only supported on Windows; mount the share (mount -t cifs) and set DELIVERY_URL to the mount path")
+		}
+		return `\\` + target.Host + filepath.FromSlash(target.Path), nil
+	}
+	return "", nil
+}
+
+// writeShareFile записывает файл в каталог на сетевой шаре или диске через временный файл,
+// чтобы скрипты, забирающие выгрузку, не прочитали ее наполовину
+func writeShareFile(dir, name string, data []byte) error {
+	remote := filepath.Join(dir, name)
+	tmp := remote + ".part"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("share write error: %v", err)
+	}
+	if err := os.Rename(tmp, remote); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("share rename error: %v", err)
+	}
+	return nil
+}
+
+// deliverExport формирует выгрузку карт и загружает ее на SFTP/FTP или записывает на сетевую шару
+func deliverExport() error {
+	shareDir, err := deliveryShareDir(config.DeliveryURL)
+	if err != nil {
+		return err
+	}
+	var target *url.URL
+	if shareDir == "" {
+		if target, err = url.Parse(config.DeliveryURL); err != nil {
+			return fmt.Errorf("invalid DELIVERY_URL: %v", err)
+		}
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+
+	cards, err := queryStaffCards(pgDB, "")
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeExport(&buf, config.DeliveryFormat, cards); err != nil {
+		return err
+	}
+
+	name, err := deliveryFileName(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if shareDir != "" {
+		if err := writeShareFile(shareDir, name, buf.Bytes()); err != nil {
+			return err
+		}
+		log.Printf("📤 Export written to %s (%d cards)", filepath.Join(shareDir, name), len(cards))
+		return nil
+	}
+
+	switch target.Scheme {
+	case "sftp":
+		err = uploadSFTP(target, name, buf.Bytes())
+	case "ftp":
+		err = uploadFTP(target, name, buf.Bytes())
+	default:
+		err = fmt.Errorf("unsupported DELIVERY_URL scheme %q, use sftp, ftp, smb, file or a share path", target.Scheme)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("📤 Export delivered to %s://%s%s (%d cards)", target.Scheme, target.Host, path.Join(target.Path, name), len(cards))
+	return nil
+}
+
+// startExportDelivery запускает ежедневную доставку выгрузки
+func startExportDelivery() error {
+	log.Printf("📤 Export delivery enabled: daily at %s, format %s", config.DeliveryTime, config.DeliveryFormat)
+	return startDailyJob("Export delivery", config.DeliveryTime, deliverExport)
+}
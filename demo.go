@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// demoSeed начальное значение генератора: демо-данные одинаковы при каждом запуске
+const demoSeed = 20240101
+
+// Словари для генерации демо-сотрудников
+var (
+	demoLastNames   = []string{"Иванов", "Петров", "Сидоров", "Смирнов", "Кузнецов", "Попов", "Соколов", "Лебедев", "Козлов", "Новиков", "Морозов", "Волков", "Алексеев", "Федоров", "Семенов", "Егоров"}
+	demoFirstNames  = []string{"Александр", "Дмитрий", "Максим", "Сергей", "Андрей", "Алексей", "Артем", "Илья", "Кирилл", "Михаил", "Никита", "Роман"}
+	demoMiddleNames = []string{"Александрович", "Дмитриевич", "Сергеевич", "Андреевич", "Алексеевич", "Михайлович", "Иванович", "Петрович"}
+	demoPositions   = []string{"Инженер", "Мастер участка", "Оператор станка", "Кладовщик", "Бухгалтер", "Водитель", "Электромонтер", "Начальник смены"}
+	demoDepartments = []Department{
+		{ID: 1, Name: "Завод"},
+		{ID: 2, Name: "Администрация", ParentID: demoInt64(1)},
+		{ID: 3, Name: "Бухгалтерия", ParentID: demoInt64(2)},
+		{ID: 4, Name: "Производство", ParentID: demoInt64(1)},
+		{ID: 5, Name: "Механический цех", ParentID: demoInt64(4)},
+		{ID: 6, Name: "Сборочный цех", ParentID: demoInt64(4)},
+		{ID: 7, Name: "Склад", ParentID: demoInt64(1)},
+		{ID: 8, Name: "Транспортный участок", ParentID: demoInt64(1)},
+	}
+	demoReaders = []Reader{
+		{ID: 1, Name: "Турникет 1 вход", Address: "10.0.0.11", DoorID: demoInt64(1), DoorName: "Проходная", Controller: "PERCo-CT/L04"},
+		{ID: 2, Name: "Турникет 1 выход", Address: "10.0.0.11", DoorID: demoInt64(1), DoorName: "Проходная", Controller: "PERCo-CT/L04"},
+		{ID: 3, Name: "Склад", Address: "10.0.0.21", DoorID: demoInt64(2), DoorName: "Склад", Controller: "PERCo-CL201"},
+		{ID: 4, Name: "Шлагбаум", Address: "10.0.0.31", DoorID: demoInt64(3), DoorName: "Въезд", Controller: "PERCo-CL15"},
+	}
+)
+
+// demoInt64 возвращает указатель на число для литералов демо-данных
+func demoInt64(v int64) *int64 {
+	return &v
+}
+
+// demoSource генерирует сотрудников, подразделения и считыватели для DEMO_MODE вместо базы PERCo
+type demoSource struct{}
+
+func init() {
+	registerSource("demo", func() SourceConnector { return demoSource{} })
+}
+
+// Name возвращает имя источника
+func (demoSource) Name() string {
+	return "Demo"
+}
+
+// FetchStaffCards генерирует DEMO_STAFF_COUNT сотрудников с картами; у части есть номер автомобиля
+func (demoSource) FetchStaffCards(ctx context.Context, emit func(StaffCard) error) error {
+	rnd := rand.New(rand.NewSource(demoSeed))
+	pick := func(list []string) *string {
+		s := list[rnd.Intn(len(list))]
+		return &s
+	}
+	site := config.Site
+
+	count := 0
+	for i := 1; i <= config.DemoStaffCount; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		department := demoDepartments[1+rnd.Intn(len(demoDepartments)-1)]
+		tab := strconv.Itoa(1000 + i)
+		status := "active"
+		switch n := rnd.Intn(20); {
+		case n == 0:
+			status = "blocked"
+		case n == 1:
+			status = "dismissed"
+		}
+		sc := StaffCard{
+			IDStaff:        int64(i),
+			Identifier:     strconv.Itoa(1000000 + rnd.Intn(9000000)),
+			IdentifierType: identifierCard,
+			TabNumber:      &tab,
+			LastName:       pick(demoLastNames),
+			FirstName:      pick(demoFirstNames),
+			MiddleName:     pick(demoMiddleNames),
+			Position:       pick(demoPositions),
+			DepartmentID:   &department.ID,
+			Department:     &department.Name,
+			Status:         &status,
+		}
+		if site != "" {
+			sc.Site = &site
+		}
+		if rnd.Intn(10) == 0 {
+			until := time.Now().AddDate(0, 0, rnd.Intn(60)-10)
+			sc.ValidUntil = &until
+		}
+		if err := emit(sc); err != nil {
+			return err
+		}
+		count++
+
+		if rnd.Intn(8) == 0 {
+			plate := normalizePlate(fmt.Sprintf("А%03dВС%d", rnd.Intn(1000), 77+rnd.Intn(3)*100))
+			car := sc
+			car.Identifier, car.IdentifierType, car.ValidUntil = plate, identifierPlate, nil
+			if err := emit(car); err != nil {
+				return err
+			}
+			count++
+		}
+	}
+
+	log.Printf("📥 Generated %d demo records", count)
+	return nil
+}
+
+// FetchDepartments возвращает демо-оргструктуру
+func (demoSource) FetchDepartments(ctx context.Context) ([]Department, error) {
+	return demoDepartments, nil
+}
+
+// FetchReaders возвращает демо-считыватели
+func (demoSource) FetchReaders(ctx context.Context) ([]Reader, error) {
+	return demoReaders, nil
+}
+
+// seedDemoData заполняет пустую базу демо-данными: синхронизация из demoSource и проходы за две недели
+func seedDemoData(pgDB *sql.DB) error {
+	var cards int
+	if err := pgDB.QueryRow("SELECT COUNT(*) FROM staff_cards").Scan(&cards); err != nil {
+		return fmt.Errorf("error counting cards: %v", err)
+	}
+	if cards == 0 {
+		log.Println("🧪 Demo mode: generating sample staff and cards...")
+		if _, err := runSync(); err != nil {
+			return err
+		}
+	}
+
+	var events int
+	if err := pgDB.QueryRow("SELECT COUNT(*) FROM events").Scan(&events); err != nil {
+		return fmt.Errorf("error counting events: %v", err)
+	}
+	if events > 0 {
+		return nil
+	}
+	return seedDemoEvents(pgDB)
+}
+
+// seedDemoEvents генерирует входы и выходы демо-сотрудников по рабочим дням за последние 14 дней
+func seedDemoEvents(pgDB *sql.DB) error {
+	rnd := rand.New(rand.NewSource(demoSeed))
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -14)
+
+	tx, err := pgDB.Begin()
+	if err != nil {
+		return fmt.Errorf("transaction error: %v", err)
+	}
+	defer tx.Rollback()
+
+	for t := from; !t.After(now); t = t.AddDate(0, 1, 0) {
+		if err := ensureEventPartition(tx, t); err != nil {
+			return err
+		}
+	}
+	if err := ensureEventPartition(tx, now); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO events (source_id, id_staff, event_time, direction, area_id, site)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
+
+	sourceID := int64(0)
+	insert := func(idStaff int64, at time.Time, direction int) error {
+		if at.After(now) {
+			return nil
+		}
+		sourceID++
+		_, err := stmt.Exec(sourceID, idStaff, at, direction, 1, config.Site)
+		return err
+	}
+	for day := from; day.Before(now); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		for id := int64(1); id <= int64(config.DemoStaffCount); id++ {
+			// Примерно каждый десятый сотрудник в этот день отсутствует
+			if rnd.Intn(10) == 0 {
+				continue
+			}
+			in := day.Add(7*time.Hour + 30*time.Minute + time.Duration(rnd.Intn(90))*time.Minute)
+			out := day.Add(16*time.Hour + 30*time.Minute + time.Duration(rnd.Intn(120))*time.Minute)
+			if err := insert(id, in, directionIn); err != nil {
+				return fmt.Errorf("error inserting demo event: %v", err)
+			}
+			if err := insert(id, out, directionOut); err != nil {
+				return fmt.Errorf("error inserting demo event: %v", err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing demo events: %v", err)
+	}
+	log.Printf("🧪 Demo mode: generated %d passage events", sourceID)
+
+	if err := updatePassageStateFromEvents(pgDB, from); err != nil {
+		return err
+	}
+	return updateStaffLastSeenFromEvents(pgDB, from)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Department подразделение из дерева оргструктуры PERCo
+type Department struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	ParentID *int64 `json:"parent_id"`
+	Path     string `json:"path,omitempty"`
+	Depth    int    `json:"depth,omitempty"`
+	Staff    int    `json:"staff"`
+}
+
+// DepartmentSource источник, который умеет отдавать дерево подразделений
+type DepartmentSource interface {
+	FetchDepartments(ctx context.Context) ([]Department, error)
+}
+
+// initDepartmentsTable создает таблицу подразделений и представление с полными путями
+func initDepartmentsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS departments (
+			id BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			parent_id BIGINT
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating departments table: %v", err)
+	}
+
+	// ancestors содержит id самого подразделения и всех вышестоящих, что позволяет
+	// одним условием выбрать подразделение вместе с вложенными
+	_, err = db.Exec(`
+		CREATE OR REPLACE VIEW department_paths AS
+		WITH RECURSIVE tree AS (
+			SELECT id, name, parent_id, name::TEXT AS path, 1 AS depth, ARRAY[id] AS ancestors
+			FROM departments
+			WHERE parent_id IS NULL OR parent_id NOT IN (SELECT id FROM departments)
+			UNION ALL
+			SELECT d.id, d.name, d.parent_id, t.path || ' / ' || d.name, t.depth + 1, t.ancestors || d.id
+			FROM departments d
+			JOIN tree t ON d.parent_id = t.id
+			WHERE NOT d.id = ANY(t.ancestors)
+		)
+		SELECT id, name, parent_id, path, depth, ancestors FROM tree
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating department_paths view: %v", err)
+	}
+	return nil
+}
+
+// fetchDepartments получает дерево подразделений, если источник его поддерживает
+func fetchDepartments(ctx context.Context) ([]Department, error) {
+	source, err := newSourceConnector()
+	if err != nil {
+		return nil, err
+	}
+	ds, ok := source.(DepartmentSource)
+	if !ok {
+		return nil, nil
+	}
+	return ds.FetchDepartments(ctx)
+}
+
+// replaceDepartments перезаписывает таблицу подразделений в рамках транзакции синхронизации
+func replaceDepartments(tx *sql.Tx, departments []Department) error {
+	if _, err := tx.Exec("DELETE FROM departments"); err != nil {
+		return fmt.Errorf("Error clearing departments: %v", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO departments (id, name, parent_id) VALUES ($1, $2, $3)")
+	if err != nil {
+		return fmt.Errorf("Error preparing departments statement: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, d := range departments {
+		if _, err := stmt.Exec(d.ID, d.Name, d.ParentID); err != nil {
+			return fmt.Errorf("Error inserting department %d: %v", d.ID, err)
+		}
+	}
+	log.Printf("🏢 Synced %d departments", len(departments))
+	return nil
+}
+
+// departmentsHandler возвращает плоский список подразделений с путями и числом сотрудников
+func departmentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	departments, err := loadDepartments(pgDB)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	returnJSONSuccess(w, departments, fmt.Sprintf("%d departments", len(departments)))
+}
+
+// loadDepartments читает дерево подразделений в порядке полного пути
+func loadDepartments(db *sql.DB) ([]Department, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.name, p.parent_id, p.path, p.depth,
+			(SELECT COUNT(DISTINCT sc.id_staff) FROM staff_cards sc WHERE sc.department_id = p.id)
+		FROM department_paths p
+		ORDER BY p.path
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("Departments query error: %v", err)
+	}
+	defer rows.Close()
+
+	departments := []Department{}
+	for rows.Next() {
+		var d Department
+		if err := rows.Scan(&d.ID, &d.Name, &d.ParentID, &d.Path, &d.Depth, &d.Staff); err != nil {
+			return nil, fmt.Errorf("Error scanning department: %v", err)
+		}
+		departments = append(departments, d)
+	}
+	return departments, rows.Err()
+}
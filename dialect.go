@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// sqlDialect различия SQL целевой базы: драйвер, миграции, upsert и служебные запросы
+type sqlDialect struct {
+	name   string
+	driver string
+	// migrations каталог встроенных миграций схемы
+	migrations string
+	// extended поддерживаются возможности PostgreSQL: секции событий, JSONB, рекурсивные представления,
+	// журналы и отчеты; без них доступны синхронизация сотрудников и карт, поиск и проверка доступа
+	extended bool
+}
+
+var (
+	postgresDialect = &sqlDialect{name: "postgres", driver: "postgres", migrations: "migrations", extended: true}
+	mysqlDialect    = &sqlDialect{name: "mysql", driver: "mysql-numbered", migrations: "migrations/mysql"}
+)
+
+func init() {
+	// Запросы сервиса написаны с параметрами $1, $2... в стиле PostgreSQL; для MySQL они заменяются на ?
+	sql.Register("mysql-numbered", rewritingDriver{mysql.MySQLDriver{}, func(query string) (string, []int) {
+		return rebindPlaceholders(prefixTables(query))
+	}})
+	sql.Register("postgres-prefixed", rewritingDriver{&pq.Driver{}, func(query string) (string, []int) {
+		return prefixTables(query), nil
+	}})
+}
+
+// targetDialect возвращает диалект базы, выбранной в TARGET_DB
+func targetDialect() *sqlDialect {
+	if config.TargetDB == "mysql" {
+		return mysqlDialect
+	}
+	return postgresDialect
+}
+
+// upsert возвращает окончание INSERT для строки, которая уже есть по ключу conflict:
+// перечисленные колонки update перезаписываются, без них строка остается прежней
+func (d *sqlDialect) upsert(conflict string, update ...string) string {
+	if d.extended {
+		if len(update) == 0 {
+			return " ON CONFLICT (" + conflict + ") DO NOTHING"
+		}
+		sets := make([]string, len(update))
+		for i, col := range update {
+			sets[i] = col + " = EXCLUDED." + col
+		}
+		return " ON CONFLICT (" + conflict + ") DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+
+	// MySQL сам находит нарушенный уникальный ключ; присваивание колонке ключа самой себе ничего не меняет
+	if len(update) == 0 {
+		first, _, _ := strings.Cut(conflict, ",")
+		first = strings.TrimSpace(first)
+		return " ON DUPLICATE KEY UPDATE " + first + " = " + first
+	}
+	sets := make([]string, len(update))
+	for i, col := range update {
+		sets[i] = col + " = VALUES(" + col + ")"
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+// caseInsensitive заменяет ILIKE на LIKE для MySQL, где сравнение строк по умолчанию без учета регистра
+func (d *sqlDialect) caseInsensitive(condition string) string {
+	if d.extended {
+		return condition
+	}
+	return strings.ReplaceAll(condition, " ILIKE ", " LIKE ")
+}
+
+// staffCardColumns список колонок выборки карт; без справочников PostgreSQL их поля пустые
+func (d *sqlDialect) staffCardColumns() string {
+	if d.extended {
+		return staffCardColumns
+	}
+	return `id_staff, identifier, identifier_type, tab_number, site, wiegand_facility, wiegand_number, last_name, first_name, middle_name, status, info,
+	email, phone, ad_account, department_id, NULL AS department,
+	position, valid_from, valid_until,
+	identifier IN (SELECT identifier FROM blocklist) AS blocklisted,
+	'[]' AS access_groups, CAST(extra_fields AS CHAR), NULL AS last_seen,
+	NULL AS notes, '[]' AS tags, NULL AS custodian, '[]' AS staff_tags`
+}
+
+// tryLockQuery запрос неблокирующего захвата сессионной блокировки по ключу $1; возвращает true при успехе
+func (d *sqlDialect) tryLockQuery() string {
+	if d.extended {
+		return "SELECT pg_try_advisory_lock($1)"
+	}
+	return "SELECT GET_LOCK(CONCAT('perco_web_', $1), 0) = 1"
+}
+
+// unlockQuery запрос освобождения блокировки tryLockQuery
+func (d *sqlDialect) unlockQuery() string {
+	if d.extended {
+		return "SELECT pg_advisory_unlock($1)"
+	}
+	return "SELECT RELEASE_LOCK(CONCAT('perco_web_', $1))"
+}
+
+// tableExists проверяет, создана ли таблица name
+func (d *sqlDialect) tableExists(q rowQuerier, name string) (bool, error) {
+	var exists bool
+	if d.extended {
+		err := q.QueryRow("SELECT to_regclass($1) IS NOT NULL", prefixedName(name)).Scan(&exists)
+		return exists, err
+	}
+	err := q.QueryRow(`SELECT COUNT(*) > 0 FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name = $1`, prefixedName(name)).Scan(&exists)
+	return exists, err
+}
+
+// indexExists проверяет, создан ли индекс с именем name
+func (d *sqlDialect) indexExists(q rowQuerier, name string) (bool, error) {
+	var exists bool
+	if d.extended {
+		err := q.QueryRow("SELECT to_regclass($1) IS NOT NULL", prefixedName(name)).Scan(&exists)
+		return exists, err
+	}
+	err := q.QueryRow(`SELECT COUNT(*) > 0 FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND index_name = $1`, prefixedName(name)).Scan(&exists)
+	return exists, err
+}
+
+// mysqlConnString дополняет MYSQL_DSN параметрами, без которых сервис не работает:
+// разбор TIMESTAMP в time.Time, несколько операторов в файле миграции и время в поясе отображения
+func mysqlConnString(dsn string) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("invalid MYSQL_DSN: %v", err)
+	}
+	cfg.ParseTime = true
+	cfg.MultiStatements = true
+	cfg.Loc = displayLocation()
+	return cfg.FormatDSN(), nil
+}
+
+// openMySQLPool открывает пул MySQL/MariaDB с ограничениями POSTGRES_MAX_* и POSTGRES_CONN_MAX_LIFETIME
+func openMySQLPool(dsn string) (*sql.DB, error) {
+	connStr, err := mysqlConnString(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return openPool(mysqlDialect.driver, connStr, config.PostgresMaxOpenConns, config.PostgresMaxIdleConns,
+		config.PostgresConnMaxLifetime)
+}
+
+// rewritingDriver оборачивает драйвер базы и переписывает текст каждого запроса функцией rewrite.
+// rewrite возвращает номера исходных параметров $N в порядке появления ? в новом тексте
+// или nil, если параметры остались прежними.
+type rewritingDriver struct {
+	driver.Driver
+	rewrite func(query string) (string, []int)
+}
+
+// Open открывает соединение исходного драйвера
+func (d rewritingDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &rewritingConn{Conn: conn, rewrite: d.rewrite}, nil
+}
+
+// rewritingConn соединение, которое переписывает запросы
+type rewritingConn struct {
+	driver.Conn
+	rewrite func(query string) (string, []int)
+}
+
+func (c *rewritingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *rewritingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	query, order := c.rewrite(query)
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rewritingStmt{Stmt: stmt, order: order}, nil
+}
+
+func (c *rewritingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *rewritingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	query, order := c.rewrite(query)
+	return e.ExecContext(ctx, query, reorderArgs(args, order))
+}
+
+func (c *rewritingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	query, order := c.rewrite(query)
+	return q.QueryContext(ctx, query, reorderArgs(args, order))
+}
+
+func (c *rewritingConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *rewritingConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *rewritingConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *rewritingConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// rewritingStmt подготовленный запрос, который принимает аргументы в нумерации исходного запроса
+type rewritingStmt struct {
+	driver.Stmt
+	order []int
+}
+
+func (s *rewritingStmt) NumInput() int {
+	if s.order == nil {
+		return s.Stmt.NumInput()
+	}
+	n := 0
+	for _, i := range s.order {
+		if i > n {
+			n = i
+		}
+	}
+	return n
+}
+
+func (s *rewritingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	args = reorderArgs(args, s.order)
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+	return s.Stmt.Exec(namedValues(args))
+}
+
+func (s *rewritingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	args = reorderArgs(args, s.order)
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+	return s.Stmt.Query(namedValues(args))
+}
+
+func (s *rewritingStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// rebindPlaceholders заменяет $N вне строковых литералов и идентификаторов в кавычках на ?
+// и возвращает номера исходных параметров в порядке появления; nil - замен не было
+func rebindPlaceholders(query string) (string, []int) {
+	if !strings.Contains(query, "$") {
+		return query, nil
+	}
+	var b strings.Builder
+	var order []int
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			n := 0
+			for i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9' {
+				i++
+				n = n*10 + int(query[i]-'0')
+			}
+			order = append(order, n)
+			b.WriteByte('?')
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), order
+}
+
+// reorderArgs раскладывает аргументы по позициям ? согласно order
+func reorderArgs(args []driver.NamedValue, order []int) []driver.NamedValue {
+	if order == nil {
+		return args
+	}
+	out := make([]driver.NamedValue, len(order))
+	for i, n := range order {
+		if n >= 1 && n <= len(args) {
+			out[i] = args[n-1]
+		}
+		out[i].Ordinal = i + 1
+	}
+	return out
+}
+
+// namedValues преобразует аргументы для драйверов без методов с контекстом
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	return values
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestRebindPlaceholders(t *testing.T) {
+	tests := []struct {
+		name, query, want string
+		order             []int
+	}{
+		{"no placeholders", "SELECT 1", "SELECT 1", nil},
+		{"dollar without number", "SELECT '$' || x FROM t WHERE y = $$a$$", "SELECT '$' || x FROM t WHERE y = $$a$$", nil},
+		{"in order", "INSERT INTO t VALUES ($1, $2, $3)", "INSERT INTO t VALUES (?, ?, ?)", []int{1, 2, 3}},
+		{"repeated", "WHERE identifier = $1 OR identifier LIKE $1", "WHERE identifier = ? OR identifier LIKE ?", []int{1, 1}},
+		{"reordered", "UPDATE t SET a = $2 WHERE id = $1", "UPDATE t SET a = ? WHERE id = ?", []int{2, 1}},
+		{"multi-digit", "VALUES ($9, $10, $11)", "VALUES (?, ?, ?)", []int{9, 10, 11}},
+		{"single quoted", "SELECT '$1', $2", "SELECT '$1', ?", []int{2}},
+		{"escaped quote", "SELECT 'it''s $1', $2", "SELECT 'it''s $1', ?", []int{2}},
+		{"double quoted", `SELECT "$1" FROM t WHERE a = $1`, `SELECT "$1" FROM t WHERE a = ?`, []int{1}},
+		{"backquoted", "SELECT `$2` FROM t WHERE a = $2", "SELECT `$2` FROM t WHERE a = ?", []int{2}},
+	}
+	for _, tt := range tests {
+		got, order := rebindPlaceholders(tt.query)
+		if got != tt.want || !reflect.DeepEqual(order, tt.order) {
+			t.Errorf("%s: rebindPlaceholders(%q) = %q, %v; want %q, %v", tt.name, tt.query, got, order, tt.want, tt.order)
+		}
+	}
+}
+
+func TestReorderArgs(t *testing.T) {
+	args := []driver.NamedValue{{Ordinal: 1, Value: "a"}, {Ordinal: 2, Value: "b"}}
+	got := reorderArgs(args, []int{2, 1, 2})
+	want := []driver.NamedValue{{Ordinal: 1, Value: "b"}, {Ordinal: 2, Value: "a"}, {Ordinal: 3, Value: "b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reorderArgs = %v; want %v", got, want)
+	}
+	if got := reorderArgs(args, nil); !reflect.DeepEqual(got, args) {
+		t.Errorf("reorderArgs without order = %v; want %v", got, args)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	tests := []struct {
+		dialect  *sqlDialect
+		conflict string
+		update   []string
+		want     string
+	}{
+		{postgresDialect, "identifier", nil, " ON CONFLICT (identifier) DO NOTHING"},
+		{postgresDialect, "id_staff, identifier", []string{"status", "info"},
+			" ON CONFLICT (id_staff, identifier) DO UPDATE SET status = EXCLUDED.status, info = EXCLUDED.info"},
+		{mysqlDialect, "identifier", nil, " ON DUPLICATE KEY UPDATE identifier = identifier"},
+		{mysqlDialect, "id_staff, identifier", nil, " ON DUPLICATE KEY UPDATE id_staff = id_staff"},
+		{mysqlDialect, "id_staff, identifier", []string{"status", "info"},
+			" ON DUPLICATE KEY UPDATE status = VALUES(status), info = VALUES(info)"},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.upsert(tt.conflict, tt.update...); got != tt.want {
+			t.Errorf("%s upsert(%q, %v) = %q; want %q", tt.dialect.name, tt.conflict, tt.update, got, tt.want)
+		}
+	}
+}
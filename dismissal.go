@@ -0,0 +1,126 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// dismissedCard карта уволенного сотрудника, которая будет или была внесена в стоп-лист
+type dismissedCard struct {
+	Identifier     string `json:"identifier"`
+	IdentifierType string `json:"identifier_type"`
+	IDStaff        int64  `json:"id_staff"`
+	Name           string `json:"name"`
+	DismissalDate  string `json:"dismissal_date"`
+}
+
+// findDismissedCards ищет карты вне стоп-листа, у которых дата увольнения из DISMISSAL_DATE_FIELD раньше before
+func findDismissedCards(db *sql.DB, before time.Time) ([]dismissedCard, error) {
+	cards := []dismissedCard{}
+	err := eachStaffCard(db, "extra_fields ->> $1 IS NOT NULL AND "+notBlocklistedCondition, func(sc StaffCard) error {
+		value := sc.ExtraFields[config.DismissalDateField]
+		dismissed, err := parseExtraFieldDate(value)
+		if err != nil {
+			log.Printf("⚠️ Staff %d: cannot parse dismissal date %q", sc.IDStaff, value)
+			return nil
+		}
+		if !dismissed.Before(before) {
+			return nil
+		}
+		cards = append(cards, dismissedCard{
+			Identifier:     sc.Identifier,
+			IdentifierType: sc.IdentifierType,
+			IDStaff:        sc.IDStaff,
+			Name:           staffFullName(sc),
+			DismissalDate:  dismissed.Format("2006-01-02"),
+		})
+		return nil
+	}, config.DismissalDateField)
+	return cards, err
+}
+
+// blockDismissedCards вносит карты уволенных до before в стоп-лист с записью в журнал
+func blockDismissedCards(db *sql.DB, before time.Time, actor string) ([]dismissedCard, error) {
+	cards, err := findDismissedCards(db, before)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cards {
+		e := BlocklistEntry{
+			Identifier: c.Identifier,
+			Reason:     fmt.Sprintf("dismissed %s (before %s)", c.DismissalDate, before.Format("2006-01-02")),
+			AddedBy:    actor,
+		}
+		if _, err := changeBlocklist(db, "add", e); err != nil {
+			return nil, err
+		}
+	}
+	if len(cards) > 0 {
+		log.Printf("⛔ %d cards of staff dismissed before %s added to blocklist by %s", len(cards), before.Format("2006-01-02"), actor)
+	}
+	return cards, nil
+}
+
+// blockDismissedAfterSync вносит в стоп-лист карты уволенных до сегодняшнего дня; включается DISMISSAL_AUTO_BLOCK
+func blockDismissedAfterSync(*SyncResult) {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ Dismissal block: PostgreSQL connection error: %v", err)
+		return
+	}
+	now := displayTime(time.Now())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if _, err := blockDismissedCards(pgDB, today, "dismissal-job"); err != nil {
+		log.Printf("❌ Dismissal block failed: %v", err)
+	}
+}
+
+// dismissedCardsHandler блокирует карты сотрудников, уволенных до даты before (YYYY-MM-DD или DD.MM.YYYY),
+// по дате увольнения из синхронизированного дополнительного поля. ?dry_run=true только показывает карты.
+func dismissedCardsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if config.DismissalDateField == "" {
+		returnJSONError(w, "DISMISSAL_DATE_FIELD is not configured", http.StatusNotFound)
+		return
+	}
+	before, err := parseCSVDate(r.URL.Query().Get("before"))
+	if err != nil {
+		returnJSONError(w, "'before': "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var cards []dismissedCard
+	if dryRun {
+		cards, err = findDismissedCards(pgDB, before)
+	} else {
+		cards, err = blockDismissedCards(pgDB, before, requestActor(r))
+	}
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	message := fmt.Sprintf("%d cards blocked", len(cards))
+	if dryRun {
+		message = fmt.Sprintf("%d cards would be blocked", len(cards))
+	}
+	returnJSONSuccess(w, map[string]interface{}{
+		"before":  before.Format("2006-01-02"),
+		"dry_run": dryRun,
+		"cards":   cards,
+	}, message)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DuplicatePersonStaff одна из записей вероятного дубля с ее идентификаторами
+type DuplicatePersonStaff struct {
+	IDStaff     int64    `json:"id_staff"`
+	TabNumber   string   `json:"tab_number"`
+	BirthDate   string   `json:"birth_date,omitempty"`
+	Department  string   `json:"department"`
+	Position    string   `json:"position"`
+	Identifiers []string `json:"identifiers"`
+}
+
+// DuplicatePerson записи PERCo с одинаковым ФИО и совпадающим табельным номером или датой рождения.
+// MatchedBy - tab_number, birth_date или name, если запрошены и просто однофамильцы.
+type DuplicatePerson struct {
+	Name      string                 `json:"name"`
+	MatchedBy []string               `json:"matched_by"`
+	Staff     []DuplicatePersonStaff `json:"staff"`
+}
+
+// duplicateNameCondition карты сотрудников, чье ФИО (свернутый name_key) встречается у нескольких id_staff
+const duplicateNameCondition = `name_key IN (SELECT name_key FROM staff WHERE COALESCE(name_key, '') <> ''
+	GROUP BY name_key HAVING COUNT(*) > 1)`
+
+// findDuplicatePersons группирует однофамильцев и оставляет группы с общим табельным номером или датой
+// рождения из BIRTH_DATE_FIELD; nameOnly добавляет всех однофамильцев
+func findDuplicatePersons(cards []StaffCard, nameOnly bool) []DuplicatePerson {
+	type person struct {
+		name  string
+		staff *DuplicatePersonStaff
+	}
+	byName := make(map[string][]*person)
+	staffIndex := make(map[int64]*person)
+	for _, sc := range cards {
+		if p, ok := staffIndex[sc.IDStaff]; ok {
+			p.staff.Identifiers = append(p.staff.Identifiers, sc.Identifier)
+			continue
+		}
+		p := &person{name: staffFullName(sc), staff: &DuplicatePersonStaff{
+			IDStaff:     sc.IDStaff,
+			TabNumber:   strings.TrimSpace(strValue(sc.TabNumber)),
+			Department:  strValue(sc.Department),
+			Position:    strValue(sc.Position),
+			Identifiers: []string{sc.Identifier},
+		}}
+		if config.BirthDateField != "" {
+			if d, err := parseExtraFieldDate(sc.ExtraFields[config.BirthDateField]); err == nil {
+				p.staff.BirthDate = d.Format("2006-01-02")
+			}
+		}
+		staffIndex[sc.IDStaff] = p
+		key := nameSearchKey(sc)
+		byName[key] = append(byName[key], p)
+	}
+
+	keys := make([]string, 0, len(byName))
+	for key := range byName {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var result []DuplicatePerson
+	for _, key := range keys {
+		people := byName[key]
+		if len(people) < 2 {
+			continue
+		}
+		sort.Slice(people, func(i, j int) bool { return people[i].staff.IDStaff < people[j].staff.IDStaff })
+
+		// Записи делятся по совпадающему признаку; одна и та же пара с двумя признаками дает одну группу
+		buckets := make(map[string][]*person)
+		var bucketOrder []string
+		addToBucket := func(match string, p *person) {
+			if _, ok := buckets[match]; !ok {
+				bucketOrder = append(bucketOrder, match)
+			}
+			buckets[match] = append(buckets[match], p)
+		}
+		for _, p := range people {
+			if p.staff.TabNumber != "" {
+				addToBucket("tab_number\x00"+p.staff.TabNumber, p)
+			}
+			if p.staff.BirthDate != "" {
+				addToBucket("birth_date\x00"+p.staff.BirthDate, p)
+			}
+			if nameOnly {
+				addToBucket("name", p)
+			}
+		}
+
+		groups := make(map[string]*DuplicatePerson)
+		var groupOrder []string
+		for _, bucket := range bucketOrder {
+			members := buckets[bucket]
+			if len(members) < 2 {
+				continue
+			}
+			ids := make([]string, len(members))
+			for i, p := range members {
+				ids[i] = strconv.FormatInt(p.staff.IDStaff, 10)
+			}
+			setKey := strings.Join(ids, ",")
+			match, _, _ := strings.Cut(bucket, "\x00")
+			if g, ok := groups[setKey]; ok {
+				g.MatchedBy = append(g.MatchedBy, match)
+				continue
+			}
+			g := &DuplicatePerson{Name: members[0].name, MatchedBy: []string{match}}
+			for _, p := range members {
+				g.Staff = append(g.Staff, *p.staff)
+			}
+			groups[setKey] = g
+			groupOrder = append(groupOrder, setKey)
+		}
+		for _, setKey := range groupOrder {
+			result = append(result, *groups[setKey])
+		}
+	}
+	return result
+}
+
+// duplicatePersonsRows формирует строки таблицы отчета: по строке на каждую запись группы
+func duplicatePersonsRows(groups []DuplicatePerson) [][]string {
+	rows := [][]string{{"Группа", "ФИО", "Совпадение", "ID сотрудника", "Табельный номер", "Дата рождения",
+		"Подразделение", "Должность", "Идентификаторы"}}
+	for i, g := range groups {
+		for _, s := range g.Staff {
+			rows = append(rows, []string{strconv.Itoa(i + 1), g.Name, strings.Join(g.MatchedBy, ", "),
+				strconv.FormatInt(s.IDStaff, 10), s.TabNumber, s.BirthDate, s.Department, s.Position,
+				strings.Join(s.Identifiers, ", ")})
+		}
+	}
+	return rows
+}
+
+// duplicatePersonsReportHandler отчет о вероятных дублях сотрудников в PERCo: из-за них выдаются
+// двойные пропуска и двоится учет времени. ?name_only=true добавляет всех однофамильцев, format=xlsx - книга Excel.
+func duplicatePersonsReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pgDB, err := connectPostgresRead()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := searchContext(r)
+	defer cancel()
+	var cards []StaffCard
+	err = eachStaffCardContext(ctx, pgDB, duplicateNameCondition, func(sc StaffCard) error {
+		cards = append(cards, sc)
+		return nil
+	})
+	if err != nil {
+		log.Printf("❌ Duplicate persons report failed: %v", err)
+		returnSearchError(w, ctx, err)
+		return
+	}
+	groups := findDuplicatePersons(cards, r.URL.Query().Get("name_only") == "true")
+	if groups == nil {
+		groups = []DuplicatePerson{}
+	}
+
+	if r.URL.Query().Get("format") == "xlsx" {
+		w.Header().Set("Content-Type", exportContentTypes["xlsx"])
+		w.Header().Set("Content-Disposition", `attachment; filename="duplicate_persons.xlsx"`)
+		if err := writeRowsXLSX(w, "Дубли сотрудников", duplicatePersonsRows(groups)); err != nil {
+			log.Printf("❌ Duplicate persons export failed: %v", err)
+		}
+		return
+	}
+
+	returnJSONSuccess(w, groups, fmt.Sprintf("%d probable duplicate groups", len(groups)))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// EvacuationDepartment находящиеся в здании сотрудники одного подразделения
+type EvacuationDepartment struct {
+	Department string     `json:"department"`
+	Count      int        `json:"count"`
+	People     []Occupant `json:"people"`
+}
+
+// EvacuationList список для пункта сбора: все, кто в здании, по подразделениям
+type EvacuationList struct {
+	GeneratedAt time.Time              `json:"generated_at"`
+	Total       int                    `json:"total"`
+	Departments []EvacuationDepartment `json:"departments"`
+}
+
+// evacuationPage печатная форма списка эвакуации; колонка "Отметка" заполняется на пункте сбора
+var evacuationPage = template.Must(template.New("evacuation").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="UTF-8">
+<title>Список эвакуации {{.List.GeneratedAt.Format "02.01.2006 15:04"}}</title>
+<style>
+body { font-family: Arial, sans-serif; font-size: 12pt; margin: 20px; }
+h1 { font-size: 16pt; margin: 0 0 4px; }
+h2 { font-size: 13pt; margin: 18px 0 6px; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #000; padding: 3px 6px; text-align: left; }
+td.mark { width: 60px; }
+section { page-break-inside: avoid; }
+.no-print { margin-bottom: 12px; }
+@media print { .no-print { display: none; } body { margin: 0; } }
+</style>
+</head>
+<body>
+<div class="no-print"><button onclick="window.print()">🖨️ Печать</button></div>
+<h1>Список эвакуации: в здании {{.List.Total}} чел.</h1>
+<p>Сформирован {{.List.GeneratedAt.Format "02.01.2006 15:04:05"}}</p>
+{{range .List.Departments}}
+<section>
+<h2>{{.Department}} ({{.Count}})</h2>
+<table>
+<thead><tr><th>№</th><th>ФИО</th><th>Должность</th><th>Телефон</th><th>Зона</th><th>Вход</th><th>Отметка</th></tr></thead>
+<tbody>
+{{range $i, $p := .People}}<tr><td>{{inc $i}}</td><td>{{$p.Name}}</td><td>{{$p.Position}}</td><td>{{$p.Phone}}</td><td>{{$p.Zone}}</td><td>{{$p.Since.Format "15:04"}}</td><td class="mark"></td></tr>
+{{end}}</tbody>
+</table>
+</section>
+{{else}}
+<p>В здании никого нет.</p>
+{{end}}
+{{if .AutoPrint}}<script>window.addEventListener('load', function() { window.print(); });</script>{{end}}
+</body>
+</html>
+`))
+
+// buildEvacuationList группирует находящихся в здании по подразделениям в порядке первого появления
+func buildEvacuationList(occupants []Occupant) EvacuationList {
+	list := EvacuationList{GeneratedAt: time.Now(), Total: len(occupants), Departments: []EvacuationDepartment{}}
+	index := make(map[string]int)
+	for _, o := range occupants {
+		name := o.Department
+		if name == "" {
+			name = "Без подразделения"
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(list.Departments)
+			index[name] = i
+			list.Departments = append(list.Departments, EvacuationDepartment{Department: name})
+		}
+		list.Departments[i].People = append(list.Departments[i].People, o)
+		list.Departments[i].Count++
+	}
+	return list
+}
+
+// evacuationReportHandler список всех, кто в здании, по подразделениям для пункта сбора при учениях
+// и тревогах. format=html - печатная форма, print=true сразу открывает диалог печати; ?zone= - одна зона.
+func evacuationReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var zone *int64
+	if s := r.URL.Query().Get("zone"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			returnJSONError(w, "Invalid 'zone' parameter", http.StatusBadRequest)
+			return
+		}
+		zone = &id
+	}
+	html := r.URL.Query().Get("format") == "html"
+
+	pgDB, err := connectPostgresRead()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		if html {
+			renderErrorPage(w, http.StatusInternalServerError, "База данных недоступна")
+			return
+		}
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	occupants, err := loadOccupants(pgDB, zone)
+	if err != nil {
+		log.Printf("❌ Evacuation list failed: %v", err)
+		if html {
+			renderErrorPage(w, http.StatusInternalServerError, "Не удалось сформировать список эвакуации")
+			return
+		}
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	list := buildEvacuationList(occupants)
+
+	w.Header().Set("Cache-Control", "no-store")
+	if html {
+		renderPage(w, evacuationPage, struct {
+			List      EvacuationList
+			AutoPrint bool
+		}{list, r.URL.Query().Get("print") == "true"})
+		return
+	}
+	returnJSONSuccess(w, list, fmt.Sprintf("%d people inside", list.Total))
+}
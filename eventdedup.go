@@ -0,0 +1,67 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// eventDedupKey признаки повторного сообщения: в событиях PERCo нет считывателя, его заменяет зона
+type eventDedupKey struct {
+	IDStaff   int64
+	Direction int
+	AreaID    int64 // -1, если зона не указана
+}
+
+// eventDeduplicator отбрасывает повторы считывателя: событие с тем же сотрудником, направлением и зоной
+// в пределах окна от последнего сохраненного. Последнее событие ключа ищется сначала среди загружаемых,
+// затем в events, чтобы повтор на границе двух загрузок тоже отбрасывался.
+type eventDeduplicator struct {
+	tx      *sql.Tx
+	window  time.Duration
+	last    map[eventDedupKey]time.Time
+	skipped int
+}
+
+// newEventDeduplicator создает фильтр повторов; при нулевом окне он пропускает все события
+func newEventDeduplicator(tx *sql.Tx, window time.Duration) *eventDeduplicator {
+	return &eventDeduplicator{tx: tx, window: window, last: make(map[eventDedupKey]time.Time)}
+}
+
+// repeated возвращает true для повтора; иначе запоминает событие как последнее для своего ключа
+func (d *eventDeduplicator) repeated(ev PassEvent) (bool, error) {
+	if d.window <= 0 {
+		return false, nil
+	}
+	key := eventDedupKey{IDStaff: ev.IDStaff, Direction: ev.Direction, AreaID: -1}
+	if ev.AreaID != nil {
+		key.AreaID = *ev.AreaID
+	}
+
+	last, ok := d.last[key]
+	if !ok {
+		var stored sql.NullTime
+		err := d.tx.QueryRow(`
+			SELECT MAX(event_time) FROM events
+			WHERE id_staff = $1 AND direction = $2 AND COALESCE(area_id, -1) = $3
+				AND event_time BETWEEN $4 AND $5
+		`, key.IDStaff, key.Direction, key.AreaID, ev.EventTime.Add(-d.window), ev.EventTime.Add(d.window)).Scan(&stored)
+		if err != nil {
+			return false, fmt.Errorf("error checking repeated event %d: %v", ev.SourceID, err)
+		}
+		last, ok = stored.Time, stored.Valid
+	}
+
+	if ok {
+		diff := ev.EventTime.Sub(last)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= d.window {
+			d.skipped++
+			return true, nil
+		}
+	}
+	d.last[key] = ev.EventTime
+	return false, nil
+}
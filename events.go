@@ -0,0 +1,181 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Направления прохода в событиях PERCo
+const (
+	directionIn  = 1
+	directionOut = 2
+)
+
+// PassEvent структура для события прохода
+type PassEvent struct {
+	SourceID  int64     `json:"source_id"`
+	IDStaff   int64     `json:"id_staff"`
+	EventTime time.Time `json:"event_time"`
+	Direction int       `json:"direction"`
+	AreaID    *int64    `json:"area_id"`
+	Site      *string   `json:"site"`
+}
+
+// initEventsTable создает секционированную по месяцам таблицу событий проходов
+// и секции на текущий и следующий месяц; обычная таблица прежних версий переносится в секции
+func initEventsTable(db *sql.DB) error {
+	exists, partitioned, err := eventsPartitioned(db)
+	if err != nil {
+		return err
+	}
+	var legacy []string
+	if partitioned {
+		if legacy, err = timestampColumnsWithoutZone(db, "events", "event_time"); err != nil {
+			return err
+		}
+	}
+	switch {
+	case exists && !partitioned:
+		if err := convertEventsTable(db); err != nil {
+			return err
+		}
+	case len(legacy) > 0:
+		if err := convertEventsTimeZone(db); err != nil {
+			return err
+		}
+	default:
+		if _, err := db.Exec(eventsTableDDL); err != nil {
+			return fmt.Errorf("error creating events table: %v", err)
+		}
+	}
+
+	// Индекс на секционированной таблице создается и во всех секциях
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS events_staff_time_idx ON events (id_staff, event_time)")
+	if err != nil {
+		return fmt.Errorf("error creating events index: %v", err)
+	}
+	now := time.Now()
+	for _, t := range []time.Time{now, now.AddDate(0, 1, 0)} {
+		if err := ensureEventPartition(db, t); err != nil {
+			return err
+		}
+	}
+	return detachOldEventPartitions(db)
+}
+
+// syncEvents догружает из Firebird события, появившиеся после последней синхронизации
+func syncEvents() error {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+
+	var lastID int64
+	if err := pgDB.QueryRow("SELECT COALESCE(MAX(source_id), 0) FROM events").Scan(&lastID); err != nil {
+		return fmt.Errorf("error reading last event id: %v", err)
+	}
+
+	fbDB, err := connectFirebird()
+	if err != nil {
+		return fmt.Errorf("Firebird connection error: %v", err)
+	}
+
+	ctx, cancel := syncContext()
+	defer cancel()
+	rows, err := fbDB.QueryContext(ctx, config.EventsQuery, lastID)
+	if err != nil {
+		return fmt.Errorf("Firebird events query error: %v", err)
+	}
+	defer rows.Close()
+
+	tx, err := pgDB.Begin()
+	if err != nil {
+		return fmt.Errorf("transaction error: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO events (source_id, id_staff, event_time, direction, area_id, site)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+		ON CONFLICT (source_id, event_time) DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
+
+	count := 0
+	var earliest time.Time
+	// Секция создается при первом событии месяца, например при догрузке старой истории
+	partitions := make(map[time.Time]bool)
+	retentionStart := eventsRetentionStart()
+	skipped := 0
+	dedup := newEventDeduplicator(tx, config.EventsDedupWindow)
+	for rows.Next() {
+		var ev PassEvent
+		var areaID sql.NullInt64
+		if err := rows.Scan(&ev.SourceID, &ev.IDStaff, &ev.EventTime, &ev.Direction, &areaID); err != nil {
+			return fmt.Errorf("error scanning event: %v", err)
+		}
+		ev.EventTime = sourceTime(ev.EventTime)
+		if areaID.Valid {
+			ev.AreaID = &areaID.Int64
+		}
+		// Секции месяцев за пределами EVENTS_RETENTION_MONTHS уже отсоединены
+		month := monthStart(ev.EventTime)
+		if month.Before(retentionStart) {
+			skipped++
+			continue
+		}
+		if !partitions[month] {
+			if err := ensureEventPartition(tx, month); err != nil {
+				return err
+			}
+			partitions[month] = true
+		}
+		if repeated, err := dedup.repeated(ev); err != nil {
+			return err
+		} else if repeated {
+			continue
+		}
+		if _, err := stmt.Exec(ev.SourceID, ev.IDStaff, ev.EventTime, ev.Direction, ev.AreaID, config.Site); err != nil {
+			return fmt.Errorf("error inserting event %d: %v", ev.SourceID, err)
+		}
+		if count == 0 || ev.EventTime.Before(earliest) {
+			earliest = ev.EventTime
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating events: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing events: %v", err)
+	}
+	if skipped > 0 {
+		log.Printf("🚶 Skipped %d events older than EVENTS_RETENTION_MONTHS", skipped)
+	}
+	if dedup.skipped > 0 {
+		log.Printf("🚶 Skipped %d repeated reader reports within EVENTS_DEDUP_WINDOW", dedup.skipped)
+	}
+
+	if count > 0 {
+		log.Printf("🚶 Imported %d new passage events", count)
+		if err := updatePassageStateFromEvents(pgDB, earliest); err != nil {
+			return err
+		}
+		if err := updateStaffLastSeenFromEvents(pgDB, earliest); err != nil {
+			return err
+		}
+	}
+	return detachOldEventPartitions(pgDB)
+}
+
+// startEventsSync запускает периодическую загрузку событий проходов
+func startEventsSync() {
+	log.Printf("🚶 Passage events sync enabled: every %s", config.EventsInterval)
+	startPeriodicJob("Events sync", config.EventsInterval, syncEvents)
+}
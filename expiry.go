@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ExpiryOverride локально заданный срок действия карты, который сохраняется между синхронизациями
+type ExpiryOverride struct {
+	Identifier string     `json:"identifier"`
+	ValidUntil *time.Time `json:"valid_until"`
+}
+
+// initExpiryOverridesTable создает таблицу локальных сроков действия карт
+func initExpiryOverridesTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS card_expiry_overrides (
+			identifier TEXT PRIMARY KEY,
+			valid_until TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating card_expiry_overrides table: %v", err)
+	}
+	return nil
+}
+
+// applyExpiryOverrides переносит локальные сроки действия поверх данных источника
+func applyExpiryOverrides(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		UPDATE cards c SET valid_until = o.valid_until
+		FROM card_expiry_overrides o
+		WHERE o.identifier = c.identifier
+	`)
+	if err != nil {
+		return fmt.Errorf("Error applying expiry overrides: %v", err)
+	}
+	return nil
+}
+
+// cardExpiryHandler задает (POST) или снимает (DELETE) локальный срок действия карты.
+// valid_until = null в POST означает бессрочную карту независимо от данных PERCo.
+func cardExpiryHandler(w http.ResponseWriter, r *http.Request) {
+	var req ExpiryOverride
+	switch r.Method {
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			returnJSONError(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		req.Identifier = r.URL.Query().Get("identifier")
+	default:
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Identifier == "" {
+		returnJSONError(w, "Missing 'identifier'", http.StatusBadRequest)
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if _, err := pgDB.Exec("DELETE FROM card_expiry_overrides WHERE identifier = $1", req.Identifier); err != nil {
+			returnJSONError(w, fmt.Sprintf("Error removing expiry override: %v", err), http.StatusInternalServerError)
+			return
+		}
+		// Срок из источника вернется при следующей синхронизации
+		log.Printf("📅 Expiry override removed for %s", req.Identifier)
+		returnJSONSuccess(w, req, "Expiry override removed, source value applies after next sync")
+		return
+	}
+
+	_, err = pgDB.Exec(`
+		INSERT INTO card_expiry_overrides (identifier, valid_until, updated_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (identifier) DO UPDATE SET valid_until = EXCLUDED.valid_until, updated_at = EXCLUDED.updated_at
+	`, req.Identifier, req.ValidUntil)
+	if err != nil {
+		returnJSONError(w, fmt.Sprintf("Error saving expiry override: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if _, err := pgDB.Exec("UPDATE cards SET valid_until = $2 WHERE identifier = $1", req.Identifier, req.ValidUntil); err != nil {
+		returnJSONError(w, fmt.Sprintf("Error updating card: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("📅 Expiry override set for %s", req.Identifier)
+	returnJSONSuccess(w, req, "Expiry override saved")
+}
+
+// expiringCardsHandler возвращает карты, срок действия которых истекает в ближайшие days дней
+func expiringCardsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days := 30
+	if s := r.URL.Query().Get("days"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > 3650 {
+			returnJSONError(w, "'days' must be between 0 and 3650", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	stream, err := newJSONStream(w, r.URL.Query().Get("format") == "ndjson")
+	if err != nil {
+		return
+	}
+	err = eachStaffCard(pgDB, "valid_until > NOW() AND valid_until <= NOW() + make_interval(days => $1)", func(sc StaffCard) error {
+		return stream.write(sc)
+	}, days)
+	if err != nil {
+		log.Printf("❌ Expiring cards query failed: %v", err)
+		return
+	}
+	stream.close(fmt.Sprintf("%d cards expire within %d days", stream.count, days))
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// strValue возвращает значение строки или пустую строку для nil
+func strValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// formatDate возвращает дату в формате ДД.ММ.ГГГГ или пустую строку для nil
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("02.01.2006")
+}
+
+// formatDateTime форматирует момент времени для выгрузки; nil дает пустую строку
+func formatDateTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("02.01.2006 15:04")
+}
+
+// cardRows формирует строки таблицы с заголовком для выгрузки; дополнительные поля идут последними
+func cardRows(cards []StaffCard) [][]string {
+	extraKeys := extraFieldKeys()
+	rows := [][]string{cardHeader(extraKeys)}
+	for _, sc := range cards {
+		rows = append(rows, cardRow(sc, extraKeys))
+	}
+	return rows
+}
+
+// cardHeader возвращает заголовок таблицы выгрузки карт
+func cardHeader(extraKeys []string) []string {
+	header := []string{"ID сотрудника", "Номер карты", "Фамилия", "Имя", "Отчество", "Статус", "Инфо", "E-mail", "Телефон", "Подразделение", "Должность", "Тип идентификатора", "Действует до", "Табельный номер", "Последнее использование", "Объект"}
+	return append(header, extraKeys...)
+}
+
+// cardRow формирует строку выгрузки для одной карты
+func cardRow(sc StaffCard, extraKeys []string) []string {
+	row := []string{
+		strconv.FormatInt(sc.IDStaff, 10),
+		sc.Identifier,
+		strValue(sc.LastName),
+		strValue(sc.FirstName),
+		strValue(sc.MiddleName),
+		strValue(sc.Status),
+		strValue(sc.Info),
+		strValue(sc.Email),
+		strValue(sc.Phone),
+		strValue(sc.Department),
+		strValue(sc.Position),
+		sc.IdentifierType,
+		formatDate(sc.ValidUntil),
+		strValue(sc.TabNumber),
+		formatDateTime(sc.LastSeen),
+		strValue(sc.Site),
+	}
+	for _, key := range extraKeys {
+		row = append(row, sc.ExtraFields[key])
+	}
+	return row
+}
+
+// newCSVWriter начинает CSV, понятный Excel (BOM и разделитель из конфигурации)
+func newCSVWriter(w io.Writer) (*csv.Writer, error) {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	writer := csv.NewWriter(w)
+	if config.ExportCSVDelimiter != "" {
+		writer.Comma = []rune(config.ExportCSVDelimiter)[0]
+	}
+	return writer, nil
+}
+
+// writeRowsCSV записывает строки в CSV, понятный Excel
+func writeRowsCSV(w io.Writer, rows [][]string) error {
+	writer, err := newCSVWriter(w)
+	if err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// writeRowsXLSX записывает строки на лист книги Excel
+func writeRowsXLSX(w io.Writer, sheet string, rows [][]string) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		values := make([]interface{}, len(row))
+		for j, v := range row {
+			values[j] = v
+		}
+		if err := f.SetSheetRow(sheet, cell, &values); err != nil {
+			return err
+		}
+	}
+	if len(rows) > 0 {
+		if err := f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+			return err
+		}
+	}
+	return f.Write(w)
+}
+
+// writeExport записывает выгрузку карт в формате csv или xlsx
+func writeExport(w io.Writer, format string, cards []StaffCard) error {
+	switch format {
+	case "csv":
+		return writeRowsCSV(w, cardRows(cards))
+	case "xlsx":
+		return writeRowsXLSX(w, "Карты", cardRows(cards))
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// exportContentTypes MIME-типы форматов выгрузки
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"json":   "application/json",
+	"ndjson": "application/x-ndjson",
+}
+
+// streamExport пишет выгрузку карт по мере чтения из базы, не собирая весь список в памяти.
+// XLSX собирается целиком библиотекой, поэтому для него используется writeExport.
+func streamExport(w http.ResponseWriter, db *sql.DB, format string, filter *cardFilter) error {
+	switch format {
+	case "csv":
+		writer, err := newCSVWriter(w)
+		if err != nil {
+			return err
+		}
+		extraKeys := extraFieldKeys()
+		if err := writer.Write(cardHeader(extraKeys)); err != nil {
+			return err
+		}
+		flusher, _ := w.(http.Flusher)
+		count := 0
+		err = eachStaffCard(db, filter.where(), func(sc StaffCard) error {
+			if err := writer.Write(cardRow(sc, extraKeys)); err != nil {
+				return err
+			}
+			if count++; count%streamFlushEvery == 0 {
+				writer.Flush()
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			return nil
+		}, filter.args...)
+		if err != nil {
+			return err
+		}
+		writer.Flush()
+		return writer.Error()
+	case "json", "ndjson":
+		stream, err := newJSONStream(w, format == "ndjson")
+		if err != nil {
+			return err
+		}
+		err = eachStaffCard(db, filter.where(), func(sc StaffCard) error {
+			return stream.write(sc)
+		}, filter.args...)
+		if err != nil {
+			return err
+		}
+		return stream.close(fmt.Sprintf("%d cards", stream.count))
+	default:
+		cards, err := queryStaffCards(db, filter.where(), filter.args...)
+		if err != nil {
+			return err
+		}
+		return writeExport(w, format, cards)
+	}
+}
+
+// exportHandler отдает список карт файлом CSV или XLSX либо потоком JSON/NDJSON
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		returnJSONError(w, "Unsupported format, use csv, xlsx, json or ndjson", http.StatusBadRequest)
+		return
+	}
+
+	pgDB, err := connectPostgresRead()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	filter, err := cardFilterFromQuery(r.URL.Query())
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// После начала потока статус уже отправлен: при ошибке ответ обрывается,
+	// и клиент видит незавершенный CSV или JSON
+	w.Header().Set("Content-Type", contentType)
+	if format == "csv" || format == "xlsx" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="cards_%s.%s"`, time.Now().Format("20060102"), format))
+	}
+	if err := streamExport(w, pgDB, format, filter); err != nil {
+		log.Printf("❌ Export failed: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// extraField дополнительное поле карты: ключ в extra_fields и выражение Firebird
+type extraField struct {
+	Key  string
+	Expr string
+}
+
+// extraFieldKeyPattern допустимые ключи дополнительных полей
+var extraFieldKeyPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// extraFieldCondition условие для карт, у которых extra_fields содержит пары из $1
+const extraFieldCondition = `extra_fields @> $1::jsonb`
+
+// parseExtraFields разбирает EXTRA_FIELDS вида "badge_color=s.BADGE_COLOR;floor=sc.FLOOR".
+// Пары разделяются точкой с запятой, чтобы в выражениях можно было использовать функции с запятыми.
+func parseExtraFields(s string) ([]extraField, error) {
+	var fields []extraField
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(s, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, expr, ok := strings.Cut(pair, "=")
+		key, expr = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(expr)
+		if !ok || expr == "" || !extraFieldKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid EXTRA_FIELDS entry %q, expected key=expression", pair)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate EXTRA_FIELDS key %q", key)
+		}
+		seen[key] = true
+		fields = append(fields, extraField{Key: key, Expr: expr})
+	}
+	return fields, nil
+}
+
+// extraFieldKeys возвращает ключи дополнительных полей в порядке конфигурации
+func extraFieldKeys() []string {
+	fields, err := parseExtraFields(config.ExtraFields)
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, len(fields))
+	for i, f := range fields {
+		keys[i] = f.Key
+	}
+	return keys
+}
+
+// parseExtraFieldDate разбирает дату из дополнительного поля. Firebird отдает TIMESTAMP
+// как "2024-01-15 00:00:00.0000", поэтому при неудаче берется только дата.
+func parseExtraFieldDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	t, err := parseCSVDate(s)
+	if err != nil && len(s) > 10 {
+		if d, e := parseCSVDate(s[:10]); e == nil {
+			return d, nil
+		}
+	}
+	return t, err
+}
+
+// validateExtraFieldKey проверяет настройку с ключом дополнительного поля: для Firebird ключ должен
+// быть в EXTRA_FIELDS, для CSV поля задаются колонками файла
+func validateExtraFieldKey(name, key string) error {
+	if !extraFieldKeyPattern.MatchString(key) {
+		return fmt.Errorf("%s=%q is not a valid extra field key", name, key)
+	}
+	if config.SourceType != "firebird" {
+		return nil
+	}
+	for _, k := range extraFieldKeys() {
+		if k == key {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s=%q is not listed in EXTRA_FIELDS", name, key)
+}
+
+// extraFieldsJSON сериализует дополнительные поля для колонки JSONB
+func extraFieldsJSON(fields map[string]string) string {
+	if len(fields) == 0 {
+		return "{}"
+	}
+	data, _ := json.Marshal(fields)
+	return string(data)
+}
+
+// addExtraFieldFilters добавляет условия для параметров вида extra.key=value
+func addExtraFieldFilters(f *cardFilter, q url.Values) error {
+	var keys []string
+	for param := range q {
+		if strings.HasPrefix(param, "extra.") {
+			keys = append(keys, param)
+		}
+	}
+	sort.Strings(keys)
+	for _, param := range keys {
+		key := strings.ToLower(strings.TrimPrefix(param, "extra."))
+		if !extraFieldKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid extra field %q", key)
+		}
+		f.add(extraFieldCondition, extraFieldsJSON(map[string]string{key: q.Get(param)}))
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// version версия сборки; задается при сборке через -ldflags "-X main.version=..."
+var version = "dev"
+
+// commandLine параметры командной строки, которые накладываются поверх переменных окружения
+type commandLine struct {
+	envFile string
+	args    []string // подкоманда и ее аргументы
+}
+
+// parseFlags разбирает флаги до подкоманды. Флаги --port и --log-level записываются в переменные
+// окружения PORT и LOG_LEVEL, поэтому имеют приоритет и над окружением, и над файлом .env.
+func parseFlags(args []string) (*commandLine, error) {
+	fs := flag.NewFlagSet("perco_web", flag.ContinueOnError)
+	cl := &commandLine{}
+	fs.StringVar(&cl.envFile, "config", "", "path to the .env file (default .env in the working directory)")
+	port := fs.String("port", "", "HTTP port, overrides PORT")
+	logLevel := fs.String("log-level", "", "log level: info, warn or error; overrides LOG_LEVEL")
+	showVersion := fs.Bool("version", false, "print version and exit")
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: perco_web [flags] [command]\n\n")
+		fmt.Fprintf(out, "Commands:\n")
+		fmt.Fprintf(out, "  (none)                            start the web server\n")
+		fmt.Fprintf(out, "  snapshot [list | restore [key]]   S3 snapshots\n")
+		fmt.Fprintf(out, "  migrate [up | version | force N]  schema migrations\n")
+		fmt.Fprintf(out, "  loadtest --cards file [--rps N]   synthetic search/verify load\n")
+		fmt.Fprintf(out, "  config init [file]                commented configuration template\n")
+		fmt.Fprintf(out, "  check                             validate config, databases and permissions before deployment\n")
+		fmt.Fprintf(out, "  backup [--out file.json.gz]       save cards, blocklist, overrides and history to a file\n")
+		fmt.Fprintf(out, "  restore [--in] file.json.gz       replace data with a backup file\n")
+		fmt.Fprintf(out, "  import-csv [--dry-run] file.csv   import temporary cards (contractors) from CSV\n")
+		fmt.Fprintf(out, "  service install [--config file] | uninstall | start | stop   Windows service\n\n")
+		fmt.Fprintf(out, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *showVersion {
+		fmt.Println("perco_web", version)
+		os.Exit(0)
+	}
+	if *port != "" {
+		os.Setenv("PORT", *port)
+	}
+	if *logLevel != "" {
+		os.Setenv("LOG_LEVEL", *logLevel)
+	}
+	cl.args = fs.Args()
+	return cl, nil
+}
+
+// Уровни журнала: строки с ⚠️ - предупреждения, с ❌ - ошибки, остальные - информационные
+const (
+	logLevelInfo = iota
+	logLevelWarn
+	logLevelError
+)
+
+// parseLogLevel переводит LOG_LEVEL в уровень журнала; debug принимается как info
+func parseLogLevel(s string) (int, error) {
+	switch strings.ToLower(s) {
+	case "", "info", "debug":
+		return logLevelInfo, nil
+	case "warn", "warning":
+		return logLevelWarn, nil
+	case "error":
+		return logLevelError, nil
+	default:
+		return 0, fmt.Errorf("unsupported LOG_LEVEL %q, use info, warn or error", s)
+	}
+}
+
+// logOutput назначение журнала; служба Windows заменяет его журналом событий
+var logOutput io.Writer = os.Stderr
+
+// logLineLevel уровень строки журнала по ее значку
+func logLineLevel(p []byte) int {
+	switch {
+	case bytes.Contains(p, []byte("❌")):
+		return logLevelError
+	case bytes.Contains(p, []byte("⚠️")):
+		return logLevelWarn
+	}
+	return logLevelInfo
+}
+
+// levelWriter пропускает в журнал только строки не ниже заданного уровня
+type levelWriter struct {
+	out io.Writer
+	min int
+}
+
+func (lw levelWriter) Write(p []byte) (int, error) {
+	if logLineLevel(p) < lw.min {
+		return len(p), nil
+	}
+	return lw.out.Write(p)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// dataFreshness возраст данных с последней успешной синхронизации и признак устаревания
+// по DATA_STALE_AFTER. Контроллеры сами решают, что делать с устаревшими данными.
+type dataFreshness struct {
+	DataAgeSeconds *int64 `json:"data_age_seconds,omitempty"`
+	Stale          bool   `json:"stale"`
+}
+
+// currentDataFreshness вычисляет возраст данных; если он неизвестен, поле возраста пустое,
+// а данные считаются устаревшими только когда синхронизаций не было вовсе
+func currentDataFreshness(db *sql.DB) dataFreshness {
+	syncTime, err := lastSyncTime(db)
+	if err != nil {
+		log.Printf("⚠️ Cannot read last sync time: %v", err)
+		return dataFreshness{}
+	}
+	if syncTime.IsZero() {
+		return dataFreshness{Stale: true}
+	}
+	age := time.Since(syncTime)
+	seconds := int64(age.Seconds())
+	return dataFreshness{DataAgeSeconds: &seconds, Stale: age > dataStaleAfter()}
+}
+
+// dataStaleAfter порог устаревания данных; по умолчанию совпадает с STATUS_MAX_SYNC_AGE
+func dataStaleAfter() time.Duration {
+	if config.DataStaleAfter > 0 {
+		return config.DataStaleAfter
+	}
+	return config.StatusMaxSyncAge
+}
+
+// searchResponse ответ API поиска с возрастом данных
+type searchResponse struct {
+	APIResponse
+	dataFreshness
+}
+
+// returnSearchResponse отвечает как returnJSONSuccess или returnJSONError, добавляя возраст данных
+func returnSearchResponse(w http.ResponseWriter, db *sql.DB, data interface{}, message string, statusCode int) {
+	resp := searchResponse{dataFreshness: currentDataFreshness(db)}
+	if statusCode == http.StatusOK {
+		resp.APIResponse = APIResponse{Success: true, Message: message, Data: data}
+	} else {
+		resp.APIResponse = APIResponse{Success: false, Error: message}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Виды дней производственного календаря
+const (
+	calendarHoliday = "holiday" // выходной или праздничный день
+	calendarShort   = "short"   // сокращенный предпраздничный день
+	calendarWorkday = "workday" // рабочий день, перенесенный на выходной
+)
+
+// Holiday день производственного календаря, отличающийся от обычной недели
+type Holiday struct {
+	Date string `json:"date"`
+	Kind string `json:"kind"`
+	Name string `json:"name,omitempty"`
+}
+
+// holidaysClient загружает производственный календарь по HOLIDAYS_URL
+var holidaysClient = &http.Client{Timeout: 30 * time.Second}
+
+// productionCalendar формат производственного календаря xmlcalendar.ru
+type productionCalendar struct {
+	Year     int `xml:"year,attr"`
+	Holidays []struct {
+		ID    string `xml:"id,attr"`
+		Title string `xml:"title,attr"`
+	} `xml:"holidays>holiday"`
+	Days []struct {
+		D string `xml:"d,attr"`
+		T int    `xml:"t,attr"`
+		H string `xml:"h,attr"`
+	} `xml:"days>day"`
+}
+
+// initHolidaysTable создает таблицу производственного календаря
+func initHolidaysTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS holidays (
+			date DATE PRIMARY KEY,
+			kind VARCHAR(10) NOT NULL,
+			name VARCHAR(255)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating holidays table: %v", err)
+	}
+	return nil
+}
+
+// parseProductionCalendar разбирает XML календаря: t=1 праздник, t=2 сокращенный, t=3 рабочий
+func parseProductionCalendar(r io.Reader) (int, []Holiday, error) {
+	var cal productionCalendar
+	if err := xml.NewDecoder(r).Decode(&cal); err != nil {
+		return 0, nil, fmt.Errorf("invalid production calendar: %v", err)
+	}
+	if cal.Year < 1990 || cal.Year > 2100 {
+		return 0, nil, fmt.Errorf("invalid production calendar year %d", cal.Year)
+	}
+
+	titles := make(map[string]string)
+	for _, h := range cal.Holidays {
+		titles[h.ID] = h.Title
+	}
+	kinds := map[int]string{1: calendarHoliday, 2: calendarShort, 3: calendarWorkday}
+
+	var days []Holiday
+	for _, d := range cal.Days {
+		date, err := time.Parse("02.01.2006", d.D+"."+strconv.Itoa(cal.Year))
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid calendar day %q", d.D)
+		}
+		kind, ok := kinds[d.T]
+		if !ok {
+			return 0, nil, fmt.Errorf("unknown type %d of calendar day %s", d.T, d.D)
+		}
+		days = append(days, Holiday{Date: date.Format("2006-01-02"), Kind: kind, Name: titles[d.H]})
+	}
+	return cal.Year, days, nil
+}
+
+// replaceHolidays перезаписывает календарь за год
+func replaceHolidays(db *sql.DB, year int, days []Holiday) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM holidays WHERE EXTRACT(YEAR FROM date) = $1", year); err != nil {
+		return fmt.Errorf("error clearing holidays of %d: %v", year, err)
+	}
+	for _, d := range days {
+		_, err := tx.Exec("INSERT INTO holidays (date, kind, name) VALUES ($1, $2, NULLIF($3, ''))", d.Date, d.Kind, d.Name)
+		if err != nil {
+			return fmt.Errorf("error inserting holiday %s: %v", d.Date, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// downloadProductionCalendar загружает календарь за год по шаблону HOLIDAYS_URL ({year} заменяется на год)
+func downloadProductionCalendar(year int) (io.ReadCloser, error) {
+	if config.HolidaysURL == "" {
+		return nil, fmt.Errorf("HOLIDAYS_URL is not configured, upload the calendar XML in the request body")
+	}
+	url := strings.ReplaceAll(config.HolidaysURL, "{year}", strconv.Itoa(year))
+	resp, err := holidaysClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading production calendar: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("production calendar download returned %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// loadCalendar возвращает виды дней календаря за период [from, to)
+func loadCalendar(db *sql.DB, from, to time.Time) (map[string]string, error) {
+	rows, err := db.Query("SELECT to_char(date, 'YYYY-MM-DD'), kind FROM holidays WHERE date >= $1 AND date < $2", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("holidays query error: %v", err)
+	}
+	defer rows.Close()
+
+	calendar := make(map[string]string)
+	for rows.Next() {
+		var date, kind string
+		if err := rows.Scan(&date, &kind); err != nil {
+			return nil, fmt.Errorf("error scanning holiday: %v", err)
+		}
+		calendar[date] = kind
+	}
+	return calendar, rows.Err()
+}
+
+// isWorkday определяет рабочий день по календарю, а без записи в календаре по дню недели
+func isWorkday(calendar map[string]string, day time.Time) bool {
+	switch calendar[day.Format("2006-01-02")] {
+	case calendarHoliday:
+		return false
+	case calendarWorkday, calendarShort:
+		return true
+	}
+	return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+}
+
+// holidaysHandler отдает календарь за год (GET ?year=) или импортирует его (POST):
+// XML в теле запроса или загрузка по HOLIDAYS_URL, если тело пустое
+func holidaysHandler(w http.ResponseWriter, r *http.Request) {
+	year := time.Now().Year()
+	if s := r.URL.Query().Get("year"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1990 || n > 2100 {
+			returnJSONError(w, "Invalid 'year' parameter", http.StatusBadRequest)
+			return
+		}
+		year = n
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		from := time.Date(year, 1, 1, 0, 0, 0, 0, time.Local)
+		rows, err := pgDB.Query(`
+			SELECT to_char(date, 'YYYY-MM-DD'), kind, COALESCE(name, '')
+			FROM holidays WHERE date >= $1 AND date < $2 ORDER BY date
+		`, from, from.AddDate(1, 0, 0))
+		if err != nil {
+			returnJSONError(w, fmt.Sprintf("Holidays query error: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		days := []Holiday{}
+		for rows.Next() {
+			var d Holiday
+			if err := rows.Scan(&d.Date, &d.Kind, &d.Name); err != nil {
+				returnJSONError(w, fmt.Sprintf("Error scanning holiday: %v", err), http.StatusInternalServerError)
+				return
+			}
+			days = append(days, d)
+		}
+		returnJSONSuccess(w, days, fmt.Sprintf("%d calendar days in %d", len(days), year))
+
+	case http.MethodPost:
+		body := r.Body
+		if r.ContentLength == 0 {
+			if body, err = downloadProductionCalendar(year); err != nil {
+				returnJSONError(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+		}
+		defer body.Close()
+
+		calYear, days, err := parseProductionCalendar(io.LimitReader(body, 1<<20))
+		if err != nil {
+			returnJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := replaceHolidays(pgDB, calYear, days); err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("📆 Imported production calendar for %d: %d days", calYear, len(days))
+		returnJSONSuccess(w, days, fmt.Sprintf("Imported %d calendar days for %d", len(days), calYear))
+
+	default:
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// newHTTPServer создает сервер с ограничениями времени и размеров запросов из конфигурации,
+// чтобы медленные клиенты не держали соединения и горутины бесконечно
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           withRealClientAddr(limitRequestBody(handler)),
+		ReadHeaderTimeout: config.HTTPReadHeaderTimeout,
+		ReadTimeout:       config.HTTPReadTimeout,
+		WriteTimeout:      config.HTTPWriteTimeout,
+		IdleTimeout:       config.HTTPIdleTimeout,
+		MaxHeaderBytes:    config.HTTPMaxHeaderBytes,
+	}
+}
+
+// limitRequestBody ограничивает тело запроса HTTP_MAX_BODY_BYTES; при превышении обработчик получит ошибку чтения
+func limitRequestBody(next http.Handler) http.Handler {
+	if config.HTTPMaxBodyBytes <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > int64(config.HTTPMaxBodyBytes) {
+			returnJSONError(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, int64(config.HTTPMaxBodyBytes))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withoutWriteTimeout снимает HTTP_WRITE_TIMEOUT для заведомо долгих ответов: синхронизации, выгрузок и отчетов
+func withoutWriteTimeout(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if config.HTTPWriteTimeout > 0 {
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+				log.Printf("⚠️ Cannot lift write timeout for %s: %v", r.URL.Path, err)
+			}
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Типы идентификаторов в staff_cards
+const (
+	identifierCard      = "card"
+	identifierPIN       = "pin"
+	identifierMobile    = "mobile"
+	identifierBiometric = "biometric"
+	identifierPlate     = "plate"
+)
+
+// IdentifierType класс идентификатора, который сообщает считыватель
+type IdentifierType struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// identifierTypes поддерживаемые типы идентификаторов
+var identifierTypes = []IdentifierType{
+	{identifierCard, "Proximity card"},
+	{identifierPIN, "PIN code"},
+	{identifierMobile, "Mobile ID"},
+	{identifierBiometric, "Biometric template ID"},
+	{identifierPlate, "Vehicle license plate"},
+}
+
+// identifierTypeAliases другие названия типов, встречающиеся в источниках
+var identifierTypeAliases = map[string]string{
+	"":          identifierCard,
+	"proximity": identifierCard,
+	"rfid":      identifierCard,
+	"code":      identifierPIN,
+	"phone":     identifierMobile,
+	"ble":       identifierMobile,
+	"nfc":       identifierMobile,
+	"bio":       identifierBiometric,
+	"face":      identifierBiometric,
+	"finger":    identifierBiometric,
+	"vehicle":   identifierPlate,
+	"lpr":       identifierPlate,
+}
+
+// normalizeIdentifierType приводит тип из источника к одному из identifierTypes
+func normalizeIdentifierType(t string) (string, error) {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if alias, ok := identifierTypeAliases[t]; ok {
+		return alias, nil
+	}
+	for _, it := range identifierTypes {
+		if it.Code == t {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown identifier type %q", t)
+}
+
+// initIdentifierTypesTable создает справочник типов идентификаторов
+func initIdentifierTypesTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS identifier_types (
+			code VARCHAR(20) PRIMARY KEY,
+			description VARCHAR(255) NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating identifier_types table: %v", err)
+	}
+	seed := "INSERT INTO identifier_types (code, description) VALUES ($1, $2)" +
+		targetDialect().upsert("code", "description")
+	for _, it := range identifierTypes {
+		_, err := db.Exec(seed, it.Code, it.Description)
+		if err != nil {
+			return fmt.Errorf("error seeding identifier type %s: %v", it.Code, err)
+		}
+	}
+	return nil
+}
+
+// identifierTypesHandler возвращает список поддерживаемых типов идентификаторов
+func identifierTypesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	returnJSONSuccess(w, identifierTypes, fmt.Sprintf("%d identifier types", len(identifierTypes)))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// trigramIndexes GIN-индексы pg_trgm для колонок, по которым идет поиск ILIKE
+var trigramIndexes = []struct {
+	name, table, column string
+}{
+	{"staff_last_name_trgm_idx", "staff", "last_name"},
+	{"staff_first_name_trgm_idx", "staff", "first_name"},
+	{"staff_middle_name_trgm_idx", "staff", "middle_name"},
+	{"staff_email_trgm_idx", "staff", "email"},
+	{"staff_ad_account_trgm_idx", "staff", "ad_account"},
+	{"staff_tab_number_trgm_idx", "staff", "tab_number"},
+	{"cards_identifier_trgm_idx", "cards", "identifier"},
+	{"staff_name_key_trgm_idx", "staff", "name_key"},
+}
+
+// initSearchIndexes создает btree-индексы фильтров и, если разрешено, trigram-индексы поиска.
+// Без прав на CREATE EXTENSION поиск продолжает работать последовательным сканированием.
+func initSearchIndexes(db *sql.DB) error {
+	statements := []string{
+		`CREATE INDEX IF NOT EXISTS staff_position_idx ON staff (LOWER(position))`,
+		`CREATE INDEX IF NOT EXISTS staff_department_idx ON staff (department_id)`,
+		`CREATE INDEX IF NOT EXISTS staff_site_idx ON staff (site)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("error creating filter indexes: %v", err)
+		}
+	}
+
+	if !config.SearchTrigramIndexes {
+		return nil
+	}
+	if _, err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm"); err != nil {
+		log.Printf("⚠️ pg_trgm is not available, name search will not use indexes: %v", err)
+		return nil
+	}
+	for _, idx := range trigramIndexes {
+		stmt := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (%s gin_trgm_ops)", idx.name, idx.table, idx.column)
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("error creating trigram index %s: %v", idx.name, err)
+		}
+	}
+	log.Printf("🔎 Trigram search indexes are ready")
+	return nil
+}
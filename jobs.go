@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// startPeriodicJob запускает функцию в фоне с заданным интервалом
+func startPeriodicJob(name string, interval time.Duration, job func() error) {
+	// time.NewTicker паникует на неположительном интервале и уронил бы процесс
+	if interval <= 0 {
+		log.Printf("❌ %s is not started: interval %s must be positive", name, interval)
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := job(); err != nil {
+				log.Printf("❌ %s failed: %v", name, err)
+			}
+		}
+	}()
+}
+
+// startDailyJob запускает функцию в фоне каждый день в заданное время "15:04"
+func startDailyJob(name, at string, job func() error) error {
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return fmt.Errorf("invalid time %q for %s: %v", at, name, err)
+	}
+
+	go func() {
+		for {
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+			time.Sleep(time.Until(next))
+
+			if err := job(); err != nil {
+				log.Printf("❌ %s failed: %v", name, err)
+			}
+		}
+	}()
+	return nil
+}
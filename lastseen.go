@@ -0,0 +1,63 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// lastSeenColumn последнее использование карты: проверка через /api/verify по самой карте
+// или событие прохода сотрудника (события PERCo привязаны к сотруднику, а не к карте)
+const lastSeenColumn = `GREATEST(
+		(SELECT last_seen FROM card_last_seen cls WHERE cls.identifier = staff_cards.identifier),
+		(SELECT last_seen FROM staff_last_seen sls WHERE sls.id_staff = staff_cards.id_staff)) AS last_seen`
+
+// initLastSeenTables создает таблицы последнего использования карт и сотрудников
+func initLastSeenTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS card_last_seen (
+			identifier TEXT PRIMARY KEY,
+			last_seen TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS staff_last_seen (
+			id_staff BIGINT PRIMARY KEY,
+			last_seen TIMESTAMPTZ NOT NULL
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("error creating last seen tables: %v", err)
+		}
+	}
+	// Проверки карт записаны через NOW() в поясе сессии, проходы сотрудников - по времени PERCo
+	if err := convertToTimestamptz(db, "card_last_seen", "", "last_seen"); err != nil {
+		return err
+	}
+	return convertToTimestamptz(db, "staff_last_seen", config.SourceTimezone, "last_seen")
+}
+
+// touchCardLastSeen отмечает использование карты при проверке
+func touchCardLastSeen(db *sql.DB, identifier string) error {
+	_, err := db.Exec(`
+		INSERT INTO card_last_seen (identifier, last_seen) VALUES ($1, NOW())
+		ON CONFLICT (identifier) DO UPDATE SET last_seen = EXCLUDED.last_seen
+	`, identifier)
+	if err != nil {
+		return fmt.Errorf("error updating card last seen: %v", err)
+	}
+	return nil
+}
+
+// updateStaffLastSeenFromEvents переносит время последнего события каждого сотрудника начиная с since
+func updateStaffLastSeenFromEvents(db *sql.DB, since time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO staff_last_seen (id_staff, last_seen)
+		SELECT id_staff, MAX(event_time) FROM events WHERE event_time >= $1 GROUP BY id_staff
+		ON CONFLICT (id_staff) DO UPDATE SET last_seen = EXCLUDED.last_seen
+			WHERE staff_last_seen.last_seen < EXCLUDED.last_seen
+	`, since)
+	if err != nil {
+		return fmt.Errorf("error updating staff last seen: %v", err)
+	}
+	return nil
+}
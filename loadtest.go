@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// loadtestSampleSize сколько карт из файла берется для построения запросов
+const loadtestSampleSize = 2000
+
+// loadtestRequest вид запроса нагрузочного теста и его доля в трафике
+type loadtestRequest struct {
+	name   string
+	weight int
+	build  func(sample []StaffCard, rnd *rand.Rand) string
+}
+
+// loadtestMix соотношение запросов как у рабочей установки: в основном проверки от турникетов,
+// реже поиск операторов по номеру карты и по фамилии. Часть проверок идет с неизвестными картами.
+// Проверки идут с dry_run, чтобы не отмечать использование карт и проходы в рабочих данных.
+var loadtestMix = []loadtestRequest{
+	{"verify", 70, func(sample []StaffCard, rnd *rand.Rand) string {
+		if rnd.Intn(10) == 0 {
+			return "/api/verify?identifier=" + fmt.Sprint(rnd.Int63n(1e10)) + "&direction=in&dry_run=true"
+		}
+		sc := sample[rnd.Intn(len(sample))]
+		return "/api/verify?identifier=" + url.QueryEscape(sc.Identifier) + "&type=" + url.QueryEscape(sc.IdentifierType) + "&dry_run=true"
+	}},
+	{"search_card", 20, func(sample []StaffCard, rnd *rand.Rand) string {
+		return "/api/search?card=" + url.QueryEscape(sample[rnd.Intn(len(sample))].Identifier)
+	}},
+	{"search_name", 10, func(sample []StaffCard, rnd *rand.Rand) string {
+		sc := sample[rnd.Intn(len(sample))]
+		name := sc.Identifier
+		if sc.LastName != nil && *sc.LastName != "" {
+			name = *sc.LastName
+		}
+		return "/?search=" + url.QueryEscape(name)
+	}},
+}
+
+// loadtestStats задержки и ошибки по одному виду запросов
+type loadtestStats struct {
+	latencies []time.Duration
+	errors    int
+}
+
+// runLoadtestCommand подает на экземпляр сервиса синтетический трафик поиска и проверок
+// с заданной частотой и выводит перцентили задержек
+func runLoadtestCommand(args []string) error {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	target := fs.String("target", "http://localhost:"+config.Port+config.BasePath, "base URL of the instance under test")
+	rps := fs.Int("rps", 200, "requests per second")
+	duration := fs.Duration("duration", 60*time.Second, "test duration")
+	concurrency := fs.Int("concurrency", 100, "maximum requests in flight")
+	timeout := fs.Duration("timeout", 10*time.Second, "per-request timeout")
+	cardsFile := fs.String("cards", "", "file with card identifiers, one per line, or an NDJSON export")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *rps < 1 || *concurrency < 1 {
+		return fmt.Errorf("--rps and --concurrency must be positive")
+	}
+	if *cardsFile == "" {
+		return fmt.Errorf("--cards is required: a file with card identifiers, one per line, or an NDJSON export")
+	}
+	base := strings.TrimRight(*target, "/")
+
+	client := &http.Client{
+		Timeout:   *timeout,
+		Transport: &http.Transport{MaxIdleConnsPerHost: *concurrency},
+	}
+	sample, err := loadtestSample(*cardsFile)
+	if err != nil {
+		return err
+	}
+	log.Printf("🔥 Load test: %d rps for %s against %s using %d sample cards", *rps, *duration, base, len(sample))
+
+	totalWeight := 0
+	for _, req := range loadtestMix {
+		totalWeight += req.weight
+	}
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	pick := func() loadtestRequest {
+		n := rnd.Intn(totalWeight)
+		for _, req := range loadtestMix {
+			if n < req.weight {
+				return req
+			}
+			n -= req.weight
+		}
+		return loadtestMix[0]
+	}
+
+	var mu sync.Mutex
+	stats := make(map[string]*loadtestStats)
+	for _, req := range loadtestMix {
+		stats[req.name] = &loadtestStats{}
+	}
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, *concurrency)
+	dropped := 0
+
+	ticker := time.NewTicker(time.Second / time.Duration(*rps))
+	defer ticker.Stop()
+	started := time.Now()
+	deadline := time.After(*duration)
+loop:
+	for {
+		select {
+		case <-deadline:
+			break loop
+		case <-ticker.C:
+		}
+		// Если цель не успевает, запросы не копятся: лишние отбрасываются и учитываются отдельно
+		select {
+		case slots <- struct{}{}:
+		default:
+			dropped++
+			continue
+		}
+		req := pick()
+		path := req.build(sample, rnd)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			start := time.Now()
+			ok := loadtestDo(client, base+path)
+			elapsed := time.Since(start)
+
+			mu.Lock()
+			s := stats[req.name]
+			s.latencies = append(s.latencies, elapsed)
+			if !ok {
+				s.errors++
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	printLoadtestReport(stats, time.Since(started), dropped)
+	return nil
+}
+
+// loadtestSample читает карты из файла, чтобы запросы попадали в данные цели: строка файла - либо
+// номер карты, либо запись выгрузки NDJSON. Служебный /api/export для этого не нужен.
+func loadtestSample(path string) ([]StaffCard, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening cards file: %v", err)
+	}
+	defer f.Close()
+	return readLoadtestSample(f)
+}
+
+// readLoadtestSample разбирает строки файла карт; пустые и неразобранные строки пропускаются
+func readLoadtestSample(r io.Reader) ([]StaffCard, error) {
+	var sample []StaffCard
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() && len(sample) < loadtestSampleSize {
+		line := strings.TrimSpace(scanner.Text())
+		var sc StaffCard
+		if strings.HasPrefix(line, "{") {
+			if err := json.Unmarshal([]byte(line), &sc); err != nil {
+				continue
+			}
+		} else {
+			sc.Identifier = line
+		}
+		if sc.Identifier == "" {
+			continue
+		}
+		sample = append(sample, sc)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading cards file: %v", err)
+	}
+	if len(sample) == 0 {
+		return nil, fmt.Errorf("cards file has no identifiers to build requests from")
+	}
+	return sample, nil
+}
+
+// loadtestDo выполняет запрос и дочитывает ответ; ошибкой считаются сбой соединения и коды 5xx
+func loadtestDo(client *http.Client, u string) bool {
+	resp, err := client.Get(u)
+	if err != nil {
+		return false
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+// percentile возвращает перцентиль p отсортированных задержек
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+// printLoadtestReport выводит итог по каждому виду запросов и по всему тесту
+func printLoadtestReport(stats map[string]*loadtestStats, elapsed time.Duration, dropped int) {
+	var all []time.Duration
+	errors := 0
+	fmt.Printf("%-12s %8s %7s %9s %9s %9s %9s\n", "request", "count", "errors", "p50", "p90", "p99", "max")
+	row := func(name string, latencies []time.Duration, errs int) {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		ms := func(d time.Duration) string { return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000) }
+		fmt.Printf("%-12s %8d %7d %9s %9s %9s %9s\n", name, len(latencies), errs,
+			ms(percentile(latencies, 0.50)), ms(percentile(latencies, 0.90)),
+			ms(percentile(latencies, 0.99)), ms(percentile(latencies, 1)))
+	}
+	for _, req := range loadtestMix {
+		s := stats[req.name]
+		row(req.name, s.latencies, s.errors)
+		all = append(all, s.latencies...)
+		errors += s.errors
+	}
+	row("total", all, errors)
+	fmt.Printf("achieved_rps=%.1f dropped=%d\n", float64(len(all))/elapsed.Seconds(), dropped)
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,29 +22,330 @@ import (
 
 // Config структура для хранения конфигурации
 type Config struct {
+	// Веб-сервер и журнал
+	Port     string
+	LogLevel string
+
+	// Служебный порт для /metrics, /debug/pprof и административных API, например 127.0.0.1:9090; пусто - все на основном порту
+	AdminAddr string
+
+	// Префикс, под которым сервис опубликован на обратном прокси, например /perco; пусто - корень домена
+	BasePath string
+
+	// Адреса и подсети обратных прокси, которым доверяются X-Forwarded-For и X-Real-IP
+	TrustedProxies string
+
+	// Несколько организаций: JSON-файл арендаторов, порты их процессов и имя арендатора, заданное шлюзом
+	TenantsFile     string
+	TenantsBasePort int
+	TenantName      string
+
 	FirebirdUser     string
 	FirebirdPassword string
 	FirebirdHost     string
 	FirebirdPort     string
 	FirebirdDB       string
 	FirebirdCharset  string
+
+	// Перекодирование строк Firebird в UTF-8: auto, win1251 или off
+	FirebirdTranscode string
+
+	// Часовые пояса IANA: в каком PERCo хранит время в Firebird и в каком показывать время в API, интерфейсе
+	// и отчетах; пусто - пояс сервиса
+	SourceTimezone  string
+	DisplayTimezone string
+
+	// Демо-режим: сгенерированные сотрудники и проходы вместо PERCo
+	DemoMode       bool
+	DemoStaffCount int
+
+	// Источник данных: firebird, percoweb, csv или demo
+	SourceType       string
+	PercoWebURL      string
+	PercoWebLogin    string
+	PercoWebPassword string
+	PercoWebToken    string
+	PercoWebPageSize int
+	CSVSourceFile    string
+	CSVDelimiter     string
+	VehiclesQuery    string
+
+	// Колонка STAFF_CARDS со сроком действия карты; пусто - сроки задаются только локально
+	CardValidUntilColumn string
+
+	// Выражение Firebird с кодом статуса и соответствие кодов меткам active/blocked/dismissed
+	StatusColumn  string
+	StatusMapping string
+
+	ExtraFields string
+
+	// Ключ дополнительного поля с датой увольнения и блокировка карт уволенных после синхронизации
+	DismissalDateField string
+	DismissalAutoBlock bool
+
+	// Ключ дополнительного поля с датой рождения для поиска дублей сотрудников
+	BirthDateField string
+
+	// Объект (здание) по умолчанию и выражение Firebird, если в одной базе несколько объектов
+	Site       string
+	SiteColumn string
+
+	// Запросы к Firebird для групп доступа; пустой ACCESS_GROUPS_QUERY отключает синхронизацию
+	AccessGroupsQuery      string
+	AccessGroupDoorsQuery  string
+	StaffAccessGroupsQuery string
+	ReadersQuery           string
+	PhotosQuery            string
+	ShiftsQuery            string
+	StaffShiftsQuery       string
+
+	// Целевая база: postgres или mysql (MySQL/MariaDB по MYSQL_DSN вместо POSTGRES_*)
+	TargetDB string
+	MySQLDSN string
+
 	PostgresHost     string
 	PostgresPort     string
 	PostgresUser     string
 	PostgresPassword string
 	PostgresDB       string
 	PostgresSSLMode  string
+	InsertBatchSize  int
+
+	// Схема PostgreSQL и префикс имен таблиц для общей базы нескольких приложений
+	PostgresSchema string
+	TablePrefix    string
+
+	// Реплика только для чтения (DSN) для поиска, статистики и отчетов; пусто - все запросы к основной базе
+	PostgresReadDSN string
+
+	// Число пакетов синхронизации в памяти одновременно: чтение источника идет параллельно записи
+	SyncWorkers int
+
+	// Что делать с повторными идентификаторами в источнике: keep-first, keep-latest-by-staff-id, reject-and-report
+	DuplicatePolicy string
+
+	// Обслуживание таблиц после синхронизации: ANALYZE и при необходимости VACUUM
+	SyncAnalyze bool
+	SyncVacuum  bool
+
+	// Ограничения пулов соединений; 0 - значение database/sql по умолчанию
+	PostgresMaxOpenConns    int
+	PostgresMaxIdleConns    int
+	PostgresConnMaxLifetime time.Duration
+	FirebirdMaxOpenConns    int
+	FirebirdMaxIdleConns    int
+	FirebirdConnMaxLifetime time.Duration
+
+	// Расписание и внешний запуск синхронизации
+	SyncInterval      time.Duration
+	SyncTriggerSecret string
+
+	// Другой интервал вне рабочих часов и окна без синхронизации по расписанию, например 07:30-09:00
+	SyncOffHoursInterval time.Duration
+	SyncWorkHours        string
+	SyncWorkDays         string
+	SyncBlackout         string
+
+	// Команды или URL, вызываемые до и после каждой синхронизации
+	SyncPreHook     string
+	SyncPostHook    string
+	SyncHookTimeout time.Duration
+
+	// Ограничения времени запросов: интерактивный поиск и синхронизация; 0 - без ограничения
+	SearchTimeout time.Duration
+	SyncTimeout   time.Duration
+
+	// Сжатие HTTP-ответов: gzip, zstd или оба через запятую; пусто - без сжатия
+	HTTPCompression string
+
+	// Ограничения HTTP-сервера против медленных клиентов; 0 - без ограничения
+	HTTPReadHeaderTimeout time.Duration
+	HTTPReadTimeout       time.Duration
+	HTTPWriteTimeout      time.Duration
+	HTTPIdleTimeout       time.Duration
+	HTTPMaxHeaderBytes    int
+	HTTPMaxBodyBytes      int
+
+	// Время жизни кэша счетчиков /api/stats; 0 - без кэша
+	StatsCacheTTL time.Duration
+
+	// Обогащение данными из Active Directory
+	ADEnabled      bool
+	ADURL          string
+	ADBindDN       string
+	ADBindPassword string
+	ADBaseDN       string
+	ADFilter       string
+	ADTabAttribute string
+
+	// Мониторинг
+	StatusMaxSyncAge time.Duration
+
+	// Возраст данных, после которого ответы поиска и проверки помечаются stale; 0 - как STATUS_MAX_SYNC_AGE
+	DataStaleAfter time.Duration
+
+	// Trigram-индексы для поиска ILIKE (нужно расширение pg_trgm)
+	SearchTrigramIndexes bool
+
+	// Резервное копирование в S3
+	BackupEnabled   bool
+	BackupInterval  time.Duration
+	BackupRetention int
+	S3Endpoint      string
+	S3AccessKey     string
+	S3SecretKey     string
+	S3Bucket        string
+	S3Prefix        string
+	S3Region        string
+	S3UseSSL        bool
+
+	// Выгрузка в Google Sheets
+	SheetsEnabled         bool
+	SheetsCredentialsFile string
+	SheetsSpreadsheetID   string
+	SheetsRange           string
+	SheetsFilter          string
+	SheetsInterval        time.Duration
+
+	// Рассылка списка карт на внешние контроллеры
+	ConnectorsFile string
+
+	// Публикация состояния в MQTT для Home Assistant
+	MQTTEnabled         bool
+	MQTTBroker          string
+	MQTTUsername        string
+	MQTTPassword        string
+	MQTTNodeID          string
+	MQTTTopicPrefix     string
+	MQTTDiscoveryPrefix string
+	MQTTInterval        time.Duration
+
+	// Уведомления в Slack/Mattermost
+	WebhookURL           string
+	WebhookUsername      string
+	WebhookChannel       string
+	WebhookTemplatesFile string
+	NotifyOnSuccess      bool
+
+	// Уведомления в Telegram: токен бота и чат
+	TelegramBotToken string
+	TelegramChatID   string
+
+	// Уведомления по почте: SMTP-сервер host:port, учетная запись, отправитель и получатели через запятую
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	SMTPTo       string
+
+	// Правила оповещений: подряд неудачных синхронизаций, возраст данных и процент ошибок /api/verify
+	// за интервал проверки; 0 - правило выключено. Повтор того же оповещения не чаще ALERT_COOLDOWN.
+	AlertSyncFailures      int
+	AlertDataMaxAge        time.Duration
+	AlertVerifyErrorRate   int
+	AlertVerifyMinRequests int
+	AlertCheckInterval     time.Duration
+	AlertCooldown          time.Duration
+
+	// Выгрузка файлов и доставка на SFTP/FTP
+	ExportCSVDelimiter  string
+	DeliveryURL         string
+	DeliveryFormat      string
+	DeliveryTime        string
+	DeliveryNamePattern string
+	DeliverySSHKeyFile  string
+	DeliverySSHHostKey  string
+
+	// Телефонный справочник
+	PhonebookFile     string
+	PhonebookFormat   string
+	PhonebookInterval time.Duration
+	PhonebookLDIFBase string
+
+	// События проходов
+	EventsEnabled  bool
+	EventsInterval time.Duration
+	EventsQuery    string
+
+	// Сколько месяцев событий держать в секциях events; старые секции отсоединяются. 0 - все
+	EventsRetentionMonths int
+
+	// Повторные сообщения считывателя (тот же сотрудник, направление и зона) в пределах окна отбрасываются; 0 - все
+	EventsDedupWindow time.Duration
+
+	// Кто в здании: через сколько вход без выхода перестает учитываться (0 - никогда) и названия зон PERCo
+	OccupancyMaxAge time.Duration
+	ZoneNames       string
+
+	// Отправка данных в систему учета рабочего времени
+	TimeTrackingURL      string
+	TimeTrackingToken    string
+	TimeTrackingInterval time.Duration
+	TimeTrackingDays     int
+	TimeTrackingRetries  int
+
+	// Копирование событий в ClickHouse для аналитики (HTTP-интерфейс); пусто - не используется
+	ClickHouseURL       string
+	ClickHouseUser      string
+	ClickHousePassword  string
+	ClickHouseTable     string
+	ClickHouseInterval  time.Duration
+	ClickHouseBatchSize int
+
+	// Запрет повторного входа без выхода; 0 отключает проверку
+	AntipassbackWindow time.Duration
+
+	// Время кэширования ответа /api/verify/full терминалом; 0 - без кэша
+	VerifyFullCacheTTL time.Duration
+
+	// Подписанный офлайн-список для контроллеров: ключ подписи, срок годности файла и период пересборки
+	OfflineListSecret  string
+	OfflineListMaxAge  time.Duration
+	OfflineListRefresh time.Duration
+
+	// Правила расчета отработанного времени
+	WorkDayStart      string
+	WorkDayEnd        string
+	WorkGrace         time.Duration
+	WorkLunchDuration time.Duration
+	WorkLunchAfter    time.Duration
+	WorkDayBoundary   string
+	WorkNightStart    string
+	WorkNightEnd      string
+
+	HolidaysURL string
 }
 
 // StaffCard структура для данных сотрудника и карты
 type StaffCard struct {
-	IDStaff    int64   `json:"id_staff"`
-	Identifier string  `json:"identifier"`
-	LastName   *string `json:"last_name"`
-	FirstName  *string `json:"first_name"`
-	MiddleName *string `json:"middle_name"`
-	Status     *string `json:"status"`
-	Info       *string `json:"info"`
+	IDStaff         int64             `json:"id_staff"`
+	Identifier      string            `json:"identifier"`
+	IdentifierType  string            `json:"identifier_type"`
+	TabNumber       *string           `json:"tab_number"`
+	Site            *string           `json:"site"`
+	WiegandFacility *int              `json:"wiegand_facility"`
+	WiegandNumber   *int              `json:"wiegand_number"`
+	LastName        *string           `json:"last_name"`
+	FirstName       *string           `json:"first_name"`
+	MiddleName      *string           `json:"middle_name"`
+	Status          *string           `json:"status"`
+	Info            *string           `json:"info"`
+	Email           *string           `json:"email"`
+	Phone           *string           `json:"phone"`
+	ADAccount       *string           `json:"ad_account"`
+	DepartmentID    *int64            `json:"department_id"`
+	Department      *string           `json:"department"`
+	Position        *string           `json:"position"`
+	ValidFrom       *time.Time        `json:"valid_from"`
+	ValidUntil      *time.Time        `json:"valid_until"`
+	Blocklisted     bool              `json:"blocklisted"`
+	AccessGroups    []AccessGroup     `json:"access_groups"`
+	LastSeen        *time.Time        `json:"last_seen"`
+	ExtraFields     map[string]string `json:"extra_fields,omitempty"`
+	Notes           *string           `json:"notes"`
+	Tags            []string          `json:"tags"`
+	Custodian       *string           `json:"custodian"`
+	StaffTags       []string          `json:"staff_tags"`
 }
 
 // APIResponse структура для ответов API
@@ -49,6 +354,7 @@ type APIResponse struct {
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"`
 }
 
 var (
@@ -56,36 +362,375 @@ var (
 	tmpl   *template.Template
 )
 
-func init() {
-	// Загрузка .env файла
-	if err := godotenv.Load(); err != nil {
+// loadConfig загружает файл .env (envFile или .env в рабочем каталоге) и читает конфигурацию
+// из окружения. Переменные, уже заданные в окружении, файл не перезаписывает.
+func loadConfig(envFile string) error {
+	if envFile != "" {
+		if err := godotenv.Load(envFile); err != nil {
+			return fmt.Errorf("cannot load config file %s: %v", envFile, err)
+		}
+	} else if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: .env file not found: %v", err)
 	}
 
 	// Инициализация конфигурации
 	config = Config{
+		// Веб-сервер и журнал
+		Port:     getEnv("PORT", "8080"),
+		LogLevel: getEnv("LOG_LEVEL", "info"),
+
+		// Служебный порт для /metrics, /debug/pprof и административных API, например 127.0.0.1:9090; пусто - все на основном порту
+		AdminAddr: getEnv("ADMIN_ADDR", ""),
+
+		// Префикс, под которым сервис опубликован на обратном прокси, например /perco; пусто - корень домена
+		BasePath: normalizeBasePath(getEnv("BASE_PATH", "")),
+
+		// Адреса и подсети обратных прокси, которым доверяются X-Forwarded-For и X-Real-IP
+		TrustedProxies: getEnv("TRUSTED_PROXIES", ""),
+
+		// Несколько организаций: JSON-файл арендаторов, порты их процессов и имя арендатора, заданное шлюзом
+		TenantsFile:     getEnv("TENANTS_FILE", ""),
+		TenantsBasePort: getEnvInt("TENANTS_BASE_PORT", 18080),
+		TenantName:      getEnv("TENANT_NAME", ""),
+
 		FirebirdUser:     getEnv("FIREBIRD_USER", "sysdba"),
 		FirebirdPassword: getEnv("FIREBIRD_PASSWORD", "masterkey"),
 		FirebirdHost:     getEnv("FIREBIRD_HOST", "localhost"),
 		FirebirdPort:     getEnv("FIREBIRD_PORT", "3050"),
 		FirebirdDB:       getEnv("FIREBIRD_DB", ""),
 		FirebirdCharset:  getEnv("FIREBIRD_charset", "UTF8"),
+
+		// Перекодирование строк Firebird в UTF-8: auto, win1251 или off
+		FirebirdTranscode: strings.ToLower(getEnv("FIREBIRD_TRANSCODE", "auto")),
+
+		// Часовые пояса IANA: в каком PERCo хранит время в Firebird и в каком показывать время в API, интерфейсе
+		// и отчетах; пусто - пояс сервиса
+		SourceTimezone:  getEnv("SOURCE_TIMEZONE", ""),
+		DisplayTimezone: getEnv("DISPLAY_TIMEZONE", ""),
+
+		// Демо-режим: сгенерированные сотрудники и проходы вместо PERCo
+		DemoMode:       getEnv("DEMO_MODE", "false") == "true",
+		DemoStaffCount: getEnvInt("DEMO_STAFF_COUNT", 300),
+
+		// Источник данных: firebird, percoweb, csv или demo
+		SourceType:       getEnv("SOURCE_TYPE", "firebird"),
+		PercoWebURL:      getEnv("PERCOWEB_URL", "http://localhost"),
+		PercoWebLogin:    getEnv("PERCOWEB_LOGIN", "admin"),
+		PercoWebPassword: getEnv("PERCOWEB_PASSWORD", ""),
+		PercoWebToken:    getEnv("PERCOWEB_TOKEN", ""),
+		PercoWebPageSize: getEnvInt("PERCOWEB_PAGE_SIZE", 500),
+		CSVSourceFile:    getEnv("CSV_SOURCE_FILE", "staff_cards.csv"),
+		CSVDelimiter:     getEnv("CSV_DELIMITER", ";"),
+		VehiclesQuery:    getEnv("VEHICLES_QUERY", ""),
+
+		// Колонка STAFF_CARDS со сроком действия карты; пусто - сроки задаются только локально
+		CardValidUntilColumn: getEnv("CARD_VALID_UNTIL_COLUMN", ""),
+
+		// Выражение Firebird с кодом статуса и соответствие кодов меткам active/blocked/dismissed
+		StatusColumn:  getEnv("STATUS_COLUMN", ""),
+		StatusMapping: getEnv("STATUS_MAPPING", ""),
+
+		// Дополнительные поля карты: "ключ=выражение Firebird;..." попадают в extra_fields
+		ExtraFields: getEnv("EXTRA_FIELDS", ""),
+
+		// Ключ дополнительного поля с датой увольнения и блокировка карт уволенных после синхронизации
+		DismissalDateField: strings.ToLower(getEnv("DISMISSAL_DATE_FIELD", "")),
+		DismissalAutoBlock: getEnv("DISMISSAL_AUTO_BLOCK", "false") == "true",
+
+		// Ключ дополнительного поля с датой рождения для поиска дублей сотрудников
+		BirthDateField: strings.ToLower(getEnv("BIRTH_DATE_FIELD", "")),
+
+		// Объект (здание) по умолчанию и выражение Firebird, если в одной базе несколько объектов
+		Site:       getEnv("SITE", ""),
+		SiteColumn: getEnv("SITE_COLUMN", ""),
+
+		// Запросы должны вернуть (id, name), (group_id, door_id) и (id_staff, group_id)
+		AccessGroupsQuery:      getEnv("ACCESS_GROUPS_QUERY", ""),
+		AccessGroupDoorsQuery:  getEnv("ACCESS_GROUP_DOORS_QUERY", ""),
+		StaffAccessGroupsQuery: getEnv("STAFF_ACCESS_GROUPS_QUERY", ""),
+		// Запрос считывателей: (id, name, address, door_id, door_name, controller)
+		ReadersQuery: getEnv("READERS_QUERY", ""),
+		// Запрос фотографий: (id_staff, photo); миниатюры строятся при первом запросе
+		PhotosQuery: getEnv("PHOTOS_QUERY", ""),
+		// Рабочие графики: (id, name, start, end) и назначения (id_staff, shift_id)
+		ShiftsQuery:      getEnv("SHIFTS_QUERY", ""),
+		StaffShiftsQuery: getEnv("STAFF_SHIFTS_QUERY", ""),
+
+		// Целевая база: postgres или mysql (MySQL/MariaDB по MYSQL_DSN вместо POSTGRES_*)
+		TargetDB: strings.ToLower(getEnv("TARGET_DB", "postgres")),
+		MySQLDSN: getEnv("MYSQL_DSN", ""),
+
 		PostgresHost:     getEnv("POSTGRES_HOST", "localhost"),
 		PostgresPort:     getEnv("POSTGRES_PORT", "5432"),
 		PostgresUser:     getEnv("POSTGRES_USER", "postgres"),
 		PostgresPassword: getEnv("POSTGRES_PASSWORD", ""),
 		PostgresDB:       getEnv("POSTGRES_DB", "cards_service"),
 		PostgresSSLMode:  getEnv("POSTGRES_SSLMODE", "disable"),
+		InsertBatchSize:  getEnvInt("INSERT_BATCH_SIZE", 500),
+
+		// Схема PostgreSQL и префикс имен таблиц для общей базы нескольких приложений
+		PostgresSchema: getEnv("POSTGRES_SCHEMA", "public"),
+		TablePrefix:    getEnv("TABLE_PREFIX", ""),
+
+		// Реплика только для чтения (DSN) для поиска, статистики и отчетов; пусто - все запросы к основной базе
+		PostgresReadDSN: getEnv("POSTGRES_READ_DSN", ""),
+
+		// Число пакетов синхронизации в памяти одновременно: чтение источника идет параллельно записи
+		SyncWorkers: getEnvInt("SYNC_WORKERS", 2),
+
+		// Что делать с повторными идентификаторами в источнике: keep-first, keep-latest-by-staff-id, reject-and-report
+		DuplicatePolicy: getEnv("DUPLICATE_POLICY", "keep-first"),
+
+		// Обслуживание таблиц после синхронизации: ANALYZE и при необходимости VACUUM
+		SyncAnalyze: getEnv("SYNC_ANALYZE", "true") == "true",
+		SyncVacuum:  getEnv("SYNC_VACUUM", "false") == "true",
+
+		// Ограничения пулов соединений; 0 - значение database/sql по умолчанию
+		PostgresMaxOpenConns:    getEnvInt("POSTGRES_MAX_OPEN_CONNS", 10),
+		PostgresMaxIdleConns:    getEnvInt("POSTGRES_MAX_IDLE_CONNS", 5),
+		PostgresConnMaxLifetime: getEnvDuration("POSTGRES_CONN_MAX_LIFETIME", 30*time.Minute),
+		FirebirdMaxOpenConns:    getEnvInt("FIREBIRD_MAX_OPEN_CONNS", 4),
+		FirebirdMaxIdleConns:    getEnvInt("FIREBIRD_MAX_IDLE_CONNS", 2),
+		FirebirdConnMaxLifetime: getEnvDuration("FIREBIRD_CONN_MAX_LIFETIME", 10*time.Minute),
+
+		// Расписание и внешний запуск синхронизации
+		SyncInterval:      getEnvDuration("SYNC_INTERVAL", 0),
+		SyncTriggerSecret: getEnv("SYNC_TRIGGER_SECRET", ""),
+
+		// Другой интервал вне рабочих часов и окна без синхронизации по расписанию, например 07:30-09:00
+		SyncOffHoursInterval: getEnvDuration("SYNC_OFF_HOURS_INTERVAL", 0),
+		SyncWorkHours:        getEnv("SYNC_WORK_HOURS", "08:00-20:00"),
+		SyncWorkDays:         getEnv("SYNC_WORK_DAYS", "mon-fri"),
+		SyncBlackout:         getEnv("SYNC_BLACKOUT", ""),
+
+		// Команды или URL, вызываемые до и после каждой синхронизации
+		SyncPreHook:     getEnv("SYNC_PRE_HOOK", ""),
+		SyncPostHook:    getEnv("SYNC_POST_HOOK", ""),
+		SyncHookTimeout: getEnvDuration("SYNC_HOOK_TIMEOUT", 5*time.Minute),
+
+		// Ограничения времени запросов: интерактивный поиск и синхронизация; 0 - без ограничения
+		SearchTimeout: getEnvDuration("SEARCH_TIMEOUT", 5*time.Second),
+		SyncTimeout:   getEnvDuration("SYNC_TIMEOUT", 30*time.Minute),
+
+		// Сжатие HTTP-ответов: gzip, zstd или оба через запятую; пусто - без сжатия
+		HTTPCompression: getEnv("HTTP_COMPRESSION", "gzip"),
+
+		// Ограничения HTTP-сервера против медленных клиентов; 0 - без ограничения
+		HTTPReadHeaderTimeout: getEnvDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		HTTPReadTimeout:       getEnvDuration("HTTP_READ_TIMEOUT", 30*time.Second),
+		HTTPWriteTimeout:      getEnvDuration("HTTP_WRITE_TIMEOUT", time.Minute),
+		HTTPIdleTimeout:       getEnvDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		HTTPMaxHeaderBytes:    getEnvInt("HTTP_MAX_HEADER_BYTES", 64<<10),
+		HTTPMaxBodyBytes:      getEnvInt("HTTP_MAX_BODY_BYTES", 10<<20),
+
+		// Время жизни кэша счетчиков /api/stats; 0 - без кэша
+		StatsCacheTTL: getEnvDuration("STATS_CACHE_TTL", 30*time.Second),
+
+		// Обогащение данными из Active Directory
+		ADEnabled:      getEnv("AD_ENABLED", "false") == "true",
+		ADURL:          getEnv("AD_URL", "ldap://localhost:389"),
+		ADBindDN:       getEnv("AD_BIND_DN", ""),
+		ADBindPassword: getEnv("AD_BIND_PASSWORD", ""),
+		ADBaseDN:       getEnv("AD_BASE_DN", ""),
+		ADTabAttribute: getEnv("AD_TAB_ATTRIBUTE", "employeeID"),
+		ADFilter:       getEnv("AD_FILTER", "(&(objectCategory=person)(objectClass=user))"),
+
+		// Мониторинг
+		StatusMaxSyncAge: getEnvDuration("STATUS_MAX_SYNC_AGE", 24*time.Hour),
+
+		// Возраст данных, после которого ответы поиска и проверки помечаются stale; 0 - как STATUS_MAX_SYNC_AGE
+		DataStaleAfter: getEnvDuration("DATA_STALE_AFTER", 0),
+
+		// Trigram-индексы для поиска ILIKE (нужно расширение pg_trgm)
+		SearchTrigramIndexes: getEnv("SEARCH_TRGM_INDEXES", "true") == "true",
+
+		// Резервное копирование в S3
+		BackupEnabled:   getEnv("BACKUP_ENABLED", "false") == "true",
+		BackupInterval:  getEnvDuration("BACKUP_INTERVAL", 24*time.Hour),
+		BackupRetention: getEnvInt("BACKUP_RETENTION", 14),
+		S3Endpoint:      getEnv("S3_ENDPOINT", ""),
+		S3AccessKey:     getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:     getEnv("S3_SECRET_KEY", ""),
+		S3Bucket:        getEnv("S3_BUCKET", ""),
+		S3Prefix:        getEnv("S3_PREFIX", "perco_web/"),
+		S3Region:        getEnv("S3_REGION", ""),
+		S3UseSSL:        getEnv("S3_USE_SSL", "true") == "true",
+
+		// Выгрузка в Google Sheets
+		SheetsEnabled:         getEnv("SHEETS_ENABLED", "false") == "true",
+		SheetsCredentialsFile: getEnv("SHEETS_CREDENTIALS_FILE", "service-account.json"),
+		SheetsSpreadsheetID:   getEnv("SHEETS_SPREADSHEET_ID", ""),
+		SheetsRange:           getEnv("SHEETS_RANGE", "Cards"),
+		SheetsFilter:          getEnv("SHEETS_FILTER", ""),
+		SheetsInterval:        getEnvDuration("SHEETS_INTERVAL", time.Hour),
+
+		// Рассылка списка карт на внешние контроллеры
+		ConnectorsFile: getEnv("CONNECTORS_FILE", ""),
+
+		// Публикация состояния в MQTT для Home Assistant
+		MQTTEnabled:         getEnv("MQTT_ENABLED", "false") == "true",
+		MQTTBroker:          getEnv("MQTT_BROKER", "tcp://localhost:1883"),
+		MQTTUsername:        getEnv("MQTT_USERNAME", ""),
+		MQTTPassword:        getEnv("MQTT_PASSWORD", ""),
+		MQTTNodeID:          getEnv("MQTT_NODE_ID", "main"),
+		MQTTTopicPrefix:     getEnv("MQTT_TOPIC_PREFIX", "perco_web"),
+		MQTTDiscoveryPrefix: getEnv("MQTT_DISCOVERY_PREFIX", "homeassistant"),
+		MQTTInterval:        getEnvDuration("MQTT_INTERVAL", time.Minute),
+
+		// Уведомления в Slack/Mattermost
+		WebhookURL:           getEnv("WEBHOOK_URL", ""),
+		WebhookUsername:      getEnv("WEBHOOK_USERNAME", "perco_web"),
+		WebhookChannel:       getEnv("WEBHOOK_CHANNEL", ""),
+		WebhookTemplatesFile: getEnv("WEBHOOK_TEMPLATES_FILE", ""),
+		NotifyOnSuccess:      getEnv("NOTIFY_ON_SUCCESS", "false") == "true",
+
+		// Уведомления в Telegram: токен бота и чат
+		TelegramBotToken: getEnv("TELEGRAM_BOT_TOKEN", ""),
+		TelegramChatID:   getEnv("TELEGRAM_CHAT_ID", ""),
+
+		// Уведомления по почте: SMTP-сервер host:port, учетная запись, отправитель и получатели через запятую
+		SMTPAddr:     getEnv("SMTP_ADDR", ""),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+		SMTPTo:       getEnv("SMTP_TO", ""),
+
+		// Правила оповещений: подряд неудачных синхронизаций, возраст данных и процент ошибок /api/verify
+		// за интервал проверки; 0 - правило выключено. Повтор того же оповещения не чаще ALERT_COOLDOWN.
+		AlertSyncFailures:      getEnvInt("ALERT_SYNC_FAILURES", 0),
+		AlertDataMaxAge:        getEnvDuration("ALERT_DATA_MAX_AGE", 0),
+		AlertVerifyErrorRate:   getEnvInt("ALERT_VERIFY_ERROR_RATE", 0),
+		AlertVerifyMinRequests: getEnvInt("ALERT_VERIFY_MIN_REQUESTS", 20),
+		AlertCheckInterval:     getEnvDuration("ALERT_CHECK_INTERVAL", time.Minute),
+		AlertCooldown:          getEnvDuration("ALERT_COOLDOWN", time.Hour),
+
+		// Выгрузка файлов и доставка на SFTP/FTP
+		ExportCSVDelimiter:  getEnv("EXPORT_CSV_DELIMITER", ";"),
+		DeliveryURL:         getEnv("DELIVERY_URL", ""),
+		DeliveryFormat:      getEnv("DELIVERY_FORMAT", "csv"),
+		DeliveryTime:        getEnv("DELIVERY_TIME", "02:00"),
+		DeliveryNamePattern: getEnv("DELIVERY_NAME_PATTERN", "cards_{{.Date}}.{{.Ext}}"),
+		DeliverySSHKeyFile:  getEnv("DELIVERY_SSH_KEY_FILE", ""),
+		DeliverySSHHostKey:  getEnv("DELIVERY_SSH_HOST_KEY", ""),
+
+		// Телефонный справочник
+		PhonebookFile:     getEnv("PHONEBOOK_FILE", ""),
+		PhonebookFormat:   getEnv("PHONEBOOK_FORMAT", "csv"),
+		PhonebookInterval: getEnvDuration("PHONEBOOK_INTERVAL", 24*time.Hour),
+		PhonebookLDIFBase: getEnv("PHONEBOOK_LDIF_BASE", "ou=phonebook,dc=example,dc=com"),
+
+		// События проходов
+		EventsEnabled:  getEnv("EVENTS_ENABLED", "false") == "true",
+		EventsInterval: getEnvDuration("EVENTS_INTERVAL", 5*time.Minute),
+		EventsQuery: getEnv("EVENTS_QUERY", `
+			SELECT ID_TB_IN, STAFF_ID, DATE_PASS + TIME_PASS, TYPE_PASS, AREAS_ID
+			FROM TABEL_INTERMEDIADATE
+			WHERE ID_TB_IN > ?
+			ORDER BY ID_TB_IN
+		`),
+
+		// Сколько месяцев событий держать в секциях events; старые секции отсоединяются. 0 - все
+		EventsRetentionMonths: getEnvInt("EVENTS_RETENTION_MONTHS", 0),
+
+		// Повторные сообщения считывателя (тот же сотрудник, направление и зона) в пределах окна отбрасываются; 0 - все
+		EventsDedupWindow: getEnvDuration("EVENTS_DEDUP_WINDOW", 0),
+
+		// Кто в здании: через сколько вход без выхода перестает учитываться (0 - никогда) и названия зон PERCo
+		OccupancyMaxAge: getEnvDuration("OCCUPANCY_MAX_AGE", 24*time.Hour),
+		ZoneNames:       getEnv("ZONE_NAMES", ""),
+
+		// Отправка данных в систему учета рабочего времени
+		TimeTrackingURL:      getEnv("TIMETRACKING_URL", ""),
+		TimeTrackingToken:    getEnv("TIMETRACKING_TOKEN", ""),
+		TimeTrackingInterval: getEnvDuration("TIMETRACKING_INTERVAL", time.Hour),
+		TimeTrackingDays:     getEnvInt("TIMETRACKING_DAYS", 1),
+		TimeTrackingRetries:  getEnvInt("TIMETRACKING_RETRIES", 3),
+
+		// Копирование событий в ClickHouse для аналитики (HTTP-интерфейс); пусто - не используется
+		ClickHouseURL:       getEnv("CLICKHOUSE_URL", ""),
+		ClickHouseUser:      getEnv("CLICKHOUSE_USER", ""),
+		ClickHousePassword:  getEnv("CLICKHOUSE_PASSWORD", ""),
+		ClickHouseTable:     getEnv("CLICKHOUSE_TABLE", "passage_events"),
+		ClickHouseInterval:  getEnvDuration("CLICKHOUSE_INTERVAL", time.Minute),
+		ClickHouseBatchSize: getEnvInt("CLICKHOUSE_BATCH_SIZE", 50000),
+
+		// Запрет повторного входа без выхода; 0 отключает проверку
+		AntipassbackWindow: getEnvDuration("ANTIPASSBACK_WINDOW", 0),
+
+		// Время кэширования ответа /api/verify/full терминалом; 0 - без кэша
+		VerifyFullCacheTTL: getEnvDuration("VERIFY_FULL_CACHE_TTL", 10*time.Second),
+
+		// Подписанный офлайн-список для контроллеров: ключ подписи, срок годности файла и период пересборки
+		OfflineListSecret:  getEnv("OFFLINE_LIST_SECRET", ""),
+		OfflineListMaxAge:  getEnvDuration("OFFLINE_LIST_MAX_AGE", 72*time.Hour),
+		OfflineListRefresh: getEnvDuration("OFFLINE_LIST_REFRESH", time.Minute),
+
+		// Правила расчета отработанного времени
+		WorkDayStart:      getEnv("WORK_DAY_START", "09:00"),
+		WorkDayEnd:        getEnv("WORK_DAY_END", "18:00"),
+		WorkGrace:         getEnvDuration("WORK_GRACE", 0),
+		WorkLunchDuration: getEnvDuration("WORK_LUNCH_DURATION", 0),
+		WorkLunchAfter:    getEnvDuration("WORK_LUNCH_AFTER", 4*time.Hour),
+		WorkDayBoundary:   getEnv("WORK_DAY_BOUNDARY", "00:00"),
+		WorkNightStart:    getEnv("WORK_NIGHT_START", "22:00"),
+		WorkNightEnd:      getEnv("WORK_NIGHT_END", "06:00"),
+
+		// Производственный календарь в формате xmlcalendar.ru, {year} заменяется на год
+		HolidaysURL: getEnv("HOLIDAYS_URL", ""),
+	}
+
+	// В демо-режиме данные генерируются локально, Firebird не нужен
+	if config.DemoMode {
+		config.SourceType = "demo"
+		config.EventsEnabled = false
 	}
+
+	level, err := parseLogLevel(config.LogLevel)
+	if err != nil {
+		return err
+	}
+	log.SetOutput(levelWriter{out: logOutput, min: level})
+	return nil
 }
 
 func getEnv(key, defaultValue string) string {
+	recordSetting(key, defaultValue)
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
 
+// getEnvInt читает целое число из переменной окружения
+func getEnvInt(key string, defaultValue int) int {
+	recordSetting(key, strconv.Itoa(defaultValue))
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		configParseErrors = append(configParseErrors, fmt.Sprintf("%s=%q is not a number", key, value))
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvDuration читает длительность вида "30s", "15m", "24h" из переменной окружения
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	recordSetting(key, formatDuration(defaultValue))
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		configParseErrors = append(configParseErrors, fmt.Sprintf("%s=%q is not a duration like 30s, 15m or 24h", key, value))
+		return defaultValue
+	}
+	return d
+}
+
 // returnJSONError возвращает ошибку в формате JSON
 func returnJSONError(w http.ResponseWriter, message string, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
@@ -96,6 +741,17 @@ func returnJSONError(w http.ResponseWriter, message string, statusCode int) {
 	})
 }
 
+// returnJSONErrorCode возвращает ошибку с машиночитаемым кодом
+func returnJSONErrorCode(w http.ResponseWriter, code, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(APIResponse{
+		Success: false,
+		Error:   message,
+		Code:    code,
+	})
+}
+
 // returnJSONSuccess возвращает успешный ответ в формате JSON
 func returnJSONSuccess(w http.ResponseWriter, data interface{}, message string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -112,7 +768,6 @@ func checkFirebirdConnection() error {
 	if err != nil {
 		return fmt.Errorf("failed to connect to Firebird: %v", err)
 	}
-	defer db.Close()
 
 	// Проверяем подключение с простым запросом
 	var result int
@@ -145,7 +800,6 @@ func checkPostgresConnection() error {
 	if err != nil {
 		return fmt.Errorf("failed to connect to PostgreSQL: %v", err)
 	}
-	defer db.Close()
 
 	// Проверяем подключение с простым запросом
 	var result int
@@ -153,6 +807,10 @@ func checkPostgresConnection() error {
 	if err != nil {
 		return fmt.Errorf("failed to query PostgreSQL: %v", err)
 	}
+	// База MySQL задана в MYSQL_DSN, соединение с ней уже означает, что она существует
+	if !targetDialect().extended {
+		return nil
+	}
 
 	// Проверяем существование базы данных
 	var dbExists bool
@@ -170,35 +828,61 @@ func checkPostgresConnection() error {
 	return nil
 }
 
+// connectFirebird возвращает общий пул соединений с Firebird, создавая его при первом вызове
 func connectFirebird() (*sql.DB, error) {
+	fbPool.Lock()
+	defer fbPool.Unlock()
+	if fbPool.db != nil {
+		return fbPool.db, nil
+	}
+
+	charset, err := validateFirebirdCharset(config.FirebirdCharset)
+	if err != nil {
+		return nil, err
+	}
 	connStr := fmt.Sprintf("%s:%s@%s:%s/%s?charset=%s",
 		config.FirebirdUser,
 		config.FirebirdPassword,
 		config.FirebirdHost,
 		config.FirebirdPort,
 		config.FirebirdDB,
-		config.FirebirdCharset,
+		charset,
 	)
 	log.Printf("Connecting to Firebird: %s@%s:%s/%s",
 		config.FirebirdUser, config.FirebirdHost, config.FirebirdPort, config.FirebirdDB)
 
-	db, err := sql.Open("firebirdsql", connStr)
+	db, err := openPool("firebirdsql", connStr, config.FirebirdMaxOpenConns, config.FirebirdMaxIdleConns,
+		config.FirebirdConnMaxLifetime)
 	if err != nil {
 		log.Printf("Firebird connection error: %v", err)
 		return nil, err
 	}
 
-	// Проверяем подключение
-	if err := db.Ping(); err != nil {
-		log.Printf("Firebird ping error: %v", err)
-		return nil, err
-	}
-
 	log.Printf("✅ Firebird connection established")
+	fbPool.db = db
 	return db, nil
 }
 
+// connectPostgres возвращает общий пул соединений с PostgreSQL, создавая его при первом вызове
 func connectPostgres() (*sql.DB, error) {
+	pgPool.Lock()
+	defer pgPool.Unlock()
+	if pgPool.db != nil {
+		return pgPool.db, nil
+	}
+
+	// MySQL/MariaDB подключается по MYSQL_DSN с теми же ограничениями пула
+	if !targetDialect().extended {
+		db, err := openMySQLPool(config.MySQLDSN)
+		if err != nil {
+			log.Printf("MySQL connection error: %v", err)
+			return nil, err
+		}
+		log.Printf("✅ MySQL connection established")
+		pgPool.db = db
+		return db, nil
+	}
+
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		config.PostgresHost,
 		config.PostgresPort,
@@ -210,113 +894,91 @@ func connectPostgres() (*sql.DB, error) {
 	log.Printf("Connecting to PostgreSQL: %s@%s:%s/%s",
 		config.PostgresUser, config.PostgresHost, config.PostgresPort, config.PostgresDB)
 
-	db, err := sql.Open("postgres", connStr)
+	db, err := openPool(postgresDriver(), withTimeZone(withSearchPath(connStr)), config.PostgresMaxOpenConns, config.PostgresMaxIdleConns,
+		config.PostgresConnMaxLifetime)
 	if err != nil {
 		log.Printf("PostgreSQL connection error: %v", err)
 		return nil, err
 	}
-
-	// Проверяем подключение
-	if err := db.Ping(); err != nil {
-		log.Printf("PostgreSQL ping error: %v", err)
+	if err := ensurePostgresSchema(db); err != nil {
+		db.Close()
+		log.Printf("PostgreSQL connection error: %v", err)
 		return nil, err
 	}
 
 	log.Printf("✅ PostgreSQL connection established")
+	pgPool.db = db
 	return db, nil
 }
 
-func initPostgresTable(db *sql.DB) error {
-	// Проверяем существование таблицы
-	var tableExists bool
-	err := db.QueryRow(`
-		SELECT EXISTS (
-			SELECT FROM information_schema.tables 
-			WHERE table_schema = 'public' 
-			AND table_name = 'staff_cards'
-		)
-	`).Scan(&tableExists)
+// connectPostgresRead возвращает пул реплики POSTGRES_READ_DSN для запросов только на чтение.
+// Без реплики или при ее недоступности используется основная база.
+func connectPostgresRead() (*sql.DB, error) {
+	if config.PostgresReadDSN == "" {
+		return connectPostgres()
+	}
 
-	if err != nil {
-		return fmt.Errorf("error checking table existence: %v", err)
+	pgReadPool.Lock()
+	if pgReadPool.db != nil {
+		db := pgReadPool.db
+		pgReadPool.Unlock()
+		return db, nil
+	}
+	var db *sql.DB
+	var err error
+	if targetDialect().extended {
+		db, err = openPool(postgresDriver(), withTimeZone(withSearchPath(config.PostgresReadDSN)), config.PostgresMaxOpenConns, config.PostgresMaxIdleConns,
+			config.PostgresConnMaxLifetime)
+	} else {
+		db, err = openMySQLPool(config.PostgresReadDSN)
 	}
+	if err == nil {
+		log.Printf("✅ PostgreSQL read replica connection established")
+		pgReadPool.db = db
+	}
+	pgReadPool.Unlock()
 
-	if tableExists {
-		// Проверяем структуру таблицы
-		var columns []string
-		rows, err := db.Query(`
-			SELECT column_name 
-			FROM information_schema.columns 
-			WHERE table_name = 'staff_cards'
-		`)
-		if err != nil {
-			return fmt.Errorf("error checking table structure: %v", err)
-		}
-		defer rows.Close()
+	if err != nil {
+		log.Printf("⚠️ PostgreSQL read replica unavailable, using primary: %v", err)
+		return connectPostgres()
+	}
+	return db, nil
+}
 
-		for rows.Next() {
-			var col string
-			if err := rows.Scan(&col); err != nil {
-				return fmt.Errorf("error scanning column: %v", err)
-			}
-			columns = append(columns, col)
-		}
+// staffCardsView представление со старой структурой staff_cards поверх таблиц staff и cards,
+// чтобы отчеты, OData и внешние потребители читали данные как раньше
+const staffCardsView = `
+	CREATE OR REPLACE VIEW staff_cards AS
+	SELECT c.id_staff, c.identifier, c.identifier_type, s.tab_number, s.site,
+		c.wiegand_facility, c.wiegand_number,
+		s.last_name, s.first_name, s.middle_name, c.status, c.info,
+		s.email, s.phone, s.ad_account, s.department_id, s.position,
+		c.valid_from, c.valid_until, c.extra_fields, c.updated_at, s.name_key,
+		c.notes, c.tags, c.custodian
+	FROM cards c
+	LEFT JOIN staff s ON s.id_staff = c.id_staff`
 
-		requiredColumns := map[string]bool{
-			"id_staff": true, "identifier": true, "last_name": true,
-			"first_name": true, "middle_name": true, "status": true,
-			"info": true, "updated_at": true,
-		}
+func initPostgresTable(db *sql.DB) error {
+	// Справочник типов идентификаторов нужен до создания cards
+	if err := initIdentifierTypesTable(db); err != nil {
+		return err
+	}
 
-		hasAllColumns := true
-		for col := range requiredColumns {
-			found := false
-			for _, c := range columns {
-				if c == col {
-					found = true
-					break
-				}
-			}
-			if !found {
-				hasAllColumns = false
-				break
-			}
-		}
+	// Таблицы staff и cards создаются и переносятся из прежней staff_cards версионированными миграциями
+	if err := runMigrations(db); err != nil {
+		return err
+	}
 
-		if !hasAllColumns {
-			// Переименовываем старую таблицу
-			newName := fmt.Sprintf("staff_cards_old_%s", time.Now().Format("20060102_150405"))
-			_, err := db.Exec(fmt.Sprintf("ALTER TABLE staff_cards RENAME TO %s", newName))
-			if err != nil {
-				return fmt.Errorf("error renaming table: %v", err)
-			}
-			log.Printf("📁 Old table renamed to %s", newName)
-			tableExists = false
-		}
-	}
-
-	if !tableExists {
-		// Создаем новую таблицу с полем updated_at
-		_, err := db.Exec(`
-			CREATE TABLE staff_cards (
-				id_staff BIGINT,
-				identifier TEXT,
-				last_name VARCHAR(255),
-				first_name VARCHAR(255),
-				middle_name VARCHAR(255),
-				status VARCHAR(50),
-				info VARCHAR(50),
-				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-			)
-		`)
-		if err != nil {
-			return fmt.Errorf("error creating table: %v", err)
+	if _, err := db.Exec(staffCardsView); err != nil {
+		return fmt.Errorf("error creating staff_cards view: %v", err)
+	}
+	// В схеме MySQL индексы и card_conflicts создаются миграцией
+	if targetDialect().extended {
+		if err := initCardIndexes(db); err != nil {
+			return err
 		}
-		log.Printf("✅ Created new table 'staff_cards' with updated_at field")
-	} else {
-		log.Printf("✅ Table 'staff_cards' already exists with correct structure")
 	}
-
+	log.Printf("✅ Tables 'staff' and 'cards' are ready")
 	return nil
 }
 
@@ -330,252 +992,132 @@ func updateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Подключаемся к Firebird
-	fbDB, err := connectFirebird()
-	if err != nil {
-		log.Printf("❌ Firebird connection failed: %v", err)
-		returnJSONError(w, fmt.Sprintf("Firebird connection error: %v", err), http.StatusInternalServerError)
+	result, err := runSync()
+	if errors.Is(err, errSyncLocked) {
+		returnJSONError(w, err.Error(), http.StatusConflict)
 		return
 	}
-	defer fbDB.Close()
-
-	// Получаем данные из Firebird
-	log.Println("📥 Fetching data from Firebird...")
-	query := `
-		SELECT s.LAST_NAME, s.FIRST_NAME, s.MIDDLE_NAME, s.ID_STAFF, sc.IDENTIFIER
-		FROM STAFF s
-		JOIN STAFF_CARDS sc ON s.ID_STAFF = sc.STAFF_ID
-	`
-	rows, err := fbDB.Query(query)
 	if err != nil {
-		log.Printf("❌ Firebird query failed: %v", err)
-		returnJSONError(w, fmt.Sprintf("Firebird query error: %v", err), http.StatusInternalServerError)
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var staffCards []StaffCard
-	count := 0
-	for rows.Next() {
-		var sc StaffCard
-		var lastName, firstName, middleName sql.NullString
 
-		err := rows.Scan(&lastName, &firstName, &middleName, &sc.IDStaff, &sc.Identifier)
-		if err != nil {
-			log.Printf("❌ Error scanning row: %v", err)
-			returnJSONError(w, fmt.Sprintf("Error scanning row: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		if lastName.Valid {
-			sc.LastName = &lastName.String
-		}
-		if firstName.Valid {
-			sc.FirstName = &firstName.String
-		}
-		if middleName.Valid {
-			sc.MiddleName = &middleName.String
-		}
-
-		staffCards = append(staffCards, sc)
-		count++
-
-		// Логируем прогресс каждые 100 записей
-		if count%100 == 0 {
-			log.Printf("📥 Fetched %d records...", count)
-		}
-	}
+	returnJSONSuccess(w, result, fmt.Sprintf("Updated %d records", result.RecordsUpdated))
+}
 
-	// Проверяем ошибки после итерации по строкам
-	if err = rows.Err(); err != nil {
-		log.Printf("❌ Error iterating rows: %v", err)
-		returnJSONError(w, fmt.Sprintf("Error iterating rows: %v", err), http.StatusInternalServerError)
+// searchAPIHandler обрабатывает API запросы для поиска по номеру карты
+func searchAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	log.Printf("📥 Successfully fetched %d records from Firebird", count)
-
-	// Проверяем, что есть данные для записи
-	if len(staffCards) == 0 {
-		log.Println("⚠️ No data found in Firebird")
-		returnJSONError(w, "No data found in Firebird", http.StatusInternalServerError)
+	// Получаем параметр card, card_suffix или tab из query string
+	cardNumber := r.URL.Query().Get("card")
+	tabNumber := r.URL.Query().Get("tab")
+	if suffix := r.URL.Query().Get("card_suffix"); suffix != "" && cardNumber == "" {
+		cardNumber = "*" + strings.Trim(suffix, " ")
+	}
+	if cardNumber == "" && tabNumber == "" {
+		returnJSONError(w, "Missing 'card', 'card_suffix' or 'tab' parameter", http.StatusBadRequest)
 		return
 	}
+	// Мусор от сканеров отсекается до запроса к базе, чтобы не отвечать "не найдено"
+	masked := tabNumber == "" && isCardMask(cardNumber)
+	if tabNumber == "" {
+		var err error
+		if masked {
+			cardNumber, err = validateCardMask(cardNumber)
+		} else {
+			cardNumber, err = validateCardInput(cardNumber)
+		}
+		if err != nil {
+			returnCardInputError(w, err)
+			return
+		}
+	}
 
 	// Подключаемся к PostgreSQL
-	pgDB, err := connectPostgres()
+	pgDB, err := connectPostgresRead()
 	if err != nil {
 		log.Printf("❌ PostgreSQL connection failed: %v", err)
 		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
 		return
 	}
-	defer pgDB.Close()
 
-	// Инициализируем таблицу
-	log.Println("🔄 Initializing PostgreSQL table...")
-	err = initPostgresTable(pgDB)
-	if err != nil {
-		log.Printf("❌ Table initialization failed: %v", err)
-		returnJSONError(w, fmt.Sprintf("Table initialization error: %v", err), http.StatusInternalServerError)
-		return
-	}
+	ctx, cancel := searchContext(r)
+	defer cancel()
 
-	// Записываем данные в PostgreSQL
-	log.Println("📤 Writing data to PostgreSQL...")
-	tx, err := pgDB.Begin()
-	if err != nil {
-		log.Printf("❌ Transaction start failed: %v", err)
-		returnJSONError(w, fmt.Sprintf("Transaction error: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	// Гарантируем откат транзакции в случае ошибки
-	defer func() {
+	// По табельному номеру возвращаются все карты сотрудника
+	if tabNumber != "" {
+		results, err := queryStaffCardsContext(ctx, pgDB, "tab_number = $1", strings.TrimSpace(tabNumber))
 		if err != nil {
-			tx.Rollback()
-			log.Println("🔙 Transaction rolled back due to error")
+			log.Printf("❌ Search query failed: %v", err)
+			returnSearchError(w, ctx, err)
+			return
 		}
-	}()
-
-	// Очищаем таблицу перед записью новых данных
-	log.Println("🧹 Clearing existing data...")
-	_, err = tx.Exec("DELETE FROM staff_cards")
-	if err != nil {
-		log.Printf("❌ Error clearing table: %v", err)
-		returnJSONError(w, fmt.Sprintf("Error clearing table: %v", err), http.StatusInternalServerError)
+		if len(results) == 0 {
+			returnSearchResponse(w, pgDB, nil, "Tab number not found", http.StatusNotFound)
+			return
+		}
+		returnSearchResponse(w, pgDB, results, fmt.Sprintf("%d cards found", len(results)), http.StatusOK)
 		return
 	}
 
-	// Обновляем время updated_at для всех записей
-	updateTime := time.Now().Format("2006-01-02 15:04:05")
-
-	stmt, err := tx.Prepare(`
-		INSERT INTO staff_cards 
-		(id_staff, identifier, last_name, first_name, middle_name, status, info, updated_at) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`)
-	if err != nil {
-		log.Printf("❌ Error preparing statement: %v", err)
-		returnJSONError(w, fmt.Sprintf("Error preparing statement: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer stmt.Close()
-
-	// Вставляем данные
-	insertCount := 0
-	for _, sc := range staffCards {
-		_, err := stmt.Exec(
-			sc.IDStaff,
-			sc.Identifier,
-			sc.LastName,
-			sc.FirstName,
-			sc.MiddleName,
-			sc.Status,
-			sc.Info,
-			updateTime,
-		)
+	// По маске возвращается не больше maxCardMaskCards карт; лишняя запись показывает, что совпадений больше
+	if masked {
+		where, args := cardMaskCondition(cardNumber)
+		results, err := queryStaffCardsLimit(ctx, pgDB, where, maxCardMaskCards+1, args...)
 		if err != nil {
-			log.Printf("❌ Error inserting data (ID_STAFF: %d, IDENTIFIER: %s): %v", sc.IDStaff, sc.Identifier, err)
-			returnJSONError(w, fmt.Sprintf("Error inserting data: %v", err), http.StatusInternalServerError)
+			log.Printf("❌ Search query failed: %v", err)
+			returnSearchError(w, ctx, err)
 			return
 		}
-		insertCount++
-
-		// Логируем прогресс каждые 100 записей
-		if insertCount%100 == 0 {
-			log.Printf("📤 Inserted %d records...", insertCount)
+		if len(results) == 0 {
+			returnSearchResponse(w, pgDB, nil, "No cards match the mask", http.StatusNotFound)
+			return
 		}
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		log.Printf("❌ Error committing transaction: %v", err)
-		returnJSONError(w, fmt.Sprintf("Error committing transaction: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	log.Printf("✅ Data update completed: %d records transferred at %s", len(staffCards), updateTime)
-	returnJSONSuccess(w, map[string]interface{}{
-		"records_updated": len(staffCards),
-		"last_update":     updateTime,
-	}, fmt.Sprintf("Updated %d records", len(staffCards)))
-}
-
-// searchAPIHandler обрабатывает API запросы для поиска по номеру карты
-func searchAPIHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Получаем параметр card из query string
-	cardNumber := r.URL.Query().Get("card")
-	if cardNumber == "" {
-		returnJSONError(w, "Missing 'card' parameter", http.StatusBadRequest)
-		return
-	}
-
-	// Подключаемся к PostgreSQL
-	pgDB, err := connectPostgres()
-	if err != nil {
-		log.Printf("❌ PostgreSQL connection failed: %v", err)
-		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		message := fmt.Sprintf("%d cards match the mask", len(results))
+		if len(results) > maxCardMaskCards {
+			message = fmt.Sprintf("More than %d cards match the mask, showing first %d: add more digits", maxCardMaskCards, maxCardMaskCards)
+			results = results[:maxCardMaskCards]
+		}
+		returnSearchResponse(w, pgDB, results, message, http.StatusOK)
 		return
 	}
-	defer pgDB.Close()
 
-	// Выполняем поиск по номеру карты
-	query := `
-		SELECT id_staff, identifier, last_name, first_name, middle_name, status, info
-		FROM staff_cards
-		WHERE identifier = $1
-	`
-	rows, err := pgDB.Query(query, cardNumber)
+	// Выполняем поиск по номеру карты в любом представлении
+	where, args := cardLookupCondition(cardNumber)
+	results, err := queryStaffCardsContext(ctx, pgDB, where, args...)
 	if err != nil {
 		log.Printf("❌ Search query failed: %v", err)
-		returnJSONError(w, fmt.Sprintf("Search error: %v", err), http.StatusInternalServerError)
+		returnSearchError(w, ctx, err)
 		return
 	}
-	defer rows.Close()
-
-	var results []StaffCard
-	for rows.Next() {
-		var sc StaffCard
-		var lastName, firstName, middleName, status, info sql.NullString
-
-		err := rows.Scan(&sc.IDStaff, &sc.Identifier, &lastName, &firstName, &middleName, &status, &info)
-		if err != nil {
-			log.Printf("❌ Error scanning row: %v", err)
-			returnJSONError(w, fmt.Sprintf("Error scanning row: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		if lastName.Valid {
-			sc.LastName = &lastName.String
-		}
-		if firstName.Valid {
-			sc.FirstName = &firstName.String
-		}
-		if middleName.Valid {
-			sc.MiddleName = &middleName.String
-		}
-		if status.Valid {
-			sc.Status = &status.String
-		}
-		if info.Valid {
-			sc.Info = &info.String
-		}
-
-		results = append(results, sc)
-	}
 
+	// Ненайденная карта могла просто еще не доехать из PERCo, поэтому возраст данных отдается и здесь
 	if len(results) == 0 {
-		returnJSONError(w, "Card not found", http.StatusNotFound)
+		returnSearchResponse(w, pgDB, nil, "Card not found", http.StatusNotFound)
 		return
 	}
 
 	// Возвращаем первый найденный результат
-	returnJSONSuccess(w, results[0], "Card found")
+	returnSearchResponse(w, pgDB, results[0], "Card found", http.StatusOK)
+}
+
+// searchPageData данные страницы поиска; создаются на каждый запрос, шаблон их только читает
+type searchPageData struct {
+	BasePath     string
+	SearchTerm   string
+	DepartmentID string
+	Position     string
+	Site         string
+	Tag          string
+	Departments  []Department
+	Positions    []Position
+	Sites        []Site
+	Tags         []TagCount
+	Results      []StaffCard
 }
 
 // searchHandler обрабатывает веб-запросы для поиска (HTML интерфейс)
@@ -585,72 +1127,64 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	searchTerm := r.URL.Query().Get("search")
-	if searchTerm == "" {
-		tmpl.Execute(w, nil)
-		return
+	data := &searchPageData{
+		BasePath:     config.BasePath,
+		SearchTerm:   r.URL.Query().Get("search"),
+		DepartmentID: r.URL.Query().Get("department"),
+		Position:     r.URL.Query().Get("position"),
+		Site:         r.URL.Query().Get("site"),
+		Tag:          r.URL.Query().Get("tag"),
 	}
 
 	// Подключаемся к PostgreSQL
-	pgDB, err := connectPostgres()
+	pgDB, err := connectPostgresRead()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		renderErrorPage(w, http.StatusInternalServerError, "База данных недоступна")
+		return
+	}
+
+	// Списки подразделений, должностей, объектов и тегов нужны для фильтров в форме
+	if data.Departments, err = loadDepartments(pgDB); err != nil {
+		log.Printf("⚠️ Failed to load departments: %v", err)
+	}
+	if data.Positions, err = loadPositions(pgDB); err != nil {
+		log.Printf("⚠️ Failed to load positions: %v", err)
+	}
+	if data.Sites, err = loadSites(pgDB); err != nil {
+		log.Printf("⚠️ Failed to load sites: %v", err)
+	}
+	if targetDialect().extended {
+		if data.Tags, err = loadTagCounts(pgDB); err != nil {
+			log.Printf("⚠️ Failed to load tags: %v", err)
+		}
+	}
+
+	if data.SearchTerm == "" && data.DepartmentID == "" && data.Position == "" && data.Site == "" && data.Tag == "" {
+		renderPage(w, tmpl, data)
 		return
 	}
-	defer pgDB.Close()
 
 	// Выполняем поиск
-	query := `
-		SELECT id_staff, identifier, last_name, first_name, middle_name, status, info
-		FROM staff_cards
-		WHERE last_name ILIKE $1 OR first_name ILIKE $1 OR middle_name ILIKE $1 OR identifier ILIKE $1
-	`
-	rows, err := pgDB.Query(query, "%"+searchTerm+"%")
+	filter, err := cardFilterFromQuery(r.URL.Query())
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Search error: %v", err), http.StatusInternalServerError)
+		renderErrorPage(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	defer rows.Close()
-
-	var results []StaffCard
-	for rows.Next() {
-		var sc StaffCard
-		var lastName, firstName, middleName, status, info sql.NullString
-
-		err := rows.Scan(&sc.IDStaff, &sc.Identifier, &lastName, &firstName, &middleName, &status, &info)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error scanning row: %v", err), http.StatusInternalServerError)
+	ctx, cancel := searchContext(r)
+	defer cancel()
+	data.Results, err = queryStaffCardsContext(ctx, pgDB, filter.where(), filter.args...)
+	if err != nil {
+		log.Printf("❌ Search query failed: %v", err)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			renderErrorPage(w, http.StatusGatewayTimeout, "Поиск не уложился в отведенное время, уточните запрос")
 			return
 		}
-
-		if lastName.Valid {
-			sc.LastName = &lastName.String
-		}
-		if firstName.Valid {
-			sc.FirstName = &firstName.String
-		}
-		if middleName.Valid {
-			sc.MiddleName = &middleName.String
-		}
-		if status.Valid {
-			sc.Status = &status.String
-		}
-		if info.Valid {
-			sc.Info = &info.String
-		}
-
-		results = append(results, sc)
-	}
-
-	data := struct {
-		SearchTerm string
-		Results    []StaffCard
-	}{
-		SearchTerm: searchTerm,
-		Results:    results,
+		renderErrorPage(w, http.StatusInternalServerError, "Ошибка поиска")
+		return
 	}
 
-	tmpl.Execute(w, data)
+	renderPage(w, tmpl, data)
 }
 
 // statsHandler возвращает статистику по данным
@@ -660,59 +1194,81 @@ func statsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Подключаемся к PostgreSQL
-	pgDB, err := connectPostgres()
+	// Счетчики берутся из кэша; пулы и память синхронизации всегда текущие
+	stats, err := getCachedStats()
 	if err != nil {
-		log.Printf("❌ PostgreSQL connection failed: %v", err)
-		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		log.Printf("❌ Stats failed: %v", err)
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer pgDB.Close()
 
-	// Получаем статистику
-	var totalRecords int
-	err = pgDB.QueryRow("SELECT COUNT(*) FROM staff_cards").Scan(&totalRecords)
-	if err != nil {
-		returnJSONError(w, fmt.Sprintf("Error getting stats: %v", err), http.StatusInternalServerError)
+	returnJSONSuccess(w, map[string]interface{}{
+		"total_records":               stats.TotalRecords,
+		"last_update":                 stats.LastUpdate,
+		"database":                    config.PostgresDB,
+		"last_sync_peak_memory_bytes": lastSync.peakMemoryBytes(),
+		"statements":                  preparedStatements.snapshot(),
+		"rejected_card_inputs":        rejectedCardInputStats(),
+		"pools": map[string]*PoolStats{
+			"postgres":         pgPool.stats(),
+			"postgres_replica": pgReadPool.stats(),
+			"firebird":         fbPool.stats(),
+		},
+		"description": "last_update shows when data was last synchronized from Firebird",
+	}, "Statistics retrieved")
+}
+
+func main() {
+	// Флаги командной строки накладываются поверх окружения и .env
+	cl, err := parseFlags(os.Args[1:])
+	if err == flag.ErrHelp {
 		return
 	}
-
-	// Получаем время последнего обновления
-	var lastUpdate sql.NullString
-	err = pgDB.QueryRow("SELECT MAX(updated_at) FROM staff_cards").Scan(&lastUpdate)
 	if err != nil {
-		returnJSONError(w, fmt.Sprintf("Error getting last update time: %v", err), http.StatusInternalServerError)
+		os.Exit(2)
+	}
+	// Под диспетчером служб Windows журнал ведется в журнале событий
+	if len(cl.args) == 0 {
+		startWindowsService()
+	}
+	if err := loadConfig(cl.envFile); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	// Запуск подкоманды вместо веб-сервера
+	if len(cl.args) > 0 {
+		if err := runCommand(cl.args); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
 		return
 	}
 
-	lastUpdateStr := "Never updated"
-	if lastUpdate.Valid {
-		lastUpdateStr = lastUpdate.String
+	// Шлюз нескольких организаций сам к базам не подключается: каждую обслуживает свой процесс
+	if config.TenantsFile != "" {
+		log.Fatalf("❌ %v", runTenantGateway())
 	}
 
-	returnJSONSuccess(w, map[string]interface{}{
-		"total_records": totalRecords,
-		"last_update":   lastUpdateStr,
-		"database":      config.PostgresDB,
-		"description":   "last_update shows when data was last synchronized from Firebird",
-	}, "Statistics retrieved")
-}
+	// Ошибки конфигурации выводятся все сразу, до подключения к базам
+	if err := validateConfig(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
 
-func main() {
 	// Проверка подключения к базам данных при запуске
 	log.Println("🔍 Checking database connections...")
 
-	// Проверка Firebird
-	if err := checkFirebirdConnection(); err != nil {
-		log.Printf("❌ Firebird connection check failed: %v", err)
-	} else {
-		log.Println("✅ Firebird connection check passed")
+	// Проверка Firebird (не нужна, если данные берутся из PERCo-Web API)
+	if config.SourceType == "firebird" {
+		if err := checkFirebirdConnection(); err != nil {
+			log.Printf("❌ Firebird connection check failed: %v", err)
+		} else {
+			log.Println("✅ Firebird connection check passed")
+		}
 	}
 
 	// Проверка PostgreSQL
 	if err := checkPostgresConnection(); err != nil {
 		log.Printf("❌ PostgreSQL connection check failed: %v", err)
-		log.Fatal("Cannot start server without PostgreSQL connection")
+		log.Fatal("❌ Cannot start server without PostgreSQL connection")
 	} else {
 		log.Println("✅ PostgreSQL connection check passed")
 	}
@@ -722,11 +1278,80 @@ func main() {
 	if err != nil {
 		log.Fatalf("❌ Failed to connect to PostgreSQL for table initialization: %v", err)
 	}
-	defer pgDB.Close()
 
 	if err := initPostgresTable(pgDB); err != nil {
 		log.Fatalf("❌ Failed to initialize PostgreSQL table: %v", err)
 	}
+	// Остальные таблицы используют возможности PostgreSQL: секции, JSONB, рекурсивные представления
+	if targetDialect().extended {
+		if err := initSearchIndexes(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize search indexes: %v", err)
+		}
+		if err := initSyncHistoryTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize sync history table: %v", err)
+		}
+		if err := initDepartmentsTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize departments table: %v", err)
+		}
+		if err := initAccessGroupTables(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize access group tables: %v", err)
+		}
+		if err := initReadersTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize readers table: %v", err)
+		}
+		if err := initTemporaryCardsTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize temporary cards table: %v", err)
+		}
+		if err := initLastSeenTables(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize last seen tables: %v", err)
+		}
+		if err := initHolidaysTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize holidays table: %v", err)
+		}
+		if err := initShiftTables(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize shift tables: %v", err)
+		}
+		if err := initStaffPhotosTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize staff photos table: %v", err)
+		}
+		if err := initPassageStateTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize passage state table: %v", err)
+		}
+		if err := initBlocklistTables(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize blocklist tables: %v", err)
+		}
+		if err := initOfflineListTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize offline list table: %v", err)
+		}
+		if err := initExpiryOverridesTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize card expiry table: %v", err)
+		}
+		if err := initCardAnnotationsTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize card annotations table: %v", err)
+		}
+		if err := initStaffTagTables(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize staff tag tables: %v", err)
+		}
+		if err := initCardAssignmentsTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize card assignments table: %v", err)
+		}
+		if err := initCardChangesTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize card changes table: %v", err)
+		}
+		if err := initEventsTable(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize events table: %v", err)
+		}
+		if err := initReportViews(pgDB); err != nil {
+			log.Fatalf("❌ Failed to initialize report views: %v", err)
+		}
+	} else {
+		log.Printf("⚠️ TARGET_DB=%s: departments, access groups, readers, blocklist management, reports, OData, photos and backups are disabled", config.TargetDB)
+	}
+	if config.DemoMode {
+		if err := seedDemoData(pgDB); err != nil {
+			log.Fatalf("❌ Failed to generate demo data: %v", err)
+		}
+	}
 
 	// Инициализация шаблонов
 	var templateErr error
@@ -735,19 +1360,197 @@ func main() {
 		log.Fatalf("❌ Error loading template: %v", templateErr)
 	}
 
-	// Настройка маршрутов
-	http.HandleFunc("/", searchHandler)              // Веб-интерфейс поиска
-	http.HandleFunc("/update", updateHandler)        // Обновление данных из Firebird
-	http.HandleFunc("/api/search", searchAPIHandler) // API поиска по номеру карты
-	http.HandleFunc("/api/stats", statsHandler)      // API статистики
+	// Настройка маршрутов: поиск, проверка доступа и веб-интерфейс всегда на основном порту,
+	// остальное - на служебном ADMIN_ADDR, если он задан
+	publicMux.HandleFunc("/", searchHandler)                              // Веб-интерфейс поиска
+	publicMux.HandleFunc("/update", withoutWriteTimeout(updateHandler))   // Обновление данных из Firebird
+	publicMux.HandleFunc("/api/search", searchAPIHandler)                 // API поиска по номеру карты
+	handleAdmin("/api/stats", statsHandler)                               // API статистики
+	handleAdmin("/status", statusHandler)                                 // Статус для Zabbix/Nagios
+	handleAdmin("/readyz", readyzHandler)                                 // Готовность: база и версия схемы
+	handleAdmin("/api/export", withoutWriteTimeout(exportHandler))        // Выгрузка карт в CSV/XLSX/JSON/NDJSON
+	handleAdmin("/api/sync/trigger", syncTriggerHandler)                  // Запуск синхронизации по webhook
+	handleAdmin("/api/cards", cardListHandler)                            // Список карт с курсором
+	handleAdmin("/api/positions", positionsHandler)                       // Список должностей
+	publicMux.HandleFunc("/api/verify", verifyHandler)                    // Проверка допуска для контроллеров
+	publicMux.HandleFunc("/api/verify/full", verifyFullHandler)           // Проверка допуска для экранов постов охраны
+	handleAdmin("/api/identifier-types", identifierTypesHandler)          // Типы идентификаторов
+	handleAdmin("/api/reports/duplicates", duplicatePersonsReportHandler) // Вероятные дубли сотрудников
+	handleAdmin("/api/sites", sitesHandler)                               // Объекты (здания)
+	handleAdmin("/api/staff/", staffHandler)                              // Сотрудник, его фото и история карт
+	handleAdmin("/metrics", metricsHandler)                               // Метрики Prometheus
+	handleAdmin("/healthz", healthzHandler)                               // Процесс жив
+
+	// Остальные разделы используют таблицы и синтаксис PostgreSQL
+	if targetDialect().extended {
+		handleAdmin("/api/views", viewsHandler)                                              // Представления для Grafana
+		handleAdmin("/api/export/phonebook", withoutWriteTimeout(phonebookHandler))          // Телефонный справочник
+		handleAdmin("/api/changes", changesHandler)                                          // Изменения карт с курсором
+		handleAdmin("/odata/", withoutWriteTimeout(odataHandler))                            // OData фид для Power BI/Excel
+		handleAdmin("/api/departments", departmentsHandler)                                  // Дерево подразделений
+		handleAdmin("/api/tags", tagsHandler)                                                // Теги сотрудников
+		publicMux.HandleFunc("/api/verify/photo/", verifyPhotoHandler)                       // Фото по ссылке из /api/verify/full
+		handleAdmin("/api/reports/attendance", withoutWriteTimeout(attendanceReportHandler)) // Отчет о присутствии
+		handleAdmin("/api/reports/unused", withoutWriteTimeout(unusedCardsReportHandler))    // Карты без использования за N дней
+		handleAdmin("/api/reports/changes", dailyChangesReportHandler)                       // Изменения карт за день
+		handleAdmin("/api/occupancy", occupancyHandler)                                      // Кто в здании
+		handleAdmin("/api/reports/evacuation", evacuationReportHandler)                      // Список эвакуации для пункта сбора
+		handleAdmin("/api/cards/expiring", expiringCardsHandler)                             // Карты с истекающим сроком
+		handleAdmin("/api/cards/expiry", cardExpiryHandler)                                  // Локальный срок действия карты
+		handleAdmin("/api/cards/annotations", cardAnnotationsHandler)                        // Локальные пометки карт
+		handleAdmin("/api/blocklist", blocklistHandler)                                      // Стоп-лист
+		handleAdmin("/api/blocklist/audit", blocklistAuditHandler)                           // Журнал стоп-листа
+		handleAdmin("/api/cards/dismissed/block", dismissedCardsHandler)                     // Блокировка карт уволенных до даты
+		handleAdmin("/api/offline-list", offlineListHandler)                                 // Подписанный офлайн-список для контроллеров
+		handleAdmin("/api/offline-list/version", offlineListVersionHandler)                  // Версия офлайн-списка
+		handleAdmin("/api/access-groups", accessGroupsHandler)                               // Группы доступа
+		handleAdmin("/api/readers", readersHandler)                                          // Считыватели и контроллеры
+		handleAdmin("/api/cards/temporary", temporaryCardsHandler)                           // Временные пропуска
+		handleAdmin("/api/import/csv", csvImportHandler)                                     // Загрузка временных пропусков из CSV
+		handleAdmin("/api/shifts", shiftsHandler)                                            // Рабочие графики
+		handleAdmin("/api/holidays", holidaysHandler)                                        // Производственный календарь
+		handleAdmin("/api/conflicts", conflictsHandler)                                      // Дубликаты идентификаторов
+	}
+
+	// Периодическое резервное копирование в S3
+	if config.BackupEnabled {
+		startBackupScheduler()
+	}
+
+	// Очередь и расписание синхронизаций, запуск по сигналу
+	startSyncWorker()
+	startSyncSignalHandler()
+
+	// Каналы уведомлений
+	if err := initNotifiers(); err != nil {
+		log.Fatalf("❌ Failed to configure notifications: %v", err)
+	}
+	// Оповещения по правилам: неудачные синхронизации, устаревшие данные, ошибки проверки допуска
+	startAlerting()
+
+	// Рассылка списка карт на контроллеры после синхронизации
+	if config.ConnectorsFile != "" {
+		connectors, err = loadConnectors(config.ConnectorsFile)
+		if err != nil {
+			log.Fatalf("❌ Failed to load connectors: %v", err)
+		}
+		onSyncSuccess(pushToConnectors)
+		log.Printf("📡 Loaded %d outbound connectors", len(connectors))
+	}
+
+	// Карты уволенных вносятся в стоп-лист, даже если в PERCo их еще не заблокировали
+	if config.DismissalDateField != "" && config.DismissalAutoBlock {
+		onSyncSuccess(blockDismissedAfterSync)
+	}
+
+	// Офлайн-список пересобирается при первом запросе после синхронизации
+	if config.OfflineListSecret != "" {
+		onSyncSuccess(func(*SyncResult) { invalidateOfflineList() })
+	}
+
+	// Фотографии сотрудников загружаются после синхронизации карт, чтобы не удлинять ее транзакцию
+	if config.PhotosQuery != "" {
+		onSyncSuccess(syncPhotos)
+	}
+
+	// Загрузка событий проходов и отправка учета рабочего времени
+	if config.EventsEnabled {
+		startEventsSync()
+		if config.TimeTrackingURL != "" {
+			startTimeTrackingPush()
+		}
+		if config.ClickHouseURL != "" {
+			if err := startClickHouseSink(); err != nil {
+				log.Fatalf("❌ Failed to start ClickHouse sink: %v", err)
+			}
+		}
+	}
+
+	// Ежедневная доставка выгрузки на SFTP/FTP
+	if config.DeliveryURL != "" {
+		if err := startExportDelivery(); err != nil {
+			log.Fatalf("❌ Failed to schedule export delivery: %v", err)
+		}
+	}
+
+	// Периодическое обновление телефонного справочника
+	if config.PhonebookFile != "" {
+		startPhonebookExport()
+	}
+
+	// Публикация состояния в Home Assistant через MQTT
+	if config.MQTTEnabled {
+		startMQTT()
+	}
+
+	// Периодическая выгрузка в Google Sheets
+	if config.SheetsEnabled {
+		startSheetsExport()
+	}
 
 	// Запуск сервера
-	port := getEnv("PORT", "8080")
-	log.Printf("🚀 Server starting on port %s", port)
+	port := config.Port
+	log.Printf("🚀 Server starting on port %s%s (version %s)", port, config.BasePath, version)
 	log.Printf("📊 Available endpoints:")
 	log.Printf("   GET  /                 - Web interface for search")
 	log.Printf("   POST /update           - Update data from Firebird")
-	log.Printf("   GET  /api/search?card= - API search by card number")
+	log.Printf("   GET  /api/search?card= - API search by card number (or ?tab= by tab number)")
+	log.Printf("   GET  /api/search?card=123*89 or ?card_suffix=4567 - Cards matching a mask (* any digits, ? one digit)")
 	log.Printf("   GET  /api/stats        - API statistics")
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	log.Printf("   GET  /status           - Plaintext health status")
+	log.Printf("   GET  /readyz           - Readiness: database and schema version")
+	log.Printf("   GET  /api/export       - Export cards as CSV/XLSX/JSON/NDJSON")
+	log.Printf("   GET  /api/views        - Database views for Grafana")
+	log.Printf("   GET  /api/export/phonebook - Phone directory as CSV/LDIF")
+	log.Printf("   POST /api/sync/trigger - Signed webhook to queue a sync")
+	log.Printf("   GET  /api/changes?since= - Card changes after cursor")
+	log.Printf("   GET  /api/cards?cursor= - Cards page after cursor, ordered by id_staff")
+	log.Printf("   GET  /odata/           - Read-only OData v4 feed (StaffCards, Events, Readers)")
+	log.Printf("   GET  /api/departments  - Department tree with staff counts")
+	log.Printf("   GET  /api/positions    - Job positions with staff counts")
+	log.Printf("   GET  /api/tags         - Staff tags with staff counts")
+	log.Printf("   GET  /api/verify?identifier=&type=&door=&direction=&dry_run= - Access check with antipassback")
+	log.Printf("   GET  /api/verify/full?identifier=&type=&door=&direction= - Compact guard-post response with photo")
+	log.Printf("   GET  /api/verify/photo/{id}?v= - Guard-post photo, cached immutably")
+	log.Printf("   GET  /api/identifier-types - Supported identifier types")
+	log.Printf("   GET  /api/reports/attendance?from=&to=&department=&site= - Presence intervals (JSON/XLSX)")
+	log.Printf("   GET  /api/reports/unused?days=90&format= - Active cards without verifications or passages (JSON/XLSX)")
+	log.Printf("   GET  /api/reports/duplicates?name_only=&format= - Probable duplicate staff records (JSON/XLSX)")
+	log.Printf("   GET  /api/reports/changes?date=YYYY-MM-DD&format= - Cards added, removed, blocked or renamed on a day (JSON/XLSX)")
+	log.Printf("   GET  /api/occupancy?zone=&details= - People inside by department and zone")
+	log.Printf("   GET  /api/reports/evacuation?zone=&format=html&print= - Printable muster list of people inside by department")
+	log.Printf("   GET  /api/cards/expiring?days= - Cards expiring soon")
+	log.Printf("   POST /api/cards/expiry - Set local card expiry (DELETE to remove)")
+	log.Printf("   GET  /api/cards/annotations - Local card notes, tags and custodian (POST to save, DELETE to remove)")
+	log.Printf("   GET  /api/blocklist    - Blocklist (POST to add, DELETE to remove)")
+	log.Printf("   GET  /api/blocklist/audit - Blocklist change history")
+	log.Printf("   POST /api/cards/dismissed/block?before=&dry_run= - Blocklist cards of staff dismissed before a date")
+	log.Printf("   GET  /api/offline-list - Signed CSV of allowed identifiers for controllers")
+	log.Printf("   GET  /api/offline-list/version - Current offline list version")
+	log.Printf("   GET  /api/access-groups - Access groups with doors")
+	log.Printf("   GET  /api/readers      - Controllers and readers inventory")
+	log.Printf("   GET  /api/cards/temporary - Temporary cards (POST to issue, DELETE to revoke)")
+	log.Printf("   POST /api/import/csv?dry_run=&map=&skip_invalid= - Import temporary cards from CSV")
+	log.Printf("   GET  /api/conflicts    - Identifiers shared by several records")
+	log.Printf("   GET  /api/sites        - Sites (buildings) with staff counts")
+	log.Printf("   GET  /api/shifts       - Work schedules (shifts)")
+	log.Printf("   GET  /api/holidays?year= - Production calendar (POST to import XML)")
+	log.Printf("   GET  /api/staff/{id}   - Employee with all identifiers")
+	log.Printf("   GET  /api/staff/{id}/photo?size=small|medium|full - Staff photo thumbnails")
+	log.Printf("   GET  /api/staff/{id}/cards/history - Identifiers ever assigned to the employee")
+	log.Printf("   GET  /api/staff/{id}/tags - Staff tags (POST to add, PUT to replace, DELETE to remove)")
+	log.Printf("   GET  /api/staff/{id}/notes - Staff notes (POST to add, DELETE to remove)")
+	log.Printf("   GET  /metrics          - Prometheus metrics")
+	log.Printf("   GET  /healthz          - Liveness probe")
+	if config.AdminAddr != "" {
+		log.Printf("   GET  /debug/pprof/     - Go profiler")
+		log.Printf("   Only /, /update, /api/search and /api/verify* are served on port %s, the rest on %s", port, config.AdminAddr)
+		startAdminServer()
+	}
+	// Процесс арендатора доступен только через шлюз, который проверяет ключи API
+	addr := ":" + port
+	if config.TenantName != "" {
+		addr = "127.0.0.1:" + port
+	}
+	log.Fatalf("❌ HTTP server error: %v", newHTTPServer(addr, compressHandler(withBasePath(publicMux))).ListenAndServe())
 }
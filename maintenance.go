@@ -0,0 +1,37 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// syncedTables таблицы, которые полностью перезаписываются при синхронизации
+var syncedTables = []string{
+	"staff", "cards", "departments", "access_groups", "access_group_doors", "staff_access_groups",
+	"readers", "work_shifts", "staff_shifts", "card_assignments", "card_changes", "card_conflicts",
+}
+
+// analyzeSyncedTables обновляет статистику планировщика после перезагрузки данных, не дожидаясь
+// autovacuum; при SYNC_VACUUM вместо ANALYZE выполняется VACUUM (ANALYZE), освобождая удаленные строки.
+// VACUUM нельзя выполнить в транзакции, поэтому вызывается после Commit.
+func analyzeSyncedTables(db *sql.DB) error {
+	command := "ANALYZE"
+	tables := syncedTables
+	if config.SyncVacuum {
+		command = "VACUUM (ANALYZE)"
+	}
+	// В схеме MySQL только staff, cards и card_conflicts, а VACUUM нет
+	if !targetDialect().extended {
+		command = "ANALYZE TABLE"
+		tables = []string{"staff", "cards", "card_conflicts"}
+	}
+	started := time.Now()
+	if _, err := db.Exec(command + " " + strings.Join(tables, ", ")); err != nil {
+		return fmt.Errorf("%s error: %v", command, err)
+	}
+	log.Printf("📊 %s of synced tables completed in %s", command, time.Since(started).Round(time.Millisecond))
+	return nil
+}
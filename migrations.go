@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	migratemysql "github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationFiles версионированные миграции схемы, встроенные в бинарный файл:
+// migrations для PostgreSQL и migrations/mysql для MySQL/MariaDB
+//
+//go:embed migrations/*.sql migrations/mysql/*.sql
+var migrationFiles embed.FS
+
+// newMigrator создает мигратор поверх отдельного соединения из пула; Close возвращает соединение в пул
+func newMigrator(db *sql.DB) (*migrate.Migrate, error) {
+	d := targetDialect()
+	src, err := iofs.New(migrationFiles, d.migrations)
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %v", err)
+	}
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("database connection error: %v", err)
+	}
+	var driver database.Driver
+	if d.extended {
+		driver, err = postgres.WithConnection(context.Background(), conn, &postgres.Config{
+			MigrationsTable: prefixedName("schema_migrations"),
+		})
+	} else {
+		driver, err = migratemysql.WithConnection(context.Background(), conn, &migratemysql.Config{
+			MigrationsTable: prefixedName("schema_migrations"),
+		})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("migration driver error: %v", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, d.name, driver)
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("migration setup error: %v", err)
+	}
+	return m, nil
+}
+
+// runMigrations применяет к базе все миграции, которые еще не применены
+func runMigrations(db *sql.DB) error {
+	m, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("schema migration error: %v", err)
+	}
+	version, _, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("error reading schema version: %v", err)
+	}
+	log.Printf("📁 Schema version %d", version)
+	return nil
+}
+
+// latestSchemaVersion возвращает номер последней встроенной миграции
+func latestSchemaVersion() (uint, error) {
+	entries, err := fs.ReadDir(migrationFiles, targetDialect().migrations)
+	if err != nil {
+		return 0, err
+	}
+	var latest uint
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		prefix, _, _ := strings.Cut(e.Name(), "_")
+		v, err := strconv.ParseUint(prefix, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration file name %q", e.Name())
+		}
+		if uint(v) > latest {
+			latest = uint(v)
+		}
+	}
+	return latest, nil
+}
+
+// currentSchemaVersion читает версию схемы из таблицы schema_migrations; 0 - миграции не применялись
+func currentSchemaVersion(q rowQuerier) (version uint, dirty bool, err error) {
+	exists, err := targetDialect().tableExists(q, "schema_migrations")
+	if err != nil || !exists {
+		return 0, false, err
+	}
+	err = q.QueryRow("SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
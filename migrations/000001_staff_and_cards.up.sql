@@ -0,0 +1,43 @@
+-- Сотрудники и их идентификаторы хранятся отдельно: ФИО и атрибуты не дублируются по картам.
+-- IF NOT EXISTS позволяет применить миграцию к базе, созданной до появления миграций.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS staff (
+	id_staff BIGINT PRIMARY KEY,
+	tab_number VARCHAR(50),
+	site VARCHAR(100),
+	last_name VARCHAR(255),
+	first_name VARCHAR(255),
+	middle_name VARCHAR(255),
+	email VARCHAR(255),
+	phone VARCHAR(100),
+	ad_account VARCHAR(255),
+	department_id BIGINT,
+	position VARCHAR(255),
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS cards (
+	id_staff BIGINT NOT NULL,
+	identifier TEXT NOT NULL,
+	identifier_type VARCHAR(20) NOT NULL DEFAULT 'card' REFERENCES identifier_types (code),
+	wiegand_facility INTEGER GENERATED ALWAYS AS (CASE WHEN identifier ~ '^[0-9]{1,18}$'
+		THEN ((identifier::BIGINT >> 16) & 255)::INTEGER END) STORED,
+	wiegand_number INTEGER GENERATED ALWAYS AS (CASE WHEN identifier ~ '^[0-9]{1,18}$'
+		THEN (identifier::BIGINT & 65535)::INTEGER END) STORED,
+	status VARCHAR(50),
+	info VARCHAR(50),
+	valid_from TIMESTAMP,
+	valid_until TIMESTAMP,
+	extra_fields JSONB NOT NULL DEFAULT '{}',
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS cards_identifier_idx ON cards (identifier);
+CREATE INDEX IF NOT EXISTS cards_staff_idx ON cards (id_staff);
+-- Индекс для поиска карты по коду объекта и номеру Wiegand
+CREATE INDEX IF NOT EXISTS cards_wiegand_idx ON cards (wiegand_facility, wiegand_number);
+-- Индекс для фильтра ?extra.key=value
+CREATE INDEX IF NOT EXISTS cards_extra_fields_idx ON cards USING GIN (extra_fields);
+
+COMMIT;
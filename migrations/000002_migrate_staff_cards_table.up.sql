@@ -0,0 +1,53 @@
+-- Прежняя денормализованная таблица staff_cards переносится в staff и cards.
+-- Колонки, которых не было в старых версиях, добавляются на месте, поэтому данные
+-- переносятся из таблицы любой версии; после переноса на ее месте создается представление.
+BEGIN;
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_name = 'staff_cards' AND table_type = 'BASE TABLE'
+	) THEN
+		ALTER TABLE staff_cards
+			ADD COLUMN IF NOT EXISTS identifier_type VARCHAR(20),
+			ADD COLUMN IF NOT EXISTS tab_number VARCHAR(50),
+			ADD COLUMN IF NOT EXISTS site VARCHAR(100),
+			ADD COLUMN IF NOT EXISTS last_name VARCHAR(255),
+			ADD COLUMN IF NOT EXISTS first_name VARCHAR(255),
+			ADD COLUMN IF NOT EXISTS middle_name VARCHAR(255),
+			ADD COLUMN IF NOT EXISTS status VARCHAR(50),
+			ADD COLUMN IF NOT EXISTS info VARCHAR(50),
+			ADD COLUMN IF NOT EXISTS email VARCHAR(255),
+			ADD COLUMN IF NOT EXISTS phone VARCHAR(100),
+			ADD COLUMN IF NOT EXISTS ad_account VARCHAR(255),
+			ADD COLUMN IF NOT EXISTS department_id BIGINT,
+			ADD COLUMN IF NOT EXISTS position VARCHAR(255),
+			ADD COLUMN IF NOT EXISTS valid_from TIMESTAMP,
+			ADD COLUMN IF NOT EXISTS valid_until TIMESTAMP,
+			ADD COLUMN IF NOT EXISTS extra_fields JSONB,
+			ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+		INSERT INTO staff (id_staff, tab_number, site, last_name, first_name, middle_name, email, phone,
+			ad_account, department_id, position, updated_at)
+		SELECT DISTINCT ON (id_staff) id_staff, tab_number, site, last_name, first_name, middle_name, email, phone,
+			ad_account, department_id, position, updated_at
+		FROM staff_cards WHERE id_staff IS NOT NULL
+		ORDER BY id_staff, updated_at DESC
+		ON CONFLICT (id_staff) DO NOTHING;
+
+		-- Неизвестные справочнику типы считаются картами, чтобы не потерять строки из-за внешнего ключа
+		INSERT INTO cards (id_staff, identifier, identifier_type, status, info, valid_from, valid_until,
+			extra_fields, updated_at)
+		SELECT sc.id_staff, sc.identifier, COALESCE(t.code, 'card'), sc.status, sc.info, sc.valid_from,
+			sc.valid_until, COALESCE(sc.extra_fields, '{}'), sc.updated_at
+		FROM staff_cards sc
+		LEFT JOIN identifier_types t ON t.code = sc.identifier_type
+		WHERE sc.id_staff IS NOT NULL AND sc.identifier IS NOT NULL;
+
+		DROP TABLE staff_cards;
+	END IF;
+END
+$$;
+
+COMMIT;
@@ -0,0 +1,6 @@
+-- Индекс для постраничной выдачи /api/cards по ключу (id_staff, identifier) без OFFSET
+BEGIN;
+
+CREATE INDEX IF NOT EXISTS cards_staff_identifier_idx ON cards (id_staff, identifier);
+
+COMMIT;
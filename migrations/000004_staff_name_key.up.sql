@@ -0,0 +1,10 @@
+-- Ключ поиска по ФИО: нижний регистр, ё как е, без диакритики. При синхронизации его
+-- заполняет сервис; здесь существующие строки заполняются приближенно до первой синхронизации.
+BEGIN;
+
+ALTER TABLE staff ADD COLUMN IF NOT EXISTS name_key TEXT;
+
+UPDATE staff SET name_key = translate(lower(concat_ws(' ', last_name, first_name, middle_name)), 'ё', 'е')
+WHERE name_key IS NULL;
+
+COMMIT;
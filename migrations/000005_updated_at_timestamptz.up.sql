@@ -0,0 +1,11 @@
+-- Время обновления записей хранится с часовым поясом. Прежние значения толкуются в поясе сессии
+-- (DISPLAY_TIMEZONE или пояс сервера) и все равно перезаписываются первой синхронизацией.
+-- Представление staff_cards и отчеты над ним сервис создает заново после миграций.
+BEGIN;
+
+DROP VIEW IF EXISTS staff_cards CASCADE;
+
+ALTER TABLE staff ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
+ALTER TABLE cards ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
+
+COMMIT;
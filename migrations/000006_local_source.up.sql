@@ -0,0 +1,9 @@
+-- Происхождение записей: sync - загружены из PERCo, local - созданы в сервисе (временные пропуска).
+-- Синхронизация удаляет и перезаписывает только строки sync. Временные пропуска прежних версий
+-- после миграции помечены sync: первая синхронизация удалит их и вернет из temporary_cards уже как local.
+BEGIN;
+
+ALTER TABLE staff ADD COLUMN IF NOT EXISTS source VARCHAR(10) NOT NULL DEFAULT 'sync';
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS source VARCHAR(10) NOT NULL DEFAULT 'sync';
+
+COMMIT;
@@ -0,0 +1,9 @@
+-- Локальные пометки карт: заметка, теги и ответственный. Источник их не заполняет; после каждой
+-- синхронизации сервис переносит их в cards из card_annotations по идентификатору карты.
+BEGIN;
+
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS notes TEXT;
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS custodian VARCHAR(255);
+
+COMMIT;
@@ -0,0 +1,64 @@
+-- Схема MySQL/MariaDB для TARGET_DB=mysql: сотрудники, карты и служебные таблицы синхронизации и поиска.
+-- Отчеты, события и журналы используют возможности PostgreSQL и в этой схеме отсутствуют.
+CREATE TABLE IF NOT EXISTS staff (
+	id_staff BIGINT PRIMARY KEY,
+	tab_number VARCHAR(50),
+	site VARCHAR(100),
+	last_name VARCHAR(255),
+	first_name VARCHAR(255),
+	middle_name VARCHAR(255),
+	name_key VARCHAR(800),
+	email VARCHAR(255),
+	phone VARCHAR(100),
+	ad_account VARCHAR(255),
+	department_id BIGINT,
+	position VARCHAR(255),
+	updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+	INDEX staff_name_idx (last_name, first_name),
+	INDEX staff_department_idx (department_id),
+	INDEX staff_site_idx (site)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS cards (
+	id_staff BIGINT NOT NULL,
+	identifier VARCHAR(255) NOT NULL,
+	identifier_type VARCHAR(20) NOT NULL DEFAULT 'card',
+	wiegand_facility INT GENERATED ALWAYS AS (CASE WHEN identifier REGEXP '^[0-9]{1,18}$'
+		THEN (CAST(identifier AS UNSIGNED) >> 16) & 255 END) STORED,
+	wiegand_number INT GENERATED ALWAYS AS (CASE WHEN identifier REGEXP '^[0-9]{1,18}$'
+		THEN CAST(identifier AS UNSIGNED) & 65535 END) STORED,
+	status VARCHAR(50),
+	info VARCHAR(50),
+	valid_from TIMESTAMP NULL,
+	valid_until TIMESTAMP NULL,
+	extra_fields JSON NOT NULL,
+	updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+	INDEX cards_identifier_idx (identifier),
+	INDEX cards_staff_identifier_idx (id_staff, identifier),
+	INDEX cards_wiegand_idx (wiegand_facility, wiegand_number),
+	INDEX cards_updated_at_idx (updated_at),
+	FOREIGN KEY (identifier_type) REFERENCES identifier_types (code)
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS card_conflicts (
+	identifier VARCHAR(255) NOT NULL,
+	id_staff BIGINT NOT NULL,
+	detected_at TIMESTAMP NOT NULL
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS sync_history (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	started_at TIMESTAMP NOT NULL,
+	finished_at TIMESTAMP NOT NULL,
+	success BOOLEAN NOT NULL,
+	records INT NOT NULL DEFAULT 0,
+	error TEXT
+) DEFAULT CHARSET = utf8mb4;
+
+-- Стоп-лист нужен условию проверки доступа; на MySQL он ведется напрямую в базе
+CREATE TABLE IF NOT EXISTS blocklist (
+	identifier VARCHAR(255) PRIMARY KEY,
+	reason TEXT NOT NULL,
+	added_by VARCHAR(255) NOT NULL DEFAULT '',
+	added_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,4 @@
+-- Происхождение записей: sync - загружены из PERCo, local - созданы в сервисе.
+-- Синхронизация удаляет и перезаписывает только строки sync.
+ALTER TABLE staff ADD COLUMN source VARCHAR(10) NOT NULL DEFAULT 'sync';
+ALTER TABLE cards ADD COLUMN source VARCHAR(10) NOT NULL DEFAULT 'sync';
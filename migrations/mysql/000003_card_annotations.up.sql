@@ -0,0 +1,5 @@
+-- Колонки локальных пометок карт для представления staff_cards. В схеме MySQL пометки не ведутся
+-- и колонки остаются пустыми.
+ALTER TABLE cards ADD COLUMN notes TEXT NULL;
+ALTER TABLE cards ADD COLUMN tags JSON NULL;
+ALTER TABLE cards ADD COLUMN custodian VARCHAR(255) NULL;
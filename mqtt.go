@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+var mqttClient mqtt.Client
+
+// haSensor описание сенсора для MQTT discovery Home Assistant
+type haSensor struct {
+	ObjectID    string
+	Name        string
+	Template    string
+	DeviceClass string
+	Unit        string
+	Icon        string
+}
+
+// haSensors сенсоры сервиса, публикуемые в Home Assistant
+var haSensors = []haSensor{
+	{ObjectID: "last_sync", Name: "Last sync", Template: "{{ value_json.last_sync }}", DeviceClass: "timestamp"},
+	{ObjectID: "records", Name: "Card records", Template: "{{ value_json.records }}", Unit: "cards", Icon: "mdi:card-account-details"},
+	{ObjectID: "status", Name: "Status", Template: "{{ value_json.status }}", Icon: "mdi:heart-pulse"},
+	{ObjectID: "last_verify", Name: "Last verify", Template: "{{ value_json.last_verify }}", DeviceClass: "timestamp"},
+}
+
+// haReaderSensors сенсоры считывателей, уже опубликованные в discovery: исчезнувшие после синхронизации
+// считыватели снимаются пустой конфигурацией
+var haReaderSensors = struct {
+	sync.Mutex
+	published map[string]bool
+}{published: make(map[string]bool)}
+
+// readerSensor сенсор времени последней проверки допуска у двери считывателя
+func readerSensor(rd Reader) haSensor {
+	id := strconv.FormatInt(rd.ID, 10)
+	name := rd.Name
+	if name == "" {
+		name = id
+	}
+	return haSensor{
+		ObjectID:    "reader_" + id,
+		Name:        "Reader " + name + " last verify",
+		Template:    "{{ value_json.readers['" + id + "'] }}",
+		DeviceClass: "timestamp",
+	}
+}
+
+// haDiscoveryTopic топик конфигурации сенсора в discovery
+func haDiscoveryTopic(objectID string) string {
+	return fmt.Sprintf("%s/sensor/perco_web_%s/%s/config", config.MQTTDiscoveryPrefix, config.MQTTNodeID, objectID)
+}
+
+// mqttTopic возвращает топик сервиса с заданным суффиксом
+func mqttTopic(suffix string) string {
+	return fmt.Sprintf("%s/%s/%s", config.MQTTTopicPrefix, config.MQTTNodeID, suffix)
+}
+
+// connectMQTT подключается к брокеру и публикует конфигурацию сенсоров
+func connectMQTT() error {
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.MQTTBroker).
+		SetClientID("perco_web_"+config.MQTTNodeID).
+		SetUsername(config.MQTTUsername).
+		SetPassword(config.MQTTPassword).
+		SetAutoReconnect(true).
+		SetWill(mqttTopic("availability"), "offline", 1, true).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			// После переподключения брокер мог потерять retained сообщения
+			publishHADiscovery(c)
+			publishMQTTState()
+		})
+
+	mqttClient = mqtt.NewClient(opts)
+	token := mqttClient.Connect()
+	if !token.WaitTimeout(10 * time.Second) {
+		return fmt.Errorf("MQTT connection timeout")
+	}
+	return token.Error()
+}
+
+// publishHADiscovery публикует конфигурацию сенсоров сервиса и считывателей из таблицы readers
+func publishHADiscovery(c mqtt.Client) {
+	device := map[string]interface{}{
+		"identifiers":  []string{"perco_web_" + config.MQTTNodeID},
+		"name":         "PERCo Web " + config.MQTTNodeID,
+		"manufacturer": "perco_web",
+		"model":        "Card sync service",
+	}
+
+	sensors := append([]haSensor{}, haSensors...)
+	readerIDs := make(map[string]bool)
+	var readers []Reader
+	listed := false
+	// Считыватели ведутся только в схеме PostgreSQL
+	if targetDialect().extended {
+		pgDB, err := connectPostgres()
+		if err == nil {
+			readers, err = loadReaders(pgDB)
+		}
+		if err != nil {
+			log.Printf("⚠️ MQTT reader sensors are not published: %v", err)
+		} else {
+			listed = true
+		}
+	}
+	for _, rd := range readers {
+		s := readerSensor(rd)
+		sensors = append(sensors, s)
+		readerIDs[s.ObjectID] = true
+	}
+
+	for _, s := range sensors {
+		payload := map[string]interface{}{
+			"name":               s.Name,
+			"unique_id":          fmt.Sprintf("perco_web_%s_%s", config.MQTTNodeID, s.ObjectID),
+			"state_topic":        mqttTopic("state"),
+			"value_template":     s.Template,
+			"availability_topic": mqttTopic("availability"),
+			"device":             device,
+		}
+		if s.DeviceClass != "" {
+			payload["device_class"] = s.DeviceClass
+		}
+		if s.Unit != "" {
+			payload["unit_of_measurement"] = s.Unit
+		}
+		if s.Icon != "" {
+			payload["icon"] = s.Icon
+		}
+
+		data, _ := json.Marshal(payload)
+		c.Publish(haDiscoveryTopic(s.ObjectID), 1, true, data)
+	}
+
+	// Без списка считывателей опубликованные сенсоры не снимаются
+	if listed {
+		haReaderSensors.Lock()
+		for objectID := range haReaderSensors.published {
+			if !readerIDs[objectID] {
+				c.Publish(haDiscoveryTopic(objectID), 1, true, "")
+			}
+		}
+		haReaderSensors.published = readerIDs
+		haReaderSensors.Unlock()
+	}
+	c.Publish(mqttTopic("availability"), 1, true, "online")
+}
+
+// publishMQTTState публикует текущее состояние сервиса
+func publishMQTTState() {
+	if mqttClient == nil || !mqttClient.IsConnected() {
+		return
+	}
+
+	state := map[string]interface{}{
+		"status":  "ok",
+		"records": nil,
+	}
+
+	lastVerify, doors := lastVerifyTimes()
+	if !lastVerify.IsZero() {
+		state["last_verify"] = lastVerify.Format(time.RFC3339)
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		state["status"] = "degraded"
+	} else {
+		// Считыватели ведутся только в схеме PostgreSQL
+		if targetDialect().extended {
+			if readers, err := loadReaders(pgDB); err == nil {
+				readerState := make(map[string]string)
+				for _, rd := range readers {
+					if rd.DoorID == nil {
+						continue
+					}
+					if t, ok := doors[*rd.DoorID]; ok {
+						readerState[strconv.FormatInt(rd.ID, 10)] = t.Format(time.RFC3339)
+					}
+				}
+				state["readers"] = readerState
+			}
+		}
+		var records int
+		if err := pgDB.QueryRow("SELECT COUNT(*) FROM staff_cards").Scan(&records); err == nil {
+			state["records"] = records
+		}
+		if syncTime, err := lastSyncTime(pgDB); err == nil && !syncTime.IsZero() {
+			state["last_sync"] = syncTime.Format(time.RFC3339)
+			if time.Since(syncTime) > config.StatusMaxSyncAge {
+				state["status"] = "degraded"
+			}
+		}
+	}
+	if _, _, lastError := lastSync.snapshot(); lastError != "" {
+		state["status"] = "degraded"
+	}
+
+	data, _ := json.Marshal(state)
+	mqttClient.Publish(mqttTopic("state"), 1, true, data)
+}
+
+// startMQTT подключается к брокеру и публикует состояние после синхронизаций и по таймеру
+func startMQTT() {
+	if err := connectMQTT(); err != nil {
+		log.Printf("❌ MQTT connection failed: %v", err)
+		return
+	}
+	log.Printf("🏠 MQTT connected to %s, Home Assistant discovery prefix %q", config.MQTTBroker, config.MQTTDiscoveryPrefix)
+
+	// Синхронизация могла изменить список считывателей
+	onSyncSuccess(func(*SyncResult) {
+		if mqttClient.IsConnected() {
+			publishHADiscovery(mqttClient)
+		}
+		publishMQTTState()
+	})
+	startPeriodicJob("MQTT state publish", config.MQTTInterval, func() error {
+		publishMQTTState()
+		return nil
+	})
+}
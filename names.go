@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// breve комбинируемый знак, отличающий й от и; при свертке имени он сохраняется
+const breve = '\u0306'
+
+// normalizeName приводит имя к NFC, убирает пробелы по краям и схлопывает пробелы внутри
+func normalizeName(s string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(s)), " ")
+}
+
+// normalizeNamePtr нормализует необязательное имя на месте
+func normalizeNamePtr(s *string) {
+	if s != nil {
+		*s = normalizeName(*s)
+	}
+}
+
+// normalizeStaffNames нормализует ФИО и должность загружаемых записей
+func normalizeStaffNames(cards []StaffCard) {
+	for i := range cards {
+		normalizeNamePtr(cards[i].LastName)
+		normalizeNamePtr(cards[i].FirstName)
+		normalizeNamePtr(cards[i].MiddleName)
+		normalizeNamePtr(cards[i].Position)
+	}
+}
+
+// foldName сворачивает строку для поиска: нижний регистр, ё как е, без диакритики (Müller - muller).
+// Й остается отдельной буквой.
+func foldName(s string) string {
+	runes := []rune(norm.NFD.String(strings.ToLower(normalizeName(s))))
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.Is(unicode.Mn, r) && !(r == breve && i > 0 && runes[i-1] == 'и') {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return norm.NFC.String(b.String())
+}
+
+// nameSearchKey ключ поиска по ФИО, который хранится в staff.name_key
+func nameSearchKey(sc StaffCard) string {
+	return foldName(strValue(sc.LastName) + " " + strValue(sc.FirstName) + " " + strValue(sc.MiddleName))
+}
+
+// staffFullName ФИО сотрудника одной строкой без лишних пробелов для пустых частей
+func staffFullName(sc StaffCard) string {
+	return normalizeName(strValue(sc.LastName) + " " + strValue(sc.FirstName) + " " + strValue(sc.MiddleName))
+}
+
+// staffSearchArgs параметры staffSearchCondition для строки поиска
+func staffSearchArgs(term string) []interface{} {
+	return []interface{}{"%" + term + "%", "%" + foldName(term) + "%"}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Типы событий для уведомлений
+const (
+	eventSyncSuccess   = "sync_success"
+	eventSyncFailed    = "sync_failed"
+	eventSecurityAlert = "security_alert"
+	eventAlert         = "alert"
+	eventAlertResolved = "alert_resolved"
+)
+
+// Notification событие, о котором нужно оповестить
+type Notification struct {
+	Kind    string
+	Time    time.Time
+	Records int
+	Error   string
+	Message string
+}
+
+// Notifier канал доставки уведомлений
+type Notifier interface {
+	Name() string
+	Notify(n Notification) error
+}
+
+var notifiers []Notifier
+
+// defaultWebhookTemplates шаблоны сообщений по умолчанию
+var defaultWebhookTemplates = map[string]string{
+	eventSyncSuccess:   "✅ Синхронизация карт завершена: {{.Records}} записей ({{.Time.Format \"02.01.2006 15:04\"}})",
+	eventSyncFailed:    "❌ Ошибка синхронизации карт ({{.Time.Format \"02.01.2006 15:04\"}}): {{.Error}}",
+	eventSecurityAlert: "🚨 {{.Message}}",
+	eventAlert:         "⚠️ {{.Message}}",
+	eventAlertResolved: "✅ {{.Message}}",
+}
+
+// loadNotificationTemplates разбирает шаблоны по умолчанию и переопределения из WEBHOOK_TEMPLATES_FILE;
+// шаблоны общие для всех каналов
+func loadNotificationTemplates() (map[string]*template.Template, error) {
+	sources := make(map[string]string)
+	for kind, text := range defaultWebhookTemplates {
+		sources[kind] = text
+	}
+	if config.WebhookTemplatesFile != "" {
+		data, err := os.ReadFile(config.WebhookTemplatesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook templates: %v", err)
+		}
+		var custom map[string]string
+		if err := json.Unmarshal(data, &custom); err != nil {
+			return nil, fmt.Errorf("invalid webhook templates file: %v", err)
+		}
+		for kind, text := range custom {
+			sources[kind] = text
+		}
+	}
+
+	templates := make(map[string]*template.Template)
+	for kind, text := range sources {
+		t, err := template.New(kind).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook template %q: %v", kind, err)
+		}
+		templates[kind] = t
+	}
+	return templates, nil
+}
+
+// renderNotification формирует текст события по шаблону; false - для события нет шаблона
+func renderNotification(templates map[string]*template.Template, event Notification) (string, bool, error) {
+	t, ok := templates[event.Kind]
+	if !ok {
+		return "", false, nil
+	}
+	var text strings.Builder
+	if err := t.Execute(&text, event); err != nil {
+		return "", false, fmt.Errorf("template error: %v", err)
+	}
+	return text.String(), true, nil
+}
+
+// postNotificationJSON отправляет JSON в HTTP API канала и проверяет код ответа
+func postNotificationJSON(client *http.Client, endpoint string, payload interface{}) error {
+	body, _ := json.Marshal(payload)
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// webhookNotifier отправляет сообщения во входящий webhook Slack или Mattermost
+type webhookNotifier struct {
+	url       string
+	username  string
+	channel   string
+	templates map[string]*template.Template
+	client    *http.Client
+}
+
+// newWebhookNotifier создает канал webhook с общими шаблонами уведомлений
+func newWebhookNotifier(templates map[string]*template.Template) *webhookNotifier {
+	return &webhookNotifier{
+		url:       config.WebhookURL,
+		username:  config.WebhookUsername,
+		channel:   config.WebhookChannel,
+		templates: templates,
+		client:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Name возвращает имя канала
+func (n *webhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify формирует текст по шаблону события и отправляет его в webhook
+func (n *webhookNotifier) Notify(event Notification) error {
+	text, ok, err := renderNotification(n.templates, event)
+	if err != nil || !ok {
+		return err
+	}
+
+	// Формат {"text": ...} понимают и Slack, и Mattermost
+	payload := map[string]string{"text": text}
+	if n.username != "" {
+		payload["username"] = n.username
+	}
+	if n.channel != "" {
+		payload["channel"] = n.channel
+	}
+	if err := postNotificationJSON(n.client, n.url, payload); err != nil {
+		return fmt.Errorf("webhook returned %v", err)
+	}
+	return nil
+}
+
+// telegramNotifier отправляет сообщения в чат Telegram через Bot API
+type telegramNotifier struct {
+	token     string
+	chatID    string
+	templates map[string]*template.Template
+	client    *http.Client
+}
+
+// Name возвращает имя канала
+func (n *telegramNotifier) Name() string {
+	return "telegram"
+}
+
+// Notify отправляет текст события методом sendMessage
+func (n *telegramNotifier) Notify(event Notification) error {
+	text, ok, err := renderNotification(n.templates, event)
+	if err != nil || !ok {
+		return err
+	}
+	endpoint := "https://api.telegram.org/bot" + n.token + "/sendMessage"
+	if err := postNotificationJSON(n.client, endpoint, map[string]string{"chat_id": n.chatID, "text": text}); err != nil {
+		// Ошибка клиента содержит полный адрес запроса, а в нем токен бота: в журнал попадает только причина
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram returned %v", err)
+	}
+	return nil
+}
+
+// emailNotifier отправляет сообщения по почте через SMTP; STARTTLS используется, если сервер его предлагает
+type emailNotifier struct {
+	addr      string
+	auth      smtp.Auth
+	from      string
+	to        []string
+	templates map[string]*template.Template
+}
+
+// newEmailNotifier создает почтовый канал; без SMTP_USERNAME письма отправляются без авторизации
+func newEmailNotifier(templates map[string]*template.Template) *emailNotifier {
+	n := &emailNotifier{addr: config.SMTPAddr, from: config.SMTPFrom, templates: templates}
+	for _, to := range strings.Split(config.SMTPTo, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			n.to = append(n.to, to)
+		}
+	}
+	if config.SMTPUsername != "" {
+		host, _, _ := net.SplitHostPort(config.SMTPAddr)
+		n.auth = smtp.PlainAuth("", config.SMTPUsername, config.SMTPPassword, host)
+	}
+	return n
+}
+
+// Name возвращает имя канала
+func (n *emailNotifier) Name() string {
+	return "email"
+}
+
+// Notify отправляет письмо, тема которого - первая строка текста события
+func (n *emailNotifier) Notify(event Notification) error {
+	text, ok, err := renderNotification(n.templates, event)
+	if err != nil || !ok {
+		return err
+	}
+	subject, _, _ := strings.Cut(text, "\n")
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return smtp.SendMail(n.addr, n.auth, n.from, n.to, []byte(msg.String()))
+}
+
+// notify рассылает событие по всем настроенным каналам в фоне
+func notify(event Notification) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, n := range notifiers {
+		go func(n Notifier) {
+			if err := n.Notify(event); err != nil {
+				log.Printf("⚠️ Notification via %s failed: %v", n.Name(), err)
+			}
+		}(n)
+	}
+}
+
+// securityAlerts время последнего оповещения об отказе по ключу "причина:идентификатор"
+var securityAlerts = struct {
+	sync.Mutex
+	sent map[string]time.Time
+}{sent: make(map[string]time.Time)}
+
+// notifySecurityAlert оповещает службу безопасности об отказе в доступе; по одному ключу
+// не чаще ALERT_COOLDOWN, чтобы карта из стоп-листа, которую прикладывают раз за разом, не засыпала канал
+func notifySecurityAlert(key, message string) {
+	if len(notifiers) == 0 {
+		return
+	}
+	securityAlerts.Lock()
+	now := time.Now()
+	if sent, ok := securityAlerts.sent[key]; ok && now.Sub(sent) < config.AlertCooldown {
+		securityAlerts.Unlock()
+		return
+	}
+	for k, sent := range securityAlerts.sent {
+		if now.Sub(sent) >= config.AlertCooldown {
+			delete(securityAlerts.sent, k)
+		}
+	}
+	securityAlerts.sent[key] = now
+	securityAlerts.Unlock()
+
+	notify(Notification{Kind: eventSecurityAlert, Time: now, Message: message})
+}
+
+// notifySyncResult оповещает о результате синхронизации
+func notifySyncResult(result *SyncResult, err error) {
+	if err != nil {
+		notify(Notification{Kind: eventSyncFailed, Error: err.Error()})
+		return
+	}
+	if config.NotifyOnSuccess {
+		notify(Notification{Kind: eventSyncSuccess, Records: result.RecordsUpdated})
+	}
+}
+
+// initNotifiers настраивает каналы уведомлений из конфигурации
+func initNotifiers() error {
+	if config.WebhookURL == "" && config.TelegramBotToken == "" && config.SMTPAddr == "" {
+		return nil
+	}
+	templates, err := loadNotificationTemplates()
+	if err != nil {
+		return err
+	}
+	if config.WebhookURL != "" {
+		notifiers = append(notifiers, newWebhookNotifier(templates))
+		log.Printf("🔔 Webhook notifications enabled")
+	}
+	if config.TelegramBotToken != "" {
+		notifiers = append(notifiers, &telegramNotifier{
+			token:     config.TelegramBotToken,
+			chatID:    config.TelegramChatID,
+			templates: templates,
+			client:    &http.Client{Timeout: 15 * time.Second},
+		})
+		log.Printf("🔔 Telegram notifications enabled")
+	}
+	if config.SMTPAddr != "" {
+		notifiers = append(notifiers, newEmailNotifier(templates))
+		log.Printf("🔔 Email notifications enabled")
+	}
+	return nil
+}
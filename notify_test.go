@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc транспорт HTTP из функции для подмены сети в тестах
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestTelegramErrorHidesToken(t *testing.T) {
+	templates, err := loadNotificationTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &telegramNotifier{
+		token:     "123456:SECRET-TOKEN",
+		chatID:    "1",
+		templates: templates,
+		client: &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})},
+	}
+
+	err = n.Notify(Notification{Kind: eventAlert, Message: "test"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if strings.Contains(err.Error(), "SECRET-TOKEN") {
+		t.Fatalf("error exposes bot token: %v", err)
+	}
+	if !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("error lost its cause: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Occupant сотрудник, находящийся в здании: последний проход - вход
+type Occupant struct {
+	IDStaff      int64     `json:"id_staff"`
+	Name         string    `json:"name"`
+	DepartmentID *int64    `json:"department_id"`
+	Department   string    `json:"department"`
+	Position     string    `json:"position"`
+	Phone        string    `json:"phone"`
+	ZoneID       *int64    `json:"zone_id"`
+	Zone         string    `json:"zone"`
+	Since        time.Time `json:"since"`
+}
+
+// OccupancyGroup число находящихся в здании по подразделению или зоне
+type OccupancyGroup struct {
+	ID    *int64 `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// OccupancyReport кто в здании: общее число, разбивка по подразделениям и зонам
+type OccupancyReport struct {
+	Total       int              `json:"total"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Departments []OccupancyGroup `json:"departments"`
+	Zones       []OccupancyGroup `json:"zones"`
+	Occupants   []Occupant       `json:"occupants,omitempty"`
+}
+
+// parseZoneNames разбирает ZONE_NAMES вида "1=Главный корпус;2=Склад": названия зон (AREAS_ID) PERCo
+func parseZoneNames(s string) (map[int64]string, error) {
+	names := make(map[int64]string)
+	for _, pair := range strings.Split(s, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, name, ok := strings.Cut(pair, "=")
+		zone, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+		if !ok || err != nil || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid ZONE_NAMES entry %q, expected id=name", pair)
+		}
+		names[zone] = strings.TrimSpace(name)
+	}
+	return names, nil
+}
+
+// zoneName название зоны из ZONE_NAMES или "Зона N"; для прохода без зоны - "Зона не указана"
+func zoneName(names map[int64]string, zone *int64) string {
+	if zone == nil {
+		return "Зона не указана"
+	}
+	if name, ok := names[*zone]; ok {
+		return name
+	}
+	return fmt.Sprintf("Зона %d", *zone)
+}
+
+// loadOccupants возвращает сотрудников, чей последний проход - вход не старше OCCUPANCY_MAX_AGE,
+// отсортированных по подразделению и ФИО; zone ограничивает выборку одной зоной
+func loadOccupants(db *sql.DB, zone *int64) ([]Occupant, error) {
+	names, err := parseZoneNames(config.ZoneNames)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT p.id_staff, p.passed_at, p.area_id,
+			COALESCE(s.last_name, ''), COALESCE(s.first_name, ''), COALESCE(s.middle_name, ''),
+			s.department_id, COALESCE(d.name, ''), COALESCE(s.position, ''), COALESCE(s.phone, '')
+		FROM passage_state p
+		LEFT JOIN staff s ON s.id_staff = p.id_staff
+		LEFT JOIN departments d ON d.id = s.department_id
+		WHERE p.direction = $1`
+	args := []interface{}{directionIn}
+	if config.OccupancyMaxAge > 0 {
+		args = append(args, config.OccupancyMaxAge.Seconds())
+		query += fmt.Sprintf(" AND p.passed_at > NOW() - make_interval(secs => $%d)", len(args))
+	}
+	if zone != nil {
+		args = append(args, *zone)
+		query += fmt.Sprintf(" AND p.area_id = $%d", len(args))
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Occupancy query error: %v", err)
+	}
+	defer rows.Close()
+
+	occupants := []Occupant{}
+	for rows.Next() {
+		var o Occupant
+		var lastName, firstName, middleName string
+		if err := rows.Scan(&o.IDStaff, &o.Since, &o.ZoneID, &lastName, &firstName, &middleName,
+			&o.DepartmentID, &o.Department, &o.Position, &o.Phone); err != nil {
+			return nil, fmt.Errorf("Error scanning occupant: %v", err)
+		}
+		o.Name = normalizeName(lastName + " " + firstName + " " + middleName)
+		o.Zone = zoneName(names, o.ZoneID)
+		occupants = append(occupants, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(occupants, func(i, j int) bool {
+		if occupants[i].Department != occupants[j].Department {
+			return occupants[i].Department < occupants[j].Department
+		}
+		return occupants[i].Name < occupants[j].Name
+	})
+	return occupants, nil
+}
+
+// groupOccupants считает находящихся в здании по ключу; группы упорядочены по убыванию числа
+func groupOccupants(occupants []Occupant, key func(Occupant) (*int64, string)) []OccupancyGroup {
+	index := make(map[string]int)
+	groups := []OccupancyGroup{}
+	for _, o := range occupants {
+		id, name := key(o)
+		k := name
+		if id != nil {
+			k = strconv.FormatInt(*id, 10)
+		}
+		if i, ok := index[k]; ok {
+			groups[i].Count++
+			continue
+		}
+		index[k] = len(groups)
+		groups = append(groups, OccupancyGroup{ID: id, Name: name, Count: 1})
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Count > groups[j].Count })
+	return groups
+}
+
+// occupancyHandler кто в здании для табло пожарной безопасности: общее число, разбивка по подразделениям
+// и зонам. ?zone= ограничивает одной зоной, ?details=true добавляет список сотрудников.
+func occupancyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var zone *int64
+	if s := r.URL.Query().Get("zone"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			returnJSONError(w, "Invalid 'zone' parameter", http.StatusBadRequest)
+			return
+		}
+		zone = &id
+	}
+
+	pgDB, err := connectPostgresRead()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	occupants, err := loadOccupants(pgDB, zone)
+	if err != nil {
+		log.Printf("❌ Occupancy failed: %v", err)
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report := OccupancyReport{
+		Total:       len(occupants),
+		GeneratedAt: time.Now(),
+		Departments: groupOccupants(occupants, func(o Occupant) (*int64, string) {
+			if o.Department == "" {
+				return o.DepartmentID, "Без подразделения"
+			}
+			return o.DepartmentID, o.Department
+		}),
+		Zones: groupOccupants(occupants, func(o Occupant) (*int64, string) { return o.ZoneID, o.Zone }),
+	}
+	if r.URL.Query().Get("details") == "true" {
+		report.Occupants = occupants
+	}
+	// Табло опрашивает адрес постоянно, кэшировать ответ нельзя
+	w.Header().Set("Cache-Control", "no-store")
+	returnJSONSuccess(w, report, fmt.Sprintf("%d people inside", report.Total))
+}
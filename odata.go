@@ -0,0 +1,629 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// odataPageSize максимальный размер страницы; остальное клиент получает по @odata.nextLink
+const odataPageSize = 1000
+
+// odataNamespace пространство имен схемы в $metadata
+const odataNamespace = "PercoWeb"
+
+// odataProperty свойство сущности и соответствующая ему колонка
+type odataProperty struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+// odataEntitySet набор сущностей, доступный только для чтения
+type odataEntitySet struct {
+	Name       string
+	EntityType string
+	Table      string
+	Key        string
+	Properties []odataProperty
+}
+
+// odataEntitySets наборы сущностей фида. Имена свойств совпадают с колонками таблиц.
+var odataEntitySets = []odataEntitySet{
+	{
+		Name:       "StaffCards",
+		EntityType: "StaffCard",
+		Table:      "staff_cards",
+		Key:        "identifier",
+		Properties: []odataProperty{
+			{"identifier", "Edm.String", false},
+			{"identifier_type", "Edm.String", false},
+			{"tab_number", "Edm.String", true},
+			{"site", "Edm.String", true},
+			{"wiegand_facility", "Edm.Int32", true},
+			{"wiegand_number", "Edm.Int32", true},
+			{"id_staff", "Edm.Int64", false},
+			{"last_name", "Edm.String", true},
+			{"first_name", "Edm.String", true},
+			{"middle_name", "Edm.String", true},
+			{"status", "Edm.String", true},
+			{"info", "Edm.String", true},
+			{"email", "Edm.String", true},
+			{"phone", "Edm.String", true},
+			{"ad_account", "Edm.String", true},
+			{"department_id", "Edm.Int64", true},
+			{"position", "Edm.String", true},
+			{"valid_from", "Edm.DateTimeOffset", true},
+			{"valid_until", "Edm.DateTimeOffset", true},
+			{"updated_at", "Edm.DateTimeOffset", true},
+		},
+	},
+	{
+		Name:       "Events",
+		EntityType: "Event",
+		Table:      "events",
+		Key:        "source_id",
+		Properties: []odataProperty{
+			{"source_id", "Edm.Int64", false},
+			{"id_staff", "Edm.Int64", false},
+			{"event_time", "Edm.DateTimeOffset", false},
+			{"direction", "Edm.Int16", false},
+			{"area_id", "Edm.Int64", true},
+			{"site", "Edm.String", true},
+		},
+	},
+	{
+		Name:       "Readers",
+		EntityType: "Reader",
+		Table:      "readers",
+		Key:        "id",
+		Properties: []odataProperty{
+			{"id", "Edm.Int64", false},
+			{"name", "Edm.String", false},
+			{"address", "Edm.String", false},
+			{"door_id", "Edm.Int64", true},
+			{"door_name", "Edm.String", false},
+			{"controller", "Edm.String", false},
+		},
+	},
+}
+
+// property ищет свойство по имени
+func (s *odataEntitySet) property(name string) (odataProperty, bool) {
+	for _, p := range s.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return odataProperty{}, false
+}
+
+// odataHandler обслуживает служебный документ, $metadata и наборы сущностей
+func odataHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		odataError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("OData-Version", "4.0")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/odata"), "/")
+	switch path {
+	case "":
+		odataServiceDocument(w, r)
+		return
+	case "$metadata":
+		odataMetadata(w)
+		return
+	}
+
+	name, count := path, false
+	if strings.HasSuffix(path, "/$count") {
+		name, count = strings.TrimSuffix(path, "/$count"), true
+	}
+	for i := range odataEntitySets {
+		if odataEntitySets[i].Name == name {
+			odataCollection(w, r, &odataEntitySets[i], count)
+			return
+		}
+	}
+	odataError(w, fmt.Sprintf("Resource %q not found", path), http.StatusNotFound)
+}
+
+// odataBaseURL возвращает корень сервиса для ссылок @odata.context и nextLink
+func odataBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + config.BasePath + "/odata/"
+}
+
+// odataServiceDocument перечисляет доступные наборы сущностей
+func odataServiceDocument(w http.ResponseWriter, r *http.Request) {
+	sets := make([]map[string]string, 0, len(odataEntitySets))
+	for _, s := range odataEntitySets {
+		sets = append(sets, map[string]string{"name": s.Name, "kind": "EntitySet", "url": s.Name})
+	}
+	writeOData(w, map[string]interface{}{
+		"@odata.context": odataBaseURL(r) + "$metadata",
+		"value":          sets,
+	})
+}
+
+// odataMetadata формирует описание схемы в формате CSDL
+func odataMetadata(w http.ResponseWriter) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<edmx:Edmx Version="4.0" xmlns:edmx="http://docs.oasis-open.org/odata/ns/edmx">`)
+	b.WriteString(`<edmx:DataServices>`)
+	fmt.Fprintf(&b, `<Schema Namespace="%s" xmlns="http://docs.oasis-open.org/odata/ns/edm">`, odataNamespace)
+	for _, s := range odataEntitySets {
+		fmt.Fprintf(&b, `<EntityType Name="%s"><Key><PropertyRef Name="%s"/></Key>`, s.EntityType, s.Key)
+		for _, p := range s.Properties {
+			fmt.Fprintf(&b, `<Property Name="%s" Type="%s" Nullable="%t"/>`, p.Name, p.Type, p.Nullable)
+		}
+		b.WriteString(`</EntityType>`)
+	}
+	b.WriteString(`<EntityContainer Name="Container">`)
+	for _, s := range odataEntitySets {
+		fmt.Fprintf(&b, `<EntitySet Name="%s" EntityType="%s.%s"/>`, s.Name, odataNamespace, s.EntityType)
+	}
+	b.WriteString(`</EntityContainer></Schema></edmx:DataServices></edmx:Edmx>`)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(b.String()))
+}
+
+// odataCollection выполняет запрос к набору сущностей с учетом $filter/$orderby/$top/$skip/$select
+func odataCollection(w http.ResponseWriter, r *http.Request, set *odataEntitySet, countOnly bool) {
+	q := r.URL.Query()
+
+	where, args, err := parseODataFilter(set, q.Get("$filter"))
+	if err != nil {
+		odataError(w, fmt.Sprintf("Invalid $filter: %v", err), http.StatusBadRequest)
+		return
+	}
+	whereSQL := ""
+	if where != "" {
+		whereSQL = " WHERE " + where
+	}
+
+	pgDB, err := connectPostgresRead()
+	if err != nil {
+		odataError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	total := -1
+	if countOnly || q.Get("$count") == "true" {
+		if err := pgDB.QueryRow("SELECT COUNT(*) FROM "+set.Table+whereSQL, args...).Scan(&total); err != nil {
+			odataError(w, fmt.Sprintf("Count error: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if countOnly {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, total)
+		return
+	}
+
+	columns, err := parseODataSelect(set, q.Get("$select"))
+	if err != nil {
+		odataError(w, fmt.Sprintf("Invalid $select: %v", err), http.StatusBadRequest)
+		return
+	}
+	orderBy, err := parseODataOrderBy(set, q.Get("$orderby"))
+	if err != nil {
+		odataError(w, fmt.Sprintf("Invalid $orderby: %v", err), http.StatusBadRequest)
+		return
+	}
+	skip, err := odataInt(q.Get("$skip"), 0)
+	if err != nil {
+		odataError(w, "Invalid $skip", http.StatusBadRequest)
+		return
+	}
+	top, err := odataInt(q.Get("$top"), -1)
+	if err != nil {
+		odataError(w, "Invalid $top", http.StatusBadRequest)
+		return
+	}
+
+	// Размер страницы ограничен сервером; при усечении клиент получает ссылку на продолжение
+	limit := odataPageSize
+	if top >= 0 && top < limit {
+		limit = top
+	}
+
+	names := make([]string, len(columns))
+	for i, p := range columns {
+		names[i] = p.Name
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT %d OFFSET %d",
+		strings.Join(names, ", "), set.Table, whereSQL, orderBy, limit+1, skip)
+
+	rows, err := pgDB.Query(query, args...)
+	if err != nil {
+		odataError(w, fmt.Sprintf("Query error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entities := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			odataError(w, fmt.Sprintf("Error scanning row: %v", err), http.StatusInternalServerError)
+			return
+		}
+		entity := make(map[string]interface{}, len(columns))
+		for i, p := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			entity[p.Name] = values[i]
+		}
+		entities = append(entities, entity)
+	}
+	if err := rows.Err(); err != nil {
+		odataError(w, fmt.Sprintf("Error iterating rows: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	result := map[string]interface{}{
+		"@odata.context": odataBaseURL(r) + "$metadata#" + set.Name,
+	}
+	if total >= 0 {
+		result["@odata.count"] = total
+	}
+	if len(entities) > limit {
+		entities = entities[:limit]
+		// Ссылка на следующую страницу нужна только при серверном ограничении размера
+		if top < 0 || top > limit {
+			next := url.Values{}
+			for k, v := range q {
+				next[k] = v
+			}
+			next.Set("$skip", strconv.Itoa(skip+limit))
+			if top > limit {
+				next.Set("$top", strconv.Itoa(top-limit))
+			}
+			result["@odata.nextLink"] = odataBaseURL(r) + set.Name + "?" + next.Encode()
+		}
+	}
+	result["value"] = entities
+	writeOData(w, result)
+}
+
+// odataInt разбирает неотрицательный целый параметр запроса
+func odataInt(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return n, nil
+}
+
+// parseODataSelect возвращает выбранные свойства или все свойства набора
+func parseODataSelect(set *odataEntitySet, s string) ([]odataProperty, error) {
+	if s == "" || s == "*" {
+		return set.Properties, nil
+	}
+	var props []odataProperty
+	for _, name := range strings.Split(s, ",") {
+		p, ok := set.property(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown property %q", name)
+		}
+		props = append(props, p)
+	}
+	return props, nil
+}
+
+// parseODataOrderBy переводит $orderby в ORDER BY; ключ добавляется для стабильного постраничного чтения
+func parseODataOrderBy(set *odataEntitySet, s string) (string, error) {
+	var parts []string
+	if s != "" {
+		for _, item := range strings.Split(s, ",") {
+			fields := strings.Fields(item)
+			if len(fields) == 0 || len(fields) > 2 {
+				return "", fmt.Errorf("invalid item %q", item)
+			}
+			if _, ok := set.property(fields[0]); !ok {
+				return "", fmt.Errorf("unknown property %q", fields[0])
+			}
+			dir := "ASC"
+			if len(fields) == 2 {
+				switch strings.ToLower(fields[1]) {
+				case "asc":
+				case "desc":
+					dir = "DESC"
+				default:
+					return "", fmt.Errorf("invalid direction %q", fields[1])
+				}
+			}
+			parts = append(parts, fields[0]+" "+dir)
+		}
+	}
+	parts = append(parts, set.Key)
+	return strings.Join(parts, ", "), nil
+}
+
+// writeOData отправляет ответ в формате OData JSON
+func writeOData(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json;odata.metadata=minimal")
+	json.NewEncoder(w).Encode(v)
+}
+
+// odataError возвращает ошибку в формате, который понимают клиенты OData
+func odataError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"code": strconv.Itoa(statusCode), "message": message},
+	})
+}
+
+// odataFilterParser переводит выражение $filter в условие SQL с параметрами
+type odataFilterParser struct {
+	set    *odataEntitySet
+	tokens []string
+	pos    int
+	args   []interface{}
+}
+
+// parseODataFilter разбирает $filter. Поддерживаются eq/ne/gt/ge/lt/le, and/or/not,
+// скобки и функции contains/startswith/endswith (без учета регистра).
+func parseODataFilter(set *odataEntitySet, filter string) (string, []interface{}, error) {
+	if strings.TrimSpace(filter) == "" {
+		return "", nil, nil
+	}
+	tokens, err := tokenizeODataFilter(filter)
+	if err != nil {
+		return "", nil, err
+	}
+	p := &odataFilterParser{set: set, tokens: tokens}
+	where, err := p.parseOr()
+	if err != nil {
+		return "", nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return "", nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return where, p.args, nil
+}
+
+// tokenizeODataFilter делит выражение на лексемы: скобки, запятые, строки в кавычках и слова
+func tokenizeODataFilter(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '\'':
+			// Кавычка внутри строки экранируется удвоением
+			j := i + 1
+			for {
+				if j >= len(s) {
+					return nil, fmt.Errorf("unterminated string literal")
+				}
+				if s[j] == '\'' {
+					if j+1 < len(s) && s[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			tokens = append(tokens, s[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t(),'", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// peek возвращает текущую лексему без сдвига
+func (p *odataFilterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// next возвращает текущую лексему и сдвигает позицию
+func (p *odataFilterParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// expect проверяет, что следующая лексема равна want
+func (p *odataFilterParser) expect(want string) error {
+	if t := p.next(); t != want {
+		return fmt.Errorf("expected %q, got %q", want, t)
+	}
+	return nil
+}
+
+// parseOr разбирает последовательность условий, соединенных or
+func (p *odataFilterParser) parseOr() (string, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+	for p.peek() == "or" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " OR " + right + ")"
+	}
+	return left, nil
+}
+
+// parseAnd разбирает последовательность условий, соединенных and
+func (p *odataFilterParser) parseAnd() (string, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return "", err
+	}
+	for p.peek() == "and" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " AND " + right + ")"
+	}
+	return left, nil
+}
+
+// parseUnary разбирает not, скобки, вызов функции или сравнение
+func (p *odataFilterParser) parseUnary() (string, error) {
+	switch t := p.peek(); t {
+	case "not":
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + inner, nil
+	case "(":
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect(")"); err != nil {
+			return "", err
+		}
+		return "(" + inner + ")", nil
+	case "contains", "startswith", "endswith":
+		return p.parseFunction()
+	}
+	return p.parseComparison()
+}
+
+// parseFunction переводит contains/startswith/endswith в ILIKE
+func (p *odataFilterParser) parseFunction() (string, error) {
+	fn := p.next()
+	if err := p.expect("("); err != nil {
+		return "", err
+	}
+	prop, ok := p.set.property(p.next())
+	if !ok || prop.Type != "Edm.String" {
+		return "", fmt.Errorf("%s requires a string property", fn)
+	}
+	if err := p.expect(","); err != nil {
+		return "", err
+	}
+	lit := p.next()
+	if !strings.HasPrefix(lit, "'") {
+		return "", fmt.Errorf("%s requires a string literal", fn)
+	}
+	if err := p.expect(")"); err != nil {
+		return "", err
+	}
+
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(unquoteODataString(lit))
+	switch fn {
+	case "contains":
+		pattern = "%" + pattern + "%"
+	case "startswith":
+		pattern = pattern + "%"
+	case "endswith":
+		pattern = "%" + pattern
+	}
+	p.args = append(p.args, pattern)
+	return fmt.Sprintf("%s ILIKE $%d", prop.Name, len(p.args)), nil
+}
+
+// odataOperators соответствие операторов сравнения OData и SQL
+var odataOperators = map[string]string{
+	"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<=",
+}
+
+// parseComparison разбирает выражение вида "свойство оператор литерал"
+func (p *odataFilterParser) parseComparison() (string, error) {
+	name := p.next()
+	prop, ok := p.set.property(name)
+	if !ok {
+		return "", fmt.Errorf("unknown property %q", name)
+	}
+	opName := p.next()
+	op, ok := odataOperators[opName]
+	if !ok {
+		return "", fmt.Errorf("unsupported operator %q", opName)
+	}
+
+	lit := p.next()
+	if lit == "null" {
+		switch opName {
+		case "eq":
+			return prop.Name + " IS NULL", nil
+		case "ne":
+			return prop.Name + " IS NOT NULL", nil
+		}
+		return "", fmt.Errorf("null can only be compared with eq or ne")
+	}
+
+	value, err := odataLiteral(prop, lit)
+	if err != nil {
+		return "", err
+	}
+	p.args = append(p.args, value)
+	return fmt.Sprintf("%s %s $%d", prop.Name, op, len(p.args)), nil
+}
+
+// odataLiteral приводит литерал к типу свойства
+func odataLiteral(prop odataProperty, lit string) (interface{}, error) {
+	switch prop.Type {
+	case "Edm.String":
+		if len(lit) < 2 || !strings.HasPrefix(lit, "'") {
+			return nil, fmt.Errorf("%s expects a string literal", prop.Name)
+		}
+		return unquoteODataString(lit), nil
+	case "Edm.Int64", "Edm.Int32", "Edm.Int16":
+		n, err := strconv.ParseInt(lit, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s expects an integer, got %q", prop.Name, lit)
+		}
+		return n, nil
+	case "Edm.DateTimeOffset":
+		t, err := time.Parse(time.RFC3339, lit)
+		if err != nil {
+			return nil, fmt.Errorf("%s expects a date-time like 2024-01-31T00:00:00Z, got %q", prop.Name, lit)
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("unsupported property type %s", prop.Type)
+}
+
+// unquoteODataString снимает кавычки со строкового литерала
+func unquoteODataString(lit string) string {
+	return strings.ReplaceAll(lit[1:len(lit)-1], "''", "'")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseODataFilter(t *testing.T) {
+	set := &odataEntitySets[0]
+	validUntil := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		filter string
+		where  string
+		args   []interface{}
+	}{
+		{"", "", nil},
+		{"status eq 'active'", "status = $1", []interface{}{"active"}},
+		{"status ne 'blocked'", "status <> $1", []interface{}{"blocked"}},
+		{"id_staff gt 10", "id_staff > $1", []interface{}{int64(10)}},
+		{"id_staff ge 10", "id_staff >= $1", []interface{}{int64(10)}},
+		{"wiegand_number lt 100", "wiegand_number < $1", []interface{}{int64(100)}},
+		{"department_id le 5", "department_id <= $1", []interface{}{int64(5)}},
+		{"valid_until lt 2024-01-31T00:00:00Z", "valid_until < $1", []interface{}{validUntil}},
+		{"email eq null", "email IS NULL", nil},
+		{"email ne null", "email IS NOT NULL", nil},
+
+		// and связывает сильнее or, скобки меняют порядок
+		{"status eq 'a' or status eq 'b' and site eq 'c'", "(status = $1 OR (status = $2 AND site = $3))", []interface{}{"a", "b", "c"}},
+		{"status eq 'a' and status eq 'b' or site eq 'c'", "((status = $1 AND status = $2) OR site = $3)", []interface{}{"a", "b", "c"}},
+		{"(status eq 'a' or status eq 'b') and site eq 'c'", "(((status = $1 OR status = $2)) AND site = $3)", []interface{}{"a", "b", "c"}},
+		{"not status eq 'a' and site eq 'c'", "(NOT status = $1 AND site = $2)", []interface{}{"a", "c"}},
+
+		// Строки: удвоенная кавычка, слова операторов внутри литерала, символы шаблона LIKE
+		{"last_name eq 'O''Brien'", "last_name = $1", []interface{}{"O'Brien"}},
+		{"last_name eq 'a or b'", "last_name = $1", []interface{}{"a or b"}},
+		{"last_name eq ''", "last_name = $1", []interface{}{""}},
+		{"contains(last_name,'50%_off')", `last_name ILIKE $1`, []interface{}{`%50\%\_off%`}},
+		{"startswith(last_name, 'Ив')", "last_name ILIKE $1", []interface{}{"Ив%"}},
+		{"endswith(email,'\\ru')", "email ILIKE $1", []interface{}{`%\\ru`}},
+	}
+	for _, tt := range tests {
+		where, args, err := parseODataFilter(set, tt.filter)
+		if err != nil || where != tt.where || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("parseODataFilter(%q) = %q, %v, %v; want %q, %v", tt.filter, where, args, err, tt.where, tt.args)
+		}
+	}
+}
+
+func TestParseODataFilterRejects(t *testing.T) {
+	set := &odataEntitySets[0]
+	for _, filter := range []string{
+		"password eq 'x'",
+		"1 eq 1",
+		"Status eq 'x'",
+		"status like 'x'",
+		"status eq",
+		"status eq 5",
+		"id_staff eq 'x'",
+		"valid_until lt '2024-01-31'",
+		"status gt null",
+		"status eq 'abc",
+		"status eq 'a' site eq 'b'",
+		"status eq 'a'; DROP TABLE cards",
+		"(status eq 'a'",
+		"contains(id_staff,'1')",
+		"contains(last_name,1)",
+		"contains(password,'x')",
+	} {
+		if where, _, err := parseODataFilter(set, filter); err == nil {
+			t.Errorf("parseODataFilter(%q) = %q; want error", filter, where)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// offlineList офлайн-список разрешенных идентификаторов для контроллеров.
+// Версия растет только при изменении состава списка, поэтому контроллер может сравнивать ее с сохраненной.
+type offlineList struct {
+	Version     int64     `json:"version"`
+	Hash        string    `json:"hash"`
+	Count       int       `json:"count"`
+	GeneratedAt time.Time `json:"generated_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Signature   string    `json:"-"`
+	body        []byte
+}
+
+// offlineListCache собранный список; пересобирается раз в OFFLINE_LIST_REFRESH и после синхронизации
+var offlineListCache struct {
+	sync.Mutex
+	list      *offlineList
+	expiresAt time.Time
+}
+
+// initOfflineListTable создает таблицу с текущей версией офлайн-списка
+func initOfflineListTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS offline_list_state (
+			id SMALLINT PRIMARY KEY,
+			version BIGINT NOT NULL,
+			hash VARCHAR(64) NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating offline_list_state table: %v", err)
+	}
+	return nil
+}
+
+// signOfflineList подпись HMAC-SHA256 файла ключом OFFLINE_LIST_SECRET в виде sha256=<hex>
+func signOfflineList(data []byte) string {
+	mac := hmac.New(sha256.New, []byte(config.OfflineListSecret))
+	mac.Write(data)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// buildOfflineList собирает файл: строки-комментарии с версией и сроком годности, CSV с колонками
+// identifier, identifier_type, id_staff, valid_until и последней строкой "# signature=sha256=<hex>",
+// подписывающей все байты файла до нее. После expires_at контроллер не должен пускать по списку.
+func buildOfflineList(db *sql.DB) (*offlineList, error) {
+	rows, err := db.Query(`
+		SELECT identifier, identifier_type, id_staff, valid_until FROM staff_cards
+		WHERE ` + allowedCardCondition + `
+		ORDER BY identifier_type, identifier`)
+	if err != nil {
+		return nil, fmt.Errorf("Offline list query error: %v", err)
+	}
+	defer rows.Close()
+
+	var data bytes.Buffer
+	cw := csv.NewWriter(&data)
+	cw.Write([]string{"identifier", "identifier_type", "id_staff", "valid_until"})
+	count := 0
+	for rows.Next() {
+		var identifier, idType string
+		var idStaff int64
+		var validUntil sql.NullTime
+		if err := rows.Scan(&identifier, &idType, &idStaff, &validUntil); err != nil {
+			return nil, err
+		}
+		until := ""
+		if validUntil.Valid {
+			until = validUntil.Time.UTC().Format(time.RFC3339)
+		}
+		cw.Write([]string{identifier, idType, strconv.FormatInt(idStaff, 10), until})
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	cw.Flush()
+
+	// Версия увеличивается, только если хэш состава списка изменился
+	sum := sha256.Sum256(data.Bytes())
+	list := &offlineList{Hash: hex.EncodeToString(sum[:]), Count: count, GeneratedAt: time.Now().UTC()}
+	list.ExpiresAt = list.GeneratedAt.Add(config.OfflineListMaxAge)
+	err = db.QueryRow(`
+		INSERT INTO offline_list_state (id, version, hash, updated_at) VALUES (1, 1, $1, NOW())
+		ON CONFLICT (id) DO UPDATE SET version = offline_list_state.version + 1, hash = EXCLUDED.hash, updated_at = NOW()
+		WHERE offline_list_state.hash <> EXCLUDED.hash
+		RETURNING version`, list.Hash).Scan(&list.Version)
+	if err == sql.ErrNoRows {
+		err = db.QueryRow("SELECT version FROM offline_list_state WHERE id = 1").Scan(&list.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Offline list version error: %v", err)
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "# perco_web offline list\n# version=%d\n# generated_at=%s\n# expires_at=%s\n# count=%d\n",
+		list.Version, list.GeneratedAt.Format(time.RFC3339), list.ExpiresAt.Format(time.RFC3339), list.Count)
+	file.Write(data.Bytes())
+	list.Signature = signOfflineList(file.Bytes())
+	fmt.Fprintf(&file, "# signature=%s\n", list.Signature)
+	list.body = file.Bytes()
+	return list, nil
+}
+
+// currentOfflineList возвращает список из кэша или собирает его заново
+func currentOfflineList() (*offlineList, error) {
+	offlineListCache.Lock()
+	defer offlineListCache.Unlock()
+
+	if offlineListCache.list != nil && time.Now().Before(offlineListCache.expiresAt) {
+		return offlineListCache.list, nil
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return nil, fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+	list, err := buildOfflineList(pgDB)
+	if err != nil {
+		return nil, err
+	}
+	offlineListCache.list = list
+	offlineListCache.expiresAt = time.Now().Add(config.OfflineListRefresh)
+	return list, nil
+}
+
+// invalidateOfflineList сбрасывает кэш, чтобы контроллеры сразу получили список после синхронизации
+func invalidateOfflineList() {
+	offlineListCache.Lock()
+	offlineListCache.list = nil
+	offlineListCache.Unlock()
+}
+
+// offlineListHandler отдает подписанный офлайн-список. Подпись дублируется в заголовке X-Signature,
+// а ETag равен версии, поэтому повторная загрузка неизменившегося списка получает 304.
+func offlineListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := offlineListForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	etag := fmt.Sprintf(`"%d"`, list.Version)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-Offline-List-Version", strconv.FormatInt(list.Version, 10))
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="offline-list-v%d.csv"`, list.Version))
+	w.Header().Set("X-Signature", list.Signature)
+	w.Write(list.body)
+}
+
+// offlineListVersionHandler легкая проверка версии: контроллер скачивает список, только если версия изменилась
+func offlineListVersionHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := offlineListForRequest(w, r)
+	if !ok {
+		return
+	}
+	returnJSONSuccess(w, list, "")
+}
+
+// offlineListForRequest общие проверки обоих обработчиков; при ошибке ответ уже отправлен
+func offlineListForRequest(w http.ResponseWriter, r *http.Request) (*offlineList, bool) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	if config.OfflineListSecret == "" {
+		returnJSONError(w, "Offline list is disabled", http.StatusNotFound)
+		return nil, false
+	}
+	list, err := currentOfflineList()
+	if err != nil {
+		log.Printf("❌ Offline list failed: %v", err)
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return list, true
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// sqlExecer общий интерфейс *sql.DB и *sql.Tx для DDL
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// eventsTableDDL таблица событий, секционированная по месяцам времени прохода в UTC.
+// Ключ секционирования должен входить в первичный ключ.
+const eventsTableDDL = `
+	CREATE TABLE IF NOT EXISTS events (
+		source_id BIGINT NOT NULL,
+		id_staff BIGINT NOT NULL,
+		event_time TIMESTAMPTZ NOT NULL,
+		direction SMALLINT NOT NULL,
+		area_id BIGINT,
+		site VARCHAR(100),
+		PRIMARY KEY (source_id, event_time)
+	) PARTITION BY RANGE (event_time)
+`
+
+// monthStart возвращает начало месяца времени t в UTC: границы секций не зависят от поясов сервиса и сессии
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// eventPartitionName имя секции событий за месяц: events_YYYYMM с TABLE_PREFIX
+func eventPartitionName(month time.Time) string {
+	return config.TablePrefix + "events_" + month.Format("200601")
+}
+
+// ensureEventPartition создает секцию событий за месяц времени t, если ее еще нет
+func ensureEventPartition(db sqlExecer, t time.Time) error {
+	month := monthStart(t)
+	_, err := db.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s PARTITION OF events FOR VALUES FROM ('%s') TO ('%s')",
+		eventPartitionName(month), month.Format("2006-01-02 15:04:05-07"), month.AddDate(0, 1, 0).Format("2006-01-02 15:04:05-07")))
+	if err != nil {
+		return fmt.Errorf("error creating events partition for %s: %v", month.Format("2006-01"), err)
+	}
+	return nil
+}
+
+// eventsPartitioned проверяет, что таблица events уже секционирована; false - таблицы нет или она обычная
+func eventsPartitioned(db *sql.DB) (exists, partitioned bool, err error) {
+	var kind sql.NullString
+	err = db.QueryRow("SELECT relkind::text FROM pg_class WHERE oid = to_regclass('events')").Scan(&kind)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("error checking events table: %v", err)
+	}
+	return true, kind.String == "p", nil
+}
+
+// convertEventsTable переносит события из обычной таблицы прежних версий в секционированную.
+// Представления отчетов над старой таблицей удаляются и создаются заново в initReportViews.
+func convertEventsTable(db *sql.DB) error {
+	log.Println("🚶 Converting events table to monthly partitions...")
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("transaction error: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		"ALTER TABLE events RENAME TO events_unpartitioned",
+		"ALTER TABLE events_unpartitioned RENAME CONSTRAINT events_pkey TO events_unpartitioned_pkey",
+		"ALTER INDEX IF EXISTS events_staff_time_idx RENAME TO events_unpartitioned_staff_time_idx",
+		"ALTER TABLE events_unpartitioned ADD COLUMN IF NOT EXISTS site VARCHAR(100)",
+		eventsTableDDL,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("error converting events table: %v", err)
+		}
+	}
+
+	copied, months, err := copyLegacyEvents(tx, "events_unpartitioned")
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DROP TABLE events_unpartitioned CASCADE"); err != nil {
+		return fmt.Errorf("error dropping old events table: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing events conversion: %v", err)
+	}
+	log.Printf("✅ Moved %d events into %d monthly partitions", copied, months)
+	return nil
+}
+
+// convertEventsTimeZone пересоздает секционированную таблицу событий прежних версий с event_time без пояса:
+// тип ключа секционирования не меняется ALTER TABLE, поэтому события копируются во временную таблицу
+// и загружаются в новые секции. Отсоединенные архивные секции не меняются.
+func convertEventsTimeZone(db *sql.DB) error {
+	log.Println("🕒 Converting events to timestamptz...")
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("transaction error: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		"CREATE TEMP TABLE events_local_time ON COMMIT DROP AS SELECT * FROM events",
+		"DROP TABLE events CASCADE",
+		eventsTableDDL,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("error converting events table: %v", err)
+		}
+	}
+	copied, months, err := copyLegacyEvents(tx, "events_local_time")
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing events conversion: %v", err)
+	}
+	log.Printf("✅ Converted %d events in %d monthly partitions to timestamptz", copied, months)
+	return nil
+}
+
+// copyLegacyEvents переносит в events события из таблицы с event_time без пояса, записанным по времени
+// PERCo (SOURCE_TIMEZONE), и создает секции для всех их месяцев
+func copyLegacyEvents(tx *sql.Tx, table string) (copied int64, months int, err error) {
+	eventTime := legacyTimeExpr("event_time", config.SourceTimezone)
+	rows, err := tx.Query(fmt.Sprintf("SELECT DISTINCT date_trunc('month', %s AT TIME ZONE 'UTC') FROM %s", eventTime, table))
+	if err != nil {
+		return 0, 0, fmt.Errorf("error reading event months: %v", err)
+	}
+	var starts []time.Time
+	for rows.Next() {
+		var month time.Time
+		if err := rows.Scan(&month); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("error reading event months: %v", err)
+		}
+		starts = append(starts, month)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("error reading event months: %v", err)
+	}
+	for _, month := range starts {
+		if err := ensureEventPartition(tx, month); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	res, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO events (source_id, id_staff, event_time, direction, area_id, site)
+		SELECT source_id, id_staff, %s, direction, area_id, site FROM %s
+	`, eventTime, table))
+	if err != nil {
+		return 0, 0, fmt.Errorf("error copying events: %v", err)
+	}
+	copied, _ = res.RowsAffected()
+	return copied, len(starts), nil
+}
+
+// eventsRetentionStart начало первого месяца, события которого хранятся в events; нулевое время - все
+func eventsRetentionStart() time.Time {
+	if config.EventsRetentionMonths <= 0 {
+		return time.Time{}
+	}
+	return monthStart(time.Now().AddDate(0, -config.EventsRetentionMonths, 0))
+}
+
+// detachOldEventPartitions отсоединяет секции старше EVENTS_RETENTION_MONTHS. Отсоединенные
+// таблицы остаются в базе для архива и больше не участвуют в запросах к events.
+func detachOldEventPartitions(db *sql.DB) error {
+	start := eventsRetentionStart()
+	if start.IsZero() {
+		return nil
+	}
+	cutoff := eventPartitionName(start)
+
+	rows, err := db.Query(`
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'events'::regclass
+		ORDER BY c.relname
+	`)
+	if err != nil {
+		return fmt.Errorf("error listing events partitions: %v", err)
+	}
+	var old []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("error listing events partitions: %v", err)
+		}
+		// Имена events_YYYYMM сравниваются как строки в хронологическом порядке
+		if strings.HasPrefix(name, config.TablePrefix+"events_") && len(name) == len(cutoff) && name < cutoff {
+			old = append(old, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error listing events partitions: %v", err)
+	}
+
+	for _, name := range old {
+		if _, err := db.Exec("ALTER TABLE events DETACH PARTITION " + name); err != nil {
+			return fmt.Errorf("error detaching events partition %s: %v", name, err)
+		}
+		log.Printf("🗄️ Detached events partition %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// percoWebStaff структура для сотрудника в ответе PERCo-Web API
+type percoWebStaff struct {
+	ID          int64  `json:"id"`
+	LastName    string `json:"last_name"`
+	FirstName   string `json:"first_name"`
+	MiddleName  string `json:"middle_name"`
+	Identifiers []struct {
+		Identifier string `json:"identifier"`
+	} `json:"identifier"`
+}
+
+// percoWebPage структура для страницы списка сотрудников
+type percoWebPage struct {
+	Total int             `json:"total"`
+	Page  int             `json:"page"`
+	Rows  []percoWebStaff `json:"rows"`
+}
+
+var percoWebClient = &http.Client{Timeout: 60 * time.Second}
+
+// percoWebSource читает сотрудников и их карты через REST API PERCo-Web
+type percoWebSource struct{}
+
+func init() {
+	registerSource("percoweb", func() SourceConnector { return percoWebSource{} })
+}
+
+// Name возвращает имя источника
+func (percoWebSource) Name() string {
+	return "PERCo-Web"
+}
+
+// percoWebToken возвращает токен доступа: заданный в конфигурации или полученный по логину
+func percoWebToken(ctx context.Context) (string, error) {
+	if config.PercoWebToken != "" {
+		return config.PercoWebToken, nil
+	}
+
+	payload, _ := json.Marshal(map[string]string{
+		"login":    config.PercoWebLogin,
+		"password": config.PercoWebPassword,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimRight(config.PercoWebURL, "/")+"/api/system/auth", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := percoWebClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("PERCo-Web auth error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("PERCo-Web auth returned %s: %s", resp.Status, msg)
+	}
+
+	var auth struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		return "", fmt.Errorf("invalid PERCo-Web auth response: %v", err)
+	}
+	if auth.Token == "" {
+		return "", fmt.Errorf("PERCo-Web auth returned empty token")
+	}
+	return auth.Token, nil
+}
+
+// fetchPercoWebPage запрашивает одну страницу списка сотрудников
+func fetchPercoWebPage(ctx context.Context, token string, page int) (*percoWebPage, error) {
+	params := url.Values{}
+	params.Set("token", token)
+	params.Set("page", strconv.Itoa(page))
+	params.Set("rows", strconv.Itoa(config.PercoWebPageSize))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimRight(config.PercoWebURL, "/")+"/api/users/staff/table?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := percoWebClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("PERCo-Web request error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("PERCo-Web returned %s: %s", resp.Status, msg)
+	}
+
+	var result percoWebPage
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid PERCo-Web response: %v", err)
+	}
+	return &result, nil
+}
+
+// FetchStaffCards постранично читает список сотрудников с их идентификаторами
+func (percoWebSource) FetchStaffCards(ctx context.Context, emit func(StaffCard) error) error {
+	log.Printf("📥 Fetching data from PERCo-Web API %s...", config.PercoWebURL)
+
+	token, err := percoWebToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for page := 1; ; page++ {
+		result, err := fetchPercoWebPage(ctx, token, page)
+		if err != nil {
+			return err
+		}
+
+		for _, staff := range result.Rows {
+			for _, id := range staff.Identifiers {
+				if id.Identifier == "" {
+					continue
+				}
+				sc := StaffCard{IDStaff: staff.ID, Identifier: id.Identifier}
+				if staff.LastName != "" {
+					sc.LastName = &staff.LastName
+				}
+				if staff.FirstName != "" {
+					sc.FirstName = &staff.FirstName
+				}
+				if staff.MiddleName != "" {
+					sc.MiddleName = &staff.MiddleName
+				}
+				if err := emit(sc); err != nil {
+					return err
+				}
+				count++
+			}
+		}
+
+		log.Printf("📥 Fetched page %d of %d (%d records so far)", page, result.Total, count)
+		if len(result.Rows) == 0 || page >= result.Total {
+			break
+		}
+	}
+
+	log.Printf("📥 Successfully fetched %d records from PERCo-Web", count)
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// PhonebookEntry запись телефонного справочника
+type PhonebookEntry struct {
+	IDStaff    int64
+	TabNumber  string
+	LastName   string
+	FirstName  string
+	MiddleName string
+	Phone      string
+	Email      string
+	Department string
+	Position   string
+}
+
+// FullName возвращает ФИО одной строкой
+func (e PhonebookEntry) FullName() string {
+	return strings.Join(strings.Fields(e.LastName+" "+e.FirstName+" "+e.MiddleName), " ")
+}
+
+// loadPhonebook выбирает сотрудников с телефонами, по одной записи на человека
+func loadPhonebook(db *sql.DB) ([]PhonebookEntry, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT ON (id_staff) id_staff, COALESCE(tab_number, ''),
+			COALESCE(last_name, ''), COALESCE(first_name, ''), COALESCE(middle_name, ''),
+			phone, COALESCE(email, ''),
+			COALESCE((SELECT name FROM departments d WHERE d.id = staff_cards.department_id), ''),
+			COALESCE(position, '')
+		FROM staff_cards
+		WHERE COALESCE(phone, '') <> '' AND ` + allowedCardCondition + `
+		ORDER BY id_staff, updated_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("phonebook query error: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []PhonebookEntry
+	for rows.Next() {
+		var e PhonebookEntry
+		if err := rows.Scan(&e.IDStaff, &e.TabNumber, &e.LastName, &e.FirstName, &e.MiddleName, &e.Phone, &e.Email, &e.Department, &e.Position); err != nil {
+			return nil, fmt.Errorf("error scanning phonebook row: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// writePhonebookCSV записывает справочник в CSV; tab_number последней колонкой, чтобы не сдвигать прежние
+func writePhonebookCSV(w io.Writer, entries []PhonebookEntry) error {
+	rows := [][]string{{"full_name", "phone", "email", "department", "position", "tab_number"}}
+	for _, e := range entries {
+		rows = append(rows, []string{e.FullName(), e.Phone, e.Email, e.Department, e.Position, e.TabNumber})
+	}
+	return writeRowsCSV(w, rows)
+}
+
+// ldifAttr форматирует атрибут LDIF, кодируя не-ASCII значения в base64
+func ldifAttr(name, value string) string {
+	safe := utf8.ValidString(value)
+	for i := 0; i < len(value) && safe; i++ {
+		if value[i] >= 0x80 || value[i] == '\n' || value[i] == '\r' {
+			safe = false
+		}
+	}
+	if safe && value != "" && value[0] != ' ' && value[0] != ':' && value[0] != '<' {
+		return fmt.Sprintf("%s: %s\n", name, value)
+	}
+	return fmt.Sprintf("%s:: %s\n", name, base64.StdEncoding.EncodeToString([]byte(value)))
+}
+
+// writePhonebookLDIF записывает справочник в LDIF для импорта в LDAP адресную книгу
+func writePhonebookLDIF(w io.Writer, entries []PhonebookEntry) error {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		buf.WriteString(ldifAttr("dn", fmt.Sprintf("uid=%d,%s", e.IDStaff, config.PhonebookLDIFBase)))
+		buf.WriteString("objectClass: inetOrgPerson\n")
+		buf.WriteString(ldifAttr("uid", fmt.Sprint(e.IDStaff)))
+		buf.WriteString(ldifAttr("cn", e.FullName()))
+		sn := e.LastName
+		if sn == "" {
+			sn = e.FullName()
+		}
+		buf.WriteString(ldifAttr("sn", sn))
+		if e.FirstName != "" {
+			buf.WriteString(ldifAttr("givenName", e.FirstName))
+		}
+		buf.WriteString(ldifAttr("telephoneNumber", e.Phone))
+		if e.Email != "" {
+			buf.WriteString(ldifAttr("mail", e.Email))
+		}
+		if e.Department != "" {
+			buf.WriteString(ldifAttr("ou", e.Department))
+		}
+		if e.Position != "" {
+			buf.WriteString(ldifAttr("title", e.Position))
+		}
+		if e.TabNumber != "" {
+			buf.WriteString(ldifAttr("employeeNumber", e.TabNumber))
+		}
+		buf.WriteString("\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writePhonebook записывает справочник в формате csv или ldif
+func writePhonebook(w io.Writer, format string, entries []PhonebookEntry) error {
+	switch format {
+	case "csv":
+		return writePhonebookCSV(w, entries)
+	case "ldif":
+		return writePhonebookLDIF(w, entries)
+	default:
+		return fmt.Errorf("unsupported phonebook format %q", format)
+	}
+}
+
+// generatePhonebookFile перезаписывает файл справочника PHONEBOOK_FILE
+func generatePhonebookFile() error {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+
+	entries, err := loadPhonebook(pgDB)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writePhonebook(&buf, config.PhonebookFormat, entries); err != nil {
+		return err
+	}
+
+	// Запись через временный файл, чтобы АТС не прочитала файл наполовину
+	tmp := config.PhonebookFile + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing phonebook: %v", err)
+	}
+	if err := os.Rename(tmp, config.PhonebookFile); err != nil {
+		return fmt.Errorf("error replacing phonebook: %v", err)
+	}
+
+	log.Printf("☎️ Phonebook written to %s (%d entries)", config.PhonebookFile, len(entries))
+	return nil
+}
+
+// phonebookHandler отдает телефонный справочник в формате csv или ldif
+func phonebookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = config.PhonebookFormat
+	}
+	if format != "csv" && format != "ldif" {
+		returnJSONError(w, "Unsupported format, use csv or ldif", http.StatusBadRequest)
+		return
+	}
+
+	pgDB, err := connectPostgresRead()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	entries, err := loadPhonebook(pgDB)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == "ldif" {
+		contentType = "text/x-ldif; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="phonebook.%s"`, format))
+	if err := writePhonebook(w, format, entries); err != nil {
+		log.Printf("❌ Phonebook export failed: %v", err)
+	}
+}
+
+// startPhonebookExport запускает периодическое обновление файла справочника
+func startPhonebookExport() {
+	log.Printf("☎️ Phonebook export enabled: every %s to %s", config.PhonebookInterval, config.PhonebookFile)
+	startPeriodicJob("Phonebook export", config.PhonebookInterval, generatePhonebookFile)
+}
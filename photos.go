@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"net/http"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+// photoSizes максимальная сторона миниатюр в пикселях
+var photoSizes = map[string]int{
+	"small":  96,
+	"medium": 320,
+}
+
+// maxCachedThumbnails ограничивает кэш миниатюр в памяти
+const maxCachedThumbnails = 5000
+
+// PhotoSource источник, который умеет отдавать фотографии сотрудников
+type PhotoSource interface {
+	FetchPhotos(ctx context.Context, emit func(idStaff int64, photo []byte) error) error
+}
+
+// thumbnailCache миниатюры по ключу id:размер:хэш; смена фото меняет хэш и ключ
+var thumbnailCache = struct {
+	sync.Mutex
+	items map[string][]byte
+}{items: make(map[string][]byte)}
+
+// initStaffPhotosTable создает таблицу фотографий сотрудников
+func initStaffPhotosTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS staff_photos (
+			id_staff BIGINT PRIMARY KEY,
+			photo BYTEA NOT NULL,
+			hash VARCHAR(32) NOT NULL,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating staff_photos table: %v", err)
+	}
+	return nil
+}
+
+// syncPhotos загружает фотографии из источника; неизменившиеся фото не перезаписываются
+func syncPhotos(_ *SyncResult) {
+	source, err := newSourceConnector()
+	if err != nil {
+		log.Printf("❌ Photos: %v", err)
+		return
+	}
+	ps, ok := source.(PhotoSource)
+	if !ok {
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ Photos: PostgreSQL connection error: %v", err)
+		return
+	}
+
+	updated, total := 0, 0
+	err = ps.FetchPhotos(context.Background(), func(idStaff int64, photo []byte) error {
+		if len(photo) == 0 {
+			return nil
+		}
+		total++
+		sum := md5.Sum(photo)
+		res, err := pgDB.Exec(`
+			INSERT INTO staff_photos (id_staff, photo, hash, updated_at)
+			VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+			ON CONFLICT (id_staff) DO UPDATE
+				SET photo = EXCLUDED.photo, hash = EXCLUDED.hash, updated_at = EXCLUDED.updated_at
+				WHERE staff_photos.hash <> EXCLUDED.hash
+		`, idStaff, photo, hex.EncodeToString(sum[:]))
+		if err != nil {
+			return fmt.Errorf("error saving photo of %d: %v", idStaff, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("❌ Photos sync failed: %v", err)
+		return
+	}
+	log.Printf("🖼️ Photos synced: %d total, %d updated", total, updated)
+}
+
+// makeThumbnail уменьшает изображение так, чтобы большая сторона не превышала size
+func makeThumbnail(photo []byte, size int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(photo))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %v", err)
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, h*size/w
+		} else {
+			w, h = w*size/h, size
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cachedThumbnail возвращает миниатюру из кэша или создает ее
+func cachedThumbnail(key string, photo []byte, size int) ([]byte, error) {
+	thumbnailCache.Lock()
+	thumb, ok := thumbnailCache.items[key]
+	thumbnailCache.Unlock()
+	if ok {
+		return thumb, nil
+	}
+
+	thumb, err := makeThumbnail(photo, size)
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnailCache.Lock()
+	if len(thumbnailCache.items) >= maxCachedThumbnails {
+		thumbnailCache.items = make(map[string][]byte)
+	}
+	thumbnailCache.items[key] = thumb
+	thumbnailCache.Unlock()
+	return thumb, nil
+}
+
+// staffPhotoHandler отдает фото сотрудника в исходном виде или миниатюрой (size=small|medium)
+func staffPhotoHandler(w http.ResponseWriter, r *http.Request, idStaff int64) {
+	servePhoto(w, r, idStaff, "", "max-age=3600")
+}
+
+// servePhoto отдает фото с заданным Cache-Control; непустой version должен совпадать с хэшем фото
+func servePhoto(w http.ResponseWriter, r *http.Request, idStaff int64, version, cacheControl string) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sizeName := r.URL.Query().Get("size")
+	if sizeName == "" {
+		sizeName = "medium"
+	}
+	size, ok := photoSizes[sizeName]
+	if !ok && sizeName != "full" {
+		returnJSONError(w, "'size' must be small, medium or full", http.StatusBadRequest)
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var photo []byte
+	var hash string
+	err = pgDB.QueryRow("SELECT photo, hash FROM staff_photos WHERE id_staff = $1", idStaff).Scan(&photo, &hash)
+	if err == sql.ErrNoRows || (err == nil && version != "" && version != hash) {
+		returnJSONError(w, "Photo not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		returnJSONError(w, fmt.Sprintf("Photo query error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-%s"`, hash, sizeName)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body := photo
+	if sizeName != "full" {
+		body, err = cachedThumbnail(fmt.Sprintf("%d:%s:%s", idStaff, sizeName, hash), photo, size)
+		if err != nil {
+			returnJSONError(w, fmt.Sprintf("Thumbnail error: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+	} else {
+		w.Header().Set("Content-Type", http.DetectContentType(photo))
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Write(body)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"runtime"
+)
+
+// maxListedSkipped сколько пропущенных записей перечисляется в результате синхронизации
+const maxListedSkipped = 1000
+
+// pipelineResult итог записи карт из источника
+type pipelineResult struct {
+	Written      int
+	Conflicts    int
+	Skipped      int
+	SkippedCards []SkippedCard
+	PeakMemory   uint64
+}
+
+// cardPipeline обрабатывает и записывает пакеты карт из источника в рамках транзакции синхронизации
+type cardPipeline struct {
+	writer    *cardWriter
+	statuses  *statusMapper
+	conflicts *conflictTracker
+	accounts  map[string]*adAccount
+	policy    string
+	kept      map[string]int64 // id_staff оставленной записи для повторяющихся идентификаторов
+	rejected  map[string]bool  // идентификаторы, отброшенные политикой reject-and-report
+	local     map[string]bool  // идентификаторы локальных карт, которые источник не перезаписывает
+	adMatched int
+	result    pipelineResult
+}
+
+// skip учитывает запись, не попавшую в таблицу
+func (p *cardPipeline) skip(identifier string, idStaff int64, reason string) {
+	p.result.Skipped++
+	if len(p.result.SkippedCards) < maxListedSkipped {
+		p.result.SkippedCards = append(p.result.SkippedCards, SkippedCard{identifier, idStaff, reason})
+	}
+}
+
+// sample запоминает наибольший объем занятой кучи за синхронизацию
+func (p *cardPipeline) sample() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	if m.HeapInuse > p.result.PeakMemory {
+		p.result.PeakMemory = m.HeapInuse
+	}
+}
+
+// process приводит пакет к виду для записи и вставляет его; пакет изменяется на месте
+func (p *cardPipeline) process(batch []StaffCard) error {
+	// Приводим типы идентификаторов к справочнику; записи с неизвестным типом пропускаются.
+	// Идентификатор локальной карты остается за ней, запись источника с ним не загружается.
+	valid := batch[:0]
+	for _, sc := range batch {
+		if p.local[sc.Identifier] {
+			p.skip(sc.Identifier, sc.IDStaff, "local")
+			continue
+		}
+		idType, err := normalizeIdentifierType(sc.IdentifierType)
+		if err != nil {
+			log.Printf("⚠️ Skipping %s (ID_STAFF: %d): %v", sc.Identifier, sc.IDStaff, err)
+			continue
+		}
+		sc.IdentifierType = idType
+		valid = append(valid, sc)
+	}
+
+	// Коды статусов PERCo переводятся в понятные метки
+	p.statuses.apply(valid)
+	// ФИО приводятся к NFC без лишних пробелов, чтобы одинаковые имена совпадали при поиске
+	normalizeStaffNames(valid)
+	applyDefaultSite(valid)
+	if p.accounts != nil {
+		p.adMatched += enrichFromAD(valid, p.accounts)
+	}
+
+	// Повторные идентификаторы разрешаются политикой DUPLICATE_POLICY. Запись, оставленная
+	// в предыдущем пакете, удаляется из cards; в текущем пакете - заменяется или убирается на месте.
+	unique := valid[:0]
+	inBatch := make(map[string]int)
+	var drop []string
+	for _, sc := range valid {
+		duplicate, firstStaff, err := p.conflicts.check(sc)
+		if err != nil {
+			return err
+		}
+		if !duplicate {
+			inBatch[sc.Identifier] = len(unique)
+			unique = append(unique, sc)
+			continue
+		}
+		keptStaff, ok := p.kept[sc.Identifier]
+		if !ok {
+			keptStaff = firstStaff
+		}
+		i, keptInBatch := inBatch[sc.Identifier]
+
+		switch p.policy {
+		case duplicateKeepLatest:
+			if sc.IDStaff <= keptStaff {
+				p.skip(sc.Identifier, sc.IDStaff, "superseded")
+				continue
+			}
+			p.kept[sc.Identifier] = sc.IDStaff
+			p.skip(sc.Identifier, keptStaff, "superseded")
+			if keptInBatch {
+				unique[i] = sc
+				continue
+			}
+			drop = append(drop, sc.Identifier)
+			inBatch[sc.Identifier] = len(unique)
+			unique = append(unique, sc)
+		case duplicateReject:
+			if !p.rejected[sc.Identifier] {
+				p.rejected[sc.Identifier] = true
+				p.skip(sc.Identifier, keptStaff, "rejected")
+				if !keptInBatch {
+					drop = append(drop, sc.Identifier)
+				}
+			}
+			p.skip(sc.Identifier, sc.IDStaff, "rejected")
+		default:
+			p.skip(sc.Identifier, sc.IDStaff, "duplicate")
+		}
+	}
+	if len(p.rejected) > 0 {
+		kept := unique[:0]
+		for _, sc := range unique {
+			if !p.rejected[sc.Identifier] {
+				kept = append(kept, sc)
+			}
+		}
+		unique = kept
+	}
+
+	for _, identifier := range drop {
+		res, err := p.writer.tx.Exec("DELETE FROM cards WHERE identifier = $1 AND source <> '"+recordSourceLocal+"'", identifier)
+		if err != nil {
+			return fmt.Errorf("Error removing duplicate %s: %v", identifier, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			p.result.Written -= int(n)
+		}
+	}
+	if err := p.writer.insert(unique); err != nil {
+		return fmt.Errorf("Error inserting data: %v", err)
+	}
+	p.result.Written += len(unique)
+	p.sample()
+	return nil
+}
+
+// writeSourceCards читает карты из источника и записывает их пакетами по INSERT_BATCH_SIZE.
+// Чтение и запись идут параллельно, но в памяти одновременно не больше SYNC_WORKERS пакетов:
+// буферы переиспользуются, и источник ждет, пока запись освободит один из них.
+func writeSourceCards(ctx context.Context, tx *sql.Tx, updateTime string) (*pipelineResult, error) {
+	if err := validateDuplicatePolicy(config.DuplicatePolicy); err != nil {
+		return nil, err
+	}
+	source, err := newSourceConnector()
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := newStatusMapper()
+	if err != nil {
+		return nil, err
+	}
+	// Дубликаты идентификаторов сохраняются для /api/conflicts
+	conflicts, err := newConflictTracker(tx, updateTime)
+	if err != nil {
+		return nil, err
+	}
+	local, err := loadLocalIdentifiers(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &cardPipeline{
+		writer:    newCardWriter(tx, updateTime),
+		statuses:  statuses,
+		conflicts: conflicts,
+		policy:    config.DuplicatePolicy,
+		kept:      make(map[string]int64),
+		rejected:  make(map[string]bool),
+		local:     local,
+	}
+
+	// Дополняем записи атрибутами из Active Directory
+	if config.ADEnabled {
+		log.Println("📇 Loading Active Directory accounts...")
+		if p.accounts, err = loadADAccounts(); err != nil {
+			// Обогащение необязательно, синхронизация продолжается без него
+			log.Printf("⚠️ AD enrichment skipped: %v", err)
+		}
+	}
+
+	workers := config.SyncWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	batchSize := p.writer.batchSize
+	free := make(chan []StaffCard, workers)
+	for i := 0; i < workers; i++ {
+		free <- make([]StaffCard, 0, batchSize)
+	}
+	full := make(chan []StaffCard)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	log.Printf("📥 Sync source: %s", source.Name())
+	fetchErr := make(chan error, 1)
+	go func() {
+		defer close(full)
+		batch := <-free
+		send := func() error {
+			select {
+			case full <- batch:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			select {
+			case batch = <-free:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		err := source.FetchStaffCards(ctx, func(sc StaffCard) error {
+			batch = append(batch, sc)
+			if len(batch) >= batchSize {
+				return send()
+			}
+			return nil
+		})
+		if err == nil && len(batch) > 0 {
+			select {
+			case full <- batch:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		fetchErr <- err
+	}()
+
+	// После ошибки записи чтение останавливается, а оставшиеся пакеты только возвращаются в пул
+	var writeErr error
+	for batch := range full {
+		if writeErr == nil {
+			if writeErr = p.process(batch); writeErr != nil {
+				cancel()
+			}
+		}
+		free <- batch[:0]
+	}
+	if err := <-fetchErr; writeErr == nil && err != nil {
+		return nil, err
+	}
+	if writeErr != nil {
+		return nil, writeErr
+	}
+
+	statuses.report()
+	if p.accounts != nil {
+		log.Printf("📇 Enriched %d of %d records with AD attributes", p.adMatched, p.result.Written+p.result.Skipped)
+	}
+	p.result.Conflicts = conflicts.conflicts
+	return &p.result, nil
+}
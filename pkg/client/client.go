@@ -0,0 +1,303 @@
+// Package client клиент HTTP API perco_web для сервисов на Go: поиск карт, проверка доступа,
+// запуск синхронизации и журнал изменений. Временные ошибки (сеть, 429, 5xx) повторяются
+// с экспоненциальной задержкой; Verify с направлением не повторяется, так как записывает проход.
+//
+//	import "github.com/kalugin1988/perco_web/pkg/client"
+//
+//	c := client.New("http://perco.local:8080", client.Options{APIKey: "key"})
+//	res, err := c.Verify(ctx, client.VerifyRequest{Identifier: "1234567", Direction: client.DirectionIn})
+//
+// Движок синхронизации не выносится в отдельный пакет: он опирается на общую конфигурацию,
+// пулы соединений и очередь синхронизаций сервиса. Оркестратор запускает синхронизацию через
+// TriggerSync вместо вызова /update и забирает результат через ListChanges.
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options параметры клиента; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	// HTTPClient для запросов; по умолчанию клиент с таймаутом 30 секунд
+	HTTPClient *http.Client
+	// APIKey ключ арендатора, передается в X-API-Key
+	APIKey string
+	// Tenant имя арендатора для шлюза нескольких организаций, передается в X-Tenant
+	Tenant string
+	// TriggerSecret SYNC_TRIGGER_SECRET сервиса для подписи TriggerSync
+	TriggerSecret string
+	// MaxRetries число повторов после первой попытки; по умолчанию 3, отрицательное - без повторов.
+	// Verify с направлением не повторяется никогда
+	MaxRetries int
+	// MinBackoff и MaxBackoff границы задержки между повторами; по умолчанию 200ms и 5s
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// UserAgent заголовок User-Agent; по умолчанию perco_web-go-client
+	UserAgent string
+}
+
+// Client клиент API perco_web; безопасен для одновременного использования
+type Client struct {
+	baseURL string
+	opts    Options
+}
+
+// APIError ответ сервиса с ошибкой: код HTTP, машиночитаемый код (если есть) и сообщение
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("perco_web: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("perco_web: %d: %s", e.StatusCode, e.Message)
+}
+
+// temporary ошибки, после которых запрос имеет смысл повторить
+func (e *APIError) temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// envelope общий формат ответов API
+type envelope struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+	Code    string          `json:"code"`
+	Freshness
+}
+
+// New создает клиент для сервиса по адресу baseURL, включая BASE_PATH, например http://host:8080/perco
+func New(baseURL string, opts Options) *Client {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 200 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Second
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = "perco_web-go-client"
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), opts: opts}
+}
+
+// SearchCard ищет карту по номеру в любом представлении (десятичном, Wiegand, HEX)
+func (c *Client) SearchCard(ctx context.Context, card string) (*SearchResult, error) {
+	return c.search(ctx, url.Values{"card": {card}})
+}
+
+// SearchCardMask ищет карты по маске номера: * - любое число цифр, ? - одна цифра, например "123*89"
+func (c *Client) SearchCardMask(ctx context.Context, mask string) (*SearchResult, error) {
+	return c.search(ctx, url.Values{"card": {mask}})
+}
+
+// SearchCardSuffix ищет карты по последним цифрам номера
+func (c *Client) SearchCardSuffix(ctx context.Context, suffix string) (*SearchResult, error) {
+	return c.search(ctx, url.Values{"card_suffix": {suffix}})
+}
+
+// SearchTab возвращает все карты сотрудника по табельному номеру
+func (c *Client) SearchTab(ctx context.Context, tab string) (*SearchResult, error) {
+	return c.search(ctx, url.Values{"tab": {tab}})
+}
+
+func (c *Client) search(ctx context.Context, query url.Values) (*SearchResult, error) {
+	env, err := c.do(ctx, http.MethodGet, "/api/search", query, c.opts.MaxRetries, nil, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound && env != nil {
+		return &SearchResult{Cards: []StaffCard{}, Freshness: env.Freshness}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := &SearchResult{Freshness: env.Freshness}
+	if err := json.Unmarshal(env.Data, &res.Cards); err != nil {
+		return nil, fmt.Errorf("perco_web: invalid search response: %v", err)
+	}
+	return res, nil
+}
+
+// Verify проверяет, разрешен ли проход по идентификатору. Отказ - не ошибка: смотрите Allowed и Reason.
+func (c *Client) Verify(ctx context.Context, req VerifyRequest) (*VerifyResult, error) {
+	query := url.Values{"identifier": {req.Identifier}}
+	if req.Type != "" {
+		query.Set("type", req.Type)
+	}
+	if req.Door != nil {
+		query.Set("door", strconv.FormatInt(*req.Door, 10))
+	}
+	if req.Direction != "" {
+		query.Set("direction", req.Direction)
+	}
+	// Проверка с направлением записывает проход: повтор после потерянного ответа
+	// стал бы вторым проходом, и antipassback отказал бы в допуске
+	retries := c.opts.MaxRetries
+	if req.Direction != "" {
+		retries = 0
+	}
+	var res VerifyResult
+	if _, err := c.do(ctx, http.MethodGet, "/api/verify", query, retries, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// TriggerSync ставит синхронизацию в очередь подписанным запросом /api/sync/trigger.
+// Возвращает false, если синхронизация уже ожидает запуска. Нужен Options.TriggerSecret.
+func (c *Client) TriggerSync(ctx context.Context) (bool, error) {
+	if c.opts.TriggerSecret == "" {
+		return false, errors.New("perco_web: TriggerSecret is not set")
+	}
+	var res struct {
+		Queued bool `json:"queued"`
+	}
+	// Сервис отклоняет повтор подписи, поэтому каждая попытка получает свою метку времени,
+	// даже если повтор приходится на ту же секунду
+	var last int64
+	sign := func(req *http.Request, body []byte) {
+		ts := time.Now().Unix()
+		if ts <= last {
+			ts = last + 1
+		}
+		last = ts
+		timestamp := strconv.FormatInt(ts, 10)
+		mac := hmac.New(sha256.New, []byte(c.opts.TriggerSecret))
+		mac.Write([]byte(timestamp))
+		mac.Write([]byte("."))
+		mac.Write(body)
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/api/sync/trigger", nil, c.opts.MaxRetries, sign, &res); err != nil {
+		return false, err
+	}
+	return res.Queued, nil
+}
+
+// ListChanges возвращает изменения карт после курсора since (пусто - с начала журнала);
+// limit от 1 до 1000, 0 - значение сервиса по умолчанию
+func (c *Client) ListChanges(ctx context.Context, since string, limit int) (*ChangesPage, error) {
+	query := url.Values{}
+	if since != "" {
+		query.Set("since", since)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var page ChangesPage
+	if _, err := c.do(ctx, http.MethodGet, "/api/changes", query, c.opts.MaxRetries, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// do выполняет запрос не более чем с retries повторами и разбирает ответ; data ответа декодируется в out, если он задан.
+// sign вызывается перед каждой попыткой, чтобы подпись не устаревала между повторами.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, retries int, sign func(*http.Request, []byte), out interface{}) (*envelope, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		env, err := c.attempt(ctx, method, target, sign)
+		if err == nil {
+			if out != nil && len(env.Data) > 0 {
+				if err := json.Unmarshal(env.Data, out); err != nil {
+					return env, fmt.Errorf("perco_web: invalid response from %s: %v", path, err)
+				}
+			}
+			return env, nil
+		}
+		lastErr = err
+
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && !apiErr.temporary() {
+			return env, err
+		}
+		if attempt >= retries || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-time.After(c.backoff(attempt)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, lastErr
+}
+
+// attempt одна попытка запроса без тела; ответ без success возвращается вместе с *APIError
+func (c *Client) attempt(ctx context.Context, method, target string, sign func(*http.Request, []byte)) (*envelope, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+	if c.opts.APIKey != "" {
+		req.Header.Set("X-API-Key", c.opts.APIKey)
+	}
+	if c.opts.Tenant != "" {
+		req.Header.Set("X-Tenant", c.opts.Tenant)
+	}
+	if sign != nil {
+		sign(req, nil)
+	}
+
+	resp, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("perco_web: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("perco_web: error reading response: %v", err)
+	}
+
+	env := &envelope{}
+	if err := json.Unmarshal(data, env); err != nil {
+		if resp.StatusCode >= 300 {
+			return nil, &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		}
+		return nil, fmt.Errorf("perco_web: invalid JSON response: %v", err)
+	}
+	if resp.StatusCode >= 300 || !env.Success {
+		return env, &APIError{StatusCode: resp.StatusCode, Code: env.Code, Message: env.Error}
+	}
+	return env, nil
+}
+
+// backoff задержка перед повтором attempt: экспонента от MinBackoff до MaxBackoff со случайным разбросом,
+// чтобы клиенты не повторяли запросы одновременно
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.opts.MinBackoff << attempt
+	if d <= 0 || d > c.opts.MaxBackoff {
+		d = c.opts.MaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func failingClient(t *testing.T, hits *int32) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"success":false,"error":"upstream"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return New(srv.URL, Options{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+}
+
+func TestVerifyWithDirectionIsNotRetried(t *testing.T) {
+	var hits int32
+	c := failingClient(t, &hits)
+	if _, err := c.Verify(context.Background(), VerifyRequest{Identifier: "1234567", Direction: DirectionIn}); err == nil {
+		t.Fatal("expected error")
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Fatalf("verify with direction sent %d requests, want 1", n)
+	}
+}
+
+func TestVerifyWithoutDirectionIsRetried(t *testing.T) {
+	var hits int32
+	c := failingClient(t, &hits)
+	if _, err := c.Verify(context.Background(), VerifyRequest{Identifier: "1234567"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Fatalf("verify without direction sent %d requests, want 3", n)
+	}
+}
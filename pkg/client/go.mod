@@ -0,0 +1,3 @@
+module github.com/kalugin1988/perco_web/pkg/client
+
+go 1.21
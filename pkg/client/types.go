@@ -0,0 +1,98 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// StaffCard сотрудник и один его идентификатор, как их возвращают /api/search и /api/verify
+type StaffCard struct {
+	IDStaff         int64             `json:"id_staff"`
+	Identifier      string            `json:"identifier"`
+	IdentifierType  string            `json:"identifier_type"`
+	TabNumber       *string           `json:"tab_number"`
+	Site            *string           `json:"site"`
+	WiegandFacility *int              `json:"wiegand_facility"`
+	WiegandNumber   *int              `json:"wiegand_number"`
+	LastName        *string           `json:"last_name"`
+	FirstName       *string           `json:"first_name"`
+	MiddleName      *string           `json:"middle_name"`
+	Status          *string           `json:"status"`
+	Info            *string           `json:"info"`
+	Email           *string           `json:"email"`
+	Phone           *string           `json:"phone"`
+	ADAccount       *string           `json:"ad_account"`
+	DepartmentID    *int64            `json:"department_id"`
+	Department      *string           `json:"department"`
+	Position        *string           `json:"position"`
+	ValidFrom       *time.Time        `json:"valid_from"`
+	ValidUntil      *time.Time        `json:"valid_until"`
+	Blocklisted     bool              `json:"blocklisted"`
+	AccessGroups    []AccessGroup     `json:"access_groups"`
+	LastSeen        *time.Time        `json:"last_seen"`
+	ExtraFields     map[string]string `json:"extra_fields,omitempty"`
+}
+
+// AccessGroup группа доступа сотрудника
+type AccessGroup struct {
+	ID    int64   `json:"id"`
+	Name  string  `json:"name"`
+	Doors []int64 `json:"doors,omitempty"`
+}
+
+// Freshness возраст данных сервиса с последней успешной синхронизации
+type Freshness struct {
+	DataAgeSeconds *int64 `json:"data_age_seconds,omitempty"`
+	Stale          bool   `json:"stale"`
+}
+
+// SearchResult карты, найденные по номеру карты или табельному номеру; пустой Cards - ничего не найдено
+type SearchResult struct {
+	Cards []StaffCard
+	Freshness
+}
+
+// Направления прохода для VerifyRequest
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+// VerifyRequest параметры проверки доступа; пустые поля не передаются
+type VerifyRequest struct {
+	Identifier string
+	Type       string // card, plate, pin...; пусто - card
+	Door       *int64
+	Direction  string // DirectionIn, DirectionOut или пусто
+}
+
+// VerifyResult решение о допуске. Reason - ok, not_found, ambiguous, blocklisted, expired, not_yet_valid,
+// no_door_access, antipassback или status_<статус карты>.
+type VerifyResult struct {
+	Allowed        bool       `json:"allowed"`
+	Reason         string     `json:"reason"`
+	Identifier     string     `json:"identifier"`
+	IdentifierType string     `json:"identifier_type"`
+	DoorID         *int64     `json:"door_id,omitempty"`
+	DoorName       string     `json:"door_name,omitempty"`
+	Card           *StaffCard `json:"card,omitempty"`
+	Freshness
+}
+
+// CardChange запись журнала изменений карт; Old пусто для новой карты, New - для удаленной
+type CardChange struct {
+	ID         string          `json:"id"`
+	ChangedAt  time.Time       `json:"changed_at"`
+	ChangeType string          `json:"change_type"`
+	Identifier string          `json:"identifier"`
+	IDStaff    int64           `json:"id_staff"`
+	Old        json.RawMessage `json:"old"`
+	New        json.RawMessage `json:"new"`
+}
+
+// ChangesPage страница журнала изменений; NextCursor передается в следующий вызов ListChanges
+type ChangesPage struct {
+	Changes    []CardChange `json:"changes"`
+	NextCursor string       `json:"next_cursor"`
+	HasMore    bool         `json:"has_more"`
+}
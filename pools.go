@@ -0,0 +1,73 @@
+package main
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// dbPool общий пул соединений, который создается при первом обращении и живет до остановки сервиса
+type dbPool struct {
+	sync.Mutex
+	db *sql.DB
+}
+
+var (
+	pgPool     dbPool
+	pgReadPool dbPool
+	fbPool     dbPool
+)
+
+// PoolStats состояние пула соединений для /api/stats и /status
+type PoolStats struct {
+	MaxOpen     int     `json:"max_open"`
+	Open        int     `json:"open"`
+	InUse       int     `json:"in_use"`
+	Idle        int     `json:"idle"`
+	WaitCount   int64   `json:"wait_count"`
+	WaitSeconds float64 `json:"wait_seconds"`
+}
+
+// openPool открывает пул с ограничениями из конфигурации и проверяет подключение.
+// Нулевые значения оставляют ограничения database/sql по умолчанию.
+func openPool(driver, dsn string, maxOpen, maxIdle int, maxLifetime time.Duration) (*sql.DB, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if maxOpen > 0 {
+		db.SetMaxOpenConns(maxOpen)
+	}
+	if maxIdle > 0 {
+		db.SetMaxIdleConns(maxIdle)
+	}
+	if maxLifetime > 0 {
+		db.SetConnMaxLifetime(maxLifetime)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// stats возвращает состояние пула; nil, если пул еще не создан
+func (p *dbPool) stats() *PoolStats {
+	p.Lock()
+	db := p.db
+	p.Unlock()
+	if db == nil {
+		return nil
+	}
+
+	s := db.Stats()
+	return &PoolStats{
+		MaxOpen:     s.MaxOpenConnections,
+		Open:        s.OpenConnections,
+		InUse:       s.InUse,
+		Idle:        s.Idle,
+		WaitCount:   s.WaitCount,
+		WaitSeconds: s.WaitDuration.Seconds(),
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Position должность и число сотрудников, занимающих ее
+type Position struct {
+	Name  string `json:"name"`
+	Staff int    `json:"staff"`
+}
+
+// positionCondition условие для карт сотрудников с должностью $1 (без учета регистра)
+const positionCondition = `LOWER(position) = LOWER($1)`
+
+// loadPositions возвращает список должностей из staff_cards
+func loadPositions(db *sql.DB) ([]Position, error) {
+	rows, err := db.Query(`
+		SELECT position, COUNT(DISTINCT id_staff)
+		FROM staff_cards
+		WHERE COALESCE(position, '') <> ''
+		GROUP BY position
+		ORDER BY position
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("Positions query error: %v", err)
+	}
+	defer rows.Close()
+
+	positions := []Position{}
+	for rows.Next() {
+		var p Position
+		if err := rows.Scan(&p.Name, &p.Staff); err != nil {
+			return nil, fmt.Errorf("Error scanning position: %v", err)
+		}
+		positions = append(positions, p)
+	}
+	return positions, rows.Err()
+}
+
+// positionsHandler возвращает список должностей для фильтров и отчетов HR
+func positionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	positions, err := loadPositions(pgDB)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	returnJSONSuccess(w, positions, fmt.Sprintf("%d positions", len(positions)))
+}
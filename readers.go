@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Reader считыватель или контроллер СКУД и дверь, которую он обслуживает
+type Reader struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	DoorID     *int64 `json:"door_id"`
+	DoorName   string `json:"door_name"`
+	Controller string `json:"controller"`
+}
+
+// ReaderSource источник, который умеет отдавать список считывателей
+type ReaderSource interface {
+	FetchReaders(ctx context.Context) ([]Reader, error)
+}
+
+// initReadersTable создает таблицу считывателей
+func initReadersTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS readers (
+			id BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL DEFAULT '',
+			address VARCHAR(255) NOT NULL DEFAULT '',
+			door_id BIGINT,
+			door_name VARCHAR(255) NOT NULL DEFAULT '',
+			controller VARCHAR(255) NOT NULL DEFAULT ''
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating readers table: %v", err)
+	}
+	return nil
+}
+
+// fetchReaders получает считыватели, если источник их поддерживает
+func fetchReaders(ctx context.Context) ([]Reader, error) {
+	source, err := newSourceConnector()
+	if err != nil {
+		return nil, err
+	}
+	rs, ok := source.(ReaderSource)
+	if !ok {
+		return nil, nil
+	}
+	return rs.FetchReaders(ctx)
+}
+
+// replaceReaders перезаписывает таблицу считывателей в рамках транзакции синхронизации
+func replaceReaders(tx *sql.Tx, readers []Reader) error {
+	if _, err := tx.Exec("DELETE FROM readers"); err != nil {
+		return fmt.Errorf("Error clearing readers: %v", err)
+	}
+	for _, rd := range readers {
+		_, err := tx.Exec(`
+			INSERT INTO readers (id, name, address, door_id, door_name, controller)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, rd.ID, rd.Name, rd.Address, rd.DoorID, rd.DoorName, rd.Controller)
+		if err != nil {
+			return fmt.Errorf("Error inserting reader %d: %v", rd.ID, err)
+		}
+	}
+	log.Printf("📟 Synced %d readers", len(readers))
+	return nil
+}
+
+// doorName возвращает название двери по ее идентификатору или пустую строку
+func doorName(db *sql.DB, doorID int64) (string, error) {
+	var name string
+	err := db.QueryRow("SELECT door_name FROM readers WHERE door_id = $1 AND door_name <> '' LIMIT 1", doorID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return name, err
+}
+
+// loadReaders возвращает считыватели из таблицы readers
+func loadReaders(db *sql.DB) ([]Reader, error) {
+	rows, err := db.Query(`
+		SELECT id, name, address, door_id, door_name, controller
+		FROM readers
+		ORDER BY controller, name, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("Readers query error: %v", err)
+	}
+	defer rows.Close()
+
+	readers := []Reader{}
+	for rows.Next() {
+		var rd Reader
+		if err := rows.Scan(&rd.ID, &rd.Name, &rd.Address, &rd.DoorID, &rd.DoorName, &rd.Controller); err != nil {
+			return nil, fmt.Errorf("Error scanning reader: %v", err)
+		}
+		readers = append(readers, rd)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Error iterating readers: %v", err)
+	}
+	return readers, nil
+}
+
+// readersHandler возвращает список считывателей и контроллеров
+func readersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	readers, err := loadReaders(pgDB)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	returnJSONSuccess(w, readers, fmt.Sprintf("%d readers", len(readers)))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// maxPooledBuffer буферы больше этого размера не возвращаются в пул, чтобы редкая большая
+// страница не держала память после рендеринга
+const maxPooledBuffer = 1 << 20
+
+// renderBuffers пул буферов для рендеринга страниц
+var renderBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// errorPage страница ошибки веб-интерфейса
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head><meta charset="UTF-8"><title>Ошибка</title></head>
+<body>
+<h1>Ошибка {{.Status}}</h1>
+<p>{{.Message}}</p>
+<p><a href="{{.BasePath}}/">Вернуться к поиску</a></p>
+</body>
+</html>
+`))
+
+// renderPage выполняет шаблон в буфер и отдает страницу только при успешном рендеринге,
+// чтобы клиент не получил обрезанный HTML
+func renderPage(w http.ResponseWriter, t *template.Template, data interface{}) {
+	buf := renderBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			renderBuffers.Put(buf)
+		}
+	}()
+
+	if err := t.Execute(buf, data); err != nil {
+		log.Printf("❌ Template %s failed: %v", t.Name(), err)
+		renderErrorPage(w, http.StatusInternalServerError, "Не удалось сформировать страницу")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := buf.WriteTo(w); err != nil {
+		log.Printf("⚠️ Writing page failed: %v", err)
+	}
+}
+
+// renderErrorPage отдает HTML-страницу с кодом ошибки и сообщением
+func renderErrorPage(w http.ResponseWriter, status int, message string) {
+	var buf bytes.Buffer
+	if err := errorPage.Execute(&buf, struct {
+		Status   int
+		Message  string
+		BasePath string
+	}{status, message, config.BasePath}); err != nil {
+		http.Error(w, message, status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// clockWindow промежуток времени суток; конец раньше начала означает переход через полночь
+type clockWindow struct {
+	start, end time.Duration
+}
+
+// syncSchedule расписание автоматической синхронизации: интервал в рабочее и нерабочее время
+// и окна, в которые синхронизация по расписанию не запускается
+type syncSchedule struct {
+	workInterval time.Duration
+	offInterval  time.Duration
+	workHours    clockWindow
+	workDays     [7]bool
+	blackouts    []clockWindow
+}
+
+// weekdayNames сокращения дней недели для SYNC_WORK_DAYS в порядке time.Weekday
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// sinceMidnight возвращает смещение момента от начала его суток
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
+
+// atClock возвращает момент суток t со смещением offset от полуночи
+func atClock(t time.Time, offset time.Duration) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(offset)
+}
+
+// parseClockWindow разбирает промежуток "07:30-09:00"
+func parseClockWindow(s string) (clockWindow, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return clockWindow{}, fmt.Errorf("invalid window %q, use HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(strings.TrimSpace(from))
+	if err != nil {
+		return clockWindow{}, err
+	}
+	end, err := parseClock(strings.TrimSpace(to))
+	if err != nil {
+		return clockWindow{}, err
+	}
+	if start == end {
+		return clockWindow{}, fmt.Errorf("empty window %q", s)
+	}
+	return clockWindow{start, end}, nil
+}
+
+// contains проверяет, что момент t попадает в промежуток
+func (w clockWindow) contains(t time.Time) bool {
+	offset := sinceMidnight(t)
+	if w.start < w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+// endAfter возвращает конец промежутка, в который попадает момент t
+func (w clockWindow) endAfter(t time.Time) time.Time {
+	end := atClock(t, w.end)
+	if !end.After(t) {
+		end = atClock(t.AddDate(0, 0, 1), w.end)
+	}
+	return end
+}
+
+// parseWeekdays разбирает дни недели "mon-fri" или "mon,wed,sat"
+func parseWeekdays(s string) ([7]bool, error) {
+	var days [7]bool
+	index := func(name string) (int, error) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for i, n := range weekdayNames {
+			if n == name {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("unknown weekday %q, use mon, tue, wed, thu, fri, sat or sun", name)
+	}
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := index(from)
+		if err != nil {
+			return days, err
+		}
+		last := first
+		if isRange {
+			if last, err = index(to); err != nil {
+				return days, err
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// loadSyncSchedule собирает расписание синхронизации из конфигурации
+func loadSyncSchedule() (*syncSchedule, error) {
+	s := &syncSchedule{workInterval: config.SyncInterval, offInterval: config.SyncOffHoursInterval}
+	if s.offInterval <= 0 {
+		s.offInterval = s.workInterval
+	}
+
+	var err error
+	if s.workHours, err = parseClockWindow(config.SyncWorkHours); err != nil {
+		return nil, fmt.Errorf("SYNC_WORK_HOURS: %v", err)
+	}
+	if s.workDays, err = parseWeekdays(config.SyncWorkDays); err != nil {
+		return nil, fmt.Errorf("SYNC_WORK_DAYS: %v", err)
+	}
+	for _, w := range strings.Split(config.SyncBlackout, ",") {
+		if strings.TrimSpace(w) == "" {
+			continue
+		}
+		window, err := parseClockWindow(w)
+		if err != nil {
+			return nil, fmt.Errorf("SYNC_BLACKOUT: %v", err)
+		}
+		s.blackouts = append(s.blackouts, window)
+	}
+	return s, nil
+}
+
+// isWorkTime проверяет, что момент t приходится на рабочие часы рабочего дня.
+// Для ночных рабочих часов день недели определяется по началу смены.
+func (s *syncSchedule) isWorkTime(t time.Time) bool {
+	if !s.workHours.contains(t) {
+		return false
+	}
+	day := t
+	if s.workHours.start > s.workHours.end && sinceMidnight(t) < s.workHours.end {
+		day = t.AddDate(0, 0, -1)
+	}
+	return s.workDays[day.Weekday()]
+}
+
+// nextWorkStart возвращает ближайшее после t начало рабочих часов; нулевое время, если рабочих дней нет
+func (s *syncSchedule) nextWorkStart(t time.Time) time.Time {
+	for d := 0; d <= 7; d++ {
+		day := t.AddDate(0, 0, d)
+		start := atClock(day, s.workHours.start)
+		if start.After(t) && s.workDays[day.Weekday()] {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// next возвращает время следующей синхронизации после момента after
+func (s *syncSchedule) next(after time.Time) time.Time {
+	var next time.Time
+	if s.isWorkTime(after) {
+		next = after.Add(s.workInterval)
+	} else {
+		next = after.Add(s.offInterval)
+		// Редкий ночной интервал не должен откладывать первую синхронизацию рабочего дня
+		if start := s.nextWorkStart(after); !start.IsZero() && start.Before(next) {
+			next = start
+		}
+	}
+
+	// Время, попавшее в окно запрета, переносится на его конец; окна могут идти подряд,
+	// но если они покрывают все сутки, перенос не зацикливается
+	for i, moved := 0, true; moved && i <= len(s.blackouts); i++ {
+		moved = false
+		for _, b := range s.blackouts {
+			if b.contains(next) {
+				next = b.endAfter(next)
+				moved = true
+			}
+		}
+	}
+	return next
+}
+
+// startScheduledSync ставит синхронизацию в очередь по расписанию
+func startScheduledSync(s *syncSchedule) {
+	go func() {
+		for {
+			next := s.next(time.Now())
+			time.Sleep(time.Until(next))
+			enqueueSync("schedule")
+		}
+	}()
+}
+
+// describe возвращает расписание для журнала при запуске
+func (s *syncSchedule) describe() string {
+	d := fmt.Sprintf("every %s", s.workInterval)
+	if s.offInterval != s.workInterval {
+		d += fmt.Sprintf(" during %s on %s, every %s otherwise", config.SyncWorkHours, config.SyncWorkDays, s.offInterval)
+	}
+	if config.SyncBlackout != "" {
+		d += ", never during " + config.SyncBlackout
+	}
+	return d
+}
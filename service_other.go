@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// startWindowsService вне Windows служб нет: процессом управляет systemd или контейнер
+func startWindowsService() bool {
+	return false
+}
+
+// runServiceCommand команды службы доступны только в Windows
+func runServiceCommand(args []string) error {
+	return errors.New("service commands are only available on Windows; use systemd on Linux")
+}
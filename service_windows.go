@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// windowsServiceName имя службы Windows и источника журнала событий
+const windowsServiceName = "perco_web"
+
+// eventLogWriter пишет журнал сервиса в журнал событий Windows: строки с ❌ - ошибки, с ⚠️ - предупреждения
+type eventLogWriter struct {
+	el *eventlog.Log
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	var err error
+	switch logLineLevel(p) {
+	case logLevelError:
+		err = w.el.Error(1, msg)
+	case logLevelWarn:
+		err = w.el.Warning(1, msg)
+	default:
+		err = w.el.Info(1, msg)
+	}
+	return len(p), err
+}
+
+// windowsService обработчик команд диспетчера служб
+type windowsService struct{}
+
+// Execute сообщает диспетчеру, что служба работает, и ждет команды остановки.
+// Сам сервис работает в main; после возврата из Execute процесс завершается.
+func (windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			status <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			log.Printf("🛑 Windows service stop requested")
+			status <- svc.Status{State: svc.StopPending}
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// startWindowsService подключает процесс к диспетчеру служб, если он запущен как служба:
+// рабочий каталог переносится к исполняемому файлу (там index.html и .env), журнал - в журнал событий.
+// Возвращает false при обычном запуске из консоли.
+func startWindowsService() bool {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return false
+	}
+
+	if exe, err := os.Executable(); err == nil {
+		os.Chdir(filepath.Dir(exe))
+	}
+	if el, err := eventlog.Open(windowsServiceName); err == nil {
+		logOutput = eventLogWriter{el: el}
+		log.SetOutput(logOutput)
+	}
+
+	go func() {
+		if err := svc.Run(windowsServiceName, windowsService{}); err != nil {
+			log.Printf("❌ Windows service error: %v", err)
+		}
+		stopTenantProcesses()
+		os.Exit(0)
+	}()
+	return true
+}
+
+// runServiceCommand управляет службой Windows: install [--config file], uninstall, start, stop
+func runServiceCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: service install [--config file] | uninstall | start | stop")
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("cannot connect to the service manager (run as administrator): %v", err)
+	}
+	defer m.Disconnect()
+
+	switch args[0] {
+	case "install":
+		return installWindowsService(m, args[1:])
+	case "uninstall":
+		s, err := m.OpenService(windowsServiceName)
+		if err != nil {
+			return fmt.Errorf("service %s is not installed", windowsServiceName)
+		}
+		defer s.Close()
+		if err := s.Delete(); err != nil {
+			return fmt.Errorf("cannot delete service: %v", err)
+		}
+		if err := eventlog.Remove(windowsServiceName); err != nil {
+			log.Printf("⚠️ Cannot remove event log source: %v", err)
+		}
+		log.Printf("✅ Service %s removed", windowsServiceName)
+		return nil
+	case "start":
+		s, err := m.OpenService(windowsServiceName)
+		if err != nil {
+			return fmt.Errorf("service %s is not installed", windowsServiceName)
+		}
+		defer s.Close()
+		if err := s.Start(); err != nil {
+			return fmt.Errorf("cannot start service: %v", err)
+		}
+		log.Printf("✅ Service %s started", windowsServiceName)
+		return nil
+	case "stop":
+		s, err := m.OpenService(windowsServiceName)
+		if err != nil {
+			return fmt.Errorf("service %s is not installed", windowsServiceName)
+		}
+		defer s.Close()
+		st, err := s.Control(svc.Stop)
+		if err != nil {
+			return fmt.Errorf("cannot stop service: %v", err)
+		}
+		for deadline := time.Now().Add(30 * time.Second); st.State != svc.Stopped; {
+			if time.Now().After(deadline) {
+				return errors.New("service did not stop within 30s")
+			}
+			time.Sleep(300 * time.Millisecond)
+			if st, err = s.Query(); err != nil {
+				return fmt.Errorf("cannot query service status: %v", err)
+			}
+		}
+		log.Printf("✅ Service %s stopped", windowsServiceName)
+		return nil
+	default:
+		return fmt.Errorf("unknown service command %q", args[0])
+	}
+}
+
+// installWindowsService регистрирует службу с автозапуском и перезапуском при сбое.
+// Служба запускается из System32, поэтому путь к .env сохраняется абсолютным.
+func installWindowsService(m *mgr.Mgr, args []string) error {
+	fs := flag.NewFlagSet("service install", flag.ContinueOnError)
+	envFile := fs.String("config", "", "path to the .env file (default .env next to the executable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if *envFile == "" {
+		*envFile = filepath.Join(filepath.Dir(exe), ".env")
+	}
+	configPath, err := filepath.Abs(*envFile)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return fmt.Errorf("config file: %v", err)
+	}
+
+	if s, err := m.OpenService(windowsServiceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", windowsServiceName)
+	}
+	s, err := m.CreateService(windowsServiceName, exe, mgr.Config{
+		DisplayName: "PERCo Web",
+		Description: "PERCo staff cards search and synchronization service",
+		StartType:   mgr.StartAutomatic,
+	}, "--config", configPath)
+	if err != nil {
+		return fmt.Errorf("cannot create service: %v", err)
+	}
+	defer s.Close()
+
+	// Перезапуск через 10 секунд после сбоя; счетчик сбоев сбрасывается через сутки
+	restart := mgr.RecoveryAction{Type: mgr.ServiceRestart, Delay: 10 * time.Second}
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{restart, restart, restart}, 24*60*60); err != nil {
+		log.Printf("⚠️ Cannot set service recovery actions: %v", err)
+	}
+	if err := eventlog.InstallAsEventCreate(windowsServiceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		// Источник мог остаться от прошлой установки
+		if !strings.Contains(err.Error(), "exists") {
+			s.Delete()
+			return fmt.Errorf("cannot register event log source: %v", err)
+		}
+	}
+	log.Printf("✅ Service %s installed with config %s; start it with: perco_web service start", windowsServiceName, configPath)
+	return nil
+}
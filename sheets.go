@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/oauth2/jwt"
+)
+
+const sheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+
+// serviceAccountKey структура для ключа сервисного аккаунта Google
+type serviceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// newSheetsClient создает HTTP клиент, авторизованный сервисным аккаунтом
+func newSheetsClient(ctx context.Context) (*http.Client, error) {
+	data, err := os.ReadFile(config.SheetsCredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account credentials: %v", err)
+	}
+
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("invalid service account credentials: %v", err)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	cfg := &jwt.Config{
+		Email:        key.ClientEmail,
+		PrivateKey:   []byte(key.PrivateKey),
+		PrivateKeyID: key.PrivateKeyID,
+		TokenURL:     key.TokenURI,
+		Scopes:       []string{sheetsScope},
+	}
+	return cfg.Client(ctx), nil
+}
+
+// sheetsRequest выполняет запрос к Google Sheets API
+func sheetsRequest(client *http.Client, method, endpoint string, body interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Google Sheets API returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// exportToSheets заменяет содержимое листа актуальным списком карт
+func exportToSheets() error {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+
+	var cards []StaffCard
+	if config.SheetsFilter != "" {
+		cards, err = queryStaffCards(pgDB, targetDialect().caseInsensitive(staffSearchCondition), staffSearchArgs(config.SheetsFilter)...)
+	} else {
+		cards, err = queryStaffCards(pgDB, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client, err := newSheetsClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	base := fmt.Sprintf("https://sheets.googleapis.com/v4/spreadsheets/%s/values/%s",
+		url.PathEscape(config.SheetsSpreadsheetID), url.PathEscape(config.SheetsRange))
+
+	// Очищаем лист, чтобы удаленные карты не оставались в конце таблицы
+	if err := sheetsRequest(client, http.MethodPost, base+":clear", struct{}{}); err != nil {
+		return fmt.Errorf("failed to clear sheet: %v", err)
+	}
+
+	rows := cardRows(cards)
+	err = sheetsRequest(client, http.MethodPut, base+"?valueInputOption=RAW", map[string]interface{}{
+		"range":          config.SheetsRange,
+		"majorDimension": "ROWS",
+		"values":         rows,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update sheet: %v", err)
+	}
+
+	log.Printf("📊 Google Sheet updated: %d cards exported", len(cards))
+	return nil
+}
+
+// startSheetsExport запускает периодическую выгрузку в Google Sheets
+func startSheetsExport() {
+	log.Printf("📊 Google Sheets export enabled: every %s to spreadsheet %s", config.SheetsInterval, config.SheetsSpreadsheetID)
+	startPeriodicJob("Google Sheets export", config.SheetsInterval, exportToSheets)
+}
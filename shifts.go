@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Shift рабочий график PERCo с временем начала и конца смены
+type Shift struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Staff int    `json:"staff"`
+}
+
+// ShiftData графики и их назначения сотрудникам
+type ShiftData struct {
+	Shifts      []Shift
+	StaffShifts map[int64]int64
+}
+
+// ShiftSource источник, который умеет отдавать рабочие графики
+type ShiftSource interface {
+	FetchShifts(ctx context.Context) (*ShiftData, error)
+}
+
+// initShiftTables создает таблицы графиков и их назначений
+func initShiftTables(db *sql.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS work_shifts (
+			id BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			start_time TIME NOT NULL,
+			end_time TIME NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS staff_shifts (
+			id_staff BIGINT PRIMARY KEY,
+			shift_id BIGINT NOT NULL
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("error creating shift tables: %v", err)
+		}
+	}
+	return nil
+}
+
+// fetchShifts получает графики, если источник их поддерживает
+func fetchShifts(ctx context.Context) (*ShiftData, error) {
+	source, err := newSourceConnector()
+	if err != nil {
+		return nil, err
+	}
+	ss, ok := source.(ShiftSource)
+	if !ok {
+		return nil, nil
+	}
+	return ss.FetchShifts(ctx)
+}
+
+// replaceShifts перезаписывает графики в рамках транзакции синхронизации
+func replaceShifts(tx *sql.Tx, data *ShiftData) error {
+	for _, table := range []string{"staff_shifts", "work_shifts"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("Error clearing %s: %v", table, err)
+		}
+	}
+
+	for _, s := range data.Shifts {
+		_, err := tx.Exec("INSERT INTO work_shifts (id, name, start_time, end_time) VALUES ($1, $2, $3, $4)",
+			s.ID, s.Name, s.Start, s.End)
+		if err != nil {
+			return fmt.Errorf("Error inserting shift %d: %v", s.ID, err)
+		}
+	}
+	for idStaff, shiftID := range data.StaffShifts {
+		_, err := tx.Exec("INSERT INTO staff_shifts (id_staff, shift_id) VALUES ($1, $2)", idStaff, shiftID)
+		if err != nil {
+			return fmt.Errorf("Error assigning shift %d to %d: %v", shiftID, idStaff, err)
+		}
+	}
+
+	log.Printf("🕘 Synced %d shifts, %d assignments", len(data.Shifts), len(data.StaffShifts))
+	return nil
+}
+
+// loadShifts возвращает графики с числом назначенных сотрудников
+func loadShifts(db *sql.DB) ([]Shift, error) {
+	rows, err := db.Query(`
+		SELECT s.id, s.name, to_char(s.start_time, 'HH24:MI'), to_char(s.end_time, 'HH24:MI'), COUNT(ss.id_staff)
+		FROM work_shifts s
+		LEFT JOIN staff_shifts ss ON ss.shift_id = s.id
+		GROUP BY s.id
+		ORDER BY s.name, s.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("Shifts query error: %v", err)
+	}
+	defer rows.Close()
+
+	shifts := []Shift{}
+	for rows.Next() {
+		var s Shift
+		if err := rows.Scan(&s.ID, &s.Name, &s.Start, &s.End, &s.Staff); err != nil {
+			return nil, fmt.Errorf("Error scanning shift: %v", err)
+		}
+		shifts = append(shifts, s)
+	}
+	return shifts, rows.Err()
+}
+
+// loadStaffShifts возвращает назначенный график каждого сотрудника
+func loadStaffShifts(db *sql.DB) (map[int64]Shift, error) {
+	rows, err := db.Query(`
+		SELECT ss.id_staff, s.id, s.name, to_char(s.start_time, 'HH24:MI'), to_char(s.end_time, 'HH24:MI')
+		FROM staff_shifts ss
+		JOIN work_shifts s ON s.id = ss.shift_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("Staff shifts query error: %v", err)
+	}
+	defer rows.Close()
+
+	shifts := make(map[int64]Shift)
+	for rows.Next() {
+		var idStaff int64
+		var s Shift
+		if err := rows.Scan(&idStaff, &s.ID, &s.Name, &s.Start, &s.End); err != nil {
+			return nil, fmt.Errorf("Error scanning staff shift: %v", err)
+		}
+		shifts[idStaff] = s
+	}
+	return shifts, rows.Err()
+}
+
+// forShift возвращает копию правил с началом и концом дня из графика сотрудника
+func (r *WorkRules) forShift(s Shift) (*WorkRules, error) {
+	start, err := parseClock(s.Start)
+	if err != nil {
+		return nil, fmt.Errorf("shift %d: %v", s.ID, err)
+	}
+	end, err := parseClock(s.End)
+	if err != nil {
+		return nil, fmt.Errorf("shift %d: %v", s.ID, err)
+	}
+	rules := *r
+	rules.DayStart, rules.DayEnd = start, end
+	return &rules, nil
+}
+
+// shiftsHandler возвращает рабочие графики
+func shiftsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	shifts, err := loadShifts(pgDB)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	returnJSONSuccess(w, shifts, fmt.Sprintf("%d shifts", len(shifts)))
+}
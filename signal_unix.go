@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// syncSignals сигналы, по которым синхронизация ставится в очередь: kill -USR1 <pid> или systemctl kill -s USR1
+var syncSignals = []os.Signal{syscall.SIGUSR1}
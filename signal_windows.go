@@ -0,0 +1,6 @@
+package main
+
+import "os"
+
+// syncSignals в Windows нет SIGUSR1: синхронизация запускается только через HTTP и расписание
+var syncSignals []os.Signal
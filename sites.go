@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Site объект (здание) и число сотрудников на нем
+type Site struct {
+	Name  string `json:"name"`
+	Staff int    `json:"staff"`
+}
+
+// siteCondition условие для карт объекта $1
+const siteCondition = `site = $1`
+
+// applyDefaultSite проставляет SITE картам, для которых источник не указал объект
+func applyDefaultSite(cards []StaffCard) {
+	if config.Site == "" {
+		return
+	}
+	for i := range cards {
+		if cards[i].Site == nil {
+			cards[i].Site = &config.Site
+		}
+	}
+}
+
+// loadSites возвращает список объектов из staff_cards
+func loadSites(db *sql.DB) ([]Site, error) {
+	rows, err := db.Query(`
+		SELECT site, COUNT(DISTINCT id_staff)
+		FROM staff_cards
+		WHERE COALESCE(site, '') <> ''
+		GROUP BY site
+		ORDER BY site
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("Sites query error: %v", err)
+	}
+	defer rows.Close()
+
+	sites := []Site{}
+	for rows.Next() {
+		var s Site
+		if err := rows.Scan(&s.Name, &s.Staff); err != nil {
+			return nil, fmt.Errorf("Error scanning site: %v", err)
+		}
+		sites = append(sites, s)
+	}
+	return sites, rows.Err()
+}
+
+// sitesHandler возвращает список объектов для фильтров и отчетов
+func sitesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	sites, err := loadSites(pgDB)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	returnJSONSuccess(w, sites, fmt.Sprintf("%d sites", len(sites)))
+}
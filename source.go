@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// SourceConnector источник данных о сотрудниках и картах (СКУД)
+type SourceConnector interface {
+	// Name возвращает имя источника для логов
+	Name() string
+	// FetchStaffCards передает в emit записи источника по одной;
+	// ошибка emit прерывает чтение и возвращается вызывающему
+	FetchStaffCards(ctx context.Context, emit func(StaffCard) error) error
+}
+
+// SnapshotSource источник, который умеет читать все данные синхронизации из одного согласованного снимка
+type SnapshotSource interface {
+	// BeginSnapshot возвращает контекст, запросы с которым читают снимок, и функцию его закрытия
+	BeginSnapshot(ctx context.Context) (context.Context, func(), error)
+}
+
+// sourceFactories зарегистрированные типы источников по значению SOURCE_TYPE
+var sourceFactories = map[string]func() SourceConnector{}
+
+// registerSource регистрирует тип источника; вызывается из init() реализаций
+func registerSource(name string, factory func() SourceConnector) {
+	if _, exists := sourceFactories[name]; exists {
+		panic(fmt.Sprintf("source %q already registered", name))
+	}
+	sourceFactories[name] = factory
+}
+
+// newSourceConnector создает источник, выбранный в SOURCE_TYPE
+func newSourceConnector() (SourceConnector, error) {
+	factory, ok := sourceFactories[config.SourceType]
+	if !ok {
+		var names []string
+		for name := range sourceFactories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown SOURCE_TYPE %q (available: %v)", config.SourceType, names)
+	}
+	return factory(), nil
+}
+
+// beginSourceSnapshot открывает снимок источника, если источник их поддерживает; end всегда не nil
+func beginSourceSnapshot(ctx context.Context) (context.Context, func(), error) {
+	source, err := newSourceConnector()
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	ss, ok := source.(SnapshotSource)
+	if !ok {
+		return ctx, func() {}, nil
+	}
+	snapshotCtx, end, err := ss.BeginSnapshot(ctx)
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	return snapshotCtx, end, nil
+}
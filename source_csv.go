@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// csvSource читает сотрудников и карты из CSV файла с заголовком
+// (id_staff, identifier, identifier_type, tab_number, last_name, first_name, middle_name, position, status, site).
+// Колонки вида extra.ключ попадают в дополнительные поля карты.
+type csvSource struct{}
+
+func init() {
+	registerSource("csv", func() SourceConnector { return csvSource{} })
+}
+
+// Name возвращает имя источника
+func (csvSource) Name() string {
+	return "CSV"
+}
+
+// FetchStaffCards построчно читает файл CSV_SOURCE_FILE
+func (csvSource) FetchStaffCards(ctx context.Context, emit func(StaffCard) error) error {
+	log.Printf("📥 Fetching data from CSV file %s...", config.CSVSourceFile)
+
+	f, err := os.Open(config.CSVSourceFile)
+	if err != nil {
+		return fmt.Errorf("CSV source error: %v", err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	if config.CSVDelimiter != "" {
+		reader.Comma = []rune(config.CSVDelimiter)[0]
+	}
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("CSV source header error: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"id_staff", "identifier"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("CSV source is missing column %q", required)
+		}
+	}
+
+	field := func(record []string, name string) *string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) || strings.TrimSpace(record[i]) == "" {
+			return nil
+		}
+		value := strings.TrimSpace(record[i])
+		return &value
+	}
+
+	count := 0
+	for line := 2; ; line++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("CSV source line %d: %v", line, err)
+		}
+
+		idStaff, err := strconv.ParseInt(strings.TrimSpace(record[columns["id_staff"]]), 10, 64)
+		if err != nil {
+			return fmt.Errorf("CSV source line %d: invalid id_staff: %v", line, err)
+		}
+		identifier := field(record, "identifier")
+		if identifier == nil {
+			continue
+		}
+
+		sc := StaffCard{
+			IDStaff:        idStaff,
+			Identifier:     *identifier,
+			IdentifierType: strValue(field(record, "identifier_type")),
+			TabNumber:      field(record, "tab_number"),
+			LastName:       field(record, "last_name"),
+			FirstName:      field(record, "first_name"),
+			MiddleName:     field(record, "middle_name"),
+			Position:       field(record, "position"),
+			Status:         field(record, "status"),
+			Site:           field(record, "site"),
+		}
+		for name := range columns {
+			if key, ok := strings.CutPrefix(name, "extra."); ok {
+				if value := field(record, name); value != nil {
+					if sc.ExtraFields == nil {
+						sc.ExtraFields = make(map[string]string)
+					}
+					sc.ExtraFields[key] = *value
+				}
+			}
+		}
+		if t, _ := normalizeIdentifierType(sc.IdentifierType); t == identifierPlate {
+			sc.Identifier = normalizePlate(sc.Identifier)
+		}
+		if err := emit(sc); err != nil {
+			return err
+		}
+		count++
+	}
+
+	log.Printf("📥 Successfully fetched %d records from CSV", count)
+	return nil
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// firebirdSource читает сотрудников и их карты напрямую из базы PERCo в Firebird
+type firebirdSource struct{}
+
+func init() {
+	registerSource("firebird", func() SourceConnector { return firebirdSource{} })
+}
+
+// Name возвращает имя источника
+func (firebirdSource) Name() string {
+	return "Firebird"
+}
+
+// fbQuerier общий интерфейс пула Firebird и транзакции snapshot для запросов чтения
+type fbQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// fbSnapshotKey ключ контекста с транзакцией snapshot текущей синхронизации
+type fbSnapshotKey struct{}
+
+// BeginSnapshot открывает транзакцию Firebird уровня SNAPSHOT (в database/sql - REPEATABLE READ).
+// Все запросы источника с полученным контекстом видят базу на момент начала транзакции,
+// поэтому сотрудники, карты и справочники согласованы, даже если оператор PERCo правит их во время синхронизации.
+func (firebirdSource) BeginSnapshot(ctx context.Context) (context.Context, func(), error) {
+	fbDB, err := connectFirebird()
+	if err != nil {
+		return ctx, nil, fmt.Errorf("Firebird connection error: %v", err)
+	}
+	tx, err := fbDB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return ctx, nil, fmt.Errorf("Firebird snapshot transaction error: %v", err)
+	}
+	// Транзакция только читает, поэтому завершается откатом
+	return context.WithValue(ctx, fbSnapshotKey{}, tx), func() { tx.Rollback() }, nil
+}
+
+// firebirdQuerier возвращает транзакцию snapshot из ctx, а вне синхронизации - общий пул
+func firebirdQuerier(ctx context.Context) (fbQuerier, error) {
+	if tx, ok := ctx.Value(fbSnapshotKey{}).(*sql.Tx); ok {
+		return tx, nil
+	}
+	return connectFirebird()
+}
+
+// FetchStaffCards читает записи из таблиц STAFF и STAFF_CARDS с подразделением и должностью
+func (firebirdSource) FetchStaffCards(ctx context.Context, emit func(StaffCard) error) error {
+	// Подключаемся к Firebird
+	fbDB, err := firebirdQuerier(ctx)
+	if err != nil {
+		log.Printf("❌ Firebird connection failed: %v", err)
+		return fmt.Errorf("Firebird connection error: %v", err)
+	}
+
+	// Строки из баз с кодировкой NONE или WIN1251 перекодируются в UTF-8
+	decoder, err := newTextDecoder()
+	if err != nil {
+		return err
+	}
+	detectFirebirdCharset(ctx, fbDB)
+
+	// Получаем данные из Firebird
+	log.Println("📥 Fetching data from Firebird...")
+	validUntil := "CAST(NULL AS TIMESTAMP)"
+	if config.CardValidUntilColumn != "" {
+		validUntil = "sc." + config.CardValidUntilColumn
+	}
+	status := "CAST(NULL AS VARCHAR(50))"
+	if config.StatusColumn != "" {
+		status = "CAST(" + config.StatusColumn + " AS VARCHAR(50))"
+	}
+	site := "CAST(NULL AS VARCHAR(100))"
+	if config.SiteColumn != "" {
+		site = "CAST(" + config.SiteColumn + " AS VARCHAR(100))"
+	}
+	extraFields, err := parseExtraFields(config.ExtraFields)
+	if err != nil {
+		return err
+	}
+	extraColumns := ""
+	for _, f := range extraFields {
+		extraColumns += ", CAST(" + f.Expr + " AS VARCHAR(255))"
+	}
+	query := `
+		SELECT s.LAST_NAME, s.FIRST_NAME, s.MIDDLE_NAME, s.ID_STAFF, sc.IDENTIFIER, sr.SUBDIV_ID, ar.DISPLAY_NAME,
+			` + validUntil + `, ` + status + `, s.TABEL_ID, ` + site + extraColumns + `
+		FROM STAFF s
+		JOIN STAFF_CARDS sc ON s.ID_STAFF = sc.STAFF_ID
+		LEFT JOIN STAFF_REF sr ON sr.STAFF_ID = s.ID_STAFF
+		LEFT JOIN APPOINT_REF ar ON ar.ID_REF = sr.APPOINT_ID
+	`
+	rows, err := fbDB.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("❌ Firebird query failed: %v", err)
+		return fmt.Errorf("Firebird query error: %v", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var sc StaffCard
+		var lastName, firstName, middleName, position, statusCode, tabNumber, siteName sql.NullString
+		var departmentID sql.NullInt64
+		var validUntil sql.NullTime
+		extraValues := make([]sql.NullString, len(extraFields))
+
+		dest := []interface{}{&lastName, &firstName, &middleName, &sc.IDStaff, &sc.Identifier, &departmentID, &position,
+			&validUntil, &statusCode, &tabNumber, &siteName}
+		for i := range extraValues {
+			dest = append(dest, &extraValues[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			log.Printf("❌ Error scanning row: %v", err)
+			return fmt.Errorf("Error scanning row: %v", err)
+		}
+
+		if lastName.Valid {
+			sc.LastName = &lastName.String
+		}
+		if firstName.Valid {
+			sc.FirstName = &firstName.String
+		}
+		if middleName.Valid {
+			sc.MiddleName = &middleName.String
+		}
+		if departmentID.Valid {
+			sc.DepartmentID = &departmentID.Int64
+		}
+		if position.Valid && position.String != "" {
+			sc.Position = &position.String
+		}
+		if validUntil.Valid {
+			t := sourceTime(validUntil.Time)
+			sc.ValidUntil = &t
+		}
+		if statusCode.Valid {
+			sc.Status = &statusCode.String
+		}
+		if tabNumber.Valid && tabNumber.String != "" {
+			sc.TabNumber = &tabNumber.String
+		}
+		if siteName.Valid && siteName.String != "" {
+			sc.Site = &siteName.String
+		}
+		for i, f := range extraFields {
+			if extraValues[i].Valid && extraValues[i].String != "" {
+				if sc.ExtraFields == nil {
+					sc.ExtraFields = make(map[string]string)
+				}
+				sc.ExtraFields[f.Key] = extraValues[i].String
+			}
+		}
+		decoder.decodeStaffCard(&sc)
+
+		if err := emit(sc); err != nil {
+			return err
+		}
+		count++
+
+		// Логируем прогресс каждые 100 записей
+		if count%100 == 0 {
+			log.Printf("📥 Fetched %d records...", count)
+		}
+	}
+
+	// Проверяем ошибки после итерации по строкам
+	if err = rows.Err(); err != nil {
+		log.Printf("❌ Error iterating rows: %v", err)
+		return fmt.Errorf("Error iterating rows: %v", err)
+	}
+
+	log.Printf("📥 Successfully fetched %d records from Firebird", count)
+	decoder.report()
+
+	// Госномера автомобилей для шлагбаума, если задан запрос
+	if config.VehiclesQuery != "" {
+		return fetchVehiclePlates(ctx, fbDB, emit)
+	}
+	return nil
+}
+
+// FetchDepartments читает дерево подразделений из таблицы SUBDIV_REF
+func (firebirdSource) FetchDepartments(ctx context.Context) ([]Department, error) {
+	fbDB, err := firebirdQuerier(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Firebird connection error: %v", err)
+	}
+
+	rows, err := fbDB.QueryContext(ctx, "SELECT ID_REF, DISPLAY_NAME, ID_PARENT FROM SUBDIV_REF")
+	if err != nil {
+		return nil, fmt.Errorf("Firebird departments query error: %v", err)
+	}
+	defer rows.Close()
+
+	decoder, err := newTextDecoder()
+	if err != nil {
+		return nil, err
+	}
+	var departments []Department
+	for rows.Next() {
+		var d Department
+		var name sql.NullString
+		var parentID sql.NullInt64
+		if err := rows.Scan(&d.ID, &name, &parentID); err != nil {
+			return nil, fmt.Errorf("Error scanning department: %v", err)
+		}
+		if d.Name = decoder.decode("DISPLAY_NAME", name.String); len(decoder.bad) > 0 {
+			log.Printf("⚠️ Department %d: undecodable bytes in name", d.ID)
+			decoder.bad = decoder.bad[:0]
+		}
+		// Корневые подразделения могут ссылаться на 0 вместо NULL
+		if parentID.Valid && parentID.Int64 != 0 {
+			d.ParentID = &parentID.Int64
+		}
+		departments = append(departments, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Error iterating departments: %v", err)
+	}
+
+	log.Printf("📥 Fetched %d departments from Firebird", len(departments))
+	return departments, nil
+}
+
+// FetchAccessGroups читает группы доступа запросами из конфигурации, так как их схема
+// различается между версиями PERCo; без ACCESS_GROUPS_QUERY группы не синхронизируются
+func (firebirdSource) FetchAccessGroups(ctx context.Context) (*AccessData, error) {
+	if config.AccessGroupsQuery == "" {
+		return nil, nil
+	}
+
+	fbDB, err := firebirdQuerier(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Firebird connection error: %v", err)
+	}
+
+	data := &AccessData{StaffGroups: make(map[int64][]int64)}
+	index := make(map[int64]int)
+	err = queryEachRow(ctx, fbDB, config.AccessGroupsQuery, func(rows *sql.Rows) error {
+		var g AccessGroup
+		var name sql.NullString
+		if err := rows.Scan(&g.ID, &name); err != nil {
+			return err
+		}
+		g.Name = name.String
+		index[g.ID] = len(data.Groups)
+		data.Groups = append(data.Groups, g)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Firebird access groups query error: %v", err)
+	}
+
+	if config.AccessGroupDoorsQuery != "" {
+		err = queryEachRow(ctx, fbDB, config.AccessGroupDoorsQuery, func(rows *sql.Rows) error {
+			var groupID, doorID int64
+			if err := rows.Scan(&groupID, &doorID); err != nil {
+				return err
+			}
+			if i, ok := index[groupID]; ok {
+				data.Groups[i].Doors = append(data.Groups[i].Doors, doorID)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Firebird access group doors query error: %v", err)
+		}
+	}
+
+	if config.StaffAccessGroupsQuery != "" {
+		err = queryEachRow(ctx, fbDB, config.StaffAccessGroupsQuery, func(rows *sql.Rows) error {
+			var idStaff, groupID int64
+			if err := rows.Scan(&idStaff, &groupID); err != nil {
+				return err
+			}
+			data.StaffGroups[idStaff] = append(data.StaffGroups[idStaff], groupID)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Firebird staff access groups query error: %v", err)
+		}
+	}
+
+	log.Printf("📥 Fetched %d access groups from Firebird", len(data.Groups))
+	return data, nil
+}
+
+// queryEachRow выполняет запрос и передает каждую строку в scan
+func queryEachRow(ctx context.Context, db fbQuerier, query string, scan func(*sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// FetchReaders читает считыватели запросом READERS_QUERY; без него список не синхронизируется
+func (firebirdSource) FetchReaders(ctx context.Context) ([]Reader, error) {
+	if config.ReadersQuery == "" {
+		return nil, nil
+	}
+
+	fbDB, err := firebirdQuerier(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Firebird connection error: %v", err)
+	}
+
+	var readers []Reader
+	err = queryEachRow(ctx, fbDB, config.ReadersQuery, func(rows *sql.Rows) error {
+		var rd Reader
+		var name, address, doorName, controller sql.NullString
+		var doorID sql.NullInt64
+		if err := rows.Scan(&rd.ID, &name, &address, &doorID, &doorName, &controller); err != nil {
+			return err
+		}
+		rd.Name, rd.Address, rd.DoorName, rd.Controller = name.String, address.String, doorName.String, controller.String
+		if doorID.Valid {
+			rd.DoorID = &doorID.Int64
+		}
+		readers = append(readers, rd)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Firebird readers query error: %v", err)
+	}
+
+	log.Printf("📥 Fetched %d readers from Firebird", len(readers))
+	return readers, nil
+}
+
+// FetchPhotos читает фотографии запросом PHOTOS_QUERY (id_staff, photo); без него фото не синхронизируются
+func (firebirdSource) FetchPhotos(ctx context.Context, emit func(idStaff int64, photo []byte) error) error {
+	if config.PhotosQuery == "" {
+		return nil
+	}
+
+	fbDB, err := firebirdQuerier(ctx)
+	if err != nil {
+		return fmt.Errorf("Firebird connection error: %v", err)
+	}
+
+	return queryEachRow(ctx, fbDB, config.PhotosQuery, func(rows *sql.Rows) error {
+		var idStaff int64
+		var photo []byte
+		if err := rows.Scan(&idStaff, &photo); err != nil {
+			return err
+		}
+		return emit(idStaff, photo)
+	})
+}
+
+// FetchShifts читает графики запросами SHIFTS_QUERY (id, name, start, end; время строкой HH:MM)
+// и STAFF_SHIFTS_QUERY (id_staff, shift_id); без SHIFTS_QUERY графики не синхронизируются
+func (firebirdSource) FetchShifts(ctx context.Context) (*ShiftData, error) {
+	if config.ShiftsQuery == "" {
+		return nil, nil
+	}
+
+	fbDB, err := firebirdQuerier(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Firebird connection error: %v", err)
+	}
+
+	data := &ShiftData{StaffShifts: make(map[int64]int64)}
+	err = queryEachRow(ctx, fbDB, config.ShiftsQuery, func(rows *sql.Rows) error {
+		var s Shift
+		var name sql.NullString
+		if err := rows.Scan(&s.ID, &name, &s.Start, &s.End); err != nil {
+			return err
+		}
+		if _, err := parseClock(s.Start); err != nil {
+			return fmt.Errorf("shift %d start: %v", s.ID, err)
+		}
+		if _, err := parseClock(s.End); err != nil {
+			return fmt.Errorf("shift %d end: %v", s.ID, err)
+		}
+		s.Name = name.String
+		data.Shifts = append(data.Shifts, s)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Firebird shifts query error: %v", err)
+	}
+
+	if config.StaffShiftsQuery != "" {
+		err = queryEachRow(ctx, fbDB, config.StaffShiftsQuery, func(rows *sql.Rows) error {
+			var idStaff, shiftID int64
+			if err := rows.Scan(&idStaff, &shiftID); err != nil {
+				return err
+			}
+			data.StaffShifts[idStaff] = shiftID
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Firebird staff shifts query error: %v", err)
+		}
+	}
+
+	log.Printf("📥 Fetched %d shifts from Firebird", len(data.Shifts))
+	return data, nil
+}
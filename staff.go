@@ -0,0 +1,121 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Person сотрудник со всеми его идентификаторами
+type Person struct {
+	IDStaff      int64       `json:"id_staff"`
+	TabNumber    *string     `json:"tab_number"`
+	Site         *string     `json:"site"`
+	LastName     *string     `json:"last_name"`
+	FirstName    *string     `json:"first_name"`
+	MiddleName   *string     `json:"middle_name"`
+	Email        *string     `json:"email"`
+	Phone        *string     `json:"phone"`
+	ADAccount    *string     `json:"ad_account"`
+	DepartmentID *int64      `json:"department_id"`
+	Department   *string     `json:"department"`
+	Position     *string     `json:"position"`
+	Cards        []StaffCard `json:"cards"`
+	Tags         []StaffTag  `json:"tags"`
+	Notes        []StaffNote `json:"notes"`
+}
+
+// loadPerson читает сотрудника из staff и его карты; nil означает, что сотрудника нет
+func loadPerson(db *sql.DB, idStaff int64) (*Person, error) {
+	p := Person{IDStaff: idStaff}
+	department := "(SELECT path FROM department_paths dp WHERE dp.id = staff.department_id)"
+	if !targetDialect().extended {
+		department = "NULL"
+	}
+	err := db.QueryRow(`
+		SELECT tab_number, site, last_name, first_name, middle_name, email, phone, ad_account, department_id,
+			`+department+`, position
+		FROM staff WHERE id_staff = $1
+	`, idStaff).Scan(&p.TabNumber, &p.Site, &p.LastName, &p.FirstName, &p.MiddleName, &p.Email, &p.Phone,
+		&p.ADAccount, &p.DepartmentID, &p.Department, &p.Position)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Staff query error: %v", err)
+	}
+
+	if p.Cards, err = queryStaffCards(db, "id_staff = $1", idStaff); err != nil {
+		return nil, err
+	}
+	// Теги и заметки ведутся только в схеме PostgreSQL
+	if targetDialect().extended {
+		if p.Tags, err = loadStaffTags(db, idStaff); err != nil {
+			return nil, err
+		}
+		if p.Notes, err = loadStaffNotes(db, idStaff); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// personHandler отдает сотрудника с его картами
+func personHandler(w http.ResponseWriter, r *http.Request, idStaff int64) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	person, err := loadPerson(pgDB, idStaff)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if person == nil {
+		returnJSONError(w, "Employee not found", http.StatusNotFound)
+		return
+	}
+	returnJSONSuccess(w, person, fmt.Sprintf("Employee with %d cards", len(person.Cards)))
+}
+
+// staffHandler разбирает пути вида /api/staff/{id}/... и передает запрос нужному обработчику
+func staffHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/staff/"), "/"), "/")
+	idStaff, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		returnJSONError(w, "Invalid staff id", http.StatusBadRequest)
+		return
+	}
+
+	sub := strings.Join(parts[1:], "/")
+	// Фото, история карт, теги и заметки ведутся только в схеме PostgreSQL
+	if sub != "" && !targetDialect().extended {
+		returnJSONError(w, "Not available with TARGET_DB="+config.TargetDB, http.StatusNotFound)
+		return
+	}
+	switch sub {
+	case "":
+		personHandler(w, r, idStaff)
+	case "photo":
+		staffPhotoHandler(w, r, idStaff)
+	case "cards/history":
+		cardHistoryHandler(w, r, idStaff)
+	case "tags":
+		staffTagsHandler(w, r, idStaff)
+	case "notes":
+		staffNotesHandler(w, r, idStaff)
+	default:
+		returnJSONError(w, "Not found", http.StatusNotFound)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// StaffTag тег сотрудника ("подрядчик", "доступ 24/7"); хранится локально и не зависит от синхронизации
+type StaffTag struct {
+	IDStaff int64     `json:"id_staff"`
+	Tag     string    `json:"tag"`
+	AddedBy string    `json:"added_by"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// StaffNote заметка о сотруднике с автором и временем
+type StaffNote struct {
+	ID        int64     `json:"id"`
+	IDStaff   int64     `json:"id_staff"`
+	Text      string    `json:"text"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagCount тег и число сотрудников с ним для фильтров поиска
+type TagCount struct {
+	Name  string `json:"name"`
+	Staff int    `json:"staff"`
+}
+
+// maxTagLength наибольшая длина тега в символах
+const maxTagLength = 100
+
+// staffTagCondition условие для карт сотрудников с тегом $1 (без учета регистра)
+const staffTagCondition = `id_staff IN (SELECT id_staff FROM staff_tags WHERE LOWER(tag) = LOWER($1))`
+
+// initStaffTagTables создает таблицы тегов и заметок сотрудников; синхронизация эти таблицы не изменяет
+func initStaffTagTables(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS staff_tags (
+			id_staff BIGINT NOT NULL,
+			tag VARCHAR(100) NOT NULL,
+			added_by VARCHAR(255) NOT NULL DEFAULT '',
+			added_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (id_staff, tag)
+		);
+		CREATE INDEX IF NOT EXISTS staff_tags_tag_idx ON staff_tags (LOWER(tag));
+		CREATE TABLE IF NOT EXISTS staff_notes (
+			id BIGSERIAL PRIMARY KEY,
+			id_staff BIGINT NOT NULL,
+			text TEXT NOT NULL,
+			author VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS staff_notes_staff_idx ON staff_notes (id_staff, created_at)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating staff tag tables: %v", err)
+	}
+	return nil
+}
+
+// loadTagCounts возвращает теги сотрудников с числом сотрудников
+func loadTagCounts(db *sql.DB) ([]TagCount, error) {
+	rows, err := db.Query("SELECT tag, COUNT(*) FROM staff_tags GROUP BY tag ORDER BY tag")
+	if err != nil {
+		return nil, fmt.Errorf("Tags query error: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var t TagCount
+		if err := rows.Scan(&t.Name, &t.Staff); err != nil {
+			return nil, fmt.Errorf("Error scanning tag: %v", err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// loadStaffTags возвращает теги сотрудников; idStaff = 0 - теги всех сотрудников
+func loadStaffTags(db *sql.DB, idStaff int64) ([]StaffTag, error) {
+	rows, err := db.Query(`
+		SELECT id_staff, tag, added_by, added_at FROM staff_tags
+		WHERE $1 = 0 OR id_staff = $1
+		ORDER BY id_staff, tag
+	`, idStaff)
+	if err != nil {
+		return nil, fmt.Errorf("Staff tags query error: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []StaffTag{}
+	for rows.Next() {
+		var t StaffTag
+		if err := rows.Scan(&t.IDStaff, &t.Tag, &t.AddedBy, &t.AddedAt); err != nil {
+			return nil, fmt.Errorf("Error scanning staff tag: %v", err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// loadStaffNotes возвращает заметки о сотрудниках, новые первыми; idStaff = 0 - заметки обо всех сотрудниках
+func loadStaffNotes(db *sql.DB, idStaff int64) ([]StaffNote, error) {
+	rows, err := db.Query(`
+		SELECT id, id_staff, text, author, created_at FROM staff_notes
+		WHERE $1 = 0 OR id_staff = $1
+		ORDER BY created_at DESC, id DESC
+	`, idStaff)
+	if err != nil {
+		return nil, fmt.Errorf("Staff notes query error: %v", err)
+	}
+	defer rows.Close()
+
+	notes := []StaffNote{}
+	for rows.Next() {
+		var n StaffNote
+		if err := rows.Scan(&n.ID, &n.IDStaff, &n.Text, &n.Author, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("Error scanning staff note: %v", err)
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+// replaceStaffTags заменяет набор тегов сотрудника; время добавления оставшихся тегов сохраняется
+func replaceStaffTags(db *sql.DB, idStaff int64, tags []string, actor string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	lower := make([]string, len(tags))
+	for i, tag := range tags {
+		lower[i] = strings.ToLower(tag)
+	}
+	if _, err := tx.Exec("DELETE FROM staff_tags WHERE id_staff = $1 AND NOT LOWER(tag) = ANY($2)", idStaff, pq.Array(lower)); err != nil {
+		return fmt.Errorf("error removing staff tags: %v", err)
+	}
+	for _, tag := range tags {
+		if err := addStaffTag(tx, idStaff, tag, actor); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// addStaffTag добавляет тег сотруднику; тег, который уже есть с другим регистром, не дублируется
+func addStaffTag(db sqlExecer, idStaff int64, tag, actor string) error {
+	_, err := db.Exec(`
+		INSERT INTO staff_tags (id_staff, tag, added_by)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (SELECT 1 FROM staff_tags WHERE id_staff = $1 AND LOWER(tag) = LOWER($2))
+	`, idStaff, tag, actor)
+	if err != nil {
+		return fmt.Errorf("error adding staff tag %q: %v", tag, err)
+	}
+	return nil
+}
+
+// staffExists проверяет, что сотрудник есть в staff
+func staffExists(db *sql.DB, idStaff int64) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM staff WHERE id_staff = $1)", idStaff).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("Staff query error: %v", err)
+	}
+	return exists, nil
+}
+
+// staffTagsHandler перечисляет (GET), добавляет (POST {"tag"}), заменяет (PUT {"tags"})
+// и снимает (DELETE ?tag=) теги сотрудника
+func staffTagsHandler(w http.ResponseWriter, r *http.Request, idStaff int64) {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	actor := requestActor(r)
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		var req struct {
+			Tag  string   `json:"tag"`
+			Tags []string `json:"tags"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			returnJSONError(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if exists, err := staffExists(pgDB, idStaff); err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if !exists {
+			returnJSONError(w, "Employee not found", http.StatusNotFound)
+			return
+		}
+
+		tags := normalizeTags(req.Tags)
+		if r.Method == http.MethodPost {
+			if tags = normalizeTags([]string{req.Tag}); len(tags) == 0 {
+				returnJSONError(w, "Missing 'tag'", http.StatusBadRequest)
+				return
+			}
+		}
+		for _, tag := range tags {
+			if utf8.RuneCountInString(tag) > maxTagLength {
+				returnJSONError(w, fmt.Sprintf("Tag %q is longer than %d characters", tag, maxTagLength), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if r.Method == http.MethodPut {
+			err = replaceStaffTags(pgDB, idStaff, tags, actor)
+		} else {
+			err = addStaffTag(pgDB, idStaff, tags[0], actor)
+		}
+		if err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("🏷️ Tags of staff %d changed by %s", idStaff, actor)
+	case http.MethodDelete:
+		tag := r.URL.Query().Get("tag")
+		if tag == "" {
+			returnJSONError(w, "Missing 'tag'", http.StatusBadRequest)
+			return
+		}
+		res, err := pgDB.Exec("DELETE FROM staff_tags WHERE id_staff = $1 AND LOWER(tag) = LOWER($2)", idStaff, tag)
+		if err != nil {
+			returnJSONError(w, fmt.Sprintf("Error removing staff tag: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			returnJSONError(w, "Tag not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("🏷️ Tag %q removed from staff %d by %s", tag, idStaff, actor)
+	default:
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tags, err := loadStaffTags(pgDB, idStaff)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	returnJSONSuccess(w, tags, fmt.Sprintf("%d tags", len(tags)))
+}
+
+// staffNotesHandler перечисляет (GET), добавляет (POST {"text"}) и удаляет (DELETE ?id=) заметки о сотруднике
+func staffNotesHandler(w http.ResponseWriter, r *http.Request, idStaff int64) {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		notes, err := loadStaffNotes(pgDB, idStaff)
+		if err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		returnJSONSuccess(w, notes, fmt.Sprintf("%d notes", len(notes)))
+
+	case http.MethodPost:
+		var n StaffNote
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			returnJSONError(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		n.Text = strings.TrimSpace(n.Text)
+		if n.Text == "" {
+			returnJSONError(w, "Missing 'text'", http.StatusBadRequest)
+			return
+		}
+		if exists, err := staffExists(pgDB, idStaff); err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if !exists {
+			returnJSONError(w, "Employee not found", http.StatusNotFound)
+			return
+		}
+		n.IDStaff = idStaff
+		n.Author = requestActor(r)
+
+		err := pgDB.QueryRow(`
+			INSERT INTO staff_notes (id_staff, text, author) VALUES ($1, $2, $3)
+			RETURNING id, created_at
+		`, n.IDStaff, n.Text, n.Author).Scan(&n.ID, &n.CreatedAt)
+		if err != nil {
+			returnJSONError(w, fmt.Sprintf("Error saving staff note: %v", err), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("📝 Note added to staff %d by %s", idStaff, n.Author)
+		returnJSONSuccess(w, n, "Note added")
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			returnJSONError(w, "Missing or invalid 'id'", http.StatusBadRequest)
+			return
+		}
+		res, err := pgDB.Exec("DELETE FROM staff_notes WHERE id = $1 AND id_staff = $2", id, idStaff)
+		if err != nil {
+			returnJSONError(w, fmt.Sprintf("Error removing staff note: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			returnJSONError(w, "Note not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("📝 Note %d of staff %d removed by %s", id, idStaff, requestActor(r))
+		returnJSONSuccess(w, map[string]int64{"id": id}, "Note removed")
+
+	default:
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// tagsHandler возвращает теги сотрудников с числом сотрудников для фильтра поиска
+func tagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pgDB, err := connectPostgresRead()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	tags, err := loadTagCounts(pgDB)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	returnJSONSuccess(w, tags, fmt.Sprintf("%d tags", len(tags)))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// cachedStats счетчики /api/stats, которые меняются только при синхронизации
+type cachedStats struct {
+	TotalRecords int
+	LastUpdate   string
+}
+
+// statsCache кэш счетчиков /api/stats на STATS_CACHE_TTL; сбрасывается после синхронизации
+var statsCache struct {
+	sync.Mutex
+	stats     *cachedStats
+	expiresAt time.Time
+	// primary следующее заполнение читает основную базу: реплика после синхронизации может отставать
+	primary bool
+}
+
+// getCachedStats возвращает счетчики из кэша или читает их из базы, если кэш устарел
+func getCachedStats() (*cachedStats, error) {
+	statsCache.Lock()
+	defer statsCache.Unlock()
+
+	if statsCache.stats != nil && time.Now().Before(statsCache.expiresAt) {
+		return statsCache.stats, nil
+	}
+
+	connect := connectPostgresRead
+	if statsCache.primary {
+		connect = connectPostgres
+	}
+	pgDB, err := connect()
+	if err != nil {
+		return nil, fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+	// Оба счетчика читаются одним запросом
+	var stats cachedStats
+	var lastUpdate sql.NullTime
+	err = pgDB.QueryRow("SELECT COUNT(*), MAX(updated_at) FROM staff_cards").Scan(&stats.TotalRecords, &lastUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting stats: %v", err)
+	}
+	stats.LastUpdate = "Never updated"
+	if lastUpdate.Valid {
+		stats.LastUpdate = displayTime(lastUpdate.Time).Format("2006-01-02 15:04:05")
+	}
+
+	if config.StatsCacheTTL > 0 {
+		statsCache.stats = &stats
+		statsCache.expiresAt = time.Now().Add(config.StatsCacheTTL)
+	}
+	statsCache.primary = false
+	return &stats, nil
+}
+
+// invalidateStatsCache сбрасывает кэш счетчиков, чтобы следующий запрос увидел новые данные
+func invalidateStatsCache() {
+	statsCache.Lock()
+	statsCache.stats = nil
+	statsCache.primary = true
+	statsCache.Unlock()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// lastSyncTime возвращает время последней успешной синхронизации.
+// После перезапуска сервиса время берется из поля updated_at.
+func lastSyncTime(db *sql.DB) (time.Time, error) {
+	if _, lastSuccess, _ := lastSync.snapshot(); !lastSuccess.IsZero() {
+		return lastSuccess, nil
+	}
+
+	// updated_at хранится с часовым поясом, в MySQL - в поясе отображения соединения
+	var updatedAt sql.NullTime
+	if err := db.QueryRow("SELECT MAX(updated_at) FROM staff_cards").Scan(&updatedAt); err != nil {
+		return time.Time{}, err
+	}
+	if !updatedAt.Valid {
+		return time.Time{}, nil
+	}
+	return updatedAt.Time, nil
+}
+
+// statusHandler возвращает состояние сервиса в формате key=value для Zabbix/Nagios
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := "ok"
+	var problems []string
+	totalRecords := -1
+	lastSyncAge := int64(-1)
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		problems = append(problems, "postgres unavailable")
+	} else {
+		if err := pgDB.QueryRow("SELECT COUNT(*) FROM staff_cards").Scan(&totalRecords); err != nil {
+			problems = append(problems, "cannot count records")
+		}
+
+		syncTime, err := lastSyncTime(pgDB)
+		switch {
+		case err != nil:
+			problems = append(problems, "cannot read last sync time")
+		case syncTime.IsZero():
+			problems = append(problems, "never synced")
+		default:
+			lastSyncAge = int64(time.Since(syncTime).Seconds())
+			if time.Since(syncTime) > config.StatusMaxSyncAge {
+				problems = append(problems, "sync is stale")
+			}
+		}
+	}
+
+	if _, _, lastError := lastSync.snapshot(); lastError != "" {
+		problems = append(problems, "last sync failed")
+	}
+
+	if len(problems) > 0 {
+		status = "degraded"
+	}
+
+	// Всегда отвечаем 200, чтобы агенты мониторинга разбирали тело ответа
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "status=%s\n", status)
+	fmt.Fprintf(w, "last_sync_age_seconds=%d\n", lastSyncAge)
+	fmt.Fprintf(w, "total_records=%d\n", totalRecords)
+	fmt.Fprintf(w, "problems=%s\n", strings.Join(problems, ", "))
+	fmt.Fprintf(w, "last_sync_peak_memory_bytes=%d\n", lastSync.peakMemoryBytes())
+
+	// Подготовленные запросы поиска и проверки доступа
+	st := preparedStatements.snapshot()
+	fmt.Fprintf(w, "prepared_statements=%d\n", st.Prepared)
+	fmt.Fprintf(w, "prepared_statement_executions=%d\n", st.Executions)
+	fmt.Fprintf(w, "unprepared_statement_executions=%d\n", st.Unprepared)
+	fmt.Fprintf(w, "statement_prepare_errors=%d\n", st.PrepareErrors)
+
+	// Номера карт, отклоненные проверкой ввода
+	rejected := rejectedCardInputStats()
+	for _, code := range cardInputErrorCodes {
+		fmt.Fprintf(w, "rejected_card_inputs_%s=%d\n", code, rejected[code])
+	}
+
+	// Состояние пулов соединений; пул, который еще не создавался, не выводится
+	pools := []struct {
+		name string
+		pool *dbPool
+	}{{"postgres", &pgPool}, {"postgres_replica", &pgReadPool}, {"firebird", &fbPool}}
+	for _, p := range pools {
+		if s := p.pool.stats(); s != nil {
+			name := p.name
+			fmt.Fprintf(w, "%s_pool_in_use=%d\n", name, s.InUse)
+			fmt.Fprintf(w, "%s_pool_idle=%d\n", name, s.Idle)
+			fmt.Fprintf(w, "%s_pool_wait_count=%d\n", name, s.WaitCount)
+			fmt.Fprintf(w, "%s_pool_wait_seconds=%.3f\n", name, s.WaitSeconds)
+		}
+	}
+}
+
+// readyzHandler отвечает 200, когда PostgreSQL доступен и схема на версии последней встроенной миграции
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "postgres unavailable")
+		return
+	}
+
+	version, dirty, err := currentSchemaVersion(pgDB)
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "cannot read schema version: %v\n", err)
+		return
+	}
+	latest, err := latestSchemaVersion()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "cannot read migrations: %v\n", err)
+		return
+	}
+	if dirty || version != latest {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "schema_version=%d expected=%d dirty=%t\n", version, latest, dirty)
+		return
+	}
+	fmt.Fprintf(w, "ready\nschema_version=%d\n", version)
+}
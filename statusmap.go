@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// parseStatusMapping разбирает STATUS_MAPPING вида "0=active,1=blocked,2=dismissed"
+func parseStatusMapping(s string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		code, label, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(code) == "" {
+			return nil, fmt.Errorf("invalid STATUS_MAPPING entry %q, expected code=label", pair)
+		}
+		mapping[strings.TrimSpace(code)] = strings.TrimSpace(label)
+	}
+	return mapping, nil
+}
+
+// statusMapper переводит коды статуса пакетами и копит неизвестные коды до конца синхронизации
+type statusMapper struct {
+	mapping map[string]string
+	unknown map[string]int
+}
+
+// newStatusMapper разбирает STATUS_MAPPING; без него статусы не меняются
+func newStatusMapper() (*statusMapper, error) {
+	m := &statusMapper{unknown: make(map[string]int)}
+	if config.StatusMapping == "" {
+		return m, nil
+	}
+	mapping, err := parseStatusMapping(config.StatusMapping)
+	if err != nil {
+		return nil, err
+	}
+	m.mapping = mapping
+	return m, nil
+}
+
+// apply заменяет числовые коды статуса из источника на метки из STATUS_MAPPING.
+// Коды без соответствия остаются как есть, чтобы их было видно в данных и логах.
+func (m *statusMapper) apply(cards []StaffCard) {
+	if m.mapping == nil {
+		return
+	}
+	for i := range cards {
+		if cards[i].Status == nil {
+			continue
+		}
+		code := strings.TrimSpace(*cards[i].Status)
+		label, ok := m.mapping[code]
+		if !ok {
+			m.unknown[code]++
+			continue
+		}
+		if label == "" {
+			cards[i].Status = nil
+			continue
+		}
+		cards[i].Status = &label
+	}
+}
+
+// report выводит в лог коды, которых нет в STATUS_MAPPING
+func (m *statusMapper) report() {
+	for code, n := range m.unknown {
+		log.Printf("⚠️ Status code %q is not in STATUS_MAPPING (%d records)", code, n)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+)
+
+// maxPreparedStatements ограничивает число подготовленных запросов: фильтры поиска дают много
+// сочетаний условий, и редкие сочетания выполняются без подготовки
+const maxPreparedStatements = 64
+
+// StatementStats счетчики подготовленных запросов для /api/stats и /status
+type StatementStats struct {
+	Prepared      int   `json:"prepared"`
+	Executions    int64 `json:"executions"`
+	Unprepared    int64 `json:"unprepared"`
+	PrepareErrors int64 `json:"prepare_errors"`
+}
+
+// stmtKey подготовленный запрос привязан к пулу, на котором он создан
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
+// stmtCache подготовленные запросы горячих путей поиска и проверки доступа.
+// Запрос готовится один раз на пуле, database/sql сам подготавливает его на новых соединениях.
+type stmtCache struct {
+	sync.Mutex
+	stmts map[stmtKey]*sql.Stmt
+	stats StatementStats
+}
+
+var preparedStatements = stmtCache{stmts: make(map[stmtKey]*sql.Stmt)}
+
+// get возвращает подготовленный запрос; nil - запрос выполняется без подготовки
+func (c *stmtCache) get(ctx context.Context, db *sql.DB, query string) *sql.Stmt {
+	c.Lock()
+	defer c.Unlock()
+
+	key := stmtKey{db, query}
+	if stmt, ok := c.stmts[key]; ok {
+		c.stats.Executions++
+		return stmt
+	}
+	if len(c.stmts) >= maxPreparedStatements {
+		c.stats.Unprepared++
+		return nil
+	}
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		// Ошибка подготовки не мешает запросу: он выполнится обычным способом и вернет свою ошибку
+		log.Printf("⚠️ Statement prepare failed: %v", err)
+		c.stats.PrepareErrors++
+		c.stats.Unprepared++
+		return nil
+	}
+	c.stmts[key] = stmt
+	c.stats.Executions++
+	return stmt
+}
+
+// query выполняет запрос через подготовленный запрос из кэша
+func (c *stmtCache) query(ctx context.Context, db *sql.DB, query string, args ...interface{}) (*sql.Rows, error) {
+	if stmt := c.get(ctx, db, query); stmt != nil {
+		return stmt.QueryContext(ctx, args...)
+	}
+	return db.QueryContext(ctx, query, args...)
+}
+
+// queryRow выполняет запрос одной строки через подготовленный запрос из кэша
+func (c *stmtCache) queryRow(ctx context.Context, db *sql.DB, query string, args ...interface{}) *sql.Row {
+	if stmt := c.get(ctx, db, query); stmt != nil {
+		return stmt.QueryRowContext(ctx, args...)
+	}
+	return db.QueryRowContext(ctx, query, args...)
+}
+
+// snapshot возвращает текущие счетчики
+func (c *stmtCache) snapshot() StatementStats {
+	c.Lock()
+	defer c.Unlock()
+	s := c.stats
+	s.Prepared = len(c.stmts)
+	return s
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// staffCardColumns список колонок staff_cards в порядке полей scanStaffCard
+const staffCardColumns = `id_staff, identifier, identifier_type, tab_number, site, wiegand_facility, wiegand_number, last_name, first_name, middle_name, status, info,
+	email, phone, ad_account, department_id,
+	(SELECT path FROM department_paths dp WHERE dp.id = staff_cards.department_id) AS department,
+	position, valid_from, valid_until,
+	identifier IN (SELECT identifier FROM blocklist) AS blocklisted,
+	(SELECT COALESCE(json_agg(json_build_object('id', ag.id, 'name', ag.name) ORDER BY ag.name), '[]')::text
+		FROM staff_access_groups sag JOIN access_groups ag ON ag.id = sag.group_id
+		WHERE sag.id_staff = staff_cards.id_staff) AS access_groups,
+	extra_fields::text,
+	` + lastSeenColumn + `,
+	notes, array_to_json(tags)::text, custodian,
+	(SELECT COALESCE(json_agg(st.tag ORDER BY st.tag), '[]')::text
+		FROM staff_tags st WHERE st.id_staff = staff_cards.id_staff) AS staff_tags`
+
+// staffSearchCondition условие поиска по ФИО, номеру карты и учетным данным; параметры - staffSearchArgs.
+// ФИО ищется по свернутому ключу name_key, поэтому "Семенов" находит "Семёнов".
+const staffSearchCondition = `name_key LIKE $2 OR identifier ILIKE $1
+	OR email ILIKE $1 OR ad_account ILIKE $1 OR tab_number ILIKE $1`
+
+// allowedCardCondition условие для карт, которым разрешен проход
+const allowedCardCondition = `COALESCE(status, '') NOT IN ('blocked', 'dismissed')
+	AND (valid_from IS NULL OR valid_from <= NOW())
+	AND (valid_until IS NULL OR valid_until > NOW()) AND ` + notBlocklistedCondition
+
+// departmentCondition условие для карт подразделения $1 и всех вложенных в него
+const departmentCondition = `department_id IN (SELECT id FROM department_paths WHERE $1 = ANY(ancestors))`
+
+// cardFilter набор условий выборки карт с параметрами
+type cardFilter struct {
+	conditions []string
+	args       []interface{}
+}
+
+// add добавляет условие, в котором параметры обозначены как $1, $2...
+func (f *cardFilter) add(condition string, args ...interface{}) {
+	pairs := make([]string, 0, 2*len(args))
+	for i, arg := range args {
+		f.args = append(f.args, arg)
+		pairs = append(pairs, fmt.Sprintf("$%d", i+1), fmt.Sprintf("$%d", len(f.args)))
+	}
+	// Замена за один проход, чтобы новый номер не совпал со следующим заменяемым
+	f.conditions = append(f.conditions, "("+strings.NewReplacer(pairs...).Replace(condition)+")")
+}
+
+// where возвращает условие для queryStaffCards; пустая строка означает все записи
+func (f *cardFilter) where() string {
+	return strings.Join(f.conditions, " AND ")
+}
+
+// cardFilterFromQuery строит фильтр из параметров search, department, position, site, tag, type и extra.*
+func cardFilterFromQuery(q url.Values) (*cardFilter, error) {
+	f := &cardFilter{}
+	// Дерево подразделений и теги сотрудников есть только в схеме PostgreSQL
+	if !targetDialect().extended {
+		for _, name := range []string{"department", "tag"} {
+			if q.Get(name) != "" {
+				return nil, fmt.Errorf("filter %q requires TARGET_DB=postgres", name)
+			}
+		}
+	}
+	if search := q.Get("search"); search != "" {
+		f.add(targetDialect().caseInsensitive(staffSearchCondition), staffSearchArgs(search)...)
+	}
+	if dep := q.Get("department"); dep != "" {
+		id, err := strconv.ParseInt(dep, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid department id %q", dep)
+		}
+		f.add(departmentCondition, id)
+	}
+	if position := q.Get("position"); position != "" {
+		f.add(positionCondition, position)
+	}
+	if site := q.Get("site"); site != "" {
+		f.add(siteCondition, site)
+	}
+	if tag := q.Get("tag"); tag != "" {
+		f.add(staffTagCondition, tag)
+	}
+	if t := q.Get("type"); t != "" {
+		idType, err := normalizeIdentifierType(t)
+		if err != nil {
+			return nil, err
+		}
+		f.add("identifier_type = $1", idType)
+	}
+	if err := addExtraFieldFilters(f, q); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// rowScanner общий интерфейс для *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanStaffCard читает строку, выбранную со списком колонок staffCardColumns
+func scanStaffCard(row rowScanner) (StaffCard, error) {
+	var sc StaffCard
+	var accessGroups, extraFields, tags, staffTags string
+	err := row.Scan(&sc.IDStaff, &sc.Identifier, &sc.IdentifierType, &sc.TabNumber, &sc.Site, &sc.WiegandFacility, &sc.WiegandNumber,
+		&sc.LastName, &sc.FirstName, &sc.MiddleName,
+		&sc.Status, &sc.Info, &sc.Email, &sc.Phone, &sc.ADAccount, &sc.DepartmentID, &sc.Department,
+		&sc.Position, &sc.ValidFrom, &sc.ValidUntil, &sc.Blocklisted, &accessGroups, &extraFields, &sc.LastSeen,
+		&sc.Notes, &tags, &sc.Custodian, &staffTags)
+	if err != nil {
+		return sc, err
+	}
+	if err := json.Unmarshal([]byte(accessGroups), &sc.AccessGroups); err != nil {
+		return sc, err
+	}
+	if err := json.Unmarshal([]byte(tags), &sc.Tags); err != nil {
+		return sc, err
+	}
+	if err := json.Unmarshal([]byte(staffTags), &sc.StaffTags); err != nil {
+		return sc, err
+	}
+	err = json.Unmarshal([]byte(extraFields), &sc.ExtraFields)
+	return sc, err
+}
+
+// queryStaffCards выбирает записи staff_cards по условию where
+func queryStaffCards(db *sql.DB, where string, args ...interface{}) ([]StaffCard, error) {
+	return queryStaffCardsContext(context.Background(), db, where, args...)
+}
+
+// queryStaffCardsContext выбирает записи staff_cards по условию where; запрос прерывается по ctx.
+// Интерактивные выборки идут через подготовленные запросы, чтобы не планировать их заново.
+func queryStaffCardsContext(ctx context.Context, db *sql.DB, where string, args ...interface{}) ([]StaffCard, error) {
+	rows, err := preparedStatements.query(ctx, db, staffCardsQuery(where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("Search error: %v", err)
+	}
+	var results []StaffCard
+	err = scanStaffCardRows(rows, func(sc StaffCard) error {
+		results = append(results, sc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// queryStaffCardsLimit выбирает не больше limit записей staff_cards по условию where. LIMIT передается
+// в запрос: подзапросы подразделений, групп доступа и тегов считаются только для отданных строк.
+func queryStaffCardsLimit(ctx context.Context, db *sql.DB, where string, limit int, args ...interface{}) ([]StaffCard, error) {
+	rows, err := preparedStatements.query(ctx, db, staffCardsQuery(where)+fmt.Sprintf(" LIMIT %d", limit), args...)
+	if err != nil {
+		return nil, fmt.Errorf("Search error: %v", err)
+	}
+	var results []StaffCard
+	err = scanStaffCardRows(rows, func(sc StaffCard) error {
+		results = append(results, sc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// eachStaffCard передает записи staff_cards по условию where в fn по одной, не собирая их в память.
+// Ошибка fn прерывает выборку и возвращается как есть.
+func eachStaffCard(db *sql.DB, where string, fn func(StaffCard) error, args ...interface{}) error {
+	return eachStaffCardContext(context.Background(), db, where, fn, args...)
+}
+
+// eachStaffCardContext то же, что eachStaffCard, но запрос прерывается по ctx
+func eachStaffCardContext(ctx context.Context, db *sql.DB, where string, fn func(StaffCard) error, args ...interface{}) error {
+	rows, err := db.QueryContext(ctx, staffCardsQuery(where), args...)
+	if err != nil {
+		return fmt.Errorf("Search error: %v", err)
+	}
+	return scanStaffCardRows(rows, fn)
+}
+
+// staffCardsQuery строит выборку staff_cards по условию where
+func staffCardsQuery(where string) string {
+	query := "SELECT " + targetDialect().staffCardColumns() + " FROM staff_cards"
+	if where != "" {
+		query += " WHERE " + where
+	}
+	return query + " ORDER BY last_name, first_name, middle_name, identifier"
+}
+
+// scanStaffCardRows передает строки выборки в fn и закрывает rows
+func scanStaffCardRows(rows *sql.Rows, fn func(StaffCard) error) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		sc, err := scanStaffCard(rows)
+		if err != nil {
+			return fmt.Errorf("Error scanning row: %v", err)
+		}
+		if err := fn(sc); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("Error iterating rows: %v", err)
+	}
+	return nil
+}
+
+// maxInsertBatch ограничивает пакет, чтобы не превысить 65535 параметров запроса PostgreSQL
+const maxInsertBatch = 5000
+
+// cardWriter записывает карты в таблицы staff и cards многострочными INSERT пакетами по INSERT_BATCH_SIZE
+type cardWriter struct {
+	tx        *sql.Tx
+	updatedAt interface{}
+	batchSize int
+	pending   []StaffCard
+	written   int
+}
+
+// newCardWriter создает запись в рамках транзакции; updatedAt проставляется всем строкам
+func newCardWriter(tx *sql.Tx, updatedAt interface{}) *cardWriter {
+	batchSize := config.InsertBatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if batchSize > maxInsertBatch {
+		batchSize = maxInsertBatch
+	}
+	return &cardWriter{tx: tx, updatedAt: updatedAt, batchSize: batchSize}
+}
+
+// write добавляет карту в пакет и отправляет пакет, когда он заполнен
+func (cw *cardWriter) write(sc StaffCard) error {
+	cw.pending = append(cw.pending, sc)
+	if len(cw.pending) >= cw.batchSize {
+		return cw.flush()
+	}
+	return nil
+}
+
+// flush отправляет накопленные карты
+func (cw *cardWriter) flush() error {
+	if err := cw.insert(cw.pending); err != nil {
+		return err
+	}
+	cw.pending = cw.pending[:0]
+	return nil
+}
+
+// insert записывает готовый пакет карт без копирования во внутренний буфер;
+// данные сотрудника берутся из первой его карты
+func (cw *cardWriter) insert(cards []StaffCard) error {
+	if len(cards) == 0 {
+		return nil
+	}
+
+	staffRows := make([][]interface{}, 0, len(cards))
+	cardRows := make([][]interface{}, 0, len(cards))
+	for _, sc := range cards {
+		idType := sc.IdentifierType
+		if idType == "" {
+			idType = identifierCard
+		}
+		staffRows = append(staffRows, []interface{}{sc.IDStaff, sc.TabNumber, sc.Site, sc.LastName, sc.FirstName,
+			sc.MiddleName, nameSearchKey(sc), sc.Email, sc.Phone, sc.ADAccount, sc.DepartmentID, sc.Position, cw.updatedAt})
+		cardRows = append(cardRows, []interface{}{sc.IDStaff, sc.Identifier, idType, sc.Status, sc.Info,
+			sc.ValidFrom, sc.ValidUntil, extraFieldsJSON(sc.ExtraFields), cw.updatedAt})
+	}
+
+	err := execBatchInsert(cw.tx, `INSERT INTO staff (id_staff, tab_number, site, last_name, first_name, middle_name,
+		name_key, email, phone, ad_account, department_id, position, updated_at) VALUES `, targetDialect().upsert("id_staff"), staffRows)
+	if err != nil {
+		return fmt.Errorf("error inserting staff: %v", err)
+	}
+	err = execBatchInsert(cw.tx, `INSERT INTO cards (id_staff, identifier, identifier_type, status, info, valid_from,
+		valid_until, extra_fields, updated_at) VALUES `, "", cardRows)
+	if err != nil {
+		return fmt.Errorf("error inserting cards: %v", err)
+	}
+
+	cw.written += len(cards)
+	log.Printf("📤 Inserted %d records...", cw.written)
+	return nil
+}
+
+// execBatchInsert выполняет один INSERT со строками VALUES для всех rows
+func execBatchInsert(tx *sql.Tx, prefix, suffix string, rows [][]interface{}) error {
+	var query strings.Builder
+	query.WriteString(prefix)
+	args := make([]interface{}, 0, len(rows)*len(rows[0]))
+	for i, row := range rows {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString("(")
+		for j, v := range row {
+			if j > 0 {
+				query.WriteString(", ")
+			}
+			args = append(args, v)
+			fmt.Fprintf(&query, "$%d", len(args))
+		}
+		query.WriteString(")")
+	}
+	query.WriteString(suffix)
+
+	_, err := tx.Exec(query.String(), args...)
+	return err
+}
+
+// Происхождение строк staff и cards (колонка source)
+const (
+	recordSourceSync  = "sync"  // загружена из источника и перезаписывается синхронизацией
+	recordSourceLocal = "local" // создана в сервисе; синхронизация ее не удаляет и не перезаписывает
+)
+
+// clearStaffCards удаляет карты и сотрудников из источника перед полной перезагрузкой;
+// локальные записи остаются
+func clearStaffCards(tx *sql.Tx) error {
+	for _, table := range []string{"cards", "staff"} {
+		if _, err := tx.Exec("DELETE FROM " + table + " WHERE source <> '" + recordSourceLocal + "'"); err != nil {
+			return fmt.Errorf("Error clearing %s: %v", table, err)
+		}
+	}
+	return nil
+}
+
+// loadLocalIdentifiers возвращает идентификаторы локальных карт: записи источника с ними не загружаются
+func loadLocalIdentifiers(tx *sql.Tx) (map[string]bool, error) {
+	rows, err := tx.Query("SELECT identifier FROM cards WHERE source = '" + recordSourceLocal + "'")
+	if err != nil {
+		return nil, fmt.Errorf("Error loading local cards: %v", err)
+	}
+	defer rows.Close()
+
+	local := make(map[string]bool)
+	for rows.Next() {
+		var identifier string
+		if err := rows.Scan(&identifier); err != nil {
+			return nil, fmt.Errorf("Error scanning local card: %v", err)
+		}
+		local[identifier] = true
+	}
+	return local, rows.Err()
+}
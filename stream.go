@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// streamFlushEvery через сколько записей поток сбрасывается клиенту, чтобы прокси не обрывал долгий ответ
+const streamFlushEvery = 500
+
+// jsonStream пишет список в ответ API по одной записи: массивом data в обычном конверте
+// {"success":true,...} или построчно в формате NDJSON
+type jsonStream struct {
+	w       io.Writer
+	flusher http.Flusher
+	ndjson  bool
+	count   int
+}
+
+// newJSONStream выставляет заголовки и открывает массив data (для NDJSON пишет только заголовки)
+func newJSONStream(w http.ResponseWriter, ndjson bool) (*jsonStream, error) {
+	s := &jsonStream{w: w, ndjson: ndjson}
+	s.flusher, _ = w.(http.Flusher)
+	if ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		return s, nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err := io.WriteString(w, `{"success":true,"data":[`)
+	return s, err
+}
+
+// write кодирует одну запись и периодически сбрасывает буфер ответа
+func (s *jsonStream) write(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.ndjson {
+		b = append(b, '\n')
+	} else if s.count > 0 {
+		b = append([]byte{','}, b...)
+	}
+	if _, err := s.w.Write(b); err != nil {
+		return err
+	}
+	if s.count++; s.flusher != nil && s.count%streamFlushEvery == 0 {
+		s.flusher.Flush()
+	}
+	return nil
+}
+
+// close закрывает массив и дописывает сообщение; без close клиент получит невалидный JSON,
+// по которому видно, что выборка оборвалась
+func (s *jsonStream) close(message string) error {
+	if s.ndjson {
+		return nil
+	}
+	msg, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(s.w, `],"message":`+string(msg)+"}\n")
+	return err
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// SyncResult структура для результата синхронизации
+type SyncResult struct {
+	RecordsUpdated  int    `json:"records_updated"`
+	LastUpdate      string `json:"last_update"`
+	PeakMemoryBytes uint64 `json:"peak_memory_bytes"`
+
+	// Записи, не загруженные по DUPLICATE_POLICY; список ограничен первыми maxListedSkipped
+	SkippedRecords int           `json:"skipped_records"`
+	Skipped        []SkippedCard `json:"skipped,omitempty"`
+}
+
+// syncStatus хранит состояние последней синхронизации в памяти процесса
+type syncStatus struct {
+	mu          sync.Mutex
+	lastAttempt time.Time
+	lastSuccess time.Time
+	lastError   string
+	failures    int
+	peakMemory  uint64
+}
+
+var lastSync syncStatus
+
+// afterSyncHooks вызываются в фоне после каждой успешной синхронизации
+var afterSyncHooks []func(*SyncResult)
+
+// onSyncSuccess регистрирует обработчик успешной синхронизации
+func onSyncSuccess(hook func(*SyncResult)) {
+	afterSyncHooks = append(afterSyncHooks, hook)
+}
+
+// record сохраняет результат очередной попытки синхронизации
+func (s *syncStatus) record(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastAttempt = time.Now()
+	if err != nil {
+		s.lastError = err.Error()
+		s.failures++
+		return
+	}
+	s.lastSuccess = s.lastAttempt
+	s.lastError = ""
+	s.failures = 0
+}
+
+// consecutiveFailures число неудачных синхронизаций подряд после последней успешной
+func (s *syncStatus) consecutiveFailures() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failures
+}
+
+// recordPeakMemory запоминает пиковый объем кучи последней успешной синхронизации
+func (s *syncStatus) recordPeakMemory(bytes uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peakMemory = bytes
+}
+
+// peakMemoryBytes возвращает пиковый объем кучи последней синхронизации; 0 - синхронизаций еще не было
+func (s *syncStatus) peakMemoryBytes() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peakMemory
+}
+
+// snapshot возвращает копию состояния последней синхронизации
+func (s *syncStatus) snapshot() (lastAttempt, lastSuccess time.Time, lastError string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastAttempt, s.lastSuccess, s.lastError
+}
+
+// SyncHistoryEntry структура для записи журнала синхронизаций
+type SyncHistoryEntry struct {
+	ID         int64     `json:"id"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Success    bool      `json:"success"`
+	Records    int       `json:"records"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// initSyncHistoryTable создает таблицу журнала синхронизаций
+func initSyncHistoryTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS sync_history (
+			id BIGSERIAL PRIMARY KEY,
+			started_at TIMESTAMPTZ NOT NULL,
+			finished_at TIMESTAMPTZ NOT NULL,
+			success BOOLEAN NOT NULL,
+			records INTEGER NOT NULL DEFAULT 0,
+			error TEXT
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating sync_history table: %v", err)
+	}
+	// Журнал прежних версий записан по времени сервиса без пояса
+	return convertToTimestamptz(db, "sync_history", "", "started_at", "finished_at")
+}
+
+// recordSyncHistory записывает результат синхронизации в журнал
+func recordSyncHistory(startedAt time.Time, result *SyncResult, syncErr error) {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("⚠️ Cannot record sync history: %v", err)
+		return
+	}
+
+	records := 0
+	if result != nil {
+		records = result.RecordsUpdated
+	}
+	var errText sql.NullString
+	if syncErr != nil {
+		errText = sql.NullString{String: syncErr.Error(), Valid: true}
+	}
+
+	_, err = pgDB.Exec(`
+		INSERT INTO sync_history (started_at, finished_at, success, records, error)
+		VALUES ($1, $2, $3, $4, $5)
+	`, startedAt, time.Now(), syncErr == nil, records, errText)
+	if err != nil {
+		log.Printf("⚠️ Cannot record sync history: %v", err)
+	}
+}
+
+// syncMu не дает двум синхронизациям выполняться одновременно
+var syncMu sync.Mutex
+
+// syncQueue очередь запросов на синхронизацию; повторные запросы, пока один ожидает, схлопываются
+var syncQueue = make(chan string, 1)
+
+// enqueueSync ставит синхронизацию в очередь; false означает, что она уже ожидает выполнения
+func enqueueSync(reason string) bool {
+	select {
+	case syncQueue <- reason:
+		log.Printf("📬 Sync queued (%s)", reason)
+		return true
+	default:
+		return false
+	}
+}
+
+// startSyncWorker запускает обработчик очереди и расписание синхронизаций
+func startSyncWorker() {
+	go func() {
+		for reason := range syncQueue {
+			log.Printf("🔄 Starting queued sync (%s)...", reason)
+			if _, err := runSync(); err != nil && !errors.Is(err, errSyncLocked) {
+				log.Printf("❌ Queued sync failed: %v", err)
+			}
+		}
+	}()
+
+	if config.SyncInterval > 0 {
+		schedule, err := loadSyncSchedule()
+		if err != nil {
+			log.Printf("❌ Scheduled sync disabled: %v", err)
+			return
+		}
+		log.Printf("⏰ Scheduled sync enabled: %s", schedule.describe())
+		startScheduledSync(schedule)
+	}
+}
+
+// syncLockKey ключ advisory lock PostgreSQL (GET_LOCK в MySQL), общий для всех экземпляров сервиса с одной базой
+const syncLockKey int64 = 0x70657263 // "perc"
+
+// errSyncLocked синхронизацию сейчас выполняет другой экземпляр сервиса
+var errSyncLocked = errors.New("Sync is already running on another instance")
+
+// acquireSyncLock берет сессионную advisory lock на отдельном соединении, не дожидаясь ее освобождения.
+// unlock снимает блокировку и возвращает соединение в пул; при падении процесса PostgreSQL снимет ее сам.
+func acquireSyncLock() (unlock func(), err error) {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return nil, fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+	conn, err := pgDB.Conn(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+	var locked bool
+	if err := conn.QueryRowContext(context.Background(), targetDialect().tryLockQuery(), syncLockKey).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Sync lock error: %v", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, errSyncLocked
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), targetDialect().unlockQuery(), syncLockKey); err != nil {
+			log.Printf("⚠️ Sync lock release failed: %v", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// runSync переносит данные из Firebird в PostgreSQL и запоминает результат.
+// Внутри процесса синхронизации выполняются по очереди, между экземплярами - под advisory lock:
+// если синхронизацию уже выполняет другой экземпляр, возвращается errSyncLocked без записи в журнал.
+func runSync() (*SyncResult, error) {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+
+	unlock, err := acquireSyncLock()
+	if errors.Is(err, errSyncLocked) {
+		log.Printf("⏭️ Sync skipped: already running on another instance")
+		return nil, err
+	}
+
+	startedAt := time.Now()
+	runID := newSyncRunID(startedAt)
+	var result *SyncResult
+	if err == nil {
+		defer unlock()
+		if err = runPreSyncHook(runID, startedAt); err == nil {
+			result, err = syncData()
+		}
+	}
+	lastSync.record(err)
+	if result != nil {
+		lastSync.recordPeakMemory(result.PeakMemoryBytes)
+	}
+	recordSyncHistory(startedAt, result, err)
+	notifySyncResult(result, err)
+	go runPostSyncHook(runID, startedAt, result, err)
+	if err == nil {
+		// Счетчики /api/stats сбрасываются сразу, не дожидаясь истечения STATS_CACHE_TTL
+		invalidateStatsCache()
+		for _, hook := range afterSyncHooks {
+			go hook(result)
+		}
+	}
+	return result, err
+}
+
+// syncData выполняет полную перезагрузку таблицы staff_cards из источника.
+// Карты не собираются в память целиком: они читаются и записываются пакетами внутри транзакции.
+func syncData() (*SyncResult, error) {
+	// Все запросы синхронизации к источнику и PostgreSQL прерываются по SYNC_TIMEOUT
+	ctx, cancel := syncContext()
+	defer cancel()
+
+	// Все данные источника читаются из одного снимка, чтобы справочники и карты были согласованы
+	ctx, endSnapshot, err := beginSourceSnapshot(ctx)
+	if err != nil {
+		log.Printf("❌ Source snapshot failed: %v", err)
+		return nil, fmt.Errorf("Source snapshot error: %v", err)
+	}
+	defer endSnapshot()
+
+	// Справочники, журнал изменений и локальные карты есть только в схеме PostgreSQL
+	extended := targetDialect().extended
+	var departments []Department
+	var accessData *AccessData
+	var readers []Reader
+	var shifts *ShiftData
+	if extended {
+		// Дерево подразделений загружается, если источник его поддерживает
+		departments, err = fetchDepartments(ctx)
+		if err != nil {
+			log.Printf("❌ Departments fetch failed: %v", err)
+			return nil, fmt.Errorf("Departments fetch error: %v", err)
+		}
+
+		accessData, err = fetchAccessGroups(ctx)
+		if err != nil {
+			log.Printf("❌ Access groups fetch failed: %v", err)
+			return nil, fmt.Errorf("Access groups fetch error: %v", err)
+		}
+		readers, err = fetchReaders(ctx)
+		if err != nil {
+			log.Printf("❌ Readers fetch failed: %v", err)
+			return nil, fmt.Errorf("Readers fetch error: %v", err)
+		}
+		shifts, err = fetchShifts(ctx)
+		if err != nil {
+			log.Printf("❌ Shifts fetch failed: %v", err)
+			return nil, fmt.Errorf("Shifts fetch error: %v", err)
+		}
+	}
+
+	// Подключаемся к PostgreSQL
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		return nil, fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+
+	// Инициализируем таблицу
+	log.Println("🔄 Initializing PostgreSQL table...")
+	if err := initPostgresTable(pgDB); err != nil {
+		log.Printf("❌ Table initialization failed: %v", err)
+		return nil, fmt.Errorf("Table initialization error: %v", err)
+	}
+	if extended {
+		if err := initDepartmentsTable(pgDB); err != nil {
+			log.Printf("❌ Departments initialization failed: %v", err)
+			return nil, fmt.Errorf("Departments initialization error: %v", err)
+		}
+		if err := initAccessGroupTables(pgDB); err != nil {
+			log.Printf("❌ Access groups initialization failed: %v", err)
+			return nil, fmt.Errorf("Access groups initialization error: %v", err)
+		}
+		if err := initReadersTable(pgDB); err != nil {
+			log.Printf("❌ Readers initialization failed: %v", err)
+			return nil, fmt.Errorf("Readers initialization error: %v", err)
+		}
+		if err := initShiftTables(pgDB); err != nil {
+			log.Printf("❌ Shifts initialization failed: %v", err)
+			return nil, fmt.Errorf("Shifts initialization error: %v", err)
+		}
+		if err := initReportViews(pgDB); err != nil {
+			log.Printf("❌ Views initialization failed: %v", err)
+			return nil, fmt.Errorf("Views initialization error: %v", err)
+		}
+	}
+
+	// Записываем данные в PostgreSQL
+	log.Println("📤 Writing data to PostgreSQL...")
+	tx, err := pgDB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("❌ Transaction start failed: %v", err)
+		return nil, fmt.Errorf("Transaction error: %v", err)
+	}
+
+	// Откат после успешного Commit ничего не делает
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+			log.Println("🔙 Transaction rolled back due to error")
+		}
+	}()
+
+	if extended {
+		// Запоминаем прежнее состояние для журнала изменений
+		if err := snapshotCardsBeforeSync(tx); err != nil {
+			log.Printf("❌ Error saving previous state: %v", err)
+			return nil, fmt.Errorf("Error saving previous state: %v", err)
+		}
+	}
+
+	if departments != nil {
+		if err := replaceDepartments(tx, departments); err != nil {
+			log.Printf("❌ %v", err)
+			return nil, err
+		}
+	}
+	if accessData != nil {
+		if err := replaceAccessGroups(tx, accessData); err != nil {
+			log.Printf("❌ %v", err)
+			return nil, err
+		}
+	}
+	if readers != nil {
+		if err := replaceReaders(tx, readers); err != nil {
+			log.Printf("❌ %v", err)
+			return nil, err
+		}
+	}
+	if shifts != nil {
+		if err := replaceShifts(tx, shifts); err != nil {
+			log.Printf("❌ %v", err)
+			return nil, err
+		}
+	}
+
+	// Очищаем таблицу перед записью новых данных
+	log.Println("🧹 Clearing existing data...")
+	if err := clearStaffCards(tx); err != nil {
+		log.Printf("❌ %v", err)
+		return nil, err
+	}
+
+	// Обновляем время updated_at для всех записей; строка без пояса толкуется сервером в поясе сессии
+	updateTime := displayTime(time.Now()).Format("2006-01-02 15:04:05")
+
+	uniqueIndex, err := hasUniqueIdentifierIndex(tx)
+	if err != nil {
+		return nil, fmt.Errorf("Index check error: %v", err)
+	}
+
+	// Читаем источник и вставляем данные пакетами
+	written, err := writeSourceCards(ctx, tx, updateTime)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return nil, err
+	}
+	// Пустой источник откатывает транзакцию, чтобы не стереть данные
+	if written.Written == 0 {
+		log.Println("⚠️ No data found in source")
+		return nil, errors.New("No data found in source")
+	}
+	conflicts := written.Conflicts
+	if conflicts > 0 {
+		log.Printf("⚠️ %d records share identifiers with other records, see /api/conflicts", conflicts)
+	}
+	if written.Skipped > 0 {
+		log.Printf("⚠️ Skipped %d records with duplicate identifiers (%s)", written.Skipped, config.DuplicatePolicy)
+	}
+
+	if extended {
+		if err := insertTemporaryCards(tx, updateTime); err != nil {
+			log.Printf("❌ %v", err)
+			return nil, err
+		}
+
+		// Локальные сроки действия имеют приоритет над источником
+		if err := applyExpiryOverrides(tx); err != nil {
+			log.Printf("❌ %v", err)
+			return nil, err
+		}
+		// Пометки карт источник не знает, они возвращаются из card_annotations
+		if err := applyCardAnnotations(tx); err != nil {
+			log.Printf("❌ %v", err)
+			return nil, err
+		}
+
+		if err := updateCardAssignments(tx, updateTime); err != nil {
+			log.Printf("❌ %v", err)
+			return nil, err
+		}
+
+		changes, err := recordCardChanges(tx, updateTime)
+		if err != nil {
+			log.Printf("❌ %v", err)
+			return nil, err
+		}
+		log.Printf("📝 Recorded %d card changes", changes)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("❌ Error committing transaction: %v", err)
+		return nil, fmt.Errorf("Error committing transaction: %v", err)
+	}
+	committed = true
+
+	// Политика дубликатов оставляет не больше одной записи на идентификатор,
+	// поэтому уникальный индекс, которого еще нет, создается после загрузки
+	if extended && !uniqueIndex {
+		if err := initCardIndexes(pgDB); err != nil {
+			log.Printf("⚠️ %v", err)
+		}
+	}
+
+	// Свежая статистика нужна поиску сразу после большой перезагрузки
+	if config.SyncAnalyze {
+		if err := analyzeSyncedTables(pgDB); err != nil {
+			log.Printf("⚠️ %v", err)
+		}
+	}
+
+	log.Printf("✅ Data update completed: %d records transferred at %s (peak heap %d MB)",
+		written.Written, updateTime, written.PeakMemory>>20)
+	return &SyncResult{
+		RecordsUpdated:  written.Written,
+		LastUpdate:      updateTime,
+		PeakMemoryBytes: written.PeakMemory,
+		SkippedRecords:  written.Skipped,
+		Skipped:         written.SkippedCards,
+	}, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Фазы хуков синхронизации
+const (
+	syncHookPre  = "pre"
+	syncHookPost = "post"
+)
+
+// syncHookEvent данные хука: передаются команде в переменных окружения PERCO_SYNC_*, а URL - в теле JSON
+type syncHookEvent struct {
+	RunID           string  `json:"run_id"`
+	Phase           string  `json:"phase"`
+	Status          string  `json:"status,omitempty"`
+	Records         int     `json:"records"`
+	Skipped         int     `json:"skipped"`
+	Error           string  `json:"error,omitempty"`
+	StartedAt       string  `json:"started_at"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// newSyncRunID идентификатор запуска синхронизации по времени начала, общий для обоих хуков
+func newSyncRunID(startedAt time.Time) string {
+	return startedAt.Format("20060102T150405.000000")
+}
+
+// environ переменные окружения для команды хука
+func (e syncHookEvent) environ() []string {
+	return []string{
+		"PERCO_SYNC_RUN_ID=" + e.RunID,
+		"PERCO_SYNC_PHASE=" + e.Phase,
+		"PERCO_SYNC_STATUS=" + e.Status,
+		"PERCO_SYNC_RECORDS=" + strconv.Itoa(e.Records),
+		"PERCO_SYNC_SKIPPED=" + strconv.Itoa(e.Skipped),
+		"PERCO_SYNC_ERROR=" + e.Error,
+		"PERCO_SYNC_STARTED_AT=" + e.StartedAt,
+		"PERCO_SYNC_DURATION_SECONDS=" + strconv.FormatFloat(e.DurationSeconds, 'f', 1, 64),
+	}
+}
+
+// runSyncHook выполняет хук: http(s)-адрес вызывается POST с JSON, остальное запускается командой
+// оболочки. Вывод команды попадает в журнал, ошибкой считается ненулевой код или ответ не 2xx.
+func runSyncHook(hook string, event syncHookEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.SyncHookTimeout)
+	defer cancel()
+
+	if strings.HasPrefix(hook, "http://") || strings.HasPrefix(hook, "https://") {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Sync-Run-Id", event.RunID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return fmt.Errorf("hook returned %s: %s", resp.Status, msg)
+		}
+		return nil
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hook)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hook)
+	}
+	cmd.Env = append(os.Environ(), event.environ()...)
+	// Процессы, запущенные командой в фоне, не должны задерживать синхронизацию после таймаута
+	cmd.WaitDelay = 5 * time.Second
+	out, err := cmd.CombinedOutput()
+	if text := strings.TrimSpace(string(out)); text != "" {
+		log.Printf("🪝 %s-sync hook output: %s", event.Phase, text)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("hook timed out after %s", config.SyncHookTimeout)
+	}
+	return err
+}
+
+// runPreSyncHook выполняет SYNC_PRE_HOOK; ошибка хука отменяет синхронизацию
+func runPreSyncHook(runID string, startedAt time.Time) error {
+	if config.SyncPreHook == "" {
+		return nil
+	}
+	event := syncHookEvent{RunID: runID, Phase: syncHookPre, StartedAt: startedAt.Format(time.RFC3339)}
+	if err := runSyncHook(config.SyncPreHook, event); err != nil {
+		return fmt.Errorf("pre-sync hook failed: %v", err)
+	}
+	return nil
+}
+
+// runPostSyncHook выполняет SYNC_POST_HOOK после любой синхронизации, успешной или нет
+func runPostSyncHook(runID string, startedAt time.Time, result *SyncResult, syncErr error) {
+	if config.SyncPostHook == "" {
+		return
+	}
+	event := syncHookEvent{
+		RunID:           runID,
+		Phase:           syncHookPost,
+		Status:          "success",
+		StartedAt:       startedAt.Format(time.RFC3339),
+		DurationSeconds: time.Since(startedAt).Seconds(),
+	}
+	if result != nil {
+		event.Records = result.RecordsUpdated
+		event.Skipped = result.SkippedRecords
+	}
+	if syncErr != nil {
+		event.Status = "failed"
+		event.Error = syncErr.Error()
+	}
+	if err := runSyncHook(config.SyncPostHook, event); err != nil {
+		log.Printf("⚠️ Post-sync hook failed: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// prefixedObjects таблицы, представления, индексы и последовательности сервиса, к именам которых
+// добавляется TABLE_PREFIX. Новую таблицу или индекс нужно добавить сюда же.
+var prefixedObjects = map[string]bool{}
+
+func init() {
+	for _, name := range []string{
+		// Таблицы и представления
+		"access_group_doors", "access_groups", "blocklist", "blocklist_audit", "card_annotations", "card_assignments", "card_changes",
+		"card_conflicts", "card_expiry_overrides", "card_last_seen", "cards", "department_paths", "departments",
+		"events", "holidays", "identifier_types", "offline_list_state", "passage_state", "readers", "schema_migrations", "staff",
+		"staff_access_groups", "staff_cards", "staff_last_seen", "staff_notes", "staff_photos", "staff_shifts", "staff_tags",
+		"sync_history", "temporary_cards", "work_shifts",
+		// Индексы
+		"card_assignments_identifier_idx", "card_assignments_staff_idx", "cards_extra_fields_idx",
+		"cards_identifier_idx", "cards_identifier_unique_idx", "cards_staff_identifier_idx", "cards_staff_idx",
+		"cards_updated_at_idx", "cards_wiegand_idx", "events_staff_time_idx", "staff_department_idx",
+		"staff_name_idx", "staff_notes_staff_idx", "staff_position_idx", "staff_site_idx", "staff_tags_tag_idx",
+		// Прежняя несекционированная таблица событий, переименовываемая при переходе на секции
+		"events_pkey", "events_unpartitioned", "events_unpartitioned_pkey", "events_unpartitioned_staff_time_idx",
+		// Последовательности, на которые ссылается восстановление из снимка
+		"blocklist_audit_id_seq", "staff_notes_id_seq", "sync_history_id_seq", "temporary_cards_id_seq",
+	} {
+		prefixedObjects[name] = true
+	}
+	for _, idx := range trigramIndexes {
+		prefixedObjects[idx.name] = true
+	}
+	for _, v := range reportViews {
+		prefixedObjects[v.Name] = true
+	}
+}
+
+// identifierPattern имя схемы или префикс: строчные латинские буквы, цифры и _, без кавычек в SQL
+var identifierPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// prefixedName возвращает имя объекта базы с учетом TABLE_PREFIX
+func prefixedName(name string) string {
+	if config.TablePrefix == "" || !prefixedObjects[name] {
+		return name
+	}
+	return config.TablePrefix + name
+}
+
+// prefixTables добавляет TABLE_PREFIX к именам объектов сервиса в тексте запроса. Заменяются слова вне
+// кавычек и комментариев, кроме колонок после точки и псевдонимов после AS, и строки в апострофах,
+// целиком совпадающие с именем объекта, как в to_regclass('events'). Имена в кавычках и `обратных
+// кавычках` не меняются: так их передает golang-migrate, которому префикс задается отдельно.
+func prefixTables(query string) string {
+	if config.TablePrefix == "" {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 64)
+	prevWord := ""
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += i + 4
+			}
+			b.WriteString(query[i:end])
+			i = end
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(query) && query[end] != c {
+				end++
+			}
+			if end < len(query) {
+				end++
+			}
+			literal := query[i:end]
+			if c == '\'' && len(literal) > 2 && prefixedObjects[literal[1:len(literal)-1]] {
+				literal = "'" + config.TablePrefix + literal[1:]
+			}
+			b.WriteString(literal)
+			i = end
+		case isIdentByte(c):
+			end := i
+			for end < len(query) && isIdentByte(query[end]) {
+				end++
+			}
+			word := query[i:end]
+			afterDot := i > 0 && query[i-1] == '.'
+			if prefixedObjects[word] && !afterDot && !strings.EqualFold(prevWord, "AS") {
+				b.WriteString(config.TablePrefix)
+			}
+			b.WriteString(word)
+			prevWord = word
+			i = end
+		default:
+			if c == '.' || c == '(' || c == ',' || c == ';' {
+				prevWord = ""
+			}
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// postgresDriver драйвер database/sql для PostgreSQL: с TABLE_PREFIX запросы проходят через prefixTables
+func postgresDriver() string {
+	if config.TablePrefix != "" {
+		return "postgres-prefixed"
+	}
+	return "postgres"
+}
+
+// withSearchPath добавляет к DSN PostgreSQL search_path=POSTGRES_SCHEMA; lib/pq передает неизвестные
+// параметры серверу при подключении. Для схемы public DSN не меняется.
+func withSearchPath(dsn string) string {
+	if config.PostgresSchema == "public" {
+		return dsn
+	}
+	return withDSNParam(dsn, "search_path", config.PostgresSchema)
+}
+
+// withDSNParam добавляет параметр к DSN PostgreSQL в виде URL или строки key=value
+func withDSNParam(dsn, key, value string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return dsn
+		}
+		q := u.Query()
+		q.Set(key, value)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " " + key + "=" + value
+}
+
+// ensurePostgresSchema создает схему POSTGRES_SCHEMA, если ее нет. Существование проверяется заранее:
+// CREATE SCHEMA IF NOT EXISTS требует права CREATE на базу даже для готовой схемы.
+func ensurePostgresSchema(db *sql.DB) error {
+	if config.PostgresSchema == "public" {
+		return nil
+	}
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM pg_namespace WHERE nspname = $1)", config.PostgresSchema).Scan(&exists); err != nil {
+		return fmt.Errorf("error checking schema %s: %v", config.PostgresSchema, err)
+	}
+	if exists {
+		return nil
+	}
+	if _, err := db.Exec("CREATE SCHEMA " + config.PostgresSchema); err != nil {
+		return fmt.Errorf("error creating schema %s: %v", config.PostgresSchema, err)
+	}
+	log.Printf("✅ PostgreSQL schema %s created", config.PostgresSchema)
+	return nil
+}
+
+// isIdentByte часть идентификатора SQL без кавычек
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
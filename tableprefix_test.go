@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPrefixTables(t *testing.T) {
+	defer func(prefix string) { config.TablePrefix = prefix }(config.TablePrefix)
+	config.TablePrefix = "p_"
+
+	tests := []struct {
+		name, query, want string
+	}{
+		{"table", "SELECT * FROM cards WHERE id_staff = $1", "SELECT * FROM p_cards WHERE id_staff = $1"},
+		{"longer name", "SELECT * FROM staff_cards", "SELECT * FROM p_staff_cards"},
+		{"unknown name", "SELECT * FROM cards_archive", "SELECT * FROM cards_archive"},
+		{"line comment", "SELECT 1 FROM staff -- cards\nJOIN cards USING (id_staff)", "SELECT 1 FROM p_staff -- cards\nJOIN p_cards USING (id_staff)"},
+		{"block comment", "SELECT /* FROM cards */ 1 FROM staff", "SELECT /* FROM cards */ 1 FROM p_staff"},
+		{"unterminated block comment", "SELECT 1 /* cards", "SELECT 1 /* cards"},
+		{"string value", "SELECT * FROM cards WHERE info = 'cards in staff'", "SELECT * FROM p_cards WHERE info = 'cards in staff'"},
+		{"regclass literal", "SELECT to_regclass('events') IS NOT NULL", "SELECT to_regclass('p_events') IS NOT NULL"},
+		{"setval literal", "SELECT setval('sync_history_id_seq', 1)", "SELECT setval('p_sync_history_id_seq', 1)"},
+		{"double quoted", `SELECT * FROM "cards"`, `SELECT * FROM "cards"`},
+		{"backquoted", "SELECT * FROM `cards`", "SELECT * FROM `cards`"},
+		{"AS alias", "SELECT COUNT(*) AS staff FROM staff", "SELECT COUNT(*) AS staff FROM p_staff"},
+		{"lower as alias", "SELECT c.id_staff FROM staff_cards as cards", "SELECT c.id_staff FROM p_staff_cards as cards"},
+		{"alias reset by comma", "SELECT 1 AS x, staff.id_staff FROM staff", "SELECT 1 AS x, p_staff.id_staff FROM p_staff"},
+		{"dotted columns", "SELECT c.staff, staff_cards.cards FROM staff_cards c", "SELECT c.staff, p_staff_cards.cards FROM p_staff_cards c"},
+		{"dollar block", "DO $$ BEGIN IF to_regclass('events_unpartitioned') IS NULL THEN ALTER TABLE events RENAME TO events_unpartitioned; END IF; END $$",
+			"DO $$ BEGIN IF to_regclass('p_events_unpartitioned') IS NULL THEN ALTER TABLE p_events RENAME TO p_events_unpartitioned; END IF; END $$"},
+		{"placeholder", "UPDATE cards SET status = $2 WHERE identifier = $1", "UPDATE p_cards SET status = $2 WHERE identifier = $1"},
+		{"index", "CREATE INDEX IF NOT EXISTS cards_staff_idx ON cards (id_staff)", "CREATE INDEX IF NOT EXISTS p_cards_staff_idx ON p_cards (id_staff)"},
+	}
+	for _, tt := range tests {
+		if got := prefixTables(tt.query); got != tt.want {
+			t.Errorf("%s: prefixTables(%q)\n got %q\nwant %q", tt.name, tt.query, got, tt.want)
+		}
+	}
+
+	config.TablePrefix = ""
+	if got := prefixTables("SELECT * FROM cards"); got != "SELECT * FROM cards" {
+		t.Errorf("prefixTables without prefix = %q", got)
+	}
+}
+
+// createdObjectPattern имя таблицы, представления или индекса в CREATE; у имен из fmt (%s) вместо имени
+// совпадает IF из IF NOT EXISTS
+var createdObjectPattern = regexp.MustCompile(`(?i)CREATE\s+(?:OR\s+REPLACE\s+)?(?:UNIQUE\s+)?(?:TABLE|INDEX|VIEW)\s+` +
+	`(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?([a-z_][a-z0-9_]*)`)
+
+func TestPrefixedObjectsCoverCreateStatements(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, _ := filepath.Glob("migrations/*.sql")
+	mysqlMigrations, _ := filepath.Glob("migrations/mysql/*.sql")
+	files = append(append(files, migrations...), mysqlMigrations...)
+
+	created := 0
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range createdObjectPattern.FindAllStringSubmatch(string(data), -1) {
+			if strings.EqualFold(m[1], "IF") {
+				continue
+			}
+			created++
+			if !prefixedObjects[m[1]] {
+				t.Errorf("%s creates %s, which is missing from prefixedObjects", file, m[1])
+			}
+		}
+	}
+	if created < len(prefixedObjects)/2 {
+		t.Fatalf("found only %d CREATE statements, the pattern no longer matches the sources", created)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// temporaryCardStatus статус временных карт в staff_cards
+const temporaryCardStatus = "temporary"
+
+// TemporaryCard локальный временный пропуск, который не приходит из PERCo и переживает синхронизацию.
+// В staff_cards такие карты хранятся с source='local' и отрицательным id_staff, чтобы не пересекаться с сотрудниками.
+type TemporaryCard struct {
+	ID             int64     `json:"id"`
+	Identifier     string    `json:"identifier"`
+	IdentifierType string    `json:"identifier_type"`
+	LastName       string    `json:"last_name"`
+	FirstName      string    `json:"first_name"`
+	MiddleName     string    `json:"middle_name"`
+	Company        string    `json:"company"`
+	Site           string    `json:"site"`
+	ValidFrom      time.Time `json:"valid_from"`
+	ValidTo        time.Time `json:"valid_to"`
+	CreatedBy      string    `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// initTemporaryCardsTable создает таблицу временных пропусков
+func initTemporaryCardsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS temporary_cards (
+			id BIGSERIAL PRIMARY KEY,
+			identifier TEXT NOT NULL UNIQUE,
+			identifier_type VARCHAR(20) NOT NULL DEFAULT 'card',
+			last_name VARCHAR(255) NOT NULL DEFAULT '',
+			first_name VARCHAR(255) NOT NULL DEFAULT '',
+			middle_name VARCHAR(255) NOT NULL DEFAULT '',
+			company VARCHAR(255) NOT NULL DEFAULT '',
+			site VARCHAR(100) NOT NULL DEFAULT '',
+			valid_from TIMESTAMP NOT NULL,
+			valid_to TIMESTAMP NOT NULL,
+			created_by VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating temporary_cards table: %v", err)
+	}
+	return nil
+}
+
+// temporaryCardsInsert копирует действующие временные пропуска в staff и cards как локальные записи;
+// $2 ограничивает вставку одним пропуском, NULL - все пропуска
+const temporaryCardsInsert = `
+	WITH t AS (
+		SELECT * FROM temporary_cards t
+		WHERE t.valid_to > NOW() AND ($2::BIGINT IS NULL OR t.id = $2)
+			AND NOT EXISTS (SELECT 1 FROM cards c WHERE c.identifier = t.identifier)
+	), s AS (
+		INSERT INTO staff (id_staff, last_name, first_name, middle_name, name_key, site, updated_at, source)
+		SELECT -t.id, NULLIF(t.last_name, ''), NULLIF(t.first_name, ''), NULLIF(t.middle_name, ''),
+			translate(lower(concat_ws(' ', NULLIF(t.last_name, ''), NULLIF(t.first_name, ''), NULLIF(t.middle_name, ''))), 'ё', 'е'),
+			NULLIF(t.site, ''), $1::timestamptz, '` + recordSourceLocal + `'
+		FROM t
+		ON CONFLICT (id_staff) DO NOTHING
+	)
+	INSERT INTO cards (id_staff, identifier, identifier_type, status, info, valid_from, valid_until, updated_at, source)
+	SELECT -t.id, t.identifier, t.identifier_type, '` + temporaryCardStatus + `', NULLIF(t.company, ''),
+		t.valid_from, t.valid_to, $1::timestamptz, '` + recordSourceLocal + `'
+	FROM t`
+
+// expiredLocalCardsDelete удаляет из cards и staff локальные записи истекших и отозванных пропусков
+var expiredLocalCardsDelete = []string{
+	`DELETE FROM cards c WHERE c.source = '` + recordSourceLocal + `'
+		AND NOT EXISTS (SELECT 1 FROM temporary_cards t WHERE -t.id = c.id_staff AND t.valid_to > NOW())`,
+	`DELETE FROM staff s WHERE s.source = '` + recordSourceLocal + `'
+		AND NOT EXISTS (SELECT 1 FROM cards c WHERE c.id_staff = s.id_staff)`,
+}
+
+// insertTemporaryCards сверяет локальные карты с temporary_cards после перезагрузки карт из источника:
+// синхронизация их не трогает, поэтому истекшие пропуска удаляются здесь, а недостающие добавляются.
+// Пропуск, идентификатор которого уже занят картой из PERCo, не добавляется.
+func insertTemporaryCards(tx *sql.Tx, updateTime string) error {
+	for _, stmt := range expiredLocalCardsDelete {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("Error removing expired temporary cards: %v", err)
+		}
+	}
+	result, err := tx.Exec(temporaryCardsInsert, updateTime, nil)
+	if err != nil {
+		return fmt.Errorf("Error inserting temporary cards: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("🎫 Kept %d temporary cards", n)
+	}
+	return nil
+}
+
+// loadTemporaryCards возвращает временные пропуска; expired=false оставляет только действующие
+func loadTemporaryCards(db *sql.DB, expired bool) ([]TemporaryCard, error) {
+	query := `
+		SELECT id, identifier, identifier_type, last_name, first_name, middle_name, company, site,
+			valid_from, valid_to, created_by, created_at
+		FROM temporary_cards`
+	if !expired {
+		query += " WHERE valid_to > NOW()"
+	}
+	query += " ORDER BY valid_to, identifier"
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("Temporary cards query error: %v", err)
+	}
+	defer rows.Close()
+
+	cards := []TemporaryCard{}
+	for rows.Next() {
+		var c TemporaryCard
+		err := rows.Scan(&c.ID, &c.Identifier, &c.IdentifierType, &c.LastName, &c.FirstName, &c.MiddleName,
+			&c.Company, &c.Site, &c.ValidFrom, &c.ValidTo, &c.CreatedBy, &c.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("Error scanning temporary card: %v", err)
+		}
+		cards = append(cards, c)
+	}
+	return cards, rows.Err()
+}
+
+// createTemporaryCard сохраняет пропуск и сразу добавляет его в staff_cards
+func createTemporaryCard(db *sql.DB, c *TemporaryCard) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Истекший пропуск с тем же идентификатором можно выдать заново
+	_, err = tx.Exec(`DELETE FROM cards WHERE identifier = $1 AND source = '`+recordSourceLocal+`' AND valid_until <= NOW()`, c.Identifier)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM temporary_cards WHERE identifier = $1 AND valid_to <= NOW()", c.Identifier); err != nil {
+		return err
+	}
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM staff_cards WHERE identifier = $1)", c.Identifier).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("identifier %s is already in use", c.Identifier)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO temporary_cards (identifier, identifier_type, last_name, first_name, middle_name, company, site,
+			valid_from, valid_to, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`, c.Identifier, c.IdentifierType, c.LastName, c.FirstName, c.MiddleName, c.Company, c.Site,
+		c.ValidFrom, c.ValidTo, c.CreatedBy).Scan(&c.ID, &c.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error saving temporary card: %v", err)
+	}
+	if _, err := tx.Exec(temporaryCardsInsert, displayTime(time.Now()), c.ID); err != nil {
+		return fmt.Errorf("error adding temporary card: %v", err)
+	}
+	return tx.Commit()
+}
+
+// deleteTemporaryCard удаляет пропуск и его строку в staff_cards
+func deleteTemporaryCard(db *sql.DB, identifier string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow("DELETE FROM temporary_cards WHERE identifier = $1 RETURNING id", identifier).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error removing temporary card: %v", err)
+	}
+	for _, table := range []string{"cards", "staff"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE id_staff = $1", -id); err != nil {
+			return false, fmt.Errorf("error removing temporary card: %v", err)
+		}
+	}
+	return true, tx.Commit()
+}
+
+// temporaryCardsHandler выдает (POST), отзывает (DELETE ?identifier=) и перечисляет (GET) временные пропуска
+func temporaryCardsHandler(w http.ResponseWriter, r *http.Request) {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		cards, err := loadTemporaryCards(pgDB, r.URL.Query().Get("expired") == "true")
+		if err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		returnJSONSuccess(w, cards, fmt.Sprintf("%d temporary cards", len(cards)))
+
+	case http.MethodPost:
+		var c TemporaryCard
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			returnJSONError(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		c.Identifier = strings.TrimSpace(c.Identifier)
+		if c.Identifier == "" {
+			returnJSONError(w, "Missing 'identifier'", http.StatusBadRequest)
+			return
+		}
+		if c.IdentifierType == "" {
+			c.IdentifierType = identifierCard
+		}
+		if c.IdentifierType, err = normalizeIdentifierType(c.IdentifierType); err != nil {
+			returnJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if c.Site == "" {
+			c.Site = config.Site
+		}
+		if c.ValidFrom.IsZero() {
+			c.ValidFrom = time.Now()
+		}
+		if !c.ValidTo.After(c.ValidFrom) {
+			returnJSONError(w, "'valid_to' must be later than 'valid_from'", http.StatusBadRequest)
+			return
+		}
+		c.CreatedBy = requestActor(r)
+
+		if err := createTemporaryCard(pgDB, &c); err != nil {
+			returnJSONError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("🎫 Temporary card %s issued by %s until %s", c.Identifier, c.CreatedBy, c.ValidTo.Format("02.01.2006 15:04"))
+		returnJSONSuccess(w, c, "Temporary card issued")
+
+	case http.MethodDelete:
+		identifier := r.URL.Query().Get("identifier")
+		if identifier == "" {
+			returnJSONError(w, "Missing 'identifier'", http.StatusBadRequest)
+			return
+		}
+		found, err := deleteTemporaryCard(pgDB, identifier)
+		if err != nil {
+			returnJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			returnJSONError(w, "Temporary card not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("🎫 Temporary card %s revoked by %s", identifier, requestActor(r))
+		returnJSONSuccess(w, map[string]string{"identifier": identifier}, "Temporary card revoked")
+
+	default:
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
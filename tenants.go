@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Tenant организация, которую обслуживает экземпляр: свой файл конфигурации (источник PERCo, схема
+// PostgreSQL) и свои ключи API. Каждый арендатор работает в отдельном процессе сервиса, поэтому данные,
+// пулы соединений и фоновые задачи организаций не пересекаются.
+type Tenant struct {
+	Name    string            `json:"name"`
+	Config  string            `json:"config"`
+	Env     map[string]string `json:"env"`
+	APIKeys []string          `json:"api_keys"`
+
+	port  int
+	proxy *httputil.ReverseProxy
+}
+
+// tenantRestartDelay пауза перед перезапуском упавшего процесса арендатора
+const tenantRestartDelay = 5 * time.Second
+
+// loadTenants читает TENANTS_FILE: JSON-массив арендаторов. Имя арендатора становится схемой
+// PostgreSQL по умолчанию и частью пути /t/{name}/, поэтому допускает только строчные буквы, цифры и _.
+func loadTenants(path string) ([]*Tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %v", err)
+	}
+
+	var list []*Tenant
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid tenants file: %v", err)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("tenants file %s has no tenants", path)
+	}
+	if config.TenantsBasePort < 1 || config.TenantsBasePort+len(list)-1 > 65535 {
+		return nil, fmt.Errorf("TENANTS_BASE_PORT=%d leaves no ports for %d tenants", config.TenantsBasePort, len(list))
+	}
+
+	names := make(map[string]bool)
+	keys := make(map[string]string)
+	for i, t := range list {
+		if !identifierPattern.MatchString(t.Name) {
+			return nil, fmt.Errorf("tenant %q: name must contain lowercase latin letters, digits and _", t.Name)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("tenant %q is listed twice", t.Name)
+		}
+		names[t.Name] = true
+		if t.Config != "" {
+			if _, err := os.Stat(t.Config); err != nil {
+				return nil, fmt.Errorf("tenant %q: %v", t.Name, err)
+			}
+		}
+		for _, key := range t.APIKeys {
+			if key == "" {
+				return nil, fmt.Errorf("tenant %q: empty API key", t.Name)
+			}
+			if other, ok := keys[key]; ok {
+				return nil, fmt.Errorf("tenant %q: API key is already used by tenant %q", t.Name, other)
+			}
+			keys[key] = t.Name
+		}
+		t.port = config.TenantsBasePort + i
+	}
+	return list, nil
+}
+
+// tenantBasePath путь, под которым арендатор опубликован на шлюзе и в своем процессе
+func tenantBasePath(t *Tenant) string {
+	return config.BasePath + "/t/" + t.Name
+}
+
+// tenantEnviron окружение процесса арендатора: окружение шлюза, затем файл конфигурации и env арендатора,
+// затем параметры, которые задает шлюз. Переменные файла записываются в окружение явно, потому что
+// godotenv в дочернем процессе не перезаписал бы значения, унаследованные от шлюза.
+func tenantEnviron(t *Tenant) ([]string, error) {
+	values := make(map[string]string)
+	if t.Config != "" {
+		fileValues, err := godotenv.Read(t.Config)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load config file %s: %v", t.Config, err)
+		}
+		for k, v := range fileValues {
+			values[k] = v
+		}
+	}
+	for k, v := range t.Env {
+		values[k] = v
+	}
+
+	// Схема по умолчанию - имя арендатора; служебный порт шлюза арендатору не передается
+	if values["POSTGRES_SCHEMA"] == "" {
+		values["POSTGRES_SCHEMA"] = t.Name
+	}
+	if _, ok := values["ADMIN_ADDR"]; !ok {
+		values["ADMIN_ADDR"] = ""
+	}
+	values["PORT"] = strconv.Itoa(t.port)
+	values["BASE_PATH"] = tenantBasePath(t)
+	values["TRUSTED_PROXIES"] = "127.0.0.1"
+	values["TENANT_NAME"] = t.Name
+	values["TENANTS_FILE"] = ""
+
+	var env []string
+	for _, kv := range os.Environ() {
+		k, _, _ := strings.Cut(kv, "=")
+		if _, set := values[k]; !set {
+			env = append(env, kv)
+		}
+	}
+	for k, v := range values {
+		env = append(env, k+"="+v)
+	}
+	return env, nil
+}
+
+// tenantLogWriter переписывает журнал процесса арендатора в журнал шлюза с именем арендатора в начале строки
+func tenantLogWriter(name string) io.WriteCloser {
+	r, w := io.Pipe()
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			fmt.Fprintf(os.Stderr, "[%s] %s\n", name, scanner.Text())
+		}
+		r.CloseWithError(scanner.Err())
+	}()
+	return w
+}
+
+// tenantProcesses запущенные процессы арендаторов; останавливаются вместе со шлюзом
+var tenantProcesses struct {
+	sync.Mutex
+	procs    map[string]*os.Process
+	stopping bool
+}
+
+// runTenantProcess запускает процесс сервиса для арендатора и перезапускает его после завершения
+func runTenantProcess(t *Tenant) {
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatalf("❌ Cannot find executable for tenant processes: %v", err)
+	}
+	logs := tenantLogWriter(t.Name)
+	for {
+		env, err := tenantEnviron(t)
+		if err != nil {
+			log.Printf("❌ Tenant %s: %v", t.Name, err)
+			time.Sleep(tenantRestartDelay)
+			continue
+		}
+		cmd := exec.Command(exe)
+		cmd.Env = env
+		cmd.Stdout = logs
+		cmd.Stderr = logs
+
+		tenantProcesses.Lock()
+		if tenantProcesses.stopping {
+			tenantProcesses.Unlock()
+			return
+		}
+		err = cmd.Start()
+		if err == nil {
+			tenantProcesses.procs[t.Name] = cmd.Process
+		}
+		tenantProcesses.Unlock()
+
+		if err == nil {
+			log.Printf("🏢 Tenant %s started on 127.0.0.1:%d (pid %d)", t.Name, t.port, cmd.Process.Pid)
+			err = cmd.Wait()
+		}
+
+		tenantProcesses.Lock()
+		delete(tenantProcesses.procs, t.Name)
+		stopping := tenantProcesses.stopping
+		tenantProcesses.Unlock()
+		if stopping {
+			return
+		}
+		log.Printf("⚠️ Tenant %s exited: %v; restarting in %s", t.Name, err, tenantRestartDelay)
+		time.Sleep(tenantRestartDelay)
+	}
+}
+
+// stopTenantProcesses завершает процессы арендаторов и не дает их перезапустить
+func stopTenantProcesses() {
+	tenantProcesses.Lock()
+	defer tenantProcesses.Unlock()
+	tenantProcesses.stopping = true
+	for name, p := range tenantProcesses.procs {
+		if err := p.Signal(os.Interrupt); err != nil {
+			p.Kill()
+		}
+		log.Printf("🏢 Tenant %s stopped", name)
+	}
+}
+
+// signalTenantProcesses отправляет сигнал всем запущенным процессам арендаторов
+func signalTenantProcesses(sig os.Signal) {
+	tenantProcesses.Lock()
+	defer tenantProcesses.Unlock()
+	for name, p := range tenantProcesses.procs {
+		if err := p.Signal(sig); err != nil {
+			log.Printf("⚠️ Cannot send %s to tenant %s: %v", sig, name, err)
+		}
+	}
+}
+
+// requestAPIKey ключ API из X-API-Key, Authorization: Bearer или параметра api_key для ссылок из браузера
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// tenantGatewayHandler направляет запрос процессу арендатора. Арендатор выбирается путем /t/{name}/,
+// заголовком X-Tenant, параметром tenant или ключом API; ключ арендатора с ключами обязателен
+// и должен принадлежать выбранному арендатору.
+func tenantGatewayHandler(tenants []*Tenant) http.Handler {
+	byName := make(map[string]*Tenant)
+	byKey := make(map[string]*Tenant)
+	for _, t := range tenants {
+		byName[t.Name] = t
+		for _, key := range t.APIKeys {
+			byKey[key] = t
+		}
+		target := &url.URL{Scheme: "http", Host: "127.0.0.1:" + strconv.Itoa(t.port)}
+		name := t.Name
+		t.proxy = &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.SetURL(target)
+				pr.SetXForwarded()
+				pr.Out.Header.Del("X-API-Key")
+			},
+			// Потоковые ответы (события, выгрузки) передаются клиенту без буферизации
+			FlushInterval: -1,
+			ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+				log.Printf("⚠️ Tenant %s is unavailable: %v", name, err)
+				returnJSONError(w, fmt.Sprintf("Tenant %s is unavailable", name), http.StatusBadGateway)
+			},
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			healthzHandler(w, r)
+			return
+		}
+
+		// Выбор арендатора: путь, затем заголовок и параметр
+		var tenant *Tenant
+		rest := r.URL.Path
+		if after, ok := strings.CutPrefix(r.URL.Path, "/t/"); ok {
+			name, tail, _ := strings.Cut(after, "/")
+			if tenant = byName[name]; tenant == nil {
+				returnJSONError(w, fmt.Sprintf("Unknown tenant %q", name), http.StatusNotFound)
+				return
+			}
+			rest = "/" + tail
+		} else if name := firstNonEmpty(r.Header.Get("X-Tenant"), r.URL.Query().Get("tenant")); name != "" {
+			if tenant = byName[name]; tenant == nil {
+				returnJSONError(w, fmt.Sprintf("Unknown tenant %q", name), http.StatusNotFound)
+				return
+			}
+		}
+
+		key := requestAPIKey(r)
+		keyTenant := byKey[key]
+		switch {
+		case key != "" && keyTenant == nil:
+			returnJSONError(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		case tenant == nil && keyTenant == nil:
+			returnJSONError(w, "Tenant is not selected: use /t/{tenant}/, the X-Tenant header or ?tenant=", http.StatusBadRequest)
+			return
+		case tenant == nil:
+			tenant = keyTenant
+		case keyTenant != nil && keyTenant != tenant:
+			returnJSONError(w, fmt.Sprintf("API key does not belong to tenant %s", tenant.Name), http.StatusForbidden)
+			return
+		case keyTenant == nil && len(tenant.APIKeys) > 0:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+tenant.Name+`"`)
+			returnJSONError(w, fmt.Sprintf("API key required for tenant %s", tenant.Name), http.StatusUnauthorized)
+			return
+		}
+
+		// Процесс арендатора опубликован под своим BASE_PATH
+		out := r.Clone(r.Context())
+		out.URL.Path = tenantBasePath(tenant) + rest
+		out.URL.RawPath = ""
+		tenant.proxy.ServeHTTP(w, out)
+	})
+}
+
+// firstNonEmpty возвращает первую непустую строку
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// runTenantGateway запускает процессы арендаторов из TENANTS_FILE и шлюз на PORT, который
+// проверяет ключи API и направляет запросы процессам. Процессы слушают только 127.0.0.1.
+func runTenantGateway() error {
+	tenants, err := loadTenants(config.TenantsFile)
+	if err != nil {
+		return err
+	}
+
+	tenantProcesses.procs = make(map[string]*os.Process)
+	for _, t := range tenants {
+		go runTenantProcess(t)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-stop
+		log.Printf("🛑 %s received, stopping tenants", sig)
+		stopTenantProcesses()
+		os.Exit(0)
+	}()
+
+	// Сигнал синхронизации шлюзу передается всем арендаторам
+	if len(syncSignals) > 0 {
+		forward := make(chan os.Signal, 1)
+		signal.Notify(forward, syncSignals...)
+		go func() {
+			for sig := range forward {
+				signalTenantProcesses(sig)
+			}
+		}()
+	}
+
+	log.Printf("🏢 Multi-tenant gateway on port %s%s for %d tenants", config.Port, config.BasePath, len(tenants))
+	for _, t := range tenants {
+		log.Printf("   %s/t/%s/ - tenant %s (%d API keys)", config.BasePath, t.Name, t.Name, len(t.APIKeys))
+	}
+	err = newHTTPServer(":"+config.Port, withBasePath(tenantGatewayHandler(tenants))).ListenAndServe()
+	stopTenantProcesses()
+	return err
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// withTimeout добавляет к ctx ограничение времени d; 0 означает без ограничения
+func withTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, d)
+}
+
+// searchContext контекст интерактивного поиска: прерывается по SEARCH_TIMEOUT и при уходе клиента
+func searchContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return withTimeout(r.Context(), config.SearchTimeout)
+}
+
+// syncContext контекст синхронизации с ограничением SYNC_TIMEOUT
+func syncContext() (context.Context, context.CancelFunc) {
+	return withTimeout(context.Background(), config.SyncTimeout)
+}
+
+// returnSearchError отвечает 504, если поиск не уложился в SEARCH_TIMEOUT, иначе 500
+func returnSearchError(w http.ResponseWriter, ctx context.Context, err error) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		returnJSONError(w, "Search timed out", http.StatusGatewayTimeout)
+		return
+	}
+	returnJSONError(w, err.Error(), http.StatusInternalServerError)
+}
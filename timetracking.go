@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// AttendanceDay структура для первого входа и последнего выхода сотрудника за день
+type AttendanceDay struct {
+	IDStaff        int64      `json:"id_staff"`
+	FullName       string     `json:"full_name"`
+	Date           string     `json:"date"`
+	FirstIn        *time.Time `json:"first_in"`
+	LastOut        *time.Time `json:"last_out"`
+	IdempotencyKey string     `json:"idempotency_key"`
+}
+
+var timeTrackingClient = &http.Client{Timeout: 30 * time.Second}
+
+// loadAttendanceDays агрегирует события по сотрудникам и дням начиная с from
+func loadAttendanceDays(from time.Time) ([]AttendanceDay, error) {
+	pgDB, err := connectPostgres()
+	if err != nil {
+		return nil, fmt.Errorf("PostgreSQL connection error: %v", err)
+	}
+
+	rows, err := pgDB.Query(`
+		SELECT e.id_staff,
+			COALESCE((SELECT concat_ws(' ', sc.last_name, sc.first_name, sc.middle_name)
+				FROM staff_cards sc WHERE sc.id_staff = e.id_staff LIMIT 1), ''),
+			to_char(e.event_time::date, 'YYYY-MM-DD'),
+			MIN(e.event_time) FILTER (WHERE e.direction = $2),
+			MAX(e.event_time) FILTER (WHERE e.direction = $3)
+		FROM events e
+		WHERE e.event_time >= $1
+		GROUP BY e.id_staff, e.event_time::date
+		ORDER BY 3, 1
+	`, from, directionIn, directionOut)
+	if err != nil {
+		return nil, fmt.Errorf("attendance query error: %v", err)
+	}
+	defer rows.Close()
+
+	var days []AttendanceDay
+	for rows.Next() {
+		var d AttendanceDay
+		if err := rows.Scan(&d.IDStaff, &d.FullName, &d.Date, &d.FirstIn, &d.LastOut); err != nil {
+			return nil, fmt.Errorf("error scanning attendance row: %v", err)
+		}
+		// Ключ меняется только при изменении данных, поэтому повторная отправка безопасна
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%s|%v|%v", d.IDStaff, d.Date, d.FirstIn, d.LastOut)))
+		d.IdempotencyKey = hex.EncodeToString(sum[:16])
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// postAttendanceBatch отправляет порцию записей с повторами при ошибках
+func postAttendanceBatch(batch []AttendanceDay) error {
+	body, err := json.Marshal(map[string]interface{}{"records": batch})
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	batchKey := hex.EncodeToString(sum[:16])
+
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		err = sendAttendance(body, batchKey)
+		if err == nil || attempt >= config.TimeTrackingRetries {
+			return err
+		}
+		log.Printf("⚠️ Time tracking push attempt %d failed: %v, retrying in %s", attempt, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// sendAttendance выполняет один HTTP запрос к системе учета рабочего времени
+func sendAttendance(body []byte, key string) error {
+	req, err := http.NewRequest(http.MethodPost, config.TimeTrackingURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	if config.TimeTrackingToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.TimeTrackingToken)
+	}
+
+	resp, err := timeTrackingClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("time tracking API returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// pushAttendance отправляет первый вход и последний выход за последние дни
+func pushAttendance() error {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).
+		AddDate(0, 0, -config.TimeTrackingDays)
+
+	days, err := loadAttendanceDays(from)
+	if err != nil {
+		return err
+	}
+
+	const batchSize = 100
+	for i := 0; i < len(days); i += batchSize {
+		end := i + batchSize
+		if end > len(days) {
+			end = len(days)
+		}
+		if err := postAttendanceBatch(days[i:end]); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("⏱️ Pushed %d attendance records to time tracking system", len(days))
+	return nil
+}
+
+// startTimeTrackingPush запускает периодическую отправку данных учета времени
+func startTimeTrackingPush() {
+	log.Printf("⏱️ Time tracking push enabled: every %s to %s", config.TimeTrackingInterval, config.TimeTrackingURL)
+	startPeriodicJob("Time tracking push", config.TimeTrackingInterval, pushAttendance)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	// База часовых поясов встроена в бинарный файл: на Windows системной базы IANA нет
+	_ "time/tzdata"
+)
+
+// timeZones загруженные часовые пояса по имени; время каждого события не должно читать базу поясов заново
+var timeZones sync.Map
+
+// loadTimeZone возвращает пояс по имени IANA; пустое имя - пояс сервиса
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	if loc, ok := timeZones.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	timeZones.Store(name, loc)
+	return loc, nil
+}
+
+// sourceLocation пояс, в котором PERCo хранит время в Firebird (SOURCE_TIMEZONE)
+func sourceLocation() *time.Location {
+	loc, err := loadTimeZone(config.SourceTimezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// displayLocation пояс, в котором время показывается в API, интерфейсе и отчетах (DISPLAY_TIMEZONE)
+func displayLocation() *time.Location {
+	loc, err := loadTimeZone(config.DisplayTimezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// displayTime переводит момент времени в пояс отображения
+func displayTime(t time.Time) time.Time {
+	return t.In(displayLocation())
+}
+
+// sourceTime толкует время Firebird без пояса как местное время SOURCE_TIMEZONE и переводит его в пояс
+// отображения: колонки TIMESTAMP без пояса в PostgreSQL хранят время сессии, то есть DISPLAY_TIMEZONE
+func sourceTime(t time.Time) time.Time {
+	return displayTime(time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), sourceLocation()))
+}
+
+// withTimeZone добавляет к DSN PostgreSQL timezone=DISPLAY_TIMEZONE: сервер возвращает время с поясом
+// уже в поясе отображения, а строки времени без пояса толкует в нем же. Без DISPLAY_TIMEZONE DSN не меняется.
+func withTimeZone(dsn string) string {
+	if config.DisplayTimezone == "" {
+		return dsn
+	}
+	return withDSNParam(dsn, "timezone", config.DisplayTimezone)
+}
+
+// legacyTimeExpr выражение перевода колонки TIMESTAMP в TIMESTAMPTZ: прежние значения записаны
+// по местному времени пояса zone; пустой zone - пояс сессии
+func legacyTimeExpr(column, zone string) string {
+	if zone == "" {
+		return column + "::timestamptz"
+	}
+	return fmt.Sprintf("%s AT TIME ZONE %s", column, quoteLiteral(zone))
+}
+
+// timestampColumnsWithoutZone возвращает колонки таблицы, которые еще хранятся как TIMESTAMP без пояса
+func timestampColumnsWithoutZone(db *sql.DB, table string, columns ...string) ([]string, error) {
+	var legacy []string
+	for _, column := range columns {
+		var dataType string
+		err := db.QueryRow(`
+			SELECT data_type FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2
+		`, prefixedName(table), column).Scan(&dataType)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error checking %s.%s type: %v", table, column, err)
+		}
+		if dataType == "timestamp without time zone" {
+			legacy = append(legacy, column)
+		}
+	}
+	return legacy, nil
+}
+
+// convertToTimestamptz переводит колонки таблицы прежних версий из TIMESTAMP в TIMESTAMPTZ, толкуя
+// значения в поясе zone. Представления отчетов над таблицей удаляются и создаются заново в initReportViews.
+func convertToTimestamptz(db *sql.DB, table, zone string, columns ...string) error {
+	legacy, err := timestampColumnsWithoutZone(db, table, columns...)
+	if err != nil || len(legacy) == 0 {
+		return err
+	}
+	if err := dropReportViews(db); err != nil {
+		return err
+	}
+	for _, column := range legacy {
+		_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE TIMESTAMPTZ USING %s",
+			table, column, legacyTimeExpr(column, zone)))
+		if err != nil {
+			return fmt.Errorf("error converting %s.%s to timestamptz: %v", table, column, err)
+		}
+		log.Printf("🕒 Converted %s.%s to timestamptz", table, column)
+	}
+	return nil
+}
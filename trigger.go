@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTriggerSkew допустимое расхождение времени подписанного запроса
+const maxTriggerSkew = 5 * time.Minute
+
+// usedTriggerSignatures подписи принятых запросов до истечения их окна maxTriggerSkew:
+// перехваченный запрос нельзя отправить повторно, пока его метка времени еще действительна
+var usedTriggerSignatures = struct {
+	sync.Mutex
+	expires map[string]time.Time
+}{expires: make(map[string]time.Time)}
+
+// useTriggerSignature отмечает подпись запроса с меткой ts использованной; false - она уже была
+func useTriggerSignature(signature string, ts time.Time) bool {
+	key := strings.ToLower(strings.TrimPrefix(signature, "sha256="))
+	now := time.Now()
+
+	usedTriggerSignatures.Lock()
+	defer usedTriggerSignatures.Unlock()
+	for k, expires := range usedTriggerSignatures.expires {
+		if now.After(expires) {
+			delete(usedTriggerSignatures.expires, k)
+		}
+	}
+	if _, used := usedTriggerSignatures.expires[key]; used {
+		return false
+	}
+	usedTriggerSignatures.expires[key] = ts.Add(maxTriggerSkew)
+	return true
+}
+
+// verifyTriggerSignature проверяет подпись HMAC-SHA256 от "timestamp.body"
+func verifyTriggerSignature(timestamp, signature string, body []byte) bool {
+	mac := hmac.New(sha256.New, []byte(config.SyncTriggerSecret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(got, expected)
+}
+
+// startSyncSignalHandler ставит синхронизацию в очередь по сигналу ОС, как /api/sync/trigger.
+// Удобно на серверах, где запросы к localhost запрещены, а systemctl kill доступен.
+func startSyncSignalHandler() {
+	if len(syncSignals) == 0 {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syncSignals...)
+	go func() {
+		for sig := range signals {
+			if !enqueueSync("signal " + sig.String()) {
+				log.Printf("⏭️ Sync already pending, signal %s ignored", sig)
+			}
+		}
+	}()
+}
+
+// syncTriggerHandler ставит синхронизацию в очередь по подписанному запросу внешней системы.
+// Заголовки: X-Timestamp (unix-время в секундах) и X-Signature (sha256=<hex HMAC>).
+func syncTriggerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if config.SyncTriggerSecret == "" {
+		returnJSONError(w, "Sync trigger is disabled", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if err != nil {
+		returnJSONError(w, "Cannot read request body", http.StatusBadRequest)
+		return
+	}
+
+	timestamp := r.Header.Get("X-Timestamp")
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		returnJSONError(w, "Missing or invalid X-Timestamp header", http.StatusUnauthorized)
+		return
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > maxTriggerSkew || skew < -maxTriggerSkew {
+		returnJSONError(w, "Request timestamp is too old", http.StatusUnauthorized)
+		return
+	}
+	if !verifyTriggerSignature(timestamp, r.Header.Get("X-Signature"), body) {
+		log.Printf("⚠️ Rejected sync trigger with invalid signature from %s", r.RemoteAddr)
+		returnJSONError(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if !useTriggerSignature(r.Header.Get("X-Signature"), time.Unix(ts, 0)) {
+		log.Printf("⚠️ Rejected replayed sync trigger from %s", r.RemoteAddr)
+		returnJSONError(w, "Request signature was already used", http.StatusUnauthorized)
+		return
+	}
+
+	queued := enqueueSync("trigger from " + r.RemoteAddr)
+	message := "Sync queued"
+	if !queued {
+		message = "Sync already pending"
+	}
+	returnJSONSuccess(w, map[string]interface{}{"queued": queued}, message)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncTriggerRejectsReplay(t *testing.T) {
+	defer func(secret string) { config.SyncTriggerSecret = secret }(config.SyncTriggerSecret)
+	config.SyncTriggerSecret = "trigger-secret"
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	body := `{"reason":"replay test"}`
+	mac := hmac.New(sha256.New, []byte(config.SyncTriggerSecret))
+	mac.Write([]byte(timestamp + "." + body))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	send := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/sync/trigger", strings.NewReader(body))
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", signature)
+		rec := httptest.NewRecorder()
+		syncTriggerHandler(rec, req)
+		return rec.Code
+	}
+
+	if code := send("sha256=" + signature); code != http.StatusOK {
+		t.Fatalf("first request: status %d; want 200", code)
+	}
+	for _, replay := range []string{"sha256=" + signature, signature, "sha256=" + strings.ToUpper(signature)} {
+		if code := send(replay); code != http.StatusUnauthorized {
+			t.Errorf("replay with signature %q: status %d; want 401", replay, code)
+		}
+	}
+}
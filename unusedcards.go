@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unusedCardCondition действующие карты без проверок через /api/verify и без событий прохода
+// сотрудника с $1 и позже; данные берутся из card_last_seen и staff_last_seen
+const unusedCardCondition = `NOT EXISTS (SELECT 1 FROM card_last_seen cls
+		WHERE cls.identifier = staff_cards.identifier AND cls.last_seen >= $1)
+	AND NOT EXISTS (SELECT 1 FROM staff_last_seen sls
+		WHERE sls.id_staff = staff_cards.id_staff AND sls.last_seen >= $1)`
+
+// unusedCardsRows формирует строки таблицы отчета о неиспользуемых картах
+func unusedCardsRows(cards []StaffCard) [][]string {
+	rows := [][]string{{"ID сотрудника", "ФИО", "Подразделение", "Должность", "Идентификатор", "Тип", "Статус",
+		"Последнее использование"}}
+	for _, sc := range cards {
+		lastSeen := "никогда"
+		if sc.LastSeen != nil {
+			lastSeen = formatDateTime(sc.LastSeen)
+		}
+		rows = append(rows, []string{strconv.FormatInt(sc.IDStaff, 10), staffFullName(sc), strValue(sc.Department),
+			strValue(sc.Position), sc.Identifier, sc.IdentifierType, strValue(sc.Status), lastSeen})
+	}
+	return rows
+}
+
+// unusedCardsReportHandler отчет для аудита пропусков: действующие карты, не использованные days дней
+// (по умолчанию 90). Принимает фильтры /api/cards; format=xlsx отдает книгу Excel.
+func unusedCardsReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	days := 90
+	if s := q.Get("days"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			returnJSONError(w, "'days' must be a positive number", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	filter, err := cardFilterFromQuery(q)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	filter.add(allowedCardCondition)
+	filter.add(unusedCardCondition, since)
+
+	pgDB, err := connectPostgresRead()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := searchContext(r)
+	defer cancel()
+	cards := []StaffCard{}
+	err = eachStaffCardContext(ctx, pgDB, filter.where(), func(sc StaffCard) error {
+		cards = append(cards, sc)
+		return nil
+	}, filter.args...)
+	if err != nil {
+		log.Printf("❌ Unused cards report failed: %v", err)
+		returnSearchError(w, ctx, err)
+		return
+	}
+
+	if q.Get("format") == "xlsx" {
+		w.Header().Set("Content-Type", exportContentTypes["xlsx"])
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="unused_cards_%dd_%s.xlsx"`,
+			days, time.Now().Format("20060102")))
+		if err := writeRowsXLSX(w, "Неиспользуемые карты", unusedCardsRows(cards)); err != nil {
+			log.Printf("❌ Unused cards export failed: %v", err)
+		}
+		return
+	}
+
+	returnJSONSuccess(w, cards, fmt.Sprintf("%d cards unused for %d days", len(cards), days))
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configParseErrors значения, которые не удалось разобрать при загрузке конфигурации
+var configParseErrors []string
+
+// postgresSSLModes допустимые значения POSTGRES_SSLMODE
+var postgresSSLModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// validatePort проверяет, что значение - номер TCP-порта
+func validatePort(key, value string) string {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Sprintf("%s=%q is not a valid port", key, value)
+	}
+	return ""
+}
+
+// validateConfig проверяет конфигурацию целиком и возвращает все найденные проблемы одной ошибкой,
+// чтобы сервис не запускался с настройками, которые позже приведут к непонятным ошибкам драйверов
+func validateConfig() error {
+	problems := append([]string(nil), configParseErrors...)
+	add := func(problem string) {
+		if problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	addErr := func(err error) {
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	// Флаги задаются строкой "true"/"false"; другое значение молча выключило бы функцию
+	for _, s := range configSettings {
+		if s.Default != "true" && s.Default != "false" {
+			continue
+		}
+		if v := os.Getenv(s.Key); v != "" && v != "true" && v != "false" {
+			add(fmt.Sprintf("%s=%q must be true or false", s.Key, v))
+		}
+	}
+
+	add(validatePort("PORT", config.Port))
+	if config.AdminAddr != "" {
+		if _, port, err := net.SplitHostPort(config.AdminAddr); err != nil {
+			add(fmt.Sprintf("ADMIN_ADDR=%q must be host:port, e.g. 127.0.0.1:9090", config.AdminAddr))
+		} else {
+			add(validatePort("ADMIN_ADDR", port))
+		}
+	}
+	switch config.TargetDB {
+	case "postgres":
+		add(validatePort("POSTGRES_PORT", config.PostgresPort))
+		if !postgresSSLModes[config.PostgresSSLMode] {
+			add(fmt.Sprintf("POSTGRES_SSLMODE=%q is not valid, use disable, allow, prefer, require, verify-ca or verify-full",
+				config.PostgresSSLMode))
+		}
+		if config.PostgresDB == "" {
+			add("POSTGRES_DB is empty")
+		}
+		if !identifierPattern.MatchString(config.PostgresSchema) {
+			add(fmt.Sprintf("POSTGRES_SCHEMA=%q is not valid: use lowercase latin letters, digits and _", config.PostgresSchema))
+		}
+	case "mysql":
+		if config.PostgresSchema != "public" {
+			add("POSTGRES_SCHEMA requires TARGET_DB=postgres: the MySQL database is set in MYSQL_DSN")
+		}
+		if config.MySQLDSN == "" {
+			add("MYSQL_DSN is empty: set it when TARGET_DB=mysql")
+		} else {
+			_, err := mysqlConnString(config.MySQLDSN)
+			addErr(err)
+		}
+		// Секции событий, состояние проходов, стоп-лист с журналом, фото и резервные копии есть только в схеме PostgreSQL
+		for _, f := range []struct {
+			name    string
+			enabled bool
+		}{
+			{"EVENTS_ENABLED", config.EventsEnabled},
+			{"DEMO_MODE", config.DemoMode},
+			{"ANTIPASSBACK_WINDOW", config.AntipassbackWindow > 0},
+			{"BACKUP_ENABLED", config.BackupEnabled},
+			{"OFFLINE_LIST_SECRET", config.OfflineListSecret != ""},
+			{"PHOTOS_QUERY", config.PhotosQuery != ""},
+			{"PHONEBOOK_FILE", config.PhonebookFile != ""},
+			{"DISMISSAL_AUTO_BLOCK", config.DismissalDateField != "" && config.DismissalAutoBlock},
+		} {
+			if f.enabled {
+				add(f.name + " requires TARGET_DB=postgres")
+			}
+		}
+	default:
+		add(fmt.Sprintf("TARGET_DB=%q is not valid, use postgres or mysql", config.TargetDB))
+	}
+	if config.TablePrefix != "" && !identifierPattern.MatchString(config.TablePrefix) {
+		add(fmt.Sprintf("TABLE_PREFIX=%q is not valid: use lowercase latin letters, digits and _", config.TablePrefix))
+	}
+	for _, tz := range []struct {
+		key, value string
+	}{{"SOURCE_TIMEZONE", config.SourceTimezone}, {"DISPLAY_TIMEZONE", config.DisplayTimezone}} {
+		if _, err := loadTimeZone(tz.value); err != nil {
+			add(fmt.Sprintf("%s=%q is not a valid time zone, e.g. Europe/Moscow", tz.key, tz.value))
+		}
+	}
+
+	_, err := newSourceConnector()
+	addErr(err)
+	if config.SourceType == "firebird" || config.EventsEnabled {
+		if strings.TrimSpace(config.FirebirdDB) == "" {
+			add("FIREBIRD_DB is empty: set the path to the PERCo database")
+		}
+		add(validatePort("FIREBIRD_PORT", config.FirebirdPort))
+		_, err := validateFirebirdCharset(config.FirebirdCharset)
+		addErr(err)
+		_, err = newTextDecoder()
+		addErr(err)
+	}
+	if config.DemoMode && config.DemoStaffCount < 1 {
+		add("DEMO_STAFF_COUNT must be positive")
+	}
+	if config.SourceType == "percoweb" && config.PercoWebPageSize < 1 {
+		add("PERCOWEB_PAGE_SIZE must be positive")
+	}
+	addErr(validateDuplicatePolicy(config.DuplicatePolicy))
+	if config.DismissalDateField != "" {
+		addErr(validateExtraFieldKey("DISMISSAL_DATE_FIELD", config.DismissalDateField))
+	} else if config.DismissalAutoBlock {
+		add("DISMISSAL_AUTO_BLOCK requires DISMISSAL_DATE_FIELD")
+	}
+	if config.BirthDateField != "" {
+		addErr(validateExtraFieldKey("BIRTH_DATE_FIELD", config.BirthDateField))
+	}
+
+	if config.InsertBatchSize < 1 {
+		add("INSERT_BATCH_SIZE must be positive")
+	}
+	for _, e := range strings.Split(config.HTTPCompression, ",") {
+		if e = strings.TrimSpace(e); e != "" && e != "gzip" && e != "zstd" {
+			add(fmt.Sprintf("HTTP_COMPRESSION: unsupported encoding %q, use gzip or zstd", e))
+		}
+	}
+
+	_, err = parseTrustedProxies(config.TrustedProxies)
+	addErr(err)
+	timeouts := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"HTTP_READ_HEADER_TIMEOUT", config.HTTPReadHeaderTimeout}, {"HTTP_READ_TIMEOUT", config.HTTPReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", config.HTTPWriteTimeout}, {"HTTP_IDLE_TIMEOUT", config.HTTPIdleTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value < 0 {
+			add(fmt.Sprintf("%s must not be negative", t.key))
+		}
+	}
+	if config.HTTPMaxHeaderBytes < 1 {
+		add("HTTP_MAX_HEADER_BYTES must be positive")
+	}
+	if config.HTTPMaxBodyBytes < 0 {
+		add("HTTP_MAX_BODY_BYTES must not be negative")
+	}
+
+	// Расписания
+	if config.SyncInterval > 0 {
+		_, err := loadSyncSchedule()
+		addErr(err)
+	}
+	if config.DeliveryURL != "" {
+		_, err := parseClock(config.DeliveryTime)
+		addErr(prefixError("DELIVERY_TIME", err))
+		_, err = deliveryShareDir(config.DeliveryURL)
+		addErr(err)
+		if target, err := url.Parse(config.DeliveryURL); err == nil && target.Scheme == "sftp" {
+			_, err := deliveryHostKey()
+			addErr(err)
+		}
+	}
+	// Периодические задачи проверяются, только если включены
+	for _, job := range []struct {
+		key      string
+		enabled  bool
+		interval time.Duration
+	}{
+		{"BACKUP_INTERVAL", config.BackupEnabled, config.BackupInterval},
+		{"SHEETS_INTERVAL", config.SheetsEnabled, config.SheetsInterval},
+		{"EVENTS_INTERVAL", config.EventsEnabled, config.EventsInterval},
+		{"MQTT_INTERVAL", config.MQTTEnabled, config.MQTTInterval},
+		{"PHONEBOOK_INTERVAL", config.PhonebookFile != "", config.PhonebookInterval},
+		{"TIMETRACKING_INTERVAL", config.EventsEnabled && config.TimeTrackingURL != "", config.TimeTrackingInterval},
+	} {
+		if job.enabled && job.interval <= 0 {
+			add(job.key + " must be positive")
+		}
+	}
+	if (config.SyncPreHook != "" || config.SyncPostHook != "") && config.SyncHookTimeout <= 0 {
+		add("SYNC_HOOK_TIMEOUT must be positive")
+	}
+	if config.OfflineListSecret != "" {
+		if config.OfflineListMaxAge <= 0 {
+			add("OFFLINE_LIST_MAX_AGE must be positive")
+		}
+		if config.OfflineListRefresh < 0 {
+			add("OFFLINE_LIST_REFRESH must not be negative")
+		}
+	}
+	_, err = loadWorkRules()
+	addErr(prefixError("WORK_*", err))
+	if config.TelegramBotToken != "" && config.TelegramChatID == "" {
+		add("TELEGRAM_BOT_TOKEN requires TELEGRAM_CHAT_ID")
+	}
+	if config.SMTPAddr != "" {
+		if _, _, err := net.SplitHostPort(config.SMTPAddr); err != nil {
+			add(fmt.Sprintf("SMTP_ADDR=%q must be host:port, e.g. mail.example.com:587", config.SMTPAddr))
+		}
+		if config.SMTPFrom == "" || strings.TrimSpace(config.SMTPTo) == "" {
+			add("SMTP_ADDR requires SMTP_FROM and SMTP_TO")
+		}
+	}
+	if config.AlertSyncFailures < 0 {
+		add("ALERT_SYNC_FAILURES must not be negative")
+	}
+	if config.AlertDataMaxAge < 0 {
+		add("ALERT_DATA_MAX_AGE must not be negative")
+	}
+	if config.AlertVerifyErrorRate < 0 || config.AlertVerifyErrorRate > 100 {
+		add("ALERT_VERIFY_ERROR_RATE must be a percentage from 0 to 100")
+	}
+	if config.AlertVerifyMinRequests < 1 {
+		add("ALERT_VERIFY_MIN_REQUESTS must be positive")
+	}
+	if config.AlertCheckInterval <= 0 {
+		add("ALERT_CHECK_INTERVAL must be positive")
+	}
+	if config.AlertCooldown < 0 {
+		add("ALERT_COOLDOWN must not be negative")
+	}
+	if alertsEnabled() && config.WebhookURL == "" && config.TelegramBotToken == "" && config.SMTPAddr == "" {
+		add("ALERT_* rules require a notification channel: WEBHOOK_URL, TELEGRAM_BOT_TOKEN or SMTP_ADDR")
+	}
+	if config.EventsRetentionMonths < 0 {
+		add("EVENTS_RETENTION_MONTHS must not be negative")
+	}
+	if config.EventsDedupWindow < 0 {
+		add("EVENTS_DEDUP_WINDOW must not be negative")
+	}
+	if config.OccupancyMaxAge < 0 {
+		add("OCCUPANCY_MAX_AGE must not be negative")
+	}
+	_, err = parseZoneNames(config.ZoneNames)
+	addErr(err)
+	if config.ClickHouseURL != "" {
+		if !config.EventsEnabled {
+			add("CLICKHOUSE_URL requires EVENTS_ENABLED=true")
+		}
+		if u, err := url.Parse(config.ClickHouseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("CLICKHOUSE_URL must be an http(s) URL, e.g. http://clickhouse:8123")
+		}
+		if !clickHouseTablePattern.MatchString(config.ClickHouseTable) {
+			add(fmt.Sprintf("CLICKHOUSE_TABLE=%q is not a valid table name", config.ClickHouseTable))
+		}
+		if config.ClickHouseInterval <= 0 {
+			add("CLICKHOUSE_INTERVAL must be positive")
+		}
+		if config.ClickHouseBatchSize < 1 {
+			add("CLICKHOUSE_BATCH_SIZE must be positive")
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+}
+
+// prefixError добавляет к ошибке имя настройки
+func prefixError(key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s: %v", key, err)
+}
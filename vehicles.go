@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// plateLetters кириллические буквы российских номеров и их латинские двойники
+var plateLetters = strings.NewReplacer(
+	"А", "A", "В", "B", "Е", "E", "К", "K", "М", "M", "Н", "H",
+	"О", "O", "Р", "P", "С", "C", "Т", "T", "У", "Y", "Х", "X",
+)
+
+// normalizePlate приводит госномер к единому виду: верхний регистр, латиница, без пробелов и дефисов.
+// Камеры на шлагбауме и оператор PERCo могут записать один номер по-разному.
+func normalizePlate(plate string) string {
+	plate = strings.ToUpper(plate)
+	plate = strings.NewReplacer(" ", "", "-", "", "\t", "").Replace(plate)
+	return plateLetters.Replace(plate)
+}
+
+// fetchVehiclePlates читает госномера запросом VEHICLES_QUERY.
+// Запрос должен вернуть LAST_NAME, FIRST_NAME, MIDDLE_NAME, ID_STAFF и номер.
+func fetchVehiclePlates(ctx context.Context, fbDB fbQuerier, emit func(StaffCard) error) error {
+	rows, err := fbDB.QueryContext(ctx, config.VehiclesQuery)
+	if err != nil {
+		return fmt.Errorf("Firebird vehicles query error: %v", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var lastName, firstName, middleName, plate sql.NullString
+		sc := StaffCard{IdentifierType: identifierPlate}
+		if err := rows.Scan(&lastName, &firstName, &middleName, &sc.IDStaff, &plate); err != nil {
+			return fmt.Errorf("Error scanning vehicle: %v", err)
+		}
+		sc.Identifier = normalizePlate(plate.String)
+		if sc.Identifier == "" {
+			continue
+		}
+		if lastName.Valid {
+			sc.LastName = &lastName.String
+		}
+		if firstName.Valid {
+			sc.FirstName = &firstName.String
+		}
+		if middleName.Valid {
+			sc.MiddleName = &middleName.String
+		}
+		if err := emit(sc); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("Error iterating vehicles: %v", err)
+	}
+
+	log.Printf("🚗 Fetched %d vehicle plates from Firebird", count)
+	return nil
+}
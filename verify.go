@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// VerifyResult ответ контроллеру на запрос о допуске
+type VerifyResult struct {
+	Allowed        bool       `json:"allowed"`
+	Reason         string     `json:"reason"`
+	Identifier     string     `json:"identifier"`
+	IdentifierType string     `json:"identifier_type"`
+	DoorID         *int64     `json:"door_id,omitempty"`
+	DoorName       string     `json:"door_name,omitempty"`
+	Card           *StaffCard `json:"card,omitempty"`
+	dataFreshness
+}
+
+// verifyRequests счетчики запросов /api/verify и /api/verify/full для метрик и правила оповещения;
+// ошибкой считается только сбой проверки, а не отказ в доступе. Время последнего запроса
+// всего и по дверям публикуется в MQTT.
+var verifyRequests = struct {
+	sync.Mutex
+	total, errors int64
+	last          time.Time
+	doors         map[int64]time.Time
+}{doors: make(map[int64]time.Time)}
+
+// recordVerifyRequest учитывает запрос проверки допуска у двери door (nil - дверь не передана)
+func recordVerifyRequest(door *int64, failed bool) {
+	verifyRequests.Lock()
+	defer verifyRequests.Unlock()
+	verifyRequests.total++
+	if failed {
+		verifyRequests.errors++
+	}
+	verifyRequests.last = time.Now()
+	if door != nil {
+		verifyRequests.doors[*door] = verifyRequests.last
+	}
+}
+
+// lastVerifyTimes возвращает время последнего запроса проверки допуска и последнего запроса у двери
+func lastVerifyTimes() (time.Time, map[int64]time.Time) {
+	verifyRequests.Lock()
+	defer verifyRequests.Unlock()
+	doors := make(map[int64]time.Time, len(verifyRequests.doors))
+	for id, t := range verifyRequests.doors {
+		doors[id] = t
+	}
+	return verifyRequests.last, doors
+}
+
+// verifyRequestStats возвращает число запросов проверки допуска и ошибок с начала работы
+func verifyRequestStats() (total, errors int64) {
+	verifyRequests.Lock()
+	defer verifyRequests.Unlock()
+	return verifyRequests.total, verifyRequests.errors
+}
+
+// verifyParams параметры запроса проверки допуска
+type verifyParams struct {
+	identifier string
+	idType     string
+	direction  int
+	door       *int64
+	// dryRun проверка без следов: не отмечает использование карты и проход, не попадает в метрики
+	// и оповещения. Используется нагрузочным тестом, чтобы не менять рабочие данные.
+	dryRun bool
+}
+
+// recordRequest учитывает запрос в метриках, если это не пробная проверка
+func (p *verifyParams) recordRequest(failed bool) {
+	if !p.dryRun {
+		recordVerifyRequest(p.door, failed)
+	}
+}
+
+// verifyHandler отвечает контроллерам (турникеты, шлагбаум), разрешен ли проход по идентификатору.
+// Ответ всегда 200 с полем allowed, чтобы контроллеру не приходилось разбирать коды ошибок.
+func verifyHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := parseVerifyParams(w, r)
+	if !ok {
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		p.recordRequest(true)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := searchContext(r)
+	defer cancel()
+	result, err := checkAccess(ctx, pgDB, p)
+	p.recordRequest(err != nil)
+	if err != nil {
+		returnSearchError(w, ctx, err)
+		return
+	}
+	returnJSONSuccess(w, result, result.Reason)
+}
+
+// parseVerifyParams разбирает параметры identifier, type, direction, door и dry_run; при ошибке ответ уже отправлен
+func parseVerifyParams(w http.ResponseWriter, r *http.Request) (*verifyParams, bool) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	identifier := r.URL.Query().Get("identifier")
+	if identifier == "" {
+		returnJSONError(w, "Missing 'identifier' parameter", http.StatusBadRequest)
+		return nil, false
+	}
+	idType, err := normalizeIdentifierType(r.URL.Query().Get("type"))
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	switch idType {
+	case identifierPlate:
+		identifier = normalizePlate(identifier)
+	case identifierCard:
+		if identifier, err = validateCardInput(identifier); err != nil {
+			returnCardInputError(w, err)
+			return nil, false
+		}
+	}
+	direction := 0
+	switch r.URL.Query().Get("direction") {
+	case "":
+	case "in":
+		direction = directionIn
+	case "out":
+		direction = directionOut
+	default:
+		returnJSONError(w, "'direction' must be 'in' or 'out'", http.StatusBadRequest)
+		return nil, false
+	}
+	var door *int64
+	if s := r.URL.Query().Get("door"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			returnJSONError(w, "Invalid 'door' parameter", http.StatusBadRequest)
+			return nil, false
+		}
+		// Без групп доступа дверь проверить нельзя, а пропустить проверку небезопасно
+		if !targetDialect().extended {
+			returnJSONError(w, "Door access checks require TARGET_DB=postgres", http.StatusBadRequest)
+			return nil, false
+		}
+		door = &id
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	return &verifyParams{identifier: identifier, idType: idType, direction: direction, door: door, dryRun: dryRun}, true
+}
+
+// checkAccess принимает решение о допуске и отмечает проход, если передано направление
+func checkAccess(ctx context.Context, pgDB *sql.DB, p *verifyParams) (*VerifyResult, error) {
+	identifier, idType, direction, door := p.identifier, p.idType, p.direction, p.door
+	// Отметки использования, группы доступа и состояние проходов ведутся только в схеме PostgreSQL
+	extended := targetDialect().extended
+	record := extended && !p.dryRun
+
+	// Карта может быть передана десятичным номером или парой код объекта/номер
+	where, args := "identifier = $1", []interface{}{identifier}
+	if idType == identifierCard {
+		where, args = cardLookupCondition(identifier)
+	}
+	args = append(args, idType)
+	where += fmt.Sprintf(" AND identifier_type = $%d", len(args))
+
+	cards, err := queryStaffCardsContext(ctx, pgDB, where, args...)
+	if err != nil {
+		log.Printf("❌ Verify query failed: %v", err)
+		return nil, err
+	}
+	// Пара Wiegand-26 может совпасть у разных карт: пускать по случайной из них нельзя
+	ambiguous := false
+	if idType == identifierCard {
+		var ok bool
+		if cards, ok = resolveCardMatch(cards, identifier); !ok {
+			log.Printf("⚠️ Verify: %s matches %d different cards, access denied", identifier, len(cards))
+			ambiguous = true
+			cards = nil
+		}
+	}
+	if len(cards) > 0 {
+		identifier = cards[0].Identifier
+		if record {
+			if err := touchCardLastSeen(pgDB, identifier); err != nil {
+				log.Printf("⚠️ %v", err)
+			}
+		}
+	}
+
+	// Стоп-лист проверяется и для идентификаторов, которых уже нет в PERCo
+	var blocklisted bool
+	if err := preparedStatements.queryRow(ctx, pgDB, "SELECT EXISTS (SELECT 1 FROM blocklist WHERE identifier = $1)", identifier).Scan(&blocklisted); err != nil {
+		log.Printf("❌ Blocklist check failed: %v", err)
+		return nil, fmt.Errorf("Blocklist check error: %v", err)
+	}
+
+	// Для проверки конкретной двери используются группы доступа сотрудника
+	hasDoor := false
+	if door != nil && len(cards) > 0 {
+		if hasDoor, err = staffHasDoorAccess(pgDB, cards[0].IDStaff, *door); err != nil {
+			log.Printf("❌ Door access check failed: %v", err)
+			return nil, fmt.Errorf("Door access check error: %v", err)
+		}
+	}
+
+	// Повторный вход без выхода в пределах окна запрещен
+	antipassback := false
+	if direction == directionIn && config.AntipassbackWindow > 0 && extended && len(cards) > 0 {
+		if antipassback, err = antipassbackViolation(pgDB, cards[0].IDStaff); err != nil {
+			log.Printf("❌ Antipassback check failed: %v", err)
+			return nil, fmt.Errorf("Antipassback check error: %v", err)
+		}
+	}
+
+	result := &VerifyResult{Identifier: identifier, IdentifierType: idType, DoorID: door,
+		dataFreshness: currentDataFreshness(pgDB)}
+	if door != nil {
+		if result.DoorName, err = doorName(pgDB, *door); err != nil {
+			log.Printf("⚠️ Door name lookup failed: %v", err)
+		}
+	}
+	if len(cards) > 0 {
+		result.Card = &cards[0]
+	}
+	switch {
+	case blocklisted:
+		result.Reason = "blocklisted"
+	case ambiguous:
+		result.Reason = "ambiguous"
+	case len(cards) == 0:
+		result.Reason = "not_found"
+	case cards[0].ValidFrom != nil && cards[0].ValidFrom.After(time.Now()):
+		result.Reason = "not_yet_valid"
+	case cards[0].ValidUntil != nil && !cards[0].ValidUntil.After(time.Now()):
+		result.Reason = "expired"
+	case !cardAllowed(cards[0]):
+		result.Reason = "status_" + strValue(cards[0].Status)
+	case door != nil && !hasDoor:
+		result.Reason = "no_door_access"
+	case antipassback:
+		result.Reason = "antipassback"
+	default:
+		result.Allowed = true
+		result.Reason = "ok"
+	}
+
+	if (result.Reason == "blocklisted" || result.Reason == "antipassback") && !p.dryRun {
+		notifySecurityAlert(result.Reason+":"+identifier, securityAlertText(result))
+	}
+
+	if result.Allowed && direction != 0 && record {
+		if err := recordVerifiedPassage(pgDB, cards[0].IDStaff, direction); err != nil {
+			log.Printf("⚠️ %v", err)
+		}
+	}
+	return result, nil
+}
+
+// securityAlertText описание отказа по стоп-листу или antipassback для оповещения службы безопасности
+func securityAlertText(result *VerifyResult) string {
+	text := "Повторный вход без выхода: " + result.Identifier
+	if result.Reason == "blocklisted" {
+		text = "Попытка прохода по идентификатору из стоп-листа: " + result.Identifier
+	}
+	if result.Card != nil {
+		if name := staffFullName(*result.Card); name != "" {
+			text += " (" + name + ")"
+		}
+	}
+	if result.DoorName != "" {
+		text += ", дверь " + result.DoorName
+	}
+	return text
+}
+
+// cardAllowed повторяет проверку статуса из allowedCardCondition для уже загруженной записи
+func cardAllowed(sc StaffCard) bool {
+	status := strValue(sc.Status)
+	return status != "blocked" && status != "dismissed"
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Цвета экрана поста охраны
+const (
+	verifyColorGreen  = "green"  // проход разрешен
+	verifyColorYellow = "yellow" // разрешен по устаревшим данным, нужна проверка охранником
+	verifyColorRed    = "red"    // проход запрещен
+)
+
+// verifyFullResult компактный ответ для терминала охраны: только то, что выводится на экран
+type verifyFullResult struct {
+	Allowed    bool   `json:"allowed"`
+	Reason     string `json:"reason"`
+	Color      string `json:"color"`
+	Name       string `json:"name,omitempty"`
+	Department string `json:"department,omitempty"`
+	Position   string `json:"position,omitempty"`
+	PhotoURL   string `json:"photo_url,omitempty"`
+}
+
+// verifyFullHandler проверка допуска для экранов постов охраны. Ответ без обертки APIResponse,
+// с ETag и коротким кэшированием, а фото - по неизменяемой ссылке, которую терминал кэширует надолго.
+func verifyFullHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := parseVerifyParams(w, r)
+	if !ok {
+		return
+	}
+
+	pgDB, err := connectPostgres()
+	if err != nil {
+		log.Printf("❌ PostgreSQL connection failed: %v", err)
+		p.recordRequest(true)
+		returnJSONError(w, fmt.Sprintf("PostgreSQL connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := searchContext(r)
+	defer cancel()
+	result, err := checkAccess(ctx, pgDB, p)
+	p.recordRequest(err != nil)
+	if err != nil {
+		returnSearchError(w, ctx, err)
+		return
+	}
+
+	full := verifyFullResult{Allowed: result.Allowed, Reason: result.Reason, Color: verifyColorRed}
+	if result.Allowed {
+		full.Color = verifyColorGreen
+		if result.Stale {
+			full.Color = verifyColorYellow
+		}
+	}
+	if sc := result.Card; sc != nil {
+		full.Name = staffFullName(*sc)
+		full.Department = strValue(sc.Department)
+		full.Position = strValue(sc.Position)
+	}
+	// Фото сотрудников хранятся только в схеме PostgreSQL
+	if sc := result.Card; sc != nil && targetDialect().extended {
+		var hash string
+		err := pgDB.QueryRowContext(ctx, "SELECT hash FROM staff_photos WHERE id_staff = $1", sc.IDStaff).Scan(&hash)
+		switch {
+		case err == nil:
+			full.PhotoURL = fmt.Sprintf("%s/api/verify/photo/%d?v=%s", config.BasePath, sc.IDStaff, hash)
+		case err != sql.ErrNoRows:
+			log.Printf("⚠️ Photo lookup failed: %v", err)
+		}
+	}
+
+	body, err := json.Marshal(full)
+	if err != nil {
+		returnJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sum := sha1.Sum(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	// Запрос с направлением отмечает проход, поэтому его нельзя отдавать из кэша
+	if p.direction != 0 || config.VerifyFullCacheTTL <= 0 {
+		w.Header().Set("Cache-Control", "no-store")
+	} else {
+		w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(config.VerifyFullCacheTTL.Seconds())))
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// verifyPhotoHandler отдает фото (по умолчанию size=medium) по ссылке из /api/verify/full. Ссылка содержит хэш фото,
+// поэтому без нее фото не получить, а при смене фото меняется и ссылка - кэш можно не проверять.
+func verifyPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	idStaff, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/verify/photo/"), "/"), 10, 64)
+	if err != nil {
+		returnJSONError(w, "Invalid staff id", http.StatusBadRequest)
+		return
+	}
+	version := r.URL.Query().Get("v")
+	if version == "" {
+		returnJSONError(w, "Missing 'v' parameter", http.StatusBadRequest)
+		return
+	}
+	servePhoto(w, r, idStaff, version, "public, max-age=31536000, immutable")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+)
+
+// ReportView описание представления PostgreSQL для построения дашбордов
+type ReportView struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Columns     map[string]string `json:"columns"`
+	Example     string            `json:"example"`
+	definition  string
+}
+
+// reportViews представления, создаваемые при инициализации.
+// Колонка time названа так, как ее ожидает источник данных PostgreSQL в Grafana.
+var reportViews = []ReportView{
+	{
+		Name:        "cards_by_status",
+		Description: "Number of cards and employees per card status",
+		Columns: map[string]string{
+			"status": "card status, 'unknown' when empty",
+			"cards":  "number of cards",
+			"staff":  "number of distinct employees",
+		},
+		Example: "SELECT status AS metric, cards FROM cards_by_status",
+		definition: `
+			SELECT COALESCE(NULLIF(status, ''), 'unknown') AS status,
+				COUNT(*) AS cards,
+				COUNT(DISTINCT id_staff) AS staff
+			FROM staff_cards
+			GROUP BY 1`,
+	},
+	{
+		Name:        "syncs_per_day",
+		Description: "Synchronization runs per day with outcome and duration",
+		Columns: map[string]string{
+			"time":                 "day (time series column)",
+			"syncs":                "total sync runs",
+			"successful":           "successful runs",
+			"failed":               "failed runs",
+			"max_records":          "largest number of records loaded",
+			"avg_duration_seconds": "average run duration",
+		},
+		Example: "SELECT time, successful, failed FROM syncs_per_day WHERE $__timeFilter(time) ORDER BY time",
+		definition: `
+			SELECT date_trunc('day', started_at) AS time,
+				COUNT(*) AS syncs,
+				COUNT(*) FILTER (WHERE success) AS successful,
+				COUNT(*) FILTER (WHERE NOT success) AS failed,
+				MAX(records) AS max_records,
+				AVG(EXTRACT(EPOCH FROM finished_at - started_at))::float AS avg_duration_seconds
+			FROM sync_history
+			GROUP BY 1`,
+	},
+	{
+		Name:        "events_per_hour",
+		Description: "Passage events per hour split by direction",
+		Columns: map[string]string{
+			"time":    "hour (time series column)",
+			"entries": "entry events",
+			"exits":   "exit events",
+			"people":  "distinct employees seen",
+		},
+		Example: "SELECT time, entries, exits FROM events_per_hour WHERE $__timeFilter(time) ORDER BY time",
+		definition: `
+			SELECT date_trunc('hour', event_time) AS time,
+				COUNT(*) FILTER (WHERE direction = 1) AS entries,
+				COUNT(*) FILTER (WHERE direction = 2) AS exits,
+				COUNT(DISTINCT id_staff) AS people
+			FROM events
+			GROUP BY 1`,
+	},
+}
+
+// initReportViews создает или пересоздает представления для дашбордов.
+// Вызывается после initPostgresTable, чтобы представления ссылались на актуальную таблицу.
+func initReportViews(db *sql.DB) error {
+	for _, v := range reportViews {
+		if _, err := db.Exec(fmt.Sprintf("CREATE OR REPLACE VIEW %s AS %s", v.Name, v.definition)); err != nil {
+			return fmt.Errorf("error creating view %s: %v", v.Name, err)
+		}
+		if _, err := db.Exec(fmt.Sprintf("COMMENT ON VIEW %s IS %s", v.Name, quoteLiteral(v.Description))); err != nil {
+			return fmt.Errorf("error commenting view %s: %v", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// dropReportViews удаляет представления отчетов перед изменением типов колонок, на которые они ссылаются
+func dropReportViews(db *sql.DB) error {
+	for _, v := range reportViews {
+		if _, err := db.Exec("DROP VIEW IF EXISTS " + v.Name); err != nil {
+			return fmt.Errorf("error dropping view %s: %v", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// quoteLiteral экранирует строку для вставки в SQL как литерал
+func quoteLiteral(s string) string {
+	out := []rune{'\''}
+	for _, r := range s {
+		if r == '\'' {
+			out = append(out, '\'')
+		}
+		out = append(out, r)
+	}
+	return string(append(out, '\''))
+}
+
+// viewsHandler возвращает список представлений для построения дашбордов с именами в базе с учетом TABLE_PREFIX
+func viewsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		returnJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	views := make([]ReportView, len(reportViews))
+	for i, v := range reportViews {
+		v.Name = prefixedName(v.Name)
+		v.Example = prefixTables(v.Example)
+		views[i] = v
+	}
+	returnJSONSuccess(w, views, fmt.Sprintf("%d views available", len(views)))
+}
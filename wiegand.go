@@ -0,0 +1,42 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// wiegandPattern номер карты в виде "код объекта,номер" (также через / или :)
+var wiegandPattern = regexp.MustCompile(`^\s*(\d{1,3})\s*[,/:]\s*(\d{1,5})\s*$`)
+
+// cardLookupCondition строит условие поиска карты в любом представлении: десятичном
+// номере, как его хранит PERCo, или паре код объекта/номер Wiegand-26
+func cardLookupCondition(value string) (string, []interface{}) {
+	if m := wiegandPattern.FindStringSubmatch(value); m != nil {
+		facility, _ := strconv.Atoi(m[1])
+		number, _ := strconv.Atoi(m[2])
+		if facility <= 255 && number <= 65535 {
+			return "wiegand_facility = $1 AND wiegand_number = $2", []interface{}{facility, number}
+		}
+	}
+	return "identifier = $1", []interface{}{value}
+}
+
+// resolveCardMatch выбирает карты, найденные по номеру value: точное совпадение идентификатора
+// важнее пары Wiegand. Если паре соответствуют разные карты, выбрать нельзя и возвращается false.
+func resolveCardMatch(cards []StaffCard, value string) ([]StaffCard, bool) {
+	var exact []StaffCard
+	for _, sc := range cards {
+		if sc.Identifier == value {
+			exact = append(exact, sc)
+		}
+	}
+	if len(exact) > 0 {
+		return exact, true
+	}
+	for _, sc := range cards {
+		if sc.Identifier != cards[0].Identifier {
+			return cards, false
+		}
+	}
+	return cards, true
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestCardLookupCondition(t *testing.T) {
+	tests := []struct {
+		value string
+		where string
+		args  []interface{}
+	}{
+		{"12,345", "wiegand_facility = $1 AND wiegand_number = $2", []interface{}{12, 345}},
+		{" 255 / 65535 ", "wiegand_facility = $1 AND wiegand_number = $2", []interface{}{255, 65535}},
+		{"256:1", "identifier = $1", []interface{}{"256:1"}},
+		{"1:65536", "identifier = $1", []interface{}{"1:65536"}},
+		{"0012345", "identifier = $1", []interface{}{"0012345"}},
+	}
+	for _, tt := range tests {
+		where, args := cardLookupCondition(tt.value)
+		if where != tt.where || len(args) != len(tt.args) || args[0] != tt.args[0] {
+			t.Errorf("cardLookupCondition(%q) = %q, %v; want %q, %v", tt.value, where, args, tt.where, tt.args)
+		}
+	}
+}
+
+func TestResolveCardMatch(t *testing.T) {
+	card := func(idStaff int64, identifier string) StaffCard {
+		return StaffCard{IDStaff: idStaff, Identifier: identifier}
+	}
+	tests := []struct {
+		name  string
+		cards []StaffCard
+		value string
+		want  []int64
+		ok    bool
+	}{
+		{"none", nil, "12,345", nil, true},
+		{"single", []StaffCard{card(1, "809401")}, "12,345", []int64{1}, true},
+		// Один идентификатор у нескольких сотрудников - это конфликт карт, а не неоднозначная пара
+		{"same identifier", []StaffCard{card(1, "809401"), card(2, "809401")}, "12,345", []int64{1, 2}, true},
+		{"different cards", []StaffCard{card(1, "809401"), card(2, "4104393")}, "12,345", []int64{1, 2}, false},
+		{"exact match wins", []StaffCard{card(1, "809401"), card(2, "12,345")}, "12,345", []int64{2}, true},
+	}
+	for _, tt := range tests {
+		got, ok := resolveCardMatch(tt.cards, tt.value)
+		ids := make([]int64, 0, len(got))
+		for _, sc := range got {
+			ids = append(ids, sc.IDStaff)
+		}
+		if ok != tt.ok || len(ids) != len(tt.want) {
+			t.Errorf("%s: resolveCardMatch = %v, %v; want %v, %v", tt.name, ids, ok, tt.want, tt.ok)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.want[i] {
+				t.Errorf("%s: resolveCardMatch = %v; want %v", tt.name, ids, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// WorkRules правила расчета отработанного времени для расчетчиков
+type WorkRules struct {
+	DayStart      time.Duration // начало рабочего дня от полуночи
+	DayEnd        time.Duration // конец рабочего дня от полуночи
+	Grace         time.Duration // допустимое опоздание и ранний уход
+	LunchDuration time.Duration // вычет на обед
+	LunchAfter    time.Duration // обед вычитается, если отработано не меньше
+	DayBoundary   time.Duration // граница рабочих суток для ночных смен
+	NightStart    time.Duration // начало ночного времени
+	NightEnd      time.Duration // конец ночного времени
+}
+
+// WorkedDay итог сотрудника за рабочие сутки после применения правил
+type WorkedDay struct {
+	Date        string    `json:"date"`
+	FirstIn     time.Time `json:"first_in"`
+	LastOut     time.Time `json:"last_out"`
+	RawHours    float64   `json:"raw_hours"`
+	WorkedHours float64   `json:"worked_hours"`
+	NightHours  float64   `json:"night_hours"`
+	Late        bool      `json:"late"`
+	LeftEarly   bool      `json:"left_early"`
+	DayOff      bool      `json:"day_off"`
+}
+
+// parseClock разбирает время суток "15:04" в смещение от полуночи
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, use HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// loadWorkRules собирает правила из конфигурации
+func loadWorkRules() (*WorkRules, error) {
+	rules := &WorkRules{
+		Grace:         config.WorkGrace,
+		LunchDuration: config.WorkLunchDuration,
+		LunchAfter:    config.WorkLunchAfter,
+	}
+	clocks := []struct {
+		value string
+		dst   *time.Duration
+	}{
+		{config.WorkDayStart, &rules.DayStart},
+		{config.WorkDayEnd, &rules.DayEnd},
+		{config.WorkDayBoundary, &rules.DayBoundary},
+		{config.WorkNightStart, &rules.NightStart},
+		{config.WorkNightEnd, &rules.NightEnd},
+	}
+	for _, c := range clocks {
+		d, err := parseClock(c.value)
+		if err != nil {
+			return nil, err
+		}
+		*c.dst = d
+	}
+	return rules, nil
+}
+
+// workDate возвращает начало рабочих суток, к которым относится момент t
+func (r *WorkRules) workDate(t time.Time) time.Time {
+	shifted := t.Add(-r.DayBoundary)
+	return time.Date(shifted.Year(), shifted.Month(), shifted.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// nightHours считает, сколько часов интервала приходится на ночное время
+func (r *WorkRules) nightHours(in, out time.Time) float64 {
+	var total time.Duration
+	// Ночное окно начинается накануне дня входа, чтобы учесть смены после полуночи
+	day := time.Date(in.Year(), in.Month(), in.Day(), 0, 0, 0, 0, in.Location()).AddDate(0, 0, -1)
+	for !day.After(out) {
+		start := day.Add(r.NightStart)
+		end := day.Add(r.NightEnd)
+		if r.NightEnd <= r.NightStart {
+			end = end.AddDate(0, 0, 1)
+		}
+		if s, e := laterOf(start, in), earlierOf(end, out); e.After(s) {
+			total += e.Sub(s)
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return total.Hours()
+}
+
+// applyWorkRules группирует интервалы по рабочим суткам и считает отработанные часы
+func (r *WorkRules) applyWorkRules(intervals []PresenceInterval) []WorkedDay {
+	var days []WorkedDay
+	var dayDate time.Time
+	for _, in := range intervals {
+		date := r.workDate(in.In)
+		if len(days) == 0 || !date.Equal(dayDate) {
+			days = append(days, WorkedDay{Date: date.Format("2006-01-02"), FirstIn: in.In})
+			dayDate = date
+		}
+		d := &days[len(days)-1]
+		d.LastOut = in.Out
+		d.RawHours += in.Hours
+		d.NightHours += r.nightHours(in.In, in.Out)
+	}
+
+	for i := range days {
+		d := &days[i]
+		date := r.workDate(d.FirstIn)
+		worked := time.Duration(d.RawHours * float64(time.Hour))
+
+		// Опоздание и ранний уход в пределах допуска засчитываются как полный день
+		start, end := date.Add(r.DayStart), date.Add(r.DayEnd)
+		if r.DayEnd <= r.DayStart {
+			end = end.AddDate(0, 0, 1)
+		}
+		if late := d.FirstIn.Sub(start); late > 0 {
+			if late <= r.Grace {
+				worked += late
+			} else {
+				d.Late = true
+			}
+		}
+		if early := end.Sub(d.LastOut); early > 0 {
+			if early <= r.Grace {
+				worked += early
+			} else {
+				d.LeftEarly = true
+			}
+		}
+
+		if r.LunchDuration > 0 && worked >= r.LunchAfter {
+			worked -= r.LunchDuration
+		}
+		d.WorkedHours = worked.Hours()
+	}
+	return days
+}
+
+// laterOf возвращает более поздний из двух моментов
+func laterOf(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// earlierOf возвращает более ранний из двух моментов
+func earlierOf(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
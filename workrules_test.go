@@ -0,0 +1,110 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// nightShiftRules ночная смена 20:00-08:00, рабочие сутки с полудня, ночное время 22:00-06:00
+var nightShiftRules = &WorkRules{
+	DayStart:      20 * time.Hour,
+	DayEnd:        8 * time.Hour,
+	Grace:         10 * time.Minute,
+	LunchDuration: 30 * time.Minute,
+	LunchAfter:    6 * time.Hour,
+	DayBoundary:   12 * time.Hour,
+	NightStart:    22 * time.Hour,
+	NightEnd:      6 * time.Hour,
+}
+
+// marchTime момент 2024-03-<day> hh:mm UTC
+func marchTime(day, hour, minute int) time.Time {
+	return time.Date(2024, 3, day, hour, minute, 0, 0, time.UTC)
+}
+
+// presence интервал присутствия с часами, как их считает отчет
+func presence(in, out time.Time) PresenceInterval {
+	return PresenceInterval{In: in, Out: out, Hours: out.Sub(in).Hours()}
+}
+
+func hoursEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestNightHours(t *testing.T) {
+	tests := []struct {
+		name    string
+		in, out time.Time
+		want    float64
+	}{
+		{"whole night", marchTime(1, 21, 0), marchTime(2, 7, 0), 8},
+		{"before midnight", marchTime(1, 23, 0), marchTime(2, 2, 0), 3},
+		{"after midnight", marchTime(2, 1, 0), marchTime(2, 5, 0), 4},
+		{"morning edge", marchTime(2, 5, 30), marchTime(2, 9, 0), 0.5},
+		{"day shift", marchTime(1, 9, 0), marchTime(1, 18, 0), 0},
+		{"two nights", marchTime(1, 20, 0), marchTime(3, 8, 0), 16},
+	}
+	for _, tt := range tests {
+		if got := nightShiftRules.nightHours(tt.in, tt.out); !hoursEqual(got, tt.want) {
+			t.Errorf("%s: nightHours = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyWorkRulesNightShift(t *testing.T) {
+	tests := []struct {
+		name            string
+		intervals       []PresenceInterval
+		worked, night   float64
+		late, leftEarly bool
+	}{
+		// 11:55 с опозданием в допуске засчитываются как 12 часов, минус обед
+		{"across midnight", []PresenceInterval{presence(marchTime(1, 20, 5), marchTime(2, 8, 0))}, 11.5, 8, false, false},
+		{"split at midnight", []PresenceInterval{presence(marchTime(1, 20, 0), marchTime(2, 0, 30)), presence(marchTime(2, 1, 0), marchTime(2, 8, 0))}, 11, 7.5, false, false},
+		{"grace at both edges", []PresenceInterval{presence(marchTime(1, 20, 10), marchTime(2, 7, 50))}, 11.5, 8, false, false},
+		{"beyond grace", []PresenceInterval{presence(marchTime(1, 20, 11), marchTime(2, 7, 49))}, 11 + 38.0/60 - 0.5, 8, true, true},
+	}
+	for _, tt := range tests {
+		days := nightShiftRules.applyWorkRules(tt.intervals)
+		if len(days) != 1 {
+			t.Errorf("%s: got %d work days; want 1", tt.name, len(days))
+			continue
+		}
+		d := days[0]
+		if d.Date != "2024-03-01" {
+			t.Errorf("%s: date = %s; want 2024-03-01", tt.name, d.Date)
+		}
+		if !hoursEqual(d.WorkedHours, tt.worked) || !hoursEqual(d.NightHours, tt.night) {
+			t.Errorf("%s: worked %v, night %v; want %v, %v", tt.name, d.WorkedHours, d.NightHours, tt.worked, tt.night)
+		}
+		if d.Late != tt.late || d.LeftEarly != tt.leftEarly {
+			t.Errorf("%s: late %t, left early %t; want %t, %t", tt.name, d.Late, d.LeftEarly, tt.late, tt.leftEarly)
+		}
+	}
+}
+
+func TestApplyWorkRulesLunchThreshold(t *testing.T) {
+	rules := &WorkRules{
+		DayStart:      9 * time.Hour,
+		DayEnd:        18 * time.Hour,
+		LunchDuration: 30 * time.Minute,
+		LunchAfter:    6 * time.Hour,
+		DayBoundary:   4 * time.Hour,
+		NightStart:    22 * time.Hour,
+		NightEnd:      6 * time.Hour,
+	}
+	tests := []struct {
+		out    time.Time
+		worked float64
+	}{
+		{marchTime(1, 15, 0), 5.5},
+		{marchTime(1, 14, 59), 5 + 59.0/60},
+	}
+	for _, tt := range tests {
+		days := rules.applyWorkRules([]PresenceInterval{presence(marchTime(1, 9, 0), tt.out)})
+		if len(days) != 1 || !hoursEqual(days[0].WorkedHours, tt.worked) {
+			t.Errorf("09:00-%s: worked %+v; want %v", tt.out.Format("15:04"), days, tt.worked)
+		}
+	}
+}